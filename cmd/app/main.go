@@ -1,15 +1,63 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/famkampm/nentrytask/internal/account"
+	_accountHttpDeliver "github.com/famkampm/nentrytask/internal/account/delivery/http"
+	_accountUsecase "github.com/famkampm/nentrytask/internal/account/usecase"
+	_apiKeyHttpDeliver "github.com/famkampm/nentrytask/internal/apikey/delivery/http"
+	_apiKeyRepo "github.com/famkampm/nentrytask/internal/apikey/repository"
+	_apiKeyUsecase "github.com/famkampm/nentrytask/internal/apikey/usecase"
+	"github.com/famkampm/nentrytask/internal/audit"
+	_auditHttpDeliver "github.com/famkampm/nentrytask/internal/audit/delivery/http"
+	_auditRepo "github.com/famkampm/nentrytask/internal/audit/repository"
+	_auditUsecase "github.com/famkampm/nentrytask/internal/audit/usecase"
+	"github.com/famkampm/nentrytask/internal/federation"
+	_federationClient "github.com/famkampm/nentrytask/internal/federation/client"
+	_federationHttpDeliver "github.com/famkampm/nentrytask/internal/federation/delivery/http"
+	_federationRepo "github.com/famkampm/nentrytask/internal/federation/repository"
+	_federationUsecase "github.com/famkampm/nentrytask/internal/federation/usecase"
+	_mfaHttpDeliver "github.com/famkampm/nentrytask/internal/mfa/delivery/http"
+	_mfaRepo "github.com/famkampm/nentrytask/internal/mfa/repository"
+	_mfaUsecase "github.com/famkampm/nentrytask/internal/mfa/usecase"
+	"github.com/famkampm/nentrytask/internal/oidc"
+	_oidcHttpDeliver "github.com/famkampm/nentrytask/internal/oidc/delivery/http"
+	_oidcRepo "github.com/famkampm/nentrytask/internal/oidc/repository"
+	_oidcUsecase "github.com/famkampm/nentrytask/internal/oidc/usecase"
+	_profileImageRepo "github.com/famkampm/nentrytask/internal/profileimage/repository"
+	_profileImageUsecase "github.com/famkampm/nentrytask/internal/profileimage/usecase"
+	_recoveryHttpDeliver "github.com/famkampm/nentrytask/internal/recovery/delivery/http"
+	_recoveryRepo "github.com/famkampm/nentrytask/internal/recovery/repository"
+	_recoveryUsecase "github.com/famkampm/nentrytask/internal/recovery/usecase"
+	_sessionHttpDeliver "github.com/famkampm/nentrytask/internal/session/delivery/http"
+	_sessionRepo "github.com/famkampm/nentrytask/internal/session/repository"
+	_sessionUsecase "github.com/famkampm/nentrytask/internal/session/usecase"
+	"github.com/famkampm/nentrytask/internal/throttle"
+	_throttleHttpDeliver "github.com/famkampm/nentrytask/internal/throttle/delivery/http"
+	_throttleRepo "github.com/famkampm/nentrytask/internal/throttle/repository"
+	_throttleUsecase "github.com/famkampm/nentrytask/internal/throttle/usecase"
 	_userHttpDeliver "github.com/famkampm/nentrytask/internal/user/delivery/http"
 	"github.com/famkampm/nentrytask/internal/user/repository"
 	"github.com/famkampm/nentrytask/internal/user/usecase"
+	"github.com/famkampm/nentrytask/internal/verification"
+	_verificationHttpDeliver "github.com/famkampm/nentrytask/internal/verification/delivery/http"
+	_verificationRepo "github.com/famkampm/nentrytask/internal/verification/repository"
+	_verificationUsecase "github.com/famkampm/nentrytask/internal/verification/usecase"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/famkampm/nentrytask/pkg/blobstore"
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/famkampm/nentrytask/pkg/imaging"
+	"github.com/famkampm/nentrytask/pkg/mailer"
+	"github.com/famkampm/nentrytask/pkg/middlewares"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gomodule/redigo/redis"
@@ -39,15 +87,104 @@ func main() {
 	// 	log.Println("closing redis conection")
 	// 	conn.Close()
 	// }()
+	credentialPolicy, err := helper.CredentialPolicyFromEnv()
+	if err != nil {
+		log.Fatal("init credential policy err:", err)
+	}
+	helper.SetCredentialPolicy(credentialPolicy)
+	hashPolicy, err := helper.HashPolicyFromEnv()
+	if err != nil {
+		log.Fatal("init password hash policy err:", err)
+	}
+	helper.SetHashPolicy(hashPolicy)
+	trustedProxies, err := helper.TrustedProxiesFromEnv()
+	if err != nil {
+		log.Fatal("init trusted proxies err:", err)
+	}
+	helper.SetTrustedProxies(trustedProxies)
+	imagePolicy, err := imaging.PolicyFromEnv()
+	if err != nil {
+		log.Fatal("init profile image policy err:", err)
+	}
+	imaging.SetPolicy(imagePolicy)
 	map_memory := make(map[int64]string)
 	redisPool := initRedisPool()
 	userRepoMysql := repository.NewMysqlUserRepository(db)
 	userRepoRedis := repository.NewRedisUserRepository(redisPool)
 	userRepoMemory := repository.NewMemoryUserRepository(map_memory)
 	userUsecase := usecase.NewUserUsecase(userRepoMysql, userRepoRedis, userRepoMemory)
+	revoker := auth.NewRedisRevoker(redisPool)
+	stop := make(chan struct{})
+	defer close(stop)
+	initKeyManager(stop)
 	router := httprouter.New()
 
-	_userHttpDeliver.NewUserHandler(router, userUsecase)
+	auditRepo := _auditRepo.NewMysqlAuditRepository(db)
+	auditUsecase := _auditUsecase.NewAuditUsecase(auditRepo, helper.DurationFromEnv("AUDIT_RETENTION", 90*24*time.Hour))
+	startAuditPruning(auditUsecase, helper.DurationFromEnv("AUDIT_PRUNE_INTERVAL", time.Hour), stop)
+	apiKeyRepo := _apiKeyRepo.NewMysqlAPIKeyRepository(db)
+	apiKeyUsecase := _apiKeyUsecase.NewAPIKeyUsecase(apiKeyRepo)
+	sessionRepo := _sessionRepo.NewMysqlSessionRepository(db)
+	sessionActivityRepo := _sessionRepo.NewRedisSessionRepository(redisPool)
+	sessionUsecase := _sessionUsecase.NewSessionUsecase(sessionRepo, sessionActivityRepo, revoker)
+	mw := middlewares.InitMiddleware(revoker, _auditHttpDeliver.NewOverrideRecorder(auditUsecase), apiKeyUsecase, sessionUsecase, _userHttpDeliver.NewStatusChecker(userUsecase))
+
+	throttleRepo := _throttleRepo.NewRedisThrottleRepository(redisPool)
+	throttleUsecase := _throttleUsecase.NewThrottleUsecase(throttleRepo, loginPolicyFromEnv("LOGIN_MAX_ATTEMPTS_USER", 5), loginPolicyFromEnv("LOGIN_MAX_ATTEMPTS_IP", 50))
+	mail, err := mailer.NewMailerFromEnv()
+	if err != nil {
+		log.Fatal("init mailer err:", err)
+	}
+	verificationPolicy, err := verificationPolicyFromEnv()
+	if err != nil {
+		log.Fatal("init email verification policy err:", err)
+	}
+	verificationRepo := _verificationRepo.NewRedisVerificationRepository(redisPool)
+	verificationUsecase := _verificationUsecase.NewVerificationUsecase(userUsecase, verificationRepo, mail, verificationPolicy, helper.DurationFromEnv("EMAIL_VERIFICATION_TTL", 24*time.Hour), helper.DurationFromEnv("EMAIL_VERIFICATION_RESEND_COOLDOWN", time.Minute), os.Getenv("EMAIL_VERIFICATION_URL"))
+	mfaRepo := _mfaRepo.NewMysqlMFARepository(db)
+	mfaUsecase := _mfaUsecase.NewMFAUsecase(mfaRepo, userUsecase, os.Getenv("MFA_ISSUER"))
+	imageStore, err := blobstore.NewBlobStoreFromEnv()
+	if err != nil {
+		log.Fatal("init image store err:", err)
+	}
+	profileImageRepo := _profileImageRepo.NewMysqlProfileImageRepository(db)
+	profileImageUsecase := _profileImageUsecase.NewProfileImageUsecase(profileImageRepo, imageStore)
+	_userHttpDeliver.NewUserHandler(router, userUsecase, revoker, throttleUsecase, mfaUsecase, sessionUsecase, auditUsecase, verificationUsecase, profileImageUsecase, mw)
+	_userHttpDeliver.NewImageHandler(router, imageStore, helper.DurationFromEnv("IMAGE_CACHE_MAX_AGE", 365*24*time.Hour), helper.DurationFromEnv("IMAGE_REDIRECT_TTL", 0))
+	_throttleHttpDeliver.NewThrottleHandler(router, throttleUsecase, mw)
+	_mfaHttpDeliver.NewMFAHandler(router, mfaUsecase, userUsecase, throttleUsecase, mw)
+	_apiKeyHttpDeliver.NewAPIKeyHandler(router, apiKeyUsecase, mw)
+	_sessionHttpDeliver.NewSessionHandler(router, sessionUsecase, mw)
+	_auditHttpDeliver.NewAuditHandler(router, auditUsecase, mw)
+	_verificationHttpDeliver.NewVerificationHandler(router, verificationUsecase)
+	identityProviders, err := federation.LoadProviders(os.Getenv("FEDERATION_PROVIDERS_FILE"))
+	if err != nil {
+		log.Fatal("init identity providers err:", err)
+	}
+	identityRepo := _federationRepo.NewMysqlIdentityRepository(db)
+	federatedStateRepo := _federationRepo.NewRedisStateRepository(redisPool)
+	federationUsecase := _federationUsecase.NewFederationUsecase(userUsecase, identityRepo, federatedStateRepo, _federationClient.NewOIDCClient(nil), identityProviders, helper.DurationFromEnv("FEDERATED_LOGIN_STATE_TTL", 10*time.Minute))
+	_federationHttpDeliver.NewFederationHandler(router, federationUsecase, mfaUsecase, sessionUsecase, auditUsecase, mw)
+	accountUsecase := _accountUsecase.NewAccountUsecase(userUsecase, sessionUsecase, apiKeyUsecase, mfaUsecase, auditUsecase, federationUsecase, imageStore, profileImageUsecase, revoker, helper.DurationFromEnv("ACCOUNT_DELETION_GRACE", 30*24*time.Hour))
+	startAccountPurging(accountUsecase, helper.DurationFromEnv("ACCOUNT_PURGE_INTERVAL", time.Hour), stop)
+	startImageSweeping(accountUsecase, helper.DurationFromEnv("IMAGE_SWEEP_INTERVAL", 6*time.Hour), stop)
+	_accountHttpDeliver.NewAccountHandler(router, accountUsecase, auditUsecase, mw)
+
+	resetTokenRepo := _recoveryRepo.NewRedisResetTokenRepository(redisPool)
+	recoveryUsecase := _recoveryUsecase.NewRecoveryUsecase(userUsecase, resetTokenRepo, mail, revoker, sessionUsecase, helper.DurationFromEnv("PASSWORD_RESET_TTL", 30*time.Minute), os.Getenv("PASSWORD_RESET_URL"))
+	forgotThrottleUsecase := _throttleUsecase.NewThrottleUsecase(throttleRepo, forgotPolicyFromEnv("PASSWORD_FORGOT_MAX_REQUESTS_USER", 3), forgotPolicyFromEnv("PASSWORD_FORGOT_MAX_REQUESTS_IP", 20))
+	_recoveryHttpDeliver.NewRecoveryHandler(router, recoveryUsecase, auditUsecase, forgotThrottleUsecase)
+
+	oidcClients, err := oidc.LoadClients(os.Getenv("OIDC_CLIENTS_FILE"))
+	if err != nil {
+		log.Fatal("init oidc clients err:", err)
+	}
+	if len(oidcClients) > 0 && auth.SigningAlgorithm() == "" {
+		log.Fatal("init oidc provider err:", auth.ErrNoSigningKeys)
+	}
+	oidcRepo := _oidcRepo.NewRedisOIDCRepository(redisPool)
+	oidcUsecase := _oidcUsecase.NewOIDCUsecase(userUsecase, oidcRepo, oidcClients, os.Getenv("OIDC_ISSUER"), helper.DurationFromEnv("OIDC_CODE_TTL", time.Minute))
+	_oidcHttpDeliver.NewOIDCHandler(router, oidcUsecase, mw)
 
 	// run server
 	log.Fatal(http.ListenAndServe(":8080", router))
@@ -102,7 +239,8 @@ func main() {
 
 func initDB() *sql.DB {
 	drivername := os.Getenv("DB_DRIVER")
-	pathname := os.Getenv("DB_USER") + ":" + os.Getenv("DB_PASSWORD") + "@tcp(" + os.Getenv("DB_HOST") + ":" + os.Getenv("DB_PORT") + ")/" + os.Getenv("DB_NAME")
+	// parseTime lets datetime columns scan into time.Time
+	pathname := os.Getenv("DB_USER") + ":" + os.Getenv("DB_PASSWORD") + "@tcp(" + os.Getenv("DB_HOST") + ":" + os.Getenv("DB_PORT") + ")/" + os.Getenv("DB_NAME") + "?parseTime=true"
 
 	db, err := sql.Open(drivername, pathname)
 	if err != nil {
@@ -134,6 +272,147 @@ func closeDB(db *sql.DB) {
 	}
 }
 
+func intFromEnv(key string, fallback int) int {
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return n
+}
+
+// loginPolicyFromEnv shares the window and lockout settings between the
+// username and the ip policy, only the attempt limit differs
+func loginPolicyFromEnv(maxAttemptsKey string, maxAttempts int) throttle.Policy {
+	return throttle.Policy{
+		MaxAttempts: intFromEnv(maxAttemptsKey, maxAttempts),
+		Window:      helper.DurationFromEnv("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		BaseLockout: helper.DurationFromEnv("LOGIN_BASE_LOCKOUT", time.Minute),
+		MaxLockout:  helper.DurationFromEnv("LOGIN_MAX_LOCKOUT", time.Hour),
+	}
+}
+
+// forgotPolicyFromEnv is like loginPolicyFromEnv for /password/forgot
+func forgotPolicyFromEnv(maxRequestsKey string, maxRequests int) throttle.Policy {
+	return throttle.Policy{
+		MaxAttempts: intFromEnv(maxRequestsKey, maxRequests),
+		Window:      helper.DurationFromEnv("PASSWORD_FORGOT_WINDOW", time.Hour),
+		BaseLockout: helper.DurationFromEnv("PASSWORD_FORGOT_LOCKOUT", 15*time.Minute),
+		MaxLockout:  helper.DurationFromEnv("PASSWORD_FORGOT_MAX_LOCKOUT", 24*time.Hour),
+	}
+}
+
+// verificationPolicyFromEnv reads EMAIL_VERIFICATION (off, optional or
+// required) and the comma separated UNVERIFIED_RESTRICTIONS
+func verificationPolicyFromEnv() (verification.Policy, error) {
+	policy := verification.Policy{
+		Mode:         os.Getenv("EMAIL_VERIFICATION"),
+		Restrictions: map[string]bool{},
+	}
+	if policy.Mode == "" {
+		policy.Mode = verification.ModeOptional
+	}
+	if !verification.ValidMode(policy.Mode) {
+		return policy, fmt.Errorf("unknown EMAIL_VERIFICATION %q", policy.Mode)
+	}
+	restrictions := os.Getenv("UNVERIFIED_RESTRICTIONS")
+	if restrictions == "" {
+		restrictions = verification.RestrictImageUpload
+	}
+	for _, action := range strings.Split(restrictions, ",") {
+		action = strings.TrimSpace(action)
+		if action == "" || action == "none" {
+			continue
+		}
+		if !verification.ValidRestriction(action) {
+			return policy, fmt.Errorf("unknown UNVERIFIED_RESTRICTIONS action %q", action)
+		}
+		policy.Restrictions[action] = true
+	}
+	return policy, nil
+}
+
+// startAuditPruning deletes audit entries past their retention every interval
+// until stop is closed
+func startAuditPruning(us audit.Usecase, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				deleted, err := us.Prune(context.Background())
+				if err != nil {
+					log.Println("prune audit log err:", err.Error())
+				}
+				if deleted > 0 {
+					log.Println("pruned audit entries:", deleted)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// startAccountPurging hard deletes the accounts whose deletion grace period is
+// over every interval until stop is closed
+func startAccountPurging(us account.Usecase, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				purged, err := us.Purge(context.Background())
+				if err != nil {
+					log.Println("purge deleted accounts err:", err.Error())
+				}
+				if purged > 0 {
+					log.Println("purged deleted accounts:", purged)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// startImageSweeping deletes the stored images no user references every
+// interval until stop is closed
+func startImageSweeping(us account.Usecase, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				swept, err := us.SweepImages(context.Background())
+				if err != nil {
+					log.Println("sweep images err:", err.Error())
+				}
+				if swept > 0 {
+					log.Println("swept unreferenced images:", swept)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func initKeyManager(stop <-chan struct{}) {
+	km, err := auth.NewKeyManagerFromEnv()
+	if err != nil {
+		log.Fatal("init signing keys err:", err)
+	}
+	if km == nil {
+		log.Println("signing tokens with API_SECRET")
+		return
+	}
+	auth.SetKeyManager(km)
+	km.StartRotation(stop)
+}
+
 func initRedis() redis.Conn {
 	conn, err := redis.Dial("tcp", "127.0.0.1:6379")
 	if err != nil {
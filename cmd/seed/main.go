@@ -49,6 +49,41 @@ func main() {
 		log.Println("gagal create user db. err:", err.Error())
 		panic(err.Error())
 	}
+	err = MigrateUserTable(db)
+	if err != nil {
+		log.Println("gagal migrate user db. err:", err.Error())
+		panic(err.Error())
+	}
+	err = CreateMFATables(db)
+	if err != nil {
+		log.Println("gagal create mfa db. err:", err.Error())
+		panic(err.Error())
+	}
+	err = CreateAuditTable(db)
+	if err != nil {
+		log.Println("gagal create audit db. err:", err.Error())
+		panic(err.Error())
+	}
+	err = CreateAPIKeyTable(db)
+	if err != nil {
+		log.Println("gagal create api key db. err:", err.Error())
+		panic(err.Error())
+	}
+	err = CreateSessionTable(db)
+	if err != nil {
+		log.Println("gagal create session db. err:", err.Error())
+		panic(err.Error())
+	}
+	err = CreateIdentityTable(db)
+	if err != nil {
+		log.Println("gagal create identity db. err:", err.Error())
+		panic(err.Error())
+	}
+	err = CreateProfileImageRefTable(db)
+	if err != nil {
+		log.Println("gagal create profile image ref db. err:", err.Error())
+		panic(err.Error())
+	}
 
 	log.Println("DB aman")
 	hashedPassword, err := helper.Hash("pass")
@@ -62,8 +97,8 @@ func main() {
 	iterateOver := totalRow / pembagi
 	rows := MakeRows(totalRow, &pass)
 	for i := 1; i <= iterateOver; i++ {
-		startIndex := i
-		endIndex := i + pembagi
+		startIndex := (i - 1) * pembagi
+		endIndex := i * pembagi
 		err = BulkInsert(rows[startIndex:endIndex], db)
 		if err != nil {
 			log.Println("ERORR GAN: ", err.Error())
@@ -116,7 +151,7 @@ func ChooseDB(db *sql.DB) error {
 }
 
 func CreateUserTable(db *sql.DB) error {
-	stmt, err := db.Prepare("CREATE TABLE IF NOT EXISTS user (id int not null auto_increment, username varchar(40) CHARACTER SET utf8mb4 not null, password varchar(240) not null, nickname varchar(240), profile_image varchar(240), PRIMARY KEY (id), index(username) )")
+	stmt, err := db.Prepare("CREATE TABLE IF NOT EXISTS user (id int not null auto_increment, username varchar(40) CHARACTER SET utf8mb4 not null, password varchar(240) not null, nickname varchar(240), profile_image varchar(240), email varchar(240), role varchar(20) not null default 'user', status varchar(32) not null default 'active', suspended_until datetime null, deleted_at datetime null, PRIMARY KEY (id), unique index username (username) )")
 	if err != nil {
 		log.Println("createuser table. prepare error:", err.Error())
 		return err
@@ -129,6 +164,140 @@ func CreateUserTable(db *sql.DB) error {
 	return nil
 }
 
+// CreateMFATables creates the TOTP secrets and the hashed recovery codes
+func CreateMFATables(db *sql.DB) error {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS user_mfa (user_id int not null, secret varchar(64) not null, enabled tinyint(1) not null default 0, last_used_step bigint not null default 0, PRIMARY KEY (user_id) )")
+	if err != nil {
+		log.Println("create user_mfa table. exec error:", err.Error())
+		return err
+	}
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS user_mfa_recovery_code (id int not null auto_increment, user_id int not null, code_hash char(64) not null, PRIMARY KEY (id), unique index user_code (user_id, code_hash) )")
+	if err != nil {
+		log.Println("create user_mfa_recovery_code table. exec error:", err.Error())
+		return err
+	}
+	return nil
+}
+
+// CreateAuditTable creates the append only audit log
+func CreateAuditTable(db *sql.DB) error {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS audit_log (id bigint not null auto_increment, actor_id int not null, target_user_id int not null, action varchar(64) not null, ip varchar(64) not null, user_agent varchar(255) not null, result varchar(16) not null, detail varchar(512) not null, created_at datetime not null, PRIMARY KEY (id), index target_created (target_user_id, created_at), index created (created_at) )")
+	if err != nil {
+		log.Println("create audit_log table. exec error:", err.Error())
+		return err
+	}
+	return nil
+}
+
+// CreateAPIKeyTable creates the personal API keys. prefix is unique since it
+// is how a presented key finds its row.
+func CreateAPIKeyTable(db *sql.DB) error {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS user_api_key (id bigint not null auto_increment, user_id int not null, name varchar(64) not null, prefix char(12) not null, key_hash char(64) not null, scopes varchar(255) not null, expires_at datetime null, last_used_at datetime null, created_at datetime not null, PRIMARY KEY (id), unique index prefix (prefix), index user_id (user_id) )")
+	if err != nil {
+		log.Println("create user_api_key table. exec error:", err.Error())
+		return err
+	}
+	return nil
+}
+
+// CreateSessionTable creates the logins listed by GET /sessions. last_seen_at
+// is only the login time here, the live value is kept in redis.
+func CreateSessionTable(db *sql.DB) error {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS user_session (id char(32) not null, user_id int not null, user_agent varchar(255) not null, ip varchar(64) not null, created_at datetime not null, last_seen_at datetime not null, PRIMARY KEY (id), index user_id (user_id) )")
+	if err != nil {
+		log.Println("create user_session table. exec error:", err.Error())
+		return err
+	}
+	return nil
+}
+
+// CreateIdentityTable creates the external identities linked to users, one per
+// provider and user
+func CreateIdentityTable(db *sql.DB) error {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS user_identity (id bigint not null auto_increment, user_id int not null, provider varchar(32) not null, subject varchar(255) not null, email varchar(240) null, created_at datetime not null, PRIMARY KEY (id), unique index provider_subject (provider, subject), unique index user_provider (user_id, provider) )")
+	if err != nil {
+		log.Println("create user_identity table. exec error:", err.Error())
+		return err
+	}
+	return nil
+}
+
+// CreateProfileImageRefTable creates the reference counts of the profile
+// images, which are named after their content and shared between users
+func CreateProfileImageRefTable(db *sql.DB) error {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS profile_image_ref (name varchar(240) not null, refs int not null, PRIMARY KEY (name) )")
+	if err != nil {
+		log.Println("create profile_image_ref table. exec error:", err.Error())
+		return err
+	}
+	return nil
+}
+
+// MigrateUserTable adds the columns introduced after the user table was first
+// created, so existing databases catch up with CreateUserTable
+func MigrateUserTable(db *sql.DB) error {
+	err := EnsureColumn(db, "user", "email", "varchar(240)")
+	if err != nil {
+		return err
+	}
+	err = EnsureColumn(db, "user", "role", "varchar(20) not null default 'user'")
+	if err != nil {
+		return err
+	}
+	err = EnsureColumn(db, "user", "status", "varchar(32) not null default 'active'")
+	if err != nil {
+		return err
+	}
+	err = EnsureColumn(db, "user", "suspended_until", "datetime null")
+	if err != nil {
+		return err
+	}
+	err = EnsureColumn(db, "user", "deleted_at", "datetime null")
+	if err != nil {
+		return err
+	}
+	// username used to be a plain index. duplicates have to be cleaned up by
+	// hand before this succeeds
+	return EnsureUniqueIndex(db, "user", "username", "username")
+}
+
+func EnsureUniqueIndex(db *sql.DB, table, index, column string) error {
+	// MIN is NULL when the column has no index at all
+	var nonUnique sql.NullInt64
+	err := db.QueryRow("SELECT MIN(non_unique) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?", table, column).Scan(&nonUnique)
+	if err != nil {
+		log.Println("ensure unique index. check error:", err.Error())
+		return err
+	}
+	if nonUnique.Valid && nonUnique.Int64 == 0 {
+		return nil
+	}
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD UNIQUE INDEX %s_unique (%s)", table, index, column))
+	if err != nil {
+		log.Println("ensure unique index. alter error:", err.Error())
+		return err
+	}
+	return nil
+}
+
+func EnsureColumn(db *sql.DB, table, column, definition string) error {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?", table, column).Scan(&count)
+	if err != nil {
+		log.Println("ensure column. check error:", err.Error())
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		log.Println("ensure column. alter error:", err.Error())
+		return err
+	}
+	return nil
+}
+
 func BulkInsert(unsavedRows []*models.User, db *sql.DB) error {
 	valueStrings := make([]string, 0, len(unsavedRows))
 	valueArgs := make([]interface{}, 0, len(unsavedRows)*4)
@@ -0,0 +1,188 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/account"
+	"github.com/famkampm/nentrytask/internal/audit"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/user"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/famkampm/nentrytask/pkg/middlewares"
+	"github.com/famkampm/nentrytask/pkg/responses"
+	"github.com/julienschmidt/httprouter"
+	"gopkg.in/guregu/null.v3"
+)
+
+type AccountHandler struct {
+	Router         *httprouter.Router
+	AccountUsecase account.Usecase
+	AuditUsecase   audit.Usecase
+}
+
+type changeStatusRequest struct {
+	Status string `json:"status"`
+	// SuspendedUntil is RFC3339 and only given with status suspended
+	SuspendedUntil null.Time `json:"suspended_until"`
+	// Reason ends up in the audit log
+	Reason string `json:"reason"`
+}
+
+type deleteAccountResponse struct {
+	Status  string    `json:"status"`
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+// NewAccountHandler registers account deletion and the personal data export,
+// which are open to the owner and to staff with the matching permission but
+// never to API keys, and the staff only status changes.
+func NewAccountHandler(router *httprouter.Router, us account.Usecase, auditUsecase audit.Usecase, mw *middlewares.Middleware) {
+	handler := &AccountHandler{
+		Router:         router,
+		AccountUsecase: us,
+		AuditUsecase:   auditUsecase,
+	}
+	handler.Router.DELETE("/profile/:id", middlewares.SetMiddlewareJSON(mw.SetMiddlewareSelfOrPermission(auth.PermissionAccountDelete)(handler.Delete)))
+	handler.Router.GET("/profile/:id/export", mw.SetMiddlewareSelfOrPermission(auth.PermissionAccountExport)(handler.Export))
+	handler.Router.PUT("/admin/status/:id", middlewares.SetMiddlewareJSON(mw.SetMiddlewarePermission(auth.PermissionAccountStatus)(handler.ChangeStatus)))
+}
+
+func (h *AccountHandler) Delete(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user_id, err := strconv.ParseInt(ps.ByName("id"), 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, errors.New(http.StatusText(http.StatusBadRequest)))
+		return
+	}
+	purgeAt, err := h.AccountUsecase.Delete(context.TODO(), user_id)
+	if err == sql.ErrNoRows {
+		responses.ERROR(w, http.StatusNotFound, errors.New(http.StatusText(http.StatusNotFound)))
+		return
+	}
+	if err == user.ErrAccountDeleted {
+		responses.ERROR(w, http.StatusGone, err)
+		return
+	}
+	if err != nil {
+		log.Println("delete account err:", err.Error())
+		h.recordAudit(r, user_id, audit.ActionAccountDelete, audit.ResultFailure, "")
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	h.recordAudit(r, user_id, audit.ActionAccountDelete, audit.ResultSuccess, "purge at "+purgeAt.Format(time.RFC3339))
+	responses.JSON(w, http.StatusOK, &deleteAccountResponse{
+		Status:  user.StatusDeleted,
+		PurgeAt: purgeAt,
+	})
+}
+
+// Export answers with a zip archive. It is built in memory first so a failure
+// halfway still gets a proper error status.
+func (h *AccountHandler) Export(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user_id, err := strconv.ParseInt(ps.ByName("id"), 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, errors.New(http.StatusText(http.StatusBadRequest)))
+		return
+	}
+	archive := &bytes.Buffer{}
+	err = h.AccountUsecase.Export(context.TODO(), user_id, archive)
+	if err == sql.ErrNoRows {
+		responses.ERROR(w, http.StatusNotFound, errors.New(http.StatusText(http.StatusNotFound)))
+		return
+	}
+	if err != nil {
+		log.Println("export account err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	h.recordAudit(r, user_id, audit.ActionDataExport, audit.ResultSuccess, "")
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="account-%d.zip"`, user_id))
+	w.Header().Set("Content-Length", strconv.Itoa(archive.Len()))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive.Bytes())
+}
+
+// ChangeStatus suspends, bans, deletes or reactivates an account. The user is
+// logged out everywhere whatever the new status.
+func (h *AccountHandler) ChangeStatus(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user_id, err := strconv.ParseInt(ps.ByName("id"), 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, errors.New(http.StatusText(http.StatusBadRequest)))
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	defer r.Body.Close()
+	req := &changeStatusRequest{}
+	err = json.Unmarshal(body, req)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	err = h.AccountUsecase.ChangeStatus(context.TODO(), user_id, req.Status, req.SuspendedUntil)
+	switch err {
+	case nil:
+	case user.ErrInvalidStatus, user.ErrInvalidSuspension:
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	case sql.ErrNoRows:
+		responses.ERROR(w, http.StatusNotFound, errors.New(http.StatusText(http.StatusNotFound)))
+		return
+	case user.ErrAccountDeleted:
+		responses.ERROR(w, http.StatusGone, err)
+		return
+	default:
+		log.Println("change account status err:", err.Error())
+		h.recordAudit(r, user_id, audit.ActionStatusChange, audit.ResultFailure, statusDetail(req))
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	h.recordAudit(r, user_id, audit.ActionStatusChange, audit.ResultSuccess, statusDetail(req))
+	responses.JSON(w, http.StatusOK, "Status Updated")
+}
+
+func statusDetail(req *changeStatusRequest) string {
+	detail := req.Status
+	if req.SuspendedUntil.Valid {
+		detail += " until " + req.SuspendedUntil.Time.Format(time.RFC3339)
+	}
+	if req.Reason != "" {
+		detail += ": " + req.Reason
+	}
+	return detail
+}
+
+// recordAudit writes an entry about targetID, the actor is the caller
+func (h *AccountHandler) recordAudit(r *http.Request, targetID int64, action, result, detail string) {
+	entry := &models.AuditEntry{
+		ActorID:      targetID,
+		TargetUserID: targetID,
+		Action:       action,
+		IP:           helper.ClientIP(r),
+		UserAgent:    r.UserAgent(),
+		Result:       result,
+		Detail:       detail,
+	}
+	if claims, ok := auth.FromContext(r.Context()); ok {
+		entry.ActorID = claims.UserID
+	}
+	err := h.AuditUsecase.Record(context.TODO(), entry)
+	if err != nil {
+		log.Println("record audit entry err:", err.Error())
+	}
+}
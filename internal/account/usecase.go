@@ -0,0 +1,27 @@
+package account
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+type Usecase interface {
+	// Delete soft deletes the account and ends its sessions and API keys. It
+	// returns when the account is purged for good.
+	Delete(ctx context.Context, userID int64) (time.Time, error)
+	// ChangeStatus moves the account to status and ends its sessions. Moving
+	// to deleted is Delete, moving away from it within the grace period
+	// cancels the purge.
+	ChangeStatus(ctx context.Context, userID int64, status string, suspendedUntil null.Time) error
+	// Purge hard deletes the accounts whose grace period is over and returns
+	// how many
+	Purge(ctx context.Context) (int, error)
+	// SweepImages deletes the stored images no user references any more and
+	// returns how many
+	SweepImages(ctx context.Context) (int, error)
+	// Export writes a zip archive of everything held on the user to w
+	Export(ctx context.Context, userID int64, w io.Writer) error
+}
@@ -0,0 +1,330 @@
+package usecase
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/account"
+	"github.com/famkampm/nentrytask/internal/apikey"
+	"github.com/famkampm/nentrytask/internal/audit"
+	"github.com/famkampm/nentrytask/internal/federation"
+	"github.com/famkampm/nentrytask/internal/mfa"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/profileimage"
+	"github.com/famkampm/nentrytask/internal/session"
+	"github.com/famkampm/nentrytask/internal/user"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/famkampm/nentrytask/pkg/blobstore"
+	"github.com/famkampm/nentrytask/pkg/imaging"
+	"gopkg.in/guregu/null.v3"
+)
+
+const (
+	purgeBatchSize = 100
+	// sweepMinAge spares the images of uploads whose user update has not
+	// been committed yet
+	sweepMinAge = time.Hour
+	// exportPageSize is the largest page the audit usecase hands out
+	exportPageSize = 500
+)
+
+type accountUsecase struct {
+	userUsecase    user.Usecase
+	sessionUsecase session.Usecase
+	apiKeyUsecase  apikey.Usecase
+	mfaUsecase     mfa.Usecase
+	auditUsecase   audit.Usecase
+	federation     federation.Usecase
+	imageStore     blobstore.BlobStore
+	profileImages  profileimage.Usecase
+	revoker        auth.Revoker
+	grace          time.Duration
+}
+
+// NewAccountUsecase purges deleted accounts once grace has passed. Audit
+// entries are not purged with the account, they go with the audit retention.
+func NewAccountUsecase(us user.Usecase, sessionUsecase session.Usecase, apiKeyUsecase apikey.Usecase, mfaUsecase mfa.Usecase, auditUsecase audit.Usecase, federationUsecase federation.Usecase, imageStore blobstore.BlobStore, profileImageUsecase profileimage.Usecase, revoker auth.Revoker, grace time.Duration) account.Usecase {
+	return &accountUsecase{
+		userUsecase:    us,
+		sessionUsecase: sessionUsecase,
+		apiKeyUsecase:  apiKeyUsecase,
+		mfaUsecase:     mfaUsecase,
+		auditUsecase:   auditUsecase,
+		federation:     federationUsecase,
+		imageStore:     imageStore,
+		profileImages:  profileImageUsecase,
+		revoker:        revoker,
+		grace:          grace,
+	}
+}
+
+func (a *accountUsecase) Delete(ctx context.Context, userID int64) (time.Time, error) {
+	usr, err := a.userUsecase.GetByID(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if usr.Status == user.StatusDeleted {
+		return time.Time{}, user.ErrAccountDeleted
+	}
+	err = a.userUsecase.SoftDelete(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// THE ACCOUNT IS GONE FOR ITS OWNER NOW, NOTHING ISSUED BEFORE MAY STILL WORK
+	err = a.endSessions(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	keys, err := a.apiKeyUsecase.Fetch(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, key := range keys {
+		err = a.apiKeyUsecase.Revoke(ctx, userID, key.ID)
+		if err != nil && err != apikey.ErrKeyNotFound {
+			log.Println("delete account revoke api key err:", err.Error())
+			return time.Time{}, err
+		}
+	}
+	return time.Now().UTC().Add(a.grace), nil
+}
+
+// ChangeStatus keeps the API keys of suspended and banned accounts, the
+// middleware rejects them until the account is active again
+func (a *accountUsecase) ChangeStatus(ctx context.Context, userID int64, status string, suspendedUntil null.Time) error {
+	if status == user.StatusDeleted {
+		_, err := a.Delete(ctx, userID)
+		return err
+	}
+	// THE UPDATE ALONE DOESN'T TELL A MISSING USER APART
+	_, err := a.userUsecase.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = a.userUsecase.UpdateStatus(ctx, userID, status, suspendedUntil)
+	if err != nil {
+		return err
+	}
+	return a.endSessions(ctx, userID)
+}
+
+// endSessions revokes every token issued so far and ends every session
+func (a *accountUsecase) endSessions(ctx context.Context, userID int64) error {
+	err := a.revoker.RevokeUser(ctx, userID)
+	if err != nil {
+		log.Println("account revoke tokens err:", err.Error())
+		return err
+	}
+	// NO SESSION IS CURRENT, SO THIS ENDS ALL OF THEM
+	_, err = a.sessionUsecase.RevokeOthers(ctx, userID, "")
+	if err != nil {
+		log.Println("account revoke sessions err:", err.Error())
+		return err
+	}
+	return nil
+}
+
+// Purge stops at the first account that can't be purged, it is picked up
+// again by the next run
+func (a *accountUsecase) Purge(ctx context.Context) (int, error) {
+	before := time.Now().UTC().Add(-a.grace)
+	total := 0
+	for {
+		users, err := a.userUsecase.FetchDeletedBefore(ctx, before, purgeBatchSize)
+		if err != nil {
+			return total, err
+		}
+		for _, usr := range users {
+			err = a.purge(ctx, usr)
+			if err != nil {
+				log.Println("purge account", usr.ID, "err:", err.Error())
+				return total, err
+			}
+			total++
+		}
+		if len(users) < purgeBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// purge removes what Delete left for the grace period. Sessions and API keys
+// were removed already.
+func (a *accountUsecase) purge(ctx context.Context, usr *models.User) error {
+	err := a.mfaUsecase.Erase(ctx, usr.ID)
+	if err != nil {
+		return err
+	}
+	err = a.federation.Erase(ctx, usr.ID)
+	if err != nil {
+		return err
+	}
+	err = a.profileImages.Release(ctx, usr.ProfileImage.String)
+	if err != nil {
+		return err
+	}
+	return a.userUsecase.Delete(ctx, usr.ID)
+}
+
+// SweepImages removes what failed uploads and failed deletions of replaced
+// images left in the store. Images a user points at or with a reference are
+// kept, thumbnails go with their original.
+func (a *accountUsecase) SweepImages(ctx context.Context) (int, error) {
+	before := time.Now().Add(-sweepMinAge)
+	blobs := map[string][]string{}
+	err := a.imageStore.List(ctx, func(obj *blobstore.Object) error {
+		if obj.ModTime.Before(before) {
+			original := imaging.OriginalName(obj.Name)
+			blobs[original] = append(blobs[original], obj.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	originals := make([]string, 0, len(blobs))
+	for original := range blobs {
+		originals = append(originals, original)
+	}
+	total := 0
+	for start := 0; start < len(originals); start += purgeBatchSize {
+		end := start + purgeBatchSize
+		if end > len(originals) {
+			end = len(originals)
+		}
+		referenced, err := a.userUsecase.ReferencedProfileImages(ctx, originals[start:end])
+		if err != nil {
+			return total, err
+		}
+		counted, err := a.profileImages.Referenced(ctx, originals[start:end])
+		if err != nil {
+			return total, err
+		}
+		for _, name := range append(referenced, counted...) {
+			delete(blobs, name)
+		}
+		for _, original := range originals[start:end] {
+			for _, name := range blobs[original] {
+				err = a.imageStore.Delete(ctx, name)
+				if err != nil {
+					log.Println("sweep image", name, "err:", err.Error())
+					return total, err
+				}
+				total++
+			}
+		}
+	}
+	return total, nil
+}
+
+// Export writes one json file per kind of data and the profile image. Secrets
+// like the password hash, the MFA secret or API key hashes are left out.
+func (a *accountUsecase) Export(ctx context.Context, userID int64, w io.Writer) error {
+	usr, err := a.userUsecase.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	sessions, err := a.sessionUsecase.Fetch(ctx, userID, "")
+	if err != nil {
+		return err
+	}
+	keys, err := a.apiKeyUsecase.Fetch(ctx, userID)
+	if err != nil {
+		return err
+	}
+	mfaEnabled, err := a.mfaUsecase.IsEnabled(ctx, userID)
+	if err != nil {
+		return err
+	}
+	identities, err := a.federation.FetchIdentities(ctx, userID)
+	if err != nil {
+		return err
+	}
+	entries, err := a.fetchAudit(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", &models.UserProfile{
+			ID:             usr.ID,
+			Username:       usr.Username,
+			Nickname:       usr.Nickname,
+			ProfileImage:   usr.ProfileImage,
+			Email:          usr.Email,
+			Role:           usr.Role,
+			Status:         usr.Status,
+			SuspendedUntil: usr.SuspendedUntil,
+		}},
+		{"sessions.json", sessions},
+		{"api_keys.json", keys},
+		{"mfa.json", map[string]bool{"enabled": mfaEnabled}},
+		{"identities.json", identities},
+		{"audit_log.json", entries},
+	}
+	for _, file := range files {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(file.data)
+		if err != nil {
+			return err
+		}
+	}
+	err = a.addImage(ctx, archive, usr.ProfileImage.String)
+	if err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// fetchAudit pages through every entry the user is the actor or target of
+func (a *accountUsecase) fetchAudit(ctx context.Context, userID int64) ([]*models.AuditEntry, error) {
+	all := []*models.AuditEntry{}
+	filter := &models.AuditFilter{UserID: userID, Limit: exportPageSize}
+	for {
+		entries, err := a.auditUsecase.Fetch(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, entries...)
+		if len(entries) < exportPageSize {
+			return all, nil
+		}
+		filter.BeforeID = entries[len(entries)-1].ID
+	}
+}
+
+// addImage copies the profile image into the archive. A missing image is
+// skipped, profile.json still names it.
+func (a *accountUsecase) addImage(ctx context.Context, archive *zip.Writer, profileImage string) error {
+	if profileImage == "" {
+		return nil
+	}
+	img, _, err := a.imageStore.Get(ctx, profileImage)
+	if err == blobstore.ErrNotFound || err == blobstore.ErrInvalidName {
+		log.Println("export profile image missing:", profileImage)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer img.Close()
+	f, err := archive.Create("images/" + profileImage)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, img)
+	return err
+}
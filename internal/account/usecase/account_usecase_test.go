@@ -0,0 +1,274 @@
+package usecase_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/account"
+	"github.com/famkampm/nentrytask/internal/account/usecase"
+	_apiKeyMocks "github.com/famkampm/nentrytask/internal/apikey/mocks"
+	_auditMocks "github.com/famkampm/nentrytask/internal/audit/mocks"
+	_federationMocks "github.com/famkampm/nentrytask/internal/federation/mocks"
+	_mfaMocks "github.com/famkampm/nentrytask/internal/mfa/mocks"
+	"github.com/famkampm/nentrytask/internal/models"
+	_profileImageMocks "github.com/famkampm/nentrytask/internal/profileimage/mocks"
+	_sessionMocks "github.com/famkampm/nentrytask/internal/session/mocks"
+	"github.com/famkampm/nentrytask/internal/user"
+	_userMocks "github.com/famkampm/nentrytask/internal/user/mocks"
+	_authMocks "github.com/famkampm/nentrytask/pkg/auth/mocks"
+	"github.com/famkampm/nentrytask/pkg/blobstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gopkg.in/guregu/null.v3"
+)
+
+type deps struct {
+	users    *_userMocks.Usecase
+	sessions *_sessionMocks.Usecase
+	apiKeys  *_apiKeyMocks.Usecase
+	mfa      *_mfaMocks.Usecase
+	audit    *_auditMocks.Usecase
+	identity *_federationMocks.Usecase
+	revoker  *_authMocks.Revoker
+	images   blobstore.BlobStore
+	profiles *_profileImageMocks.Usecase
+}
+
+func newDeps() *deps {
+	return &deps{
+		users:    new(_userMocks.Usecase),
+		sessions: new(_sessionMocks.Usecase),
+		apiKeys:  new(_apiKeyMocks.Usecase),
+		mfa:      new(_mfaMocks.Usecase),
+		audit:    new(_auditMocks.Usecase),
+		identity: new(_federationMocks.Usecase),
+		revoker:  new(_authMocks.Revoker),
+		profiles: new(_profileImageMocks.Usecase),
+	}
+}
+
+// accountUsecase has a grace period of a day
+func (d *deps) accountUsecase() account.Usecase {
+	return usecase.NewAccountUsecase(d.users, d.sessions, d.apiKeys, d.mfa, d.audit, d.identity, d.images, d.profiles, d.revoker, 24*time.Hour)
+}
+
+func TestDeleteUsecase(t *testing.T) {
+	d := newDeps()
+	d.users.On("GetByID", mock.Anything, int64(1)).Return(&models.User{ID: 1, Status: user.StatusActive}, nil).Once()
+	d.users.On("SoftDelete", mock.Anything, int64(1)).Return(nil).Once()
+	d.revoker.On("RevokeUser", mock.Anything, int64(1)).Return(nil).Once()
+	d.sessions.On("RevokeOthers", mock.Anything, int64(1), "").Return(2, nil).Once()
+	d.apiKeys.On("Fetch", mock.Anything, int64(1)).Return([]*models.APIKey{{ID: 7}}, nil).Once()
+	d.apiKeys.On("Revoke", mock.Anything, int64(1), int64(7)).Return(nil).Once()
+
+	u := d.accountUsecase()
+	purgeAt, err := u.Delete(context.TODO(), int64(1))
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), purgeAt, time.Minute)
+	d.users.AssertExpectations(t)
+	d.revoker.AssertExpectations(t)
+	d.sessions.AssertExpectations(t)
+	d.apiKeys.AssertExpectations(t)
+}
+
+func TestDeleteAlreadyDeletedUsecase(t *testing.T) {
+	d := newDeps()
+	d.users.On("GetByID", mock.Anything, int64(1)).Return(&models.User{ID: 1, Status: user.StatusDeleted}, nil).Once()
+
+	u := d.accountUsecase()
+	_, err := u.Delete(context.TODO(), int64(1))
+	assert.Equal(t, user.ErrAccountDeleted, err)
+	d.users.AssertNotCalled(t, "SoftDelete", mock.Anything, mock.Anything)
+}
+
+func TestPurgeUsecase(t *testing.T) {
+	d := newDeps()
+	deleted := []*models.User{
+		{ID: 1, ProfileImage: null.StringFrom("a.png")},
+		// NO PROFILE IMAGE
+		{ID: 2},
+	}
+	d.users.On("FetchDeletedBefore", mock.Anything, mock.MatchedBy(func(before time.Time) bool {
+		return before.Before(time.Now().Add(-23 * time.Hour))
+	}), 100).Return(deleted, nil).Once()
+	d.mfa.On("Erase", mock.Anything, mock.Anything).Return(nil).Twice()
+	d.identity.On("Erase", mock.Anything, mock.Anything).Return(nil).Twice()
+	d.users.On("Delete", mock.Anything, int64(1)).Return(nil).Once()
+	d.users.On("Delete", mock.Anything, int64(2)).Return(nil).Once()
+	d.profiles.On("Release", mock.Anything, "a.png").Return(nil).Once()
+	d.profiles.On("Release", mock.Anything, "").Return(nil).Once()
+
+	u := d.accountUsecase()
+	purged, err := u.Purge(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, purged)
+	d.users.AssertExpectations(t)
+	d.profiles.AssertExpectations(t)
+	d.mfa.AssertExpectations(t)
+	d.identity.AssertExpectations(t)
+}
+
+func TestPurgeStopsOnErrorUsecase(t *testing.T) {
+	d := newDeps()
+	deleted := []*models.User{{ID: 1}, {ID: 2}}
+	d.users.On("FetchDeletedBefore", mock.Anything, mock.Anything, 100).Return(deleted, nil).Once()
+	d.mfa.On("Erase", mock.Anything, int64(1)).Return(sql.ErrConnDone).Once()
+
+	u := d.accountUsecase()
+	purged, err := u.Purge(context.TODO())
+	assert.Equal(t, sql.ErrConnDone, err)
+	assert.Equal(t, 0, purged)
+	d.users.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestSweepImagesUsecase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "images")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	old := time.Now().Add(-2 * time.Hour)
+	for _, name := range []string{"a.png", "a_64.png", "b.png", "b_64.png", "b_1000.png", "c.png", "e.png"} {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(path, []byte("png"), 0644))
+		assert.NoError(t, os.Chtimes(path, old, old))
+	}
+	// AN UPLOAD WHOSE USER UPDATE IS NOT COMMITTED YET
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "d.png"), []byte("png"), 0644))
+
+	d := newDeps()
+	d.images, err = blobstore.NewLocalStore(dir, "/images/", []byte("secret"))
+	assert.NoError(t, err)
+	d.users.On("ReferencedProfileImages", mock.Anything, mock.MatchedBy(func(names []string) bool {
+		sort.Strings(names)
+		return assert.Equal(t, []string{"a.png", "b.png", "c.png", "e.png"}, names)
+	})).Return([]string{"a.png", "c.png"}, nil).Once()
+	// ONLY HELD BY A REFERENCE, E.G. OF AN UPLOAD WHOSE USER UPDATE FAILED
+	d.profiles.On("Referenced", mock.Anything, mock.Anything).Return([]string{"e.png"}, nil).Once()
+
+	u := d.accountUsecase()
+	swept, err := u.SweepImages(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, swept)
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	names := []string{}
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	assert.Equal(t, []string{"a.png", "a_64.png", "c.png", "d.png", "e.png"}, names)
+	d.users.AssertExpectations(t)
+	d.profiles.AssertExpectations(t)
+}
+
+func TestSweepImagesErrorUsecase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "images")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "a.png")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("png"), 0644))
+	old := time.Now().Add(-2 * time.Hour)
+	assert.NoError(t, os.Chtimes(path, old, old))
+
+	d := newDeps()
+	d.images, err = blobstore.NewLocalStore(dir, "/images/", []byte("secret"))
+	assert.NoError(t, err)
+	d.users.On("ReferencedProfileImages", mock.Anything, []string{"a.png"}).Return(nil, sql.ErrConnDone).Once()
+
+	u := d.accountUsecase()
+	swept, err := u.SweepImages(context.TODO())
+	assert.Equal(t, sql.ErrConnDone, err)
+	assert.Equal(t, 0, swept)
+	_, err = os.Stat(path)
+	assert.NoError(t, err)
+}
+
+func TestExportUsecase(t *testing.T) {
+	d := newDeps()
+	d.users.On("GetByID", mock.Anything, int64(1)).Return(&models.User{ID: 1, Username: "user1", Password: "HASH", Status: user.StatusActive}, nil).Once()
+	d.sessions.On("Fetch", mock.Anything, int64(1), "").Return([]*models.Session{{ID: "sid1", UserID: 1}}, nil).Once()
+	d.apiKeys.On("Fetch", mock.Anything, int64(1)).Return([]*models.APIKey{}, nil).Once()
+	d.mfa.On("IsEnabled", mock.Anything, int64(1)).Return(true, nil).Once()
+	d.identity.On("FetchIdentities", mock.Anything, int64(1)).Return([]*models.ExternalIdentity{{ID: 1, UserID: 1, Provider: "corp", Subject: "sub1"}}, nil).Once()
+	firstPage := make([]*models.AuditEntry, 500)
+	for i := range firstPage {
+		firstPage[i] = &models.AuditEntry{ID: int64(600 - i)}
+	}
+	d.audit.On("Fetch", mock.Anything, mock.MatchedBy(func(f *models.AuditFilter) bool { return f.BeforeID == 0 })).Return(firstPage, nil).Once()
+	d.audit.On("Fetch", mock.Anything, mock.MatchedBy(func(f *models.AuditFilter) bool { return f.BeforeID == 101 })).Return([]*models.AuditEntry{{ID: 100}}, nil).Once()
+
+	u := d.accountUsecase()
+	buf := &bytes.Buffer{}
+	err := u.Export(context.TODO(), int64(1), buf)
+	assert.NoError(t, err)
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	files := map[string][]byte{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		assert.NoError(t, err)
+		files[f.Name], _ = ioutil.ReadAll(r)
+		r.Close()
+	}
+	assert.Contains(t, files, "sessions.json")
+	assert.Contains(t, files, "api_keys.json")
+	assert.NotContains(t, string(files["profile.json"]), "HASH")
+	entries := []*models.AuditEntry{}
+	assert.NoError(t, json.Unmarshal(files["audit_log.json"], &entries))
+	assert.Len(t, entries, 501)
+	assert.JSONEq(t, `{"enabled": true}`, string(files["mfa.json"]))
+	assert.Contains(t, string(files["identities.json"]), `"provider": "corp"`)
+	d.audit.AssertExpectations(t)
+}
+
+func TestChangeStatusUsecase(t *testing.T) {
+	d := newDeps()
+	until := null.TimeFrom(time.Now().Add(time.Hour))
+	d.users.On("GetByID", mock.Anything, int64(1)).Return(&models.User{ID: 1, Status: user.StatusActive}, nil).Once()
+	d.users.On("UpdateStatus", mock.Anything, int64(1), user.StatusSuspended, until).Return(nil).Once()
+	d.revoker.On("RevokeUser", mock.Anything, int64(1)).Return(nil).Once()
+	d.sessions.On("RevokeOthers", mock.Anything, int64(1), "").Return(1, nil).Once()
+
+	u := d.accountUsecase()
+	err := u.ChangeStatus(context.TODO(), int64(1), user.StatusSuspended, until)
+	assert.NoError(t, err)
+	d.users.AssertExpectations(t)
+	d.revoker.AssertExpectations(t)
+	d.sessions.AssertExpectations(t)
+	// keys are rejected by the middleware while suspended, not deleted
+	d.apiKeys.AssertNotCalled(t, "Fetch", mock.Anything, mock.Anything)
+}
+
+func TestChangeStatusInvalidUsecase(t *testing.T) {
+	d := newDeps()
+	d.users.On("GetByID", mock.Anything, int64(1)).Return(&models.User{ID: 1, Status: user.StatusActive}, nil).Once()
+	d.users.On("UpdateStatus", mock.Anything, int64(1), user.StatusBanned, mock.Anything).Return(user.ErrInvalidSuspension).Once()
+
+	u := d.accountUsecase()
+	err := u.ChangeStatus(context.TODO(), int64(1), user.StatusBanned, null.TimeFrom(time.Now().Add(time.Hour)))
+	assert.Equal(t, user.ErrInvalidSuspension, err)
+	d.revoker.AssertNotCalled(t, "RevokeUser", mock.Anything, mock.Anything)
+}
+
+func TestChangeStatusToDeletedUsecase(t *testing.T) {
+	d := newDeps()
+	d.users.On("GetByID", mock.Anything, int64(1)).Return(&models.User{ID: 1, Status: user.StatusBanned}, nil).Once()
+	d.users.On("SoftDelete", mock.Anything, int64(1)).Return(nil).Once()
+	d.revoker.On("RevokeUser", mock.Anything, int64(1)).Return(nil).Once()
+	d.sessions.On("RevokeOthers", mock.Anything, int64(1), "").Return(0, nil).Once()
+	d.apiKeys.On("Fetch", mock.Anything, int64(1)).Return([]*models.APIKey{}, nil).Once()
+
+	u := d.accountUsecase()
+	err := u.ChangeStatus(context.TODO(), int64(1), user.StatusDeleted, null.Time{})
+	assert.NoError(t, err)
+	d.users.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	d.users.AssertExpectations(t)
+}
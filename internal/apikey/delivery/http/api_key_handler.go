@@ -0,0 +1,123 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/apikey"
+	"github.com/famkampm/nentrytask/pkg/middlewares"
+	"github.com/famkampm/nentrytask/pkg/responses"
+	"github.com/julienschmidt/httprouter"
+)
+
+type APIKeyHandler struct {
+	Router        *httprouter.Router
+	APIKeyUsecase apikey.Usecase
+}
+
+type createAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// ExpiresIn is a go duration like "720h", empty never expires
+	ExpiresIn string `json:"expires_in"`
+}
+
+// NewAPIKeyHandler registers the key management routes. They need a login
+// token, a key can't be used to mint more keys.
+func NewAPIKeyHandler(router *httprouter.Router, us apikey.Usecase, mw *middlewares.Middleware) {
+	handler := &APIKeyHandler{
+		Router:        router,
+		APIKeyUsecase: us,
+	}
+	handler.Router.POST("/apikeys/:id", middlewares.SetMiddlewareJSON(mw.SetMiddlewareAuthentication(handler.Create)))
+	handler.Router.GET("/apikeys/:id", middlewares.SetMiddlewareJSON(mw.SetMiddlewareAuthentication(handler.Fetch)))
+	handler.Router.DELETE("/apikeys/:id/:key_id", middlewares.SetMiddlewareJSON(mw.SetMiddlewareAuthentication(handler.Revoke)))
+}
+
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user_id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, errors.New(http.StatusText(http.StatusBadRequest)))
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	defer r.Body.Close()
+	req := &createAPIKeyRequest{}
+	err = json.Unmarshal(body, req)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	var ttl time.Duration
+	if req.ExpiresIn != "" {
+		ttl, err = time.ParseDuration(req.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Invalid Expiry"))
+			return
+		}
+	}
+	key, err := h.APIKeyUsecase.Create(context.TODO(), int64(user_id), req.Name, req.Scopes, ttl)
+	switch err {
+	case nil:
+	case apikey.ErrRequiredName, apikey.ErrInvalidScope:
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	case apikey.ErrTooManyKeys:
+		responses.ERROR(w, http.StatusConflict, err)
+		return
+	default:
+		log.Println("create api key err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	responses.JSON(w, http.StatusCreated, key)
+}
+
+func (h *APIKeyHandler) Fetch(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user_id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, errors.New(http.StatusText(http.StatusBadRequest)))
+		return
+	}
+	keys, err := h.APIKeyUsecase.Fetch(context.TODO(), int64(user_id))
+	if err != nil {
+		log.Println("fetch api keys err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, keys)
+}
+
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user_id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, errors.New(http.StatusText(http.StatusBadRequest)))
+		return
+	}
+	key_id, err := strconv.Atoi(ps.ByName("key_id"))
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, errors.New(http.StatusText(http.StatusBadRequest)))
+		return
+	}
+	err = h.APIKeyUsecase.Revoke(context.TODO(), int64(user_id), int64(key_id))
+	if err == apikey.ErrKeyNotFound {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		log.Println("revoke api key err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, "API Key Revoked")
+}
@@ -0,0 +1,11 @@
+package apikey
+
+import "errors"
+
+var (
+	ErrInvalidKey   = errors.New("Invalid API Key")
+	ErrKeyNotFound  = errors.New("API Key Not Found")
+	ErrInvalidScope = errors.New("Invalid API Key Scope")
+	ErrTooManyKeys  = errors.New("Too Many API Keys")
+	ErrRequiredName = errors.New("Required Name")
+)
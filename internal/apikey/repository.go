@@ -0,0 +1,17 @@
+package apikey
+
+import (
+	"context"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/models"
+)
+
+type Repository interface {
+	Store(ctx context.Context, key *models.APIKey) error
+	GetByPrefix(ctx context.Context, prefix string) (*models.APIKey, error)
+	FetchByUserID(ctx context.Context, userID int64) ([]*models.APIKey, error)
+	// Delete only removes the key when it belongs to userID
+	Delete(ctx context.Context, userID, id int64) (bool, error)
+	TouchLastUsed(ctx context.Context, id int64, at time.Time) error
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/apikey"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/go-sql-driver/mysql"
+)
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_at`
+
+type mysqlAPIKeyRepository struct {
+	DB *sql.DB
+}
+
+func NewMysqlAPIKeyRepository(db *sql.DB) apikey.Repository {
+	return &mysqlAPIKeyRepository{
+		DB: db,
+	}
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row scanner) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	var scopes string
+	var expiresAt, lastUsedAt mysql.NullTime
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.Hash, &scopes, &expiresAt, &lastUsedAt, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	key.Scopes = []string{}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	return key, nil
+}
+
+func (m *mysqlAPIKeyRepository) Store(ctx context.Context, key *models.APIKey) error {
+	query := `insert into user_api_key (user_id, name, prefix, key_hash, scopes, expires_at, created_at) values (?, ?, ?, ?, ?, ?, ?)`
+	res, err := m.DB.ExecContext(ctx, query, key.UserID, key.Name, key.Prefix, key.Hash, strings.Join(key.Scopes, ","), key.ExpiresAt, key.CreatedAt)
+	if err != nil {
+		log.Println("store api key err:", err.Error())
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	key.ID = id
+	return nil
+}
+
+func (m *mysqlAPIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	query := `select ` + apiKeyColumns + ` from user_api_key where prefix = ?`
+	key, err := scanAPIKey(m.DB.QueryRowContext(ctx, query, prefix))
+	if err != nil {
+		return &models.APIKey{}, err
+	}
+	return key, nil
+}
+
+func (m *mysqlAPIKeyRepository) FetchByUserID(ctx context.Context, userID int64) ([]*models.APIKey, error) {
+	query := `select ` + apiKeyColumns + ` from user_api_key where user_id = ? order by id`
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.Println("fetch api keys err:", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []*models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (m *mysqlAPIKeyRepository) Delete(ctx context.Context, userID, id int64) (bool, error) {
+	res, err := m.DB.ExecContext(ctx, `delete from user_api_key where id = ? and user_id = ?`, id, userID)
+	if err != nil {
+		log.Println("delete api key err:", err.Error())
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (m *mysqlAPIKeyRepository) TouchLastUsed(ctx context.Context, id int64, at time.Time) error {
+	_, err := m.DB.ExecContext(ctx, `update user_api_key set last_used_at = ? where id = ?`, at, id)
+	if err != nil {
+		log.Println("touch api key err:", err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/famkampm/nentrytask/internal/apikey/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+var apiKeyColumns = []string{"id", "user_id", "name", "prefix", "key_hash", "scopes", "expires_at", "last_used_at", "created_at"}
+
+func TestGetByPrefixMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	now := time.Now().UTC()
+	rows := sqlmock.NewRows(apiKeyColumns).AddRow(3, 1, "ci", "abcdef", "HASH", "profile:read,profile:write", nil, now, now)
+	mock.ExpectQuery("select (.+) from user_api_key where prefix").WithArgs("abcdef").WillReturnRows(rows)
+	m := repository.NewMysqlAPIKeyRepository(db)
+	res, err := m.GetByPrefix(context.TODO(), "abcdef")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), res.ID)
+	assert.Equal(t, []string{"profile:read", "profile:write"}, res.Scopes)
+	assert.Nil(t, res.ExpiresAt)
+	assert.Equal(t, now, *res.LastUsedAt)
+}
+
+func TestDeleteMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("delete from user_api_key where id = (.+) and user_id").WithArgs(7, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	m := repository.NewMysqlAPIKeyRepository(db)
+	deleted, err := m.Delete(context.TODO(), int64(1), int64(7))
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,19 @@
+package apikey
+
+import (
+	"context"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/pkg/auth"
+)
+
+type Usecase interface {
+	// Create returns the new key in clear, it can't be recovered later.
+	// Without scopes the key gets every scope. A zero ttl never expires.
+	Create(ctx context.Context, userID int64, name string, scopes []string, ttl time.Duration) (*models.NewAPIKey, error)
+	Fetch(ctx context.Context, userID int64) ([]*models.APIKey, error)
+	Revoke(ctx context.Context, userID, id int64) error
+	// Authenticate turns a presented key into the claims of its owner
+	Authenticate(ctx context.Context, key string) (*auth.Claims, error)
+}
@@ -0,0 +1,134 @@
+package usecase
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/apikey"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/famkampm/nentrytask/pkg/helper"
+)
+
+const (
+	maxKeysPerUser = 25
+	maxNameLength  = 64
+	// last_used_at is only written when it is older than this, so a busy key
+	// doesn't cost a mysql write per request
+	lastUsedResolution = time.Minute
+)
+
+type apiKeyUsecase struct {
+	apiKeyRepo apikey.Repository
+}
+
+func NewAPIKeyUsecase(repo apikey.Repository) apikey.Usecase {
+	return &apiKeyUsecase{
+		apiKeyRepo: repo,
+	}
+}
+
+func (a *apiKeyUsecase) Create(ctx context.Context, userID int64, name string, scopes []string, ttl time.Duration) (*models.NewAPIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxNameLength {
+		return nil, apikey.ErrRequiredName
+	}
+	unique := []string{}
+	seen := map[string]bool{}
+	for _, scope := range scopes {
+		if !auth.ValidScope(scope) {
+			return nil, apikey.ErrInvalidScope
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			unique = append(unique, scope)
+		}
+	}
+	existing, err := a.apiKeyRepo.FetchByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxKeysPerUser {
+		return nil, apikey.ErrTooManyKeys
+	}
+	// nt_<prefix>_<secret>, the prefix finds the row and the whole key is hashed
+	prefix := helper.RandToken(6)
+	key := auth.APIKeyPrefix + prefix + "_" + helper.RandToken(24)
+	res := &models.NewAPIKey{
+		APIKey: models.APIKey{
+			UserID:    userID,
+			Name:      name,
+			Prefix:    prefix,
+			Hash:      helper.HashToken(key),
+			Scopes:    unique,
+			CreatedAt: time.Now().UTC(),
+		},
+		Key: key,
+	}
+	if ttl > 0 {
+		expiresAt := res.CreatedAt.Add(ttl)
+		res.ExpiresAt = &expiresAt
+	}
+	err = a.apiKeyRepo.Store(ctx, &res.APIKey)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (a *apiKeyUsecase) Fetch(ctx context.Context, userID int64) ([]*models.APIKey, error) {
+	return a.apiKeyRepo.FetchByUserID(ctx, userID)
+}
+
+func (a *apiKeyUsecase) Revoke(ctx context.Context, userID, id int64) error {
+	deleted, err := a.apiKeyRepo.Delete(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return apikey.ErrKeyNotFound
+	}
+	return nil
+}
+
+func (a *apiKeyUsecase) Authenticate(ctx context.Context, key string) (*auth.Claims, error) {
+	parts := strings.SplitN(strings.TrimPrefix(key, auth.APIKeyPrefix), "_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, apikey.ErrInvalidKey
+	}
+	stored, err := a.apiKeyRepo.GetByPrefix(ctx, parts[0])
+	if err == sql.ErrNoRows {
+		return nil, apikey.ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(stored.Hash), []byte(helper.HashToken(key))) != 1 {
+		return nil, apikey.ErrInvalidKey
+	}
+	now := time.Now().UTC()
+	if stored.ExpiresAt != nil && now.After(*stored.ExpiresAt) {
+		return nil, apikey.ErrInvalidKey
+	}
+	if stored.LastUsedAt == nil || now.Sub(*stored.LastUsedAt) > lastUsedResolution {
+		err = a.apiKeyRepo.TouchLastUsed(ctx, stored.ID, now)
+		if err != nil {
+			log.Println("api key touch last used err:", err.Error())
+		}
+	}
+	scopes := stored.Scopes
+	if len(scopes) == 0 {
+		for _, scope := range auth.APIKeyScopes {
+			scopes = append(scopes, string(scope))
+		}
+	}
+	return &auth.Claims{
+		UserID:   stored.UserID,
+		APIKeyID: stored.ID,
+		Scopes:   scopes,
+	}, nil
+}
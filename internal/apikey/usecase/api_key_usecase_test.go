@@ -0,0 +1,98 @@
+package usecase_test
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/apikey"
+	"github.com/famkampm/nentrytask/internal/apikey/mocks"
+	"github.com/famkampm/nentrytask/internal/apikey/usecase"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("FetchByUserID", mock.Anything, int64(1)).Return([]*models.APIKey{}, nil).Once()
+	mockRepo.On("Store", mock.Anything, mock.AnythingOfType("*models.APIKey")).Return(nil).Once()
+
+	u := usecase.NewAPIKeyUsecase(mockRepo)
+	key, err := u.Create(context.TODO(), int64(1), " ci ", []string{"profile:read", "profile:read"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "ci", key.Name)
+	assert.True(t, strings.HasPrefix(key.Key, auth.APIKeyPrefix+key.Prefix+"_"))
+	assert.Equal(t, helper.HashToken(key.Key), key.Hash)
+	assert.Equal(t, []string{"profile:read"}, key.Scopes)
+	assert.NotNil(t, key.ExpiresAt)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateInvalidUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	u := usecase.NewAPIKeyUsecase(mockRepo)
+	_, err := u.Create(context.TODO(), int64(1), "", nil, 0)
+	assert.Equal(t, apikey.ErrRequiredName, err)
+	_, err = u.Create(context.TODO(), int64(1), "ci", []string{"roles:write"}, 0)
+	assert.Equal(t, apikey.ErrInvalidScope, err)
+
+	full := make([]*models.APIKey, 25)
+	mockRepo.On("FetchByUserID", mock.Anything, int64(1)).Return(full, nil).Once()
+	_, err = u.Create(context.TODO(), int64(1), "ci", nil, 0)
+	assert.Equal(t, apikey.ErrTooManyKeys, err)
+}
+
+func TestRevokeNotFoundUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("Delete", mock.Anything, int64(1), int64(7)).Return(false, nil).Once()
+
+	u := usecase.NewAPIKeyUsecase(mockRepo)
+	assert.Equal(t, apikey.ErrKeyNotFound, u.Revoke(context.TODO(), int64(1), int64(7)))
+}
+
+func TestAuthenticateUsecase(t *testing.T) {
+	key := "nt_abcdef_secret"
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("GetByPrefix", mock.Anything, "abcdef").Return(&models.APIKey{ID: 3, UserID: 1, Hash: helper.HashToken(key), Scopes: []string{}}, nil).Once()
+	mockRepo.On("TouchLastUsed", mock.Anything, int64(3), mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+	u := usecase.NewAPIKeyUsecase(mockRepo)
+	claims, err := u.Authenticate(context.TODO(), key)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), claims.UserID)
+	assert.True(t, claims.IsAPIKey())
+	// no scopes means every key scope
+	assert.True(t, claims.HasScope(auth.PermissionProfileWrite))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthenticateRecentlyUsedUsecase(t *testing.T) {
+	key := "nt_abcdef_secret"
+	recent := time.Now().UTC().Add(-10 * time.Second)
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("GetByPrefix", mock.Anything, "abcdef").Return(&models.APIKey{ID: 3, UserID: 1, Hash: helper.HashToken(key), Scopes: []string{"profile:read"}, LastUsedAt: &recent}, nil).Once()
+
+	u := usecase.NewAPIKeyUsecase(mockRepo)
+	claims, err := u.Authenticate(context.TODO(), key)
+	assert.NoError(t, err)
+	assert.False(t, claims.HasScope(auth.PermissionProfileWrite))
+	mockRepo.AssertNotCalled(t, "TouchLastUsed", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthenticateInvalidUsecase(t *testing.T) {
+	expired := time.Now().UTC().Add(-time.Hour)
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("GetByPrefix", mock.Anything, "missing").Return(&models.APIKey{}, sql.ErrNoRows).Once()
+	mockRepo.On("GetByPrefix", mock.Anything, "abcdef").Return(&models.APIKey{ID: 3, UserID: 1, Hash: helper.HashToken("nt_abcdef_secret"), ExpiresAt: &expired}, nil)
+
+	u := usecase.NewAPIKeyUsecase(mockRepo)
+	for _, key := range []string{"nt_", "nt_missing_secret", "nt_abcdef_wrong", "nt_abcdef_secret"} {
+		_, err := u.Authenticate(context.TODO(), key)
+		assert.Equal(t, apikey.ErrInvalidKey, err, key)
+	}
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/audit"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/famkampm/nentrytask/pkg/middlewares"
+	"github.com/famkampm/nentrytask/pkg/responses"
+	"github.com/julienschmidt/httprouter"
+)
+
+var errInvalidQuery = errors.New("Invalid Query")
+
+type AuditHandler struct {
+	Router       *httprouter.Router
+	AuditUsecase audit.Usecase
+}
+
+// NewAuditHandler registers the audit log queries. GET /audit searches every
+// user and is staff only, GET /audit/:id is the history of one account and
+// also open to its owner.
+func NewAuditHandler(router *httprouter.Router, us audit.Usecase, mw *middlewares.Middleware) {
+	handler := &AuditHandler{
+		Router:       router,
+		AuditUsecase: us,
+	}
+	handler.Router.GET("/audit", middlewares.SetMiddlewareJSON(mw.SetMiddlewarePermission(auth.PermissionAuditRead)(handler.Fetch)))
+	handler.Router.GET("/audit/:id", middlewares.SetMiddlewareJSON(mw.SetMiddlewareSelfOrPermission(auth.PermissionAuditRead)(handler.FetchByUser)))
+}
+
+// Fetch takes the optional query parameters user_id, from, to (RFC3339),
+// before_id and limit
+func (h *AuditHandler) Fetch(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	query := r.URL.Query()
+	filter, err := parseFilter(query)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	if query.Get("user_id") != "" {
+		filter.UserID, err = strconv.ParseInt(query.Get("user_id"), 10, 64)
+		if err != nil {
+			responses.ERROR(w, http.StatusBadRequest, errInvalidQuery)
+			return
+		}
+	}
+	h.fetch(w, filter)
+}
+
+func (h *AuditHandler) FetchByUser(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user_id, err := strconv.ParseInt(ps.ByName("id"), 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, errors.New(http.StatusText(http.StatusBadRequest)))
+		return
+	}
+	filter, err := parseFilter(r.URL.Query())
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	filter.UserID = user_id
+	h.fetch(w, filter)
+}
+
+func (h *AuditHandler) fetch(w http.ResponseWriter, filter *models.AuditFilter) {
+	entries, err := h.AuditUsecase.Fetch(context.TODO(), filter)
+	if err == audit.ErrInvalidTimeRange {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		log.Println("fetch audit log err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, entries)
+}
+
+func parseFilter(query url.Values) (*models.AuditFilter, error) {
+	filter := &models.AuditFilter{}
+	var err error
+	if query.Get("from") != "" {
+		filter.From, err = time.Parse(time.RFC3339, query.Get("from"))
+		if err != nil {
+			return nil, errInvalidQuery
+		}
+	}
+	if query.Get("to") != "" {
+		filter.To, err = time.Parse(time.RFC3339, query.Get("to"))
+		if err != nil {
+			return nil, errInvalidQuery
+		}
+	}
+	if query.Get("before_id") != "" {
+		filter.BeforeID, err = strconv.ParseInt(query.Get("before_id"), 10, 64)
+		if err != nil {
+			return nil, errInvalidQuery
+		}
+	}
+	if query.Get("limit") != "" {
+		filter.Limit, err = strconv.Atoi(query.Get("limit"))
+		if err != nil {
+			return nil, errInvalidQuery
+		}
+	}
+	return filter, nil
+}
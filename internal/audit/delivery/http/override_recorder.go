@@ -0,0 +1,48 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/famkampm/nentrytask/internal/audit"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/famkampm/nentrytask/pkg/middlewares"
+)
+
+type overrideRecorder struct {
+	AuditUsecase audit.Usecase
+}
+
+// NewOverrideRecorder writes the overrides seen by the middleware to the audit log
+func NewOverrideRecorder(us audit.Usecase) middlewares.OverrideRecorder {
+	return &overrideRecorder{
+		AuditUsecase: us,
+	}
+}
+
+func (o *overrideRecorder) RecordOverride(r *http.Request, claims *auth.Claims, targetUserID int64, permission auth.Permission, status int) {
+	entry := &models.AuditEntry{
+		TargetUserID: targetUserID,
+		Action:       audit.ActionPermissionOverride,
+		IP:           helper.ClientIP(r),
+		UserAgent:    r.UserAgent(),
+		Result:       audit.ResultSuccess,
+	}
+	role := "operator"
+	if claims != nil {
+		entry.ActorID = claims.UserID
+		role = claims.Role
+	}
+	if status >= http.StatusBadRequest {
+		entry.Result = audit.ResultFailure
+	}
+	entry.Detail = fmt.Sprintf("%s %s by %s via %s: %d", r.Method, r.URL.Path, role, permission, status)
+	err := o.AuditUsecase.Record(context.TODO(), entry)
+	if err != nil {
+		log.Println("record override err:", err.Error())
+	}
+}
@@ -0,0 +1,16 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/models"
+)
+
+// Repository is append only, entries are never updated. They are only deleted
+// once they are older than the retention.
+type Repository interface {
+	Store(ctx context.Context, entry *models.AuditEntry) error
+	Fetch(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error)
+	DeleteBefore(ctx context.Context, before time.Time, limit int) (int64, error)
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/audit"
+	"github.com/famkampm/nentrytask/internal/models"
+)
+
+type mysqlAuditRepository struct {
+	DB *sql.DB
+}
+
+func NewMysqlAuditRepository(db *sql.DB) audit.Repository {
+	return &mysqlAuditRepository{
+		DB: db,
+	}
+}
+
+func (m *mysqlAuditRepository) Store(ctx context.Context, entry *models.AuditEntry) error {
+	query := `insert into audit_log (actor_id, target_user_id, action, ip, user_agent, result, detail, created_at) values (?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := m.DB.ExecContext(ctx, query, entry.ActorID, entry.TargetUserID, entry.Action, entry.IP, entry.UserAgent, entry.Result, entry.Detail, entry.CreatedAt)
+	if err != nil {
+		log.Println("store audit entry err:", err.Error())
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	entry.ID = id
+	return nil
+}
+
+func (m *mysqlAuditRepository) Fetch(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error) {
+	query := `select id, actor_id, target_user_id, action, ip, user_agent, result, detail, created_at from audit_log where 1 = 1`
+	args := []interface{}{}
+	if filter.UserID != 0 {
+		query += ` and (target_user_id = ? or actor_id = ?)`
+		args = append(args, filter.UserID, filter.UserID)
+	}
+	if !filter.From.IsZero() {
+		query += ` and created_at >= ?`
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		query += ` and created_at < ?`
+		args = append(args, filter.To)
+	}
+	if filter.BeforeID != 0 {
+		query += ` and id < ?`
+		args = append(args, filter.BeforeID)
+	}
+	query += ` order by id desc limit ?`
+	args = append(args, filter.Limit)
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Println("fetch audit entries err:", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []*models.AuditEntry{}
+	for rows.Next() {
+		entry := &models.AuditEntry{}
+		err = rows.Scan(&entry.ID, &entry.ActorID, &entry.TargetUserID, &entry.Action, &entry.IP, &entry.UserAgent, &entry.Result, &entry.Detail, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// DeleteBefore deletes at most limit entries so pruning a large backlog doesn't
+// hold a long lock on the table
+func (m *mysqlAuditRepository) DeleteBefore(ctx context.Context, before time.Time, limit int) (int64, error) {
+	res, err := m.DB.ExecContext(ctx, `delete from audit_log where created_at < ? order by created_at limit ?`, before, limit)
+	if err != nil {
+		log.Println("prune audit entries err:", err.Error())
+		return 0, err
+	}
+	return res.RowsAffected()
+}
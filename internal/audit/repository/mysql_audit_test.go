@@ -0,0 +1,46 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/famkampm/nentrytask/internal/audit/repository"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "actor_id", "target_user_id", "action", "ip", "user_agent", "result", "detail", "created_at"}).
+		AddRow(9, 1, 1, "login", "10.0.0.1", "curl/7.0", "success", "", from)
+	mock.ExpectQuery(`select (.+) from audit_log where 1 = 1 and \(target_user_id = \? or actor_id = \?\) and created_at >= \? and id < \? order by id desc limit \?`).
+		WithArgs(1, 1, from, 10, 50).WillReturnRows(rows)
+	m := repository.NewMysqlAuditRepository(db)
+	res, err := m.Fetch(context.TODO(), &models.AuditFilter{UserID: 1, From: from, BeforeID: 10, Limit: 50})
+	assert.NoError(t, err)
+	assert.Len(t, res, 1)
+	assert.Equal(t, "login", res[0].Action)
+}
+
+func TestDeleteBeforeMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	before := time.Now().UTC()
+	mock.ExpectExec("delete from audit_log where created_at < (.+) limit").WithArgs(before, 1000).WillReturnResult(sqlmock.NewResult(0, 7))
+	m := repository.NewMysqlAuditRepository(db)
+	deleted, err := m.DeleteBefore(context.TODO(), before, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), deleted)
+}
@@ -0,0 +1,33 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/famkampm/nentrytask/internal/models"
+)
+
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+const (
+	ActionPermissionOverride = "permission_override"
+	ActionLogin              = "login"
+	ActionPasswordChange     = "password_change"
+	ActionPasswordReset      = "password_reset"
+	ActionProfileUpdate      = "profile_update"
+	ActionImageUpload        = "image_upload"
+	ActionAccountDelete      = "account_delete"
+	ActionDataExport         = "data_export"
+	ActionStatusChange       = "status_change"
+	ActionIdentityLink       = "identity_link"
+	ActionIdentityUnlink     = "identity_unlink"
+)
+
+type Usecase interface {
+	Record(ctx context.Context, entry *models.AuditEntry) error
+	Fetch(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error)
+	// Prune deletes the entries older than the retention and returns how many
+	Prune(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,72 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/audit"
+	"github.com/famkampm/nentrytask/internal/models"
+)
+
+const (
+	defaultFetchLimit = 50
+	maxFetchLimit     = 500
+	pruneBatchSize    = 1000
+)
+
+type auditUsecase struct {
+	auditRepo audit.Repository
+	retention time.Duration
+}
+
+// NewAuditUsecase keeps entries for retention, zero keeps them forever
+func NewAuditUsecase(repo audit.Repository, retention time.Duration) audit.Usecase {
+	return &auditUsecase{
+		auditRepo: repo,
+		retention: retention,
+	}
+}
+
+// Record stamps the entry with the current time and stores it
+func (a *auditUsecase) Record(ctx context.Context, entry *models.AuditEntry) error {
+	entry.CreatedAt = time.Now().UTC()
+	if len(entry.UserAgent) > 255 {
+		entry.UserAgent = entry.UserAgent[:255]
+	}
+	if len(entry.Detail) > 512 {
+		entry.Detail = entry.Detail[:512]
+	}
+	return a.auditRepo.Store(ctx, entry)
+}
+
+func (a *auditUsecase) Fetch(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error) {
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, audit.ErrInvalidTimeRange
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultFetchLimit
+	}
+	if filter.Limit > maxFetchLimit {
+		filter.Limit = maxFetchLimit
+	}
+	return a.auditRepo.Fetch(ctx, filter)
+}
+
+// Prune deletes in batches until everything older than the retention is gone
+func (a *auditUsecase) Prune(ctx context.Context) (int64, error) {
+	if a.retention <= 0 {
+		return 0, nil
+	}
+	before := time.Now().UTC().Add(-a.retention)
+	var total int64
+	for {
+		deleted, err := a.auditRepo.DeleteBefore(ctx, before, pruneBatchSize)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+		if deleted < pruneBatchSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package usecase_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/audit"
+	"github.com/famkampm/nentrytask/internal/audit/mocks"
+	"github.com/famkampm/nentrytask/internal/audit/usecase"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRecordUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("Store", mock.Anything, mock.AnythingOfType("*models.AuditEntry")).Return(nil).Once()
+
+	u := usecase.NewAuditUsecase(mockRepo, 0)
+	entry := &models.AuditEntry{ActorID: int64(1), TargetUserID: int64(2), UserAgent: strings.Repeat("a", 300)}
+	err := u.Record(context.TODO(), entry)
+	assert.NoError(t, err)
+	assert.False(t, entry.CreatedAt.IsZero())
+	assert.Len(t, entry.UserAgent, 255)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestFetchUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("Fetch", mock.Anything, &models.AuditFilter{UserID: 1, Limit: 500}).Return([]*models.AuditEntry{{ID: 1}}, nil).Once()
+
+	u := usecase.NewAuditUsecase(mockRepo, 0)
+	entries, err := u.Fetch(context.TODO(), &models.AuditFilter{UserID: 1, Limit: 10000})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestFetchInvalidRangeUsecase(t *testing.T) {
+	now := time.Now()
+	u := usecase.NewAuditUsecase(new(mocks.Repository), 0)
+	_, err := u.Fetch(context.TODO(), &models.AuditFilter{From: now, To: now.Add(-time.Hour)})
+	assert.Equal(t, audit.ErrInvalidTimeRange, err)
+}
+
+func TestPruneUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("DeleteBefore", mock.Anything, mock.AnythingOfType("time.Time"), 1000).Return(int64(1000), nil).Once()
+	mockRepo.On("DeleteBefore", mock.Anything, mock.AnythingOfType("time.Time"), 1000).Return(int64(3), nil).Once()
+
+	u := usecase.NewAuditUsecase(mockRepo, 24*time.Hour)
+	deleted, err := u.Prune(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1003), deleted)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPruneDisabledUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	u := usecase.NewAuditUsecase(mockRepo, 0)
+	deleted, err := u.Prune(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+	mockRepo.AssertNotCalled(t, "DeleteBefore", mock.Anything, mock.Anything, mock.Anything)
+}
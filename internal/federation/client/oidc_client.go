@@ -0,0 +1,261 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/famkampm/nentrytask/internal/federation"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/pkg/auth"
+)
+
+// maxResponseSize caps what we read from a provider
+const maxResponseSize = 1 << 20
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type oidcClient struct {
+	HTTPClient *http.Client
+	mu         sync.Mutex
+	// documents and keys are cached by issuer, keys are fetched again when
+	// a token names a kid we don't know yet
+	documents map[string]*discoveryDocument
+	keys      map[string]map[string]auth.JWK
+}
+
+func NewOIDCClient(httpClient *http.Client) federation.Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &oidcClient{
+		HTTPClient: httpClient,
+		documents:  map[string]*discoveryDocument{},
+		keys:       map[string]map[string]auth.JWK{},
+	}
+}
+
+func (c *oidcClient) AuthorizationURL(ctx context.Context, provider *models.IdentityProvider, state, nonce, codeChallenge string) (string, error) {
+	doc, err := c.discover(ctx, provider)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(doc.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.ClientID)
+	query.Set("redirect_uri", provider.RedirectURI)
+	query.Set("scope", strings.Join(provider.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+func (c *oidcClient) Exchange(ctx context.Context, provider *models.IdentityProvider, code, codeVerifier, nonce string) (*models.ExternalClaims, error) {
+	doc, err := c.discover(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {provider.RedirectURI},
+		"code_verifier": {codeVerifier},
+		"client_id":     {provider.ClientID},
+	}
+	req, err := http.NewRequest("POST", doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if provider.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(provider.ClientID), url.QueryEscape(provider.ClientSecret))
+	}
+	tokens := &tokenResponse{}
+	status, err := c.do(req, tokens)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || tokens.IDToken == "" {
+		// A REJECTED CODE IS THE CALLER'S FAULT, NOT OURS
+		log.Println("federated login token endpoint of", provider.Name, "answered", status, tokens.Error, tokens.ErrorDescription)
+		return nil, federation.ErrInvalidIDToken
+	}
+	return c.verify(ctx, provider, doc, tokens.IDToken, nonce)
+}
+
+// verify checks the signature of the ID token against the provider JWKS and
+// that it was issued by the provider to us for this login
+func (c *oidcClient) verify(ctx context.Context, provider *models.IdentityProvider, doc *discoveryDocument, idToken, nonce string) (*models.ExternalClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *auth.SigningMethodEd25519:
+		default:
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		jwk, err := c.key(ctx, doc, kid)
+		if err != nil {
+			return nil, err
+		}
+		if jwk.Algorithm != "" && jwk.Algorithm != token.Method.Alg() {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		return jwk.PublicKey()
+	})
+	if err != nil {
+		log.Println("federated login verify id token of", provider.Name, "err:", err.Error())
+		return nil, federation.ErrInvalidIDToken
+	}
+	issuer, _ := claims["iss"].(string)
+	tokenNonce, _ := claims["nonce"].(string)
+	subject, _ := claims["sub"].(string)
+	if issuer != doc.Issuer || tokenNonce != nonce || subject == "" || !audienceContains(claims, provider.ClientID) {
+		log.Println("federated login id token of", provider.Name, "has wrong iss, aud, sub or nonce")
+		return nil, federation.ErrInvalidIDToken
+	}
+	external := &models.ExternalClaims{Subject: subject}
+	external.Email, _ = claims["email"].(string)
+	external.PreferredUsername, _ = claims["preferred_username"].(string)
+	external.Name, _ = claims["name"].(string)
+	// SOME PROVIDERS SEND email_verified AS A STRING
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		external.EmailVerified = verified
+	case string:
+		external.EmailVerified = verified == "true"
+	}
+	return external, nil
+}
+
+// audienceContains accepts aud as a string or a list. A token for several
+// audiences has to name us as the authorized party.
+func audienceContains(claims jwt.MapClaims, clientID string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		found := false
+		for _, a := range aud {
+			if a == clientID {
+				found = true
+			}
+		}
+		if len(aud) > 1 {
+			azp, _ := claims["azp"].(string)
+			return found && azp == clientID
+		}
+		return found
+	}
+	return false
+}
+
+func (c *oidcClient) discover(ctx context.Context, provider *models.IdentityProvider) (*discoveryDocument, error) {
+	c.mu.Lock()
+	doc := c.documents[provider.Issuer]
+	c.mu.Unlock()
+	if doc != nil {
+		return doc, nil
+	}
+	req, err := http.NewRequest("GET", strings.TrimSuffix(provider.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	doc = &discoveryDocument{}
+	status, err := c.do(req.WithContext(ctx), doc)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("discovery of %s answered %d", provider.Name, status)
+	}
+	if doc.Issuer != provider.Issuer || doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery of %s is incomplete or for another issuer", provider.Name)
+	}
+	c.mu.Lock()
+	c.documents[provider.Issuer] = doc
+	c.mu.Unlock()
+	return doc, nil
+}
+
+func (c *oidcClient) key(ctx context.Context, doc *discoveryDocument, kid string) (auth.JWK, error) {
+	c.mu.Lock()
+	jwk, ok := c.keys[doc.Issuer][kid]
+	c.mu.Unlock()
+	if ok {
+		return jwk, nil
+	}
+	req, err := http.NewRequest("GET", doc.JWKSURI, nil)
+	if err != nil {
+		return jwk, err
+	}
+	set := &auth.JWKSet{}
+	status, err := c.do(req.WithContext(ctx), set)
+	if err != nil {
+		return jwk, err
+	}
+	if status != http.StatusOK {
+		return jwk, fmt.Errorf("jwks of %s answered %d", doc.Issuer, status)
+	}
+	keys := map[string]auth.JWK{}
+	for _, k := range set.Keys {
+		if k.Use == "" || k.Use == "sig" {
+			keys[k.KeyID] = k
+		}
+	}
+	c.mu.Lock()
+	c.keys[doc.Issuer] = keys
+	c.mu.Unlock()
+	jwk, ok = keys[kid]
+	if !ok {
+		return jwk, auth.ErrUnknownKey
+	}
+	return jwk, nil
+}
+
+// do sends req and decodes the JSON answer into v whatever the status
+func (c *oidcClient) do(req *http.Request, v interface{}) (int, error) {
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if err != nil {
+		return 0, err
+	}
+	err = json.Unmarshal(body, v)
+	if err != nil && res.StatusCode == http.StatusOK {
+		return 0, err
+	}
+	return res.StatusCode, nil
+}
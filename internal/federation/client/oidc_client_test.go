@@ -0,0 +1,161 @@
+package client_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/famkampm/nentrytask/internal/federation"
+	"github.com/famkampm/nentrytask/internal/federation/client"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockIdP is a local identity provider answering discovery, JWKS and token
+// requests with whatever ID token claims the test sets
+type mockIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+	form   url.Values
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	idp := &mockIdP{key: key}
+	mux := http.NewServeMux()
+	idp.server = httptest.NewServer(mux)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&auth.JWKSet{Keys: []auth.JWK{{
+			KeyType:   "RSA",
+			KeyID:     "k1",
+			Use:       "sig",
+			Algorithm: "RS256",
+			N:         base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		idp.form = r.PostForm
+		if r.PostForm.Get("code") != "good-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, idp.claims)
+		token.Header["kid"] = "k1"
+		idToken, err := token.SignedString(key)
+		assert.NoError(t, err)
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idToken, "access_token": "at", "token_type": "Bearer"})
+	})
+	return idp
+}
+
+func (idp *mockIdP) provider() *models.IdentityProvider {
+	return &models.IdentityProvider{
+		Name:         "mock",
+		Issuer:       idp.server.URL,
+		ClientID:     "app",
+		ClientSecret: "secret",
+		RedirectURI:  "http://localhost:3000/callback",
+		Scopes:       []string{"openid", "email"},
+	}
+}
+
+func (idp *mockIdP) validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"aud":            "app",
+		"sub":            "sub-1",
+		"nonce":          "n-1",
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"email":          "alice@example.com",
+		"email_verified": "true",
+		"name":           "Alice",
+	}
+}
+
+func TestAuthorizationURL(t *testing.T) {
+	idp := newMockIdP(t)
+	defer idp.server.Close()
+	c := client.NewOIDCClient(nil)
+
+	res, err := c.AuthorizationURL(context.TODO(), idp.provider(), "st", "n-1", "challenge")
+	assert.NoError(t, err)
+	u, err := url.Parse(res)
+	assert.NoError(t, err)
+	assert.Equal(t, idp.server.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	query := u.Query()
+	assert.Equal(t, "code", query.Get("response_type"))
+	assert.Equal(t, "app", query.Get("client_id"))
+	assert.Equal(t, "openid email", query.Get("scope"))
+	assert.Equal(t, "st", query.Get("state"))
+	assert.Equal(t, "n-1", query.Get("nonce"))
+	assert.Equal(t, "challenge", query.Get("code_challenge"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+}
+
+func TestExchange(t *testing.T) {
+	idp := newMockIdP(t)
+	defer idp.server.Close()
+	idp.claims = idp.validClaims()
+	c := client.NewOIDCClient(nil)
+
+	claims, err := c.Exchange(context.TODO(), idp.provider(), "good-code", "verifier", "n-1")
+	assert.NoError(t, err)
+	assert.Equal(t, &models.ExternalClaims{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}, claims)
+	assert.Equal(t, "verifier", idp.form.Get("code_verifier"))
+	assert.Equal(t, "http://localhost:3000/callback", idp.form.Get("redirect_uri"))
+
+	_, err = c.Exchange(context.TODO(), idp.provider(), "bad-code", "verifier", "n-1")
+	assert.Equal(t, federation.ErrInvalidIDToken, err)
+}
+
+func TestExchangeRejectsToken(t *testing.T) {
+	idp := newMockIdP(t)
+	defer idp.server.Close()
+	c := client.NewOIDCClient(nil)
+
+	cases := map[string]func(claims jwt.MapClaims){
+		"nonce":        func(claims jwt.MapClaims) { claims["nonce"] = "n-2" },
+		"issuer":       func(claims jwt.MapClaims) { claims["iss"] = "https://evil.local" },
+		"audience":     func(claims jwt.MapClaims) { claims["aud"] = "other" },
+		"no azp":       func(claims jwt.MapClaims) { claims["aud"] = []string{"app", "other"} },
+		"expired":      func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() },
+		"no subject":   func(claims jwt.MapClaims) { delete(claims, "sub") },
+		"other client": func(claims jwt.MapClaims) { claims["aud"] = []string{"app", "other"}; claims["azp"] = "other" },
+	}
+	for name, change := range cases {
+		idp.claims = idp.validClaims()
+		change(idp.claims)
+		_, err := c.Exchange(context.TODO(), idp.provider(), "good-code", "verifier", "n-1")
+		assert.Equal(t, federation.ErrInvalidIDToken, err, name)
+	}
+
+	// SEVERAL AUDIENCES ARE FINE WHEN WE ARE THE AUTHORIZED PARTY
+	idp.claims = idp.validClaims()
+	idp.claims["aud"] = []string{"app", "other"}
+	idp.claims["azp"] = "app"
+	_, err := c.Exchange(context.TODO(), idp.provider(), "good-code", "verifier", "n-1")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,279 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/audit"
+	"github.com/famkampm/nentrytask/internal/federation"
+	"github.com/famkampm/nentrytask/internal/mfa"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/session"
+	"github.com/famkampm/nentrytask/internal/user"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/famkampm/nentrytask/pkg/middlewares"
+	"github.com/famkampm/nentrytask/pkg/responses"
+	"github.com/julienschmidt/httprouter"
+)
+
+type FederationHandler struct {
+	Router            *httprouter.Router
+	FederationUsecase federation.Usecase
+	MFAUsecase        mfa.Usecase
+	SessionUsecase    session.Usecase
+	AuditUsecase      audit.Usecase
+}
+
+type providersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+type startResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// callbackRequest carries what the provider redirected back with
+type callbackRequest struct {
+	State string `json:"state"`
+	Code  string `json:"code"`
+}
+
+// mfaRequiredResponse is the callback answer for users with 2FA enabled, the
+// login is finished at /login/mfa
+type mfaRequiredResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// NewFederationHandler registers the login with external identity providers.
+// The login page asks for the provider url, sends the user there and posts
+// the state and code the provider redirected back with to the callback.
+func NewFederationHandler(router *httprouter.Router, us federation.Usecase, mfaUsecase mfa.Usecase, sessionUsecase session.Usecase, auditUsecase audit.Usecase, mw *middlewares.Middleware) {
+	handler := &FederationHandler{
+		Router:            router,
+		FederationUsecase: us,
+		MFAUsecase:        mfaUsecase,
+		SessionUsecase:    sessionUsecase,
+		AuditUsecase:      auditUsecase,
+	}
+	handler.Router.GET("/login/providers", middlewares.SetMiddlewareJSON(handler.Providers))
+	handler.Router.POST("/login/federated/:provider", middlewares.SetMiddlewareJSON(handler.Start))
+	handler.Router.POST("/login/federated/:provider/callback", middlewares.SetMiddlewareJSON(handler.Callback))
+	handler.Router.GET("/profile/:id/identities", middlewares.SetMiddlewareJSON(mw.SetMiddlewareSelfOrPermission(auth.PermissionProfileRead)(handler.FetchIdentities)))
+	handler.Router.POST("/profile/:id/identities/:provider", middlewares.SetMiddlewareJSON(mw.SetMiddlewareAuthentication(handler.Link)))
+	handler.Router.DELETE("/profile/:id/identities/:provider", middlewares.SetMiddlewareJSON(mw.SetMiddlewareAuthentication(handler.Unlink)))
+}
+
+func (h *FederationHandler) Providers(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	responses.JSON(w, http.StatusOK, &providersResponse{Providers: h.FederationUsecase.Providers()})
+}
+
+func (h *FederationHandler) Start(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.start(w, ps.ByName("provider"), 0)
+}
+
+// Link starts a login at the provider whose identity gets linked to the
+// account of the caller
+func (h *FederationHandler) Link(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user_id, err := strconv.ParseInt(ps.ByName("id"), 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, errors.New(http.StatusText(http.StatusBadRequest)))
+		return
+	}
+	h.start(w, ps.ByName("provider"), user_id)
+}
+
+func (h *FederationHandler) start(w http.ResponseWriter, provider string, linkUserID int64) {
+	authorizationURL, err := h.FederationUsecase.Start(context.TODO(), provider, linkUserID)
+	if err == federation.ErrUnknownProvider {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		responses.ERROR(w, http.StatusBadGateway, errors.New(http.StatusText(http.StatusBadGateway)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, &startResponse{AuthorizationURL: authorizationURL})
+}
+
+// Callback logs the user in like /login does, or answers with the identity
+// when the login was started to link it
+func (h *FederationHandler) Callback(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	defer r.Body.Close()
+	req := &callbackRequest{}
+	err = json.Unmarshal(body, req)
+	if err != nil || req.State == "" || req.Code == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Required State And Code"))
+		return
+	}
+	provider := ps.ByName("provider")
+	login, err := h.FederationUsecase.Callback(context.TODO(), provider, req.State, req.Code)
+	switch err {
+	case nil:
+	case federation.ErrUnknownProvider:
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	case federation.ErrInvalidState, federation.ErrInvalidIDToken:
+		responses.ERROR(w, http.StatusUnauthorized, err)
+		return
+	case federation.ErrNoLinkedAccount:
+		h.recordAudit(r, 0, audit.ActionLogin, audit.ResultFailure, fmt.Sprintf("federated %s: %s", provider, err.Error()))
+		responses.ERROR(w, http.StatusForbidden, err)
+		return
+	case federation.ErrIdentityInUse, federation.ErrAlreadyLinked:
+		responses.ERROR(w, http.StatusConflict, err)
+		return
+	default:
+		log.Println("federated login callback err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	if login.LinkOnly {
+		if login.Linked {
+			h.recordAudit(r, login.User.ID, audit.ActionIdentityLink, audit.ResultSuccess, fmt.Sprintf("provider %s", provider))
+		}
+		responses.JSON(w, http.StatusOK, login.Identity)
+		return
+	}
+	h.login(w, r, provider, login)
+}
+
+func (h *FederationHandler) login(w http.ResponseWriter, r *http.Request, provider string, login *models.FederatedLogin) {
+	usr := login.User
+	if login.Linked {
+		h.recordAudit(r, usr.ID, audit.ActionIdentityLink, audit.ResultSuccess, fmt.Sprintf("provider %s", provider))
+	}
+	err := user.CheckStatus(usr, time.Now())
+	if err != nil {
+		h.recordAudit(r, usr.ID, audit.ActionLogin, audit.ResultFailure, fmt.Sprintf("username %s: %s", usr.Username, err.Error()))
+		if err == user.ErrAccountSuspended {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(usr.SuspendedUntil.Time).Seconds()))))
+		}
+		responses.ERROR(w, http.StatusForbidden, err)
+		return
+	}
+	// THE SECOND FACTOR OF THE ACCOUNT STILL APPLIES, WHOEVER VOUCHED FOR THE FIRST
+	mfaEnabled, err := h.MFAUsecase.IsEnabled(context.TODO(), usr.ID)
+	if err != nil {
+		log.Println("federated login check mfa err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	if mfaEnabled {
+		mfaToken, err := auth.CreateMFAPendingToken(usr.ID)
+		if err != nil {
+			responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+			return
+		}
+		responses.JSON(w, http.StatusOK, &mfaRequiredResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+			ExpiresIn:   int64(auth.MFAPendingTokenTTL().Seconds()),
+		})
+		return
+	}
+	tokenPair, err := h.startSession(r, usr)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	method := "federated " + provider
+	if login.Created {
+		method += ", account created"
+	}
+	h.recordAudit(r, usr.ID, audit.ActionLogin, audit.ResultSuccess, fmt.Sprintf("username %s: %s", usr.Username, method))
+	responses.JSON(w, http.StatusOK, tokenPair)
+}
+
+// startSession records a session for the device of r and issues its first
+// token pair
+func (h *FederationHandler) startSession(r *http.Request, usr *models.User) (*auth.TokenPair, error) {
+	sess := &models.Session{
+		ID:        helper.RandToken(16),
+		UserID:    usr.ID,
+		UserAgent: r.UserAgent(),
+		IP:        helper.ClientIP(r),
+	}
+	tokenPair, err := auth.CreateSessionTokenPair(usr.ID, sess.ID, usr.Role)
+	if err != nil {
+		return nil, err
+	}
+	err = h.SessionUsecase.Start(context.TODO(), sess)
+	if err != nil {
+		log.Println("start session err:", err.Error())
+		return nil, err
+	}
+	return tokenPair, nil
+}
+
+func (h *FederationHandler) FetchIdentities(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user_id, err := strconv.ParseInt(ps.ByName("id"), 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, errors.New(http.StatusText(http.StatusBadRequest)))
+		return
+	}
+	identities, err := h.FederationUsecase.FetchIdentities(context.TODO(), user_id)
+	if err != nil {
+		log.Println("fetch identities err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, identities)
+}
+
+func (h *FederationHandler) Unlink(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user_id, err := strconv.ParseInt(ps.ByName("id"), 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, errors.New(http.StatusText(http.StatusBadRequest)))
+		return
+	}
+	err = h.FederationUsecase.Unlink(context.TODO(), user_id, ps.ByName("provider"))
+	if err == federation.ErrIdentityNotFound {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("unlink identity err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	h.recordAudit(r, user_id, audit.ActionIdentityUnlink, audit.ResultSuccess, fmt.Sprintf("provider %s", ps.ByName("provider")))
+	responses.JSON(w, http.StatusOK, "Identity Unlinked")
+}
+
+// recordAudit writes an entry about targetID to the audit log. A failed write
+// is logged but never fails the request.
+func (h *FederationHandler) recordAudit(r *http.Request, targetID int64, action, result, detail string) {
+	entry := &models.AuditEntry{
+		ActorID:      targetID,
+		TargetUserID: targetID,
+		Action:       action,
+		IP:           helper.ClientIP(r),
+		UserAgent:    r.UserAgent(),
+		Result:       result,
+		Detail:       detail,
+	}
+	if claims, ok := auth.FromContext(r.Context()); ok {
+		entry.ActorID = claims.UserID
+	}
+	err := h.AuditUsecase.Record(context.TODO(), entry)
+	if err != nil {
+		log.Println("record audit entry err:", err.Error())
+	}
+}
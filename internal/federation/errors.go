@@ -0,0 +1,13 @@
+package federation
+
+import "errors"
+
+var (
+	ErrUnknownProvider  = errors.New("Unknown Identity Provider")
+	ErrInvalidState     = errors.New("Invalid Or Expired Login State")
+	ErrInvalidIDToken   = errors.New("Invalid ID Token From Identity Provider")
+	ErrNoLinkedAccount  = errors.New("No Account Linked To This Identity")
+	ErrIdentityInUse    = errors.New("Identity Already Linked To Another Account")
+	ErrAlreadyLinked    = errors.New("Account Already Linked To This Provider")
+	ErrIdentityNotFound = errors.New("Identity Not Found")
+)
@@ -0,0 +1,68 @@
+package federation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+
+	"github.com/famkampm/nentrytask/internal/models"
+)
+
+// providerName is used in urls and stored with every identity
+var providerName = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+var defaultScopes = []string{"openid", "email", "profile"}
+
+// LoadProviders reads the identity providers from a JSON array of
+// models.IdentityProvider. No path means no providers.
+func LoadProviders(path string) (map[string]*models.IdentityProvider, error) {
+	providers := map[string]*models.IdentityProvider{}
+	if path == "" {
+		return providers, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	list := []*models.IdentityProvider{}
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, err
+	}
+	for _, provider := range list {
+		if !providerName.MatchString(provider.Name) {
+			return nil, fmt.Errorf("invalid identity provider name %q", provider.Name)
+		}
+		if providers[provider.Name] != nil {
+			return nil, fmt.Errorf("duplicate identity provider %q", provider.Name)
+		}
+		if provider.ClientID == "" {
+			return nil, fmt.Errorf("identity provider %q has no client_id", provider.Name)
+		}
+		for _, uri := range []string{provider.Issuer, provider.RedirectURI} {
+			u, err := url.Parse(uri)
+			if err != nil || !u.IsAbs() {
+				return nil, fmt.Errorf("identity provider %q has an invalid url %q", provider.Name, uri)
+			}
+		}
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = defaultScopes
+		}
+		if !containsScope(provider.Scopes, "openid") {
+			provider.Scopes = append([]string{"openid"}, provider.Scopes...)
+		}
+		providers[provider.Name] = provider
+	}
+	return providers, nil
+}
+
+func containsScope(scopes []string, want string) bool {
+	for _, scope := range scopes {
+		if scope == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package federation_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/famkampm/nentrytask/internal/federation"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeProviders(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "federation")
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "providers.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoadProviders(t *testing.T) {
+	providers, err := federation.LoadProviders("")
+	assert.NoError(t, err)
+	assert.Empty(t, providers)
+
+	path := writeProviders(t, `[{"name":"google","issuer":"https://accounts.google.com","client_id":"id","client_secret":"s","redirect_uri":"http://localhost:3000/callback"},{"name":"corp","issuer":"https://sso.corp.local","client_id":"app","redirect_uri":"http://localhost:3000/callback","scopes":["email"],"auto_create":true}]`)
+	defer os.RemoveAll(filepath.Dir(path))
+	providers, err = federation.LoadProviders(path)
+	assert.NoError(t, err)
+	assert.Len(t, providers, 2)
+	assert.Equal(t, []string{"openid", "email", "profile"}, providers["google"].Scopes)
+	assert.Equal(t, []string{"openid", "email"}, providers["corp"].Scopes)
+	assert.True(t, providers["corp"].AutoCreate)
+}
+
+func TestLoadProvidersInvalid(t *testing.T) {
+	for _, content := range []string{
+		`[{"name":"Google","issuer":"https://accounts.google.com","client_id":"id","redirect_uri":"http://localhost/cb"}]`,
+		`[{"name":"google","issuer":"https://accounts.google.com","redirect_uri":"http://localhost/cb"}]`,
+		`[{"name":"google","issuer":"accounts.google.com","client_id":"id","redirect_uri":"http://localhost/cb"}]`,
+		`[{"name":"google","issuer":"https://accounts.google.com","client_id":"id","redirect_uri":"/cb"}]`,
+		`[{"name":"google","issuer":"https://a","client_id":"id","redirect_uri":"http://localhost/cb"},{"name":"google","issuer":"https://b","client_id":"id","redirect_uri":"http://localhost/cb"}]`,
+	} {
+		path := writeProviders(t, content)
+		_, err := federation.LoadProviders(path)
+		assert.NotNil(t, err, content)
+		os.RemoveAll(filepath.Dir(path))
+	}
+}
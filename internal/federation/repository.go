@@ -0,0 +1,34 @@
+package federation
+
+import (
+	"context"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/models"
+)
+
+// Repository stores the external identities, one per user and provider
+type Repository interface {
+	GetByProviderSubject(ctx context.Context, provider, subject string) (*models.ExternalIdentity, error)
+	FetchByUserID(ctx context.Context, userID int64) ([]*models.ExternalIdentity, error)
+	Store(ctx context.Context, identity *models.ExternalIdentity) error
+	Delete(ctx context.Context, userID int64, provider string) (bool, error)
+	DeleteByUserID(ctx context.Context, userID int64) error
+}
+
+// StateRepository keeps the login state by the hash of the state parameter
+type StateRepository interface {
+	Store(ctx context.Context, stateHash string, state *models.FederatedLoginState, ttl time.Duration) error
+	// Consume returns the state and deletes it, ErrInvalidState when it is
+	// unknown, expired or already used
+	Consume(ctx context.Context, stateHash string) (*models.FederatedLoginState, error)
+}
+
+// Client speaks OpenID Connect to the providers
+type Client interface {
+	// AuthorizationURL is where the user is sent to log in at provider
+	AuthorizationURL(ctx context.Context, provider *models.IdentityProvider, state, nonce, codeChallenge string) (string, error)
+	// Exchange trades a code for the claims of the verified ID token, which
+	// has to carry nonce
+	Exchange(ctx context.Context, provider *models.IdentityProvider, code, codeVerifier, nonce string) (*models.ExternalClaims, error)
+}
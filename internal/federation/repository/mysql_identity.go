@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+
+	"github.com/famkampm/nentrytask/internal/federation"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysqlErrDuplicateEntry is returned when an insert breaks a unique index
+const mysqlErrDuplicateEntry = 1062
+
+const identityColumns = `id, user_id, provider, subject, email, created_at`
+
+type mysqlIdentityRepository struct {
+	DB *sql.DB
+}
+
+func NewMysqlIdentityRepository(db *sql.DB) federation.Repository {
+	return &mysqlIdentityRepository{
+		DB: db,
+	}
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanIdentity(row scanner) (*models.ExternalIdentity, error) {
+	identity := &models.ExternalIdentity{}
+	err := row.Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+func (m *mysqlIdentityRepository) GetByProviderSubject(ctx context.Context, provider, subject string) (*models.ExternalIdentity, error) {
+	query := `select ` + identityColumns + ` from user_identity where provider = ? and subject = ?`
+	identity, err := scanIdentity(m.DB.QueryRowContext(ctx, query, provider, subject))
+	if err != nil {
+		return &models.ExternalIdentity{}, err
+	}
+	return identity, nil
+}
+
+func (m *mysqlIdentityRepository) FetchByUserID(ctx context.Context, userID int64) ([]*models.ExternalIdentity, error) {
+	query := `select ` + identityColumns + ` from user_identity where user_id = ? order by id`
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.Println("fetch identities err:", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	identities := []*models.ExternalIdentity{}
+	for rows.Next() {
+		identity, err := scanIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+// Store relies on the unique indexes, so two logins racing to link the same
+// identity can't both get it
+func (m *mysqlIdentityRepository) Store(ctx context.Context, identity *models.ExternalIdentity) error {
+	query := `insert into user_identity (user_id, provider, subject, email, created_at) values (?, ?, ?, ?, ?)`
+	res, err := m.DB.ExecContext(ctx, query, identity.UserID, identity.Provider, identity.Subject, identity.Email, identity.CreatedAt)
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == mysqlErrDuplicateEntry {
+		if strings.Contains(mysqlErr.Message, "user_provider") {
+			return federation.ErrAlreadyLinked
+		}
+		return federation.ErrIdentityInUse
+	}
+	if err != nil {
+		log.Println("store identity err:", err.Error())
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	identity.ID = id
+	return nil
+}
+
+func (m *mysqlIdentityRepository) Delete(ctx context.Context, userID int64, provider string) (bool, error) {
+	query := `delete from user_identity where user_id = ? and provider = ?`
+	res, err := m.DB.ExecContext(ctx, query, userID, provider)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (m *mysqlIdentityRepository) DeleteByUserID(ctx context.Context, userID int64) error {
+	query := `delete from user_identity where user_id = ?`
+	_, err := m.DB.ExecContext(ctx, query, userID)
+	return err
+}
@@ -0,0 +1,82 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/famkampm/nentrytask/internal/federation"
+	"github.com/famkampm/nentrytask/internal/federation/repository"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestGetByProviderSubjectMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	now := time.Now().UTC()
+	rows := sqlmock.NewRows([]string{"id", "user_id", "provider", "subject", "email", "created_at"}).
+		AddRow(1, 7, "google", "sub-1", "alice@example.com", now)
+	mock.ExpectQuery("select (.+) from user_identity where provider = (.+) and subject").WithArgs("google", "sub-1").WillReturnRows(rows)
+	m := repository.NewMysqlIdentityRepository(db)
+	res, err := m.GetByProviderSubject(context.TODO(), "google", "sub-1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), res.UserID)
+	assert.Equal(t, "alice@example.com", res.Email.String)
+}
+
+func TestStoreIdentityMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	now := time.Now().UTC()
+	mock.ExpectExec("insert into user_identity").WithArgs(7, "google", "sub-1", null.StringFrom("alice@example.com"), now).WillReturnResult(sqlmock.NewResult(3, 1))
+	m := repository.NewMysqlIdentityRepository(db)
+	identity := &models.ExternalIdentity{UserID: 7, Provider: "google", Subject: "sub-1", Email: null.StringFrom("alice@example.com"), CreatedAt: now}
+	assert.NoError(t, m.Store(context.TODO(), identity))
+	assert.Equal(t, int64(3), identity.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStoreIdentityDuplicateMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("insert into user_identity").WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'google-sub-1' for key 'provider_subject'"})
+	mock.ExpectExec("insert into user_identity").WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '7-google' for key 'user_provider'"})
+	m := repository.NewMysqlIdentityRepository(db)
+	identity := &models.ExternalIdentity{UserID: 7, Provider: "google", Subject: "sub-1"}
+	assert.Equal(t, federation.ErrIdentityInUse, m.Store(context.TODO(), identity))
+	assert.Equal(t, federation.ErrAlreadyLinked, m.Store(context.TODO(), identity))
+}
+
+func TestDeleteIdentityMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("delete from user_identity where user_id = (.+) and provider").WithArgs(7, "google").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("delete from user_identity where user_id = (.+) and provider").WithArgs(7, "github").WillReturnResult(sqlmock.NewResult(0, 0))
+	m := repository.NewMysqlIdentityRepository(db)
+	deleted, err := m.Delete(context.TODO(), int64(7), "google")
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = m.Delete(context.TODO(), int64(7), "github")
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+}
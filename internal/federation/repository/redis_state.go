@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/federation"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/gomodule/redigo/redis"
+)
+
+type redisStateRepository struct {
+	RedisPool *redis.Pool
+}
+
+func NewRedisStateRepository(redisPool *redis.Pool) federation.StateRepository {
+	return &redisStateRepository{
+		RedisPool: redisPool,
+	}
+}
+
+func loginStateKey(stateHash string) string {
+	return "federated_login:" + stateHash
+}
+
+func (r *redisStateRepository) Store(ctx context.Context, stateHash string, state *models.FederatedLoginState, ttl time.Duration) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	_, err = conn.Do("SET", loginStateKey(stateHash), data, "PX", ttl.Nanoseconds()/int64(time.Millisecond))
+	return err
+}
+
+// Consume reads and deletes the state in the same transaction, so a callback
+// can't be replayed
+func (r *redisStateRepository) Consume(ctx context.Context, stateHash string) (*models.FederatedLoginState, error) {
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	conn.Send("MULTI")
+	conn.Send("GET", loginStateKey(stateHash))
+	conn.Send("DEL", loginStateKey(stateHash))
+	values, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return nil, err
+	}
+	if values[0] == nil {
+		return nil, federation.ErrInvalidState
+	}
+	data, err := redis.Bytes(values[0], nil)
+	if err != nil {
+		return nil, err
+	}
+	state := &models.FederatedLoginState{}
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
@@ -0,0 +1,53 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/famkampm/nentrytask/internal/federation"
+	"github.com/famkampm/nentrytask/internal/federation/repository"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPool(t *testing.T) (*miniredis.Miniredis, *redis.Pool) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when starting miniredis", err)
+	}
+	addr := s.Addr()
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr)
+		},
+	}
+	return s, pool
+}
+
+func TestConsumeStateOnceRedis(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := repository.NewRedisStateRepository(pool)
+
+	state := &models.FederatedLoginState{Provider: "google", Nonce: "n-1", CodeVerifier: "verifier", LinkUserID: 7}
+	assert.NoError(t, r.Store(context.TODO(), "hash1", state, time.Minute))
+	res, err := r.Consume(context.TODO(), "hash1")
+	assert.NoError(t, err)
+	assert.Equal(t, state, res)
+	_, err = r.Consume(context.TODO(), "hash1")
+	assert.Equal(t, federation.ErrInvalidState, err)
+}
+
+func TestConsumeExpiredStateRedis(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := repository.NewRedisStateRepository(pool)
+
+	assert.NoError(t, r.Store(context.TODO(), "hash1", &models.FederatedLoginState{Provider: "google"}, time.Minute))
+	s.FastForward(time.Minute)
+	_, err := r.Consume(context.TODO(), "hash1")
+	assert.Equal(t, federation.ErrInvalidState, err)
+}
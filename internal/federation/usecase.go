@@ -0,0 +1,22 @@
+package federation
+
+import (
+	"context"
+
+	"github.com/famkampm/nentrytask/internal/models"
+)
+
+type Usecase interface {
+	// Providers lists the names of the configured providers
+	Providers() []string
+	// Start returns the provider URL to send the user to. linkUserID is the
+	// logged in user linking an identity, 0 to log in.
+	Start(ctx context.Context, provider string, linkUserID int64) (string, error)
+	// Callback finishes what Start began with the state and code provider
+	// redirected back with
+	Callback(ctx context.Context, provider, state, code string) (*models.FederatedLogin, error)
+	FetchIdentities(ctx context.Context, userID int64) ([]*models.ExternalIdentity, error)
+	Unlink(ctx context.Context, userID int64, provider string) error
+	// Erase drops every identity of the user, for account purges
+	Erase(ctx context.Context, userID int64) error
+}
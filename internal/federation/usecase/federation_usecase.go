@@ -0,0 +1,292 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/federation"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/user"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"gopkg.in/guregu/null.v3"
+)
+
+const (
+	maxUsernameCandidateLength = 32
+	usernameAttempts           = 5
+	usernameSuffixBytes        = 2
+)
+
+var (
+	errNoFreeUsername = errors.New("No Free Username")
+	notUsernameChar   = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+)
+
+type federationUsecase struct {
+	userUsecase  user.Usecase
+	identityRepo federation.Repository
+	stateRepo    federation.StateRepository
+	client       federation.Client
+	providers    map[string]*models.IdentityProvider
+	stateTTL     time.Duration
+}
+
+func NewFederationUsecase(us user.Usecase, repo federation.Repository, stateRepo federation.StateRepository, client federation.Client, providers map[string]*models.IdentityProvider, stateTTL time.Duration) federation.Usecase {
+	return &federationUsecase{
+		userUsecase:  us,
+		identityRepo: repo,
+		stateRepo:    stateRepo,
+		client:       client,
+		providers:    providers,
+		stateTTL:     stateTTL,
+	}
+}
+
+func (f *federationUsecase) Providers() []string {
+	names := make([]string, 0, len(f.providers))
+	for name := range f.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f *federationUsecase) Start(ctx context.Context, providerName string, linkUserID int64) (string, error) {
+	provider := f.providers[providerName]
+	if provider == nil {
+		return "", federation.ErrUnknownProvider
+	}
+	state := helper.RandToken(32)
+	loginState := &models.FederatedLoginState{
+		Provider:     provider.Name,
+		Nonce:        helper.RandToken(16),
+		CodeVerifier: helper.RandToken(32),
+		LinkUserID:   linkUserID,
+	}
+	authorizationURL, err := f.client.AuthorizationURL(ctx, provider, state, loginState.Nonce, codeChallenge(loginState.CodeVerifier))
+	if err != nil {
+		log.Println("federated login authorization url err:", err.Error())
+		return "", err
+	}
+	err = f.stateRepo.Store(ctx, helper.HashToken(state), loginState, f.stateTTL)
+	if err != nil {
+		log.Println("federated login store state err:", err.Error())
+		return "", err
+	}
+	return authorizationURL, nil
+}
+
+func (f *federationUsecase) Callback(ctx context.Context, providerName, state, code string) (*models.FederatedLogin, error) {
+	if state == "" || code == "" {
+		return nil, federation.ErrInvalidState
+	}
+	loginState, err := f.stateRepo.Consume(ctx, helper.HashToken(state))
+	if err != nil {
+		return nil, err
+	}
+	// THE STATE OF ONE PROVIDER IS NO GOOD AT ANOTHER
+	if loginState.Provider != providerName {
+		return nil, federation.ErrInvalidState
+	}
+	provider := f.providers[providerName]
+	if provider == nil {
+		return nil, federation.ErrUnknownProvider
+	}
+	claims, err := f.client.Exchange(ctx, provider, code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := f.identityRepo.GetByProviderSubject(ctx, provider.Name, claims.Subject)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("federated login get identity err:", err.Error())
+		return nil, err
+	}
+	known := err == nil
+	if loginState.LinkUserID != 0 {
+		return f.link(ctx, provider, claims, loginState.LinkUserID, identity, known)
+	}
+	if known {
+		usr, err := f.userUsecase.GetByID(ctx, identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		return &models.FederatedLogin{User: usr, Identity: identity}, nil
+	}
+	return f.firstLogin(ctx, provider, claims)
+}
+
+// link adds the identity to the account of userID, who started the login to
+// do so. Linking it again is fine, taking it from another account is not.
+func (f *federationUsecase) link(ctx context.Context, provider *models.IdentityProvider, claims *models.ExternalClaims, userID int64, identity *models.ExternalIdentity, known bool) (*models.FederatedLogin, error) {
+	if known && identity.UserID != userID {
+		return nil, federation.ErrIdentityInUse
+	}
+	usr, err := f.userUsecase.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if known {
+		return &models.FederatedLogin{User: usr, Identity: identity, LinkOnly: true}, nil
+	}
+	identity, err = f.storeIdentity(ctx, provider, claims, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.FederatedLogin{User: usr, Identity: identity, Linked: true, LinkOnly: true}, nil
+}
+
+// firstLogin links an identity nothing links to yet to the account with the
+// same verified email, or creates an account for it, as far as the provider
+// allows either. The email has to be verified on both sides, anyone can
+// register a pending account with someone else's address.
+func (f *federationUsecase) firstLogin(ctx context.Context, provider *models.IdentityProvider, claims *models.ExternalClaims) (*models.FederatedLogin, error) {
+	if provider.LinkByEmail && claims.EmailVerified && claims.Email != "" {
+		fetched, err := f.userUsecase.FetchByEmail(ctx, claims.Email)
+		if err != nil {
+			log.Println("federated login fetch users by email err:", err.Error())
+			return nil, err
+		}
+		users := []*models.User{}
+		for _, usr := range fetched {
+			if usr.Status != user.StatusPendingVerification {
+				users = append(users, usr)
+			}
+		}
+		// SEVERAL ACCOUNTS SHARING THE EMAIL CAN'T TELL US WHICH ONE IT IS
+		if len(users) == 1 {
+			identity, err := f.storeIdentity(ctx, provider, claims, users[0].ID)
+			if err != nil {
+				return nil, err
+			}
+			return &models.FederatedLogin{User: users[0], Identity: identity, Linked: true}, nil
+		}
+	}
+	if !provider.AutoCreate {
+		return nil, federation.ErrNoLinkedAccount
+	}
+	usr, err := f.createUser(ctx, provider, claims)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := f.storeIdentity(ctx, provider, claims, usr.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.FederatedLogin{User: usr, Identity: identity, Created: true, Linked: true}, nil
+}
+
+func (f *federationUsecase) storeIdentity(ctx context.Context, provider *models.IdentityProvider, claims *models.ExternalClaims, userID int64) (*models.ExternalIdentity, error) {
+	identity := &models.ExternalIdentity{
+		UserID:    userID,
+		Provider:  provider.Name,
+		Subject:   claims.Subject,
+		CreatedAt: time.Now().UTC(),
+	}
+	if claims.Email != "" {
+		identity.Email = null.StringFrom(claims.Email)
+	}
+	err := f.identityRepo.Store(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// createUser registers the account of a first login. Its random password is
+// never handed out, a password can be set later through the password reset.
+func (f *federationUsecase) createUser(ctx context.Context, provider *models.IdentityProvider, claims *models.ExternalClaims) (*models.User, error) {
+	username, err := f.freeUsername(ctx, provider, claims)
+	if err != nil {
+		return nil, err
+	}
+	password, err := helper.HashingPassword(helper.RandToken(32))
+	if err != nil {
+		return nil, err
+	}
+	usr := &models.User{
+		Username: username,
+		Password: password,
+		Role:     auth.RoleUser,
+		Status:   user.StatusActive,
+	}
+	if claims.Name != "" {
+		usr.Nickname = null.StringFrom(claims.Name)
+	}
+	// AN UNVERIFIED EMAIL COULD BELONG TO SOMEONE ELSE
+	if claims.Email != "" && claims.EmailVerified {
+		usr.Email = null.StringFrom(claims.Email)
+	}
+	err = f.userUsecase.Store(ctx, usr)
+	if err != nil {
+		log.Println("federated login create user err:", err.Error())
+		return nil, err
+	}
+	return usr, nil
+}
+
+// freeUsername derives a username from the claims that passes the credential
+// policy and isn't taken yet, adding a random suffix when it is
+func (f *federationUsecase) freeUsername(ctx context.Context, provider *models.IdentityProvider, claims *models.ExternalClaims) (string, error) {
+	candidate := claims.PreferredUsername
+	if candidate == "" {
+		candidate = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	candidate = notUsernameChar.ReplaceAllString(candidate, "")
+	if len(candidate) > maxUsernameCandidateLength {
+		candidate = candidate[:maxUsernameCandidateLength]
+	}
+	if helper.Validate("username", candidate, "") != nil {
+		candidate = provider.Name + "_" + helper.HashToken(claims.Subject)[:8]
+	}
+	username := candidate
+	for i := 0; i < usernameAttempts; i++ {
+		if i > 0 {
+			username = candidate + "_" + helper.RandToken(usernameSuffixBytes)
+		}
+		if helper.Validate("username", username, "") != nil {
+			continue
+		}
+		_, err := f.userUsecase.GetByUsername(ctx, username)
+		if err == sql.ErrNoRows {
+			return username, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", errNoFreeUsername
+}
+
+func (f *federationUsecase) FetchIdentities(ctx context.Context, userID int64) ([]*models.ExternalIdentity, error) {
+	return f.identityRepo.FetchByUserID(ctx, userID)
+}
+
+func (f *federationUsecase) Unlink(ctx context.Context, userID int64, provider string) error {
+	deleted, err := f.identityRepo.Delete(ctx, userID, provider)
+	if err != nil {
+		log.Println("unlink identity err:", err.Error())
+		return err
+	}
+	if !deleted {
+		return federation.ErrIdentityNotFound
+	}
+	return nil
+}
+
+func (f *federationUsecase) Erase(ctx context.Context, userID int64) error {
+	return f.identityRepo.DeleteByUserID(ctx, userID)
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
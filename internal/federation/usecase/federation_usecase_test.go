@@ -0,0 +1,209 @@
+package usecase_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/federation"
+	"github.com/famkampm/nentrytask/internal/federation/mocks"
+	"github.com/famkampm/nentrytask/internal/federation/usecase"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/user"
+	_userMocks "github.com/famkampm/nentrytask/internal/user/mocks"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var providers = map[string]*models.IdentityProvider{
+	"corp":   {Name: "corp", Issuer: "https://sso.corp.local", ClientID: "app", LinkByEmail: true, AutoCreate: true},
+	"google": {Name: "google", Issuer: "https://accounts.google.com", ClientID: "app"},
+}
+
+var loginState = &models.FederatedLoginState{Provider: "corp", Nonce: "n-1", CodeVerifier: "verifier"}
+
+type deps struct {
+	users  *_userMocks.Usecase
+	repo   *mocks.Repository
+	states *mocks.StateRepository
+	client *mocks.Client
+}
+
+func newUsecase() (federation.Usecase, *deps) {
+	d := &deps{
+		users:  new(_userMocks.Usecase),
+		repo:   new(mocks.Repository),
+		states: new(mocks.StateRepository),
+		client: new(mocks.Client),
+	}
+	return usecase.NewFederationUsecase(d.users, d.repo, d.states, d.client, providers, 10*time.Minute), d
+}
+
+// expectCallback lets the state and code through to the claims
+func (d *deps) expectCallback(state *models.FederatedLoginState, claims *models.ExternalClaims) {
+	d.states.On("Consume", mock.Anything, helper.HashToken("state")).Return(state, nil).Once()
+	d.client.On("Exchange", mock.Anything, providers[state.Provider], "code", state.CodeVerifier, state.Nonce).Return(claims, nil).Once()
+}
+
+func TestProvidersUsecase(t *testing.T) {
+	u, _ := newUsecase()
+	assert.Equal(t, []string{"corp", "google"}, u.Providers())
+}
+
+func TestStartUsecase(t *testing.T) {
+	u, d := newUsecase()
+	d.client.On("AuthorizationURL", mock.Anything, providers["corp"], mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return("https://sso.corp.local/authorize?x=1", nil).Once()
+	d.states.On("Store", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("*models.FederatedLoginState"), 10*time.Minute).Return(nil).Once()
+
+	authorizationURL, err := u.Start(context.TODO(), "corp", int64(7))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://sso.corp.local/authorize?x=1", authorizationURL)
+	// THE STATE IS ONLY KEPT HASHED AND REMEMBERS WHO IS LINKING
+	call := d.client.Calls[0]
+	state := call.Arguments.String(2)
+	stored := d.states.Calls[0]
+	assert.Equal(t, helper.HashToken(state), stored.Arguments.String(1))
+	assert.Equal(t, int64(7), stored.Arguments.Get(2).(*models.FederatedLoginState).LinkUserID)
+
+	_, err = u.Start(context.TODO(), "unknown", 0)
+	assert.Equal(t, federation.ErrUnknownProvider, err)
+}
+
+func TestCallbackKnownIdentityUsecase(t *testing.T) {
+	u, d := newUsecase()
+	d.expectCallback(loginState, &models.ExternalClaims{Subject: "sub-1"})
+	d.repo.On("GetByProviderSubject", mock.Anything, "corp", "sub-1").Return(&models.ExternalIdentity{ID: 1, UserID: 7, Provider: "corp", Subject: "sub-1"}, nil).Once()
+	d.users.On("GetByID", mock.Anything, int64(7)).Return(&models.User{ID: 7, Username: "alice"}, nil).Once()
+
+	login, err := u.Callback(context.TODO(), "corp", "state", "code")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), login.User.ID)
+	assert.False(t, login.Created)
+	assert.False(t, login.Linked)
+	d.repo.AssertNotCalled(t, "Store", mock.Anything, mock.Anything)
+}
+
+func TestCallbackLinkByEmailUsecase(t *testing.T) {
+	u, d := newUsecase()
+	d.expectCallback(loginState, &models.ExternalClaims{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true})
+	d.repo.On("GetByProviderSubject", mock.Anything, "corp", "sub-1").Return(&models.ExternalIdentity{}, sql.ErrNoRows).Once()
+	d.users.On("FetchByEmail", mock.Anything, "alice@example.com").Return([]*models.User{{ID: 7, Username: "alice"}}, nil).Once()
+	d.repo.On("Store", mock.Anything, mock.AnythingOfType("*models.ExternalIdentity")).Return(nil).Once()
+
+	login, err := u.Callback(context.TODO(), "corp", "state", "code")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), login.User.ID)
+	assert.True(t, login.Linked)
+	assert.False(t, login.Created)
+	assert.Equal(t, int64(7), login.Identity.UserID)
+	assert.Equal(t, "alice@example.com", login.Identity.Email.String)
+	d.users.AssertNotCalled(t, "Store", mock.Anything, mock.Anything)
+}
+
+func TestCallbackLinkByEmailPendingUsecase(t *testing.T) {
+	u, d := newUsecase()
+	d.expectCallback(loginState, &models.ExternalClaims{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true, PreferredUsername: "alice"})
+	d.repo.On("GetByProviderSubject", mock.Anything, "corp", "sub-1").Return(&models.ExternalIdentity{}, sql.ErrNoRows).Once()
+	// THE PENDING ACCOUNT NEVER PROVED IT OWNS THE EMAIL
+	d.users.On("FetchByEmail", mock.Anything, "alice@example.com").Return([]*models.User{{ID: 7, Username: "alice", Status: user.StatusPendingVerification}}, nil).Once()
+	d.users.On("GetByUsername", mock.Anything, "alice").Return(&models.User{ID: 7}, nil).Once()
+	d.users.On("GetByUsername", mock.Anything, mock.AnythingOfType("string")).Return(&models.User{}, sql.ErrNoRows).Once()
+	d.users.On("Store", mock.Anything, mock.AnythingOfType("*models.User")).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.User).ID = 9
+	}).Once()
+	d.repo.On("Store", mock.Anything, mock.AnythingOfType("*models.ExternalIdentity")).Return(nil).Once()
+
+	login, err := u.Callback(context.TODO(), "corp", "state", "code")
+	assert.NoError(t, err)
+	assert.True(t, login.Created)
+	assert.Equal(t, int64(9), login.User.ID)
+	assert.Equal(t, int64(9), login.Identity.UserID)
+}
+
+func TestCallbackCreateUserUsecase(t *testing.T) {
+	u, d := newUsecase()
+	// THE EMAIL IS NOT VERIFIED, SO IT NEITHER LINKS NOR ENDS UP ON THE ACCOUNT
+	d.expectCallback(loginState, &models.ExternalClaims{Subject: "sub-1", Email: "alice@example.com", PreferredUsername: "alice smith", Name: "Alice"})
+	d.repo.On("GetByProviderSubject", mock.Anything, "corp", "sub-1").Return(&models.ExternalIdentity{}, sql.ErrNoRows).Once()
+	d.users.On("GetByUsername", mock.Anything, "alicesmith").Return(&models.User{ID: 3}, nil).Once()
+	d.users.On("GetByUsername", mock.Anything, mock.AnythingOfType("string")).Return(&models.User{}, sql.ErrNoRows).Once()
+	d.users.On("Store", mock.Anything, mock.AnythingOfType("*models.User")).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.User).ID = 9
+	}).Once()
+	d.repo.On("Store", mock.Anything, mock.AnythingOfType("*models.ExternalIdentity")).Return(nil).Once()
+
+	login, err := u.Callback(context.TODO(), "corp", "state", "code")
+	assert.NoError(t, err)
+	assert.True(t, login.Created)
+	assert.Equal(t, int64(9), login.Identity.UserID)
+	usr := login.User
+	assert.Regexp(t, "^alicesmith_[0-9a-f]+$", usr.Username)
+	assert.Equal(t, "Alice", usr.Nickname.String)
+	assert.False(t, usr.Email.Valid)
+	assert.Equal(t, auth.RoleUser, usr.Role)
+	assert.Equal(t, user.StatusActive, usr.Status)
+	d.users.AssertNotCalled(t, "FetchByEmail", mock.Anything, mock.Anything)
+}
+
+func TestCallbackNoLinkedAccountUsecase(t *testing.T) {
+	u, d := newUsecase()
+	state := &models.FederatedLoginState{Provider: "google", Nonce: "n-1", CodeVerifier: "verifier"}
+	d.expectCallback(state, &models.ExternalClaims{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true})
+	d.repo.On("GetByProviderSubject", mock.Anything, "google", "sub-1").Return(&models.ExternalIdentity{}, sql.ErrNoRows).Once()
+
+	_, err := u.Callback(context.TODO(), "google", "state", "code")
+	assert.Equal(t, federation.ErrNoLinkedAccount, err)
+	d.users.AssertNotCalled(t, "FetchByEmail", mock.Anything, mock.Anything)
+}
+
+func TestCallbackLinkUsecase(t *testing.T) {
+	u, d := newUsecase()
+	state := &models.FederatedLoginState{Provider: "google", Nonce: "n-1", CodeVerifier: "verifier", LinkUserID: 7}
+	d.expectCallback(state, &models.ExternalClaims{Subject: "sub-1"})
+	d.repo.On("GetByProviderSubject", mock.Anything, "google", "sub-1").Return(&models.ExternalIdentity{}, sql.ErrNoRows).Once()
+	d.users.On("GetByID", mock.Anything, int64(7)).Return(&models.User{ID: 7}, nil).Once()
+	d.repo.On("Store", mock.Anything, mock.AnythingOfType("*models.ExternalIdentity")).Return(nil).Once()
+
+	login, err := u.Callback(context.TODO(), "google", "state", "code")
+	assert.NoError(t, err)
+	assert.True(t, login.LinkOnly)
+	assert.True(t, login.Linked)
+	assert.Equal(t, "google", login.Identity.Provider)
+}
+
+func TestCallbackIdentityInUseUsecase(t *testing.T) {
+	u, d := newUsecase()
+	state := &models.FederatedLoginState{Provider: "google", Nonce: "n-1", CodeVerifier: "verifier", LinkUserID: 7}
+	d.expectCallback(state, &models.ExternalClaims{Subject: "sub-1"})
+	d.repo.On("GetByProviderSubject", mock.Anything, "google", "sub-1").Return(&models.ExternalIdentity{UserID: 8, Provider: "google", Subject: "sub-1"}, nil).Once()
+
+	_, err := u.Callback(context.TODO(), "google", "state", "code")
+	assert.Equal(t, federation.ErrIdentityInUse, err)
+}
+
+func TestCallbackInvalidStateUsecase(t *testing.T) {
+	u, d := newUsecase()
+	d.states.On("Consume", mock.Anything, helper.HashToken("state")).Return(loginState, nil).Once()
+	d.states.On("Consume", mock.Anything, helper.HashToken("used")).Return(nil, federation.ErrInvalidState).Once()
+
+	// THE STATE WAS ISSUED FOR corp
+	_, err := u.Callback(context.TODO(), "google", "state", "code")
+	assert.Equal(t, federation.ErrInvalidState, err)
+	_, err = u.Callback(context.TODO(), "corp", "used", "code")
+	assert.Equal(t, federation.ErrInvalidState, err)
+	_, err = u.Callback(context.TODO(), "corp", "", "code")
+	assert.Equal(t, federation.ErrInvalidState, err)
+	d.client.AssertNotCalled(t, "Exchange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUnlinkUsecase(t *testing.T) {
+	u, d := newUsecase()
+	d.repo.On("Delete", mock.Anything, int64(7), "google").Return(true, nil).Once()
+	d.repo.On("Delete", mock.Anything, int64(7), "corp").Return(false, nil).Once()
+
+	assert.NoError(t, u.Unlink(context.TODO(), int64(7), "google"))
+	assert.Equal(t, federation.ErrIdentityNotFound, u.Unlink(context.TODO(), int64(7), "corp"))
+}
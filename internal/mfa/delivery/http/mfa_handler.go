@@ -0,0 +1,184 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/mfa"
+	"github.com/famkampm/nentrytask/internal/throttle"
+	"github.com/famkampm/nentrytask/internal/user"
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/famkampm/nentrytask/pkg/middlewares"
+	"github.com/famkampm/nentrytask/pkg/responses"
+	"github.com/julienschmidt/httprouter"
+)
+
+type MFAHandler struct {
+	Router          *httprouter.Router
+	MFAUsecase      mfa.Usecase
+	UserUsecase     user.Usecase
+	ThrottleUsecase throttle.Usecase
+}
+
+type confirmRequest struct {
+	Code string `json:"code"`
+}
+
+type disableRequest struct {
+	CurrentPassword string `json:"current_password"`
+	Code            string `json:"code"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func NewMFAHandler(router *httprouter.Router, us mfa.Usecase, userUsecase user.Usecase, throttleUsecase throttle.Usecase, mw *middlewares.Middleware) {
+	handler := &MFAHandler{
+		Router:          router,
+		MFAUsecase:      us,
+		UserUsecase:     userUsecase,
+		ThrottleUsecase: throttleUsecase,
+	}
+	handler.Router.POST("/mfa/enroll/:id", middlewares.SetMiddlewareJSON(mw.SetMiddlewareAuthentication(handler.Enroll)))
+	handler.Router.POST("/mfa/confirm/:id", middlewares.SetMiddlewareJSON(mw.SetMiddlewareAuthentication(handler.Confirm)))
+	handler.Router.POST("/mfa/disable/:id", middlewares.SetMiddlewareJSON(mw.SetMiddlewareAuthentication(handler.Disable)))
+}
+
+// Enroll creates a new TOTP secret. 2FA is not enforced until it is confirmed.
+func (h *MFAHandler) Enroll(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user_id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, errors.New(http.StatusText(http.StatusBadRequest)))
+		return
+	}
+	enrollment, err := h.MFAUsecase.Enroll(context.TODO(), int64(user_id))
+	if err == mfa.ErrAlreadyEnabled {
+		responses.ERROR(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		log.Println("mfa enroll err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, enrollment)
+}
+
+// Confirm enables 2FA and answers with the recovery codes
+func (h *MFAHandler) Confirm(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user_id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, errors.New(http.StatusText(http.StatusBadRequest)))
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	defer r.Body.Close()
+	req := &confirmRequest{}
+	err = json.Unmarshal(body, req)
+	if err != nil || req.Code == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Required Code"))
+		return
+	}
+	codes, err := h.MFAUsecase.Confirm(context.TODO(), int64(user_id), req.Code)
+	switch err {
+	case nil:
+	case mfa.ErrInvalidCode:
+		responses.ERROR(w, http.StatusForbidden, err)
+		return
+	case mfa.ErrNotEnrolled:
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	case mfa.ErrAlreadyEnabled:
+		responses.ERROR(w, http.StatusConflict, err)
+		return
+	default:
+		log.Println("mfa confirm err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, &recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable turns 2FA off. It checks a password and a code, so it shares the
+// login throttle.
+func (h *MFAHandler) Disable(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user_id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, errors.New(http.StatusText(http.StatusBadRequest)))
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	defer r.Body.Close()
+	req := &disableRequest{}
+	err = json.Unmarshal(body, req)
+	if err != nil || req.CurrentPassword == "" || req.Code == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Required Password And Code"))
+		return
+	}
+	usr, err := h.UserUsecase.GetByID(context.TODO(), int64(user_id))
+	if err != nil {
+		log.Println("mfa disable get user err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	ip := helper.ClientIP(r)
+	wait, err := h.ThrottleUsecase.Check(context.TODO(), usr.Username, ip)
+	if err != nil {
+		log.Println("mfa disable throttle check err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	if wait > 0 {
+		tooManyAttempts(w, wait)
+		return
+	}
+	err = h.MFAUsecase.Disable(context.TODO(), int64(user_id), req.CurrentPassword, req.Code)
+	switch err {
+	case nil:
+	case user.ErrIncorrectPassword, mfa.ErrInvalidCode:
+		h.disableFailed(w, usr.Username, ip, err)
+		return
+	case mfa.ErrNotEnrolled:
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	default:
+		log.Println("mfa disable err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, "Two-Factor Authentication Disabled")
+}
+
+// disableFailed counts a wrong password or code like a failed login
+func (h *MFAHandler) disableFailed(w http.ResponseWriter, username, ip string, err error) {
+	wait, throttleErr := h.ThrottleUsecase.RecordFailure(context.TODO(), username, ip)
+	if throttleErr != nil {
+		log.Println("mfa disable throttle record failure err:", throttleErr.Error())
+	}
+	if wait > 0 {
+		tooManyAttempts(w, wait)
+		return
+	}
+	responses.ERROR(w, http.StatusForbidden, err)
+}
+
+func tooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	responses.ERROR(w, http.StatusTooManyRequests, errors.New("Too Many Attempts"))
+}
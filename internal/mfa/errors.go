@@ -0,0 +1,9 @@
+package mfa
+
+import "errors"
+
+var (
+	ErrAlreadyEnabled = errors.New("Two-Factor Authentication Already Enabled")
+	ErrNotEnrolled    = errors.New("Two-Factor Authentication Not Enrolled")
+	ErrInvalidCode    = errors.New("Invalid Authentication Code")
+)
@@ -0,0 +1,20 @@
+package mfa
+
+import (
+	"context"
+
+	"github.com/famkampm/nentrytask/internal/models"
+)
+
+// Repository stores TOTP secrets and the hashes of recovery codes
+type Repository interface {
+	GetByUserID(ctx context.Context, userID int64) (*models.MFA, error)
+	Save(ctx context.Context, m *models.MFA) error
+	Enable(ctx context.Context, userID int64, recoveryCodeHashes []string) error
+	Delete(ctx context.Context, userID int64) error
+	// UseStep records step as used and reports false when it, or a later
+	// step, was used already
+	UseStep(ctx context.Context, userID int64, step int64) (bool, error)
+	// UseRecoveryCode removes the code and reports whether it existed
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error)
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/famkampm/nentrytask/internal/mfa"
+	"github.com/famkampm/nentrytask/internal/models"
+)
+
+type mysqlMFARepository struct {
+	DB *sql.DB
+}
+
+func NewMysqlMFARepository(db *sql.DB) mfa.Repository {
+	return &mysqlMFARepository{
+		DB: db,
+	}
+}
+
+func (m *mysqlMFARepository) GetByUserID(ctx context.Context, userID int64) (*models.MFA, error) {
+	res := &models.MFA{}
+	query := `select user_id, secret, enabled, last_used_step from user_mfa where user_id = ?`
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&res.UserID, &res.Secret, &res.Enabled, &res.LastUsedStep)
+	if err != nil {
+		return &models.MFA{}, err
+	}
+	return res, nil
+}
+
+// Save replaces the secret of the user, which disables 2FA until confirmed again
+func (m *mysqlMFARepository) Save(ctx context.Context, mf *models.MFA) error {
+	query := `insert into user_mfa (user_id, secret, enabled, last_used_step) values (?, ?, 0, 0)
+		on duplicate key update secret = values(secret), enabled = 0, last_used_step = 0`
+	_, err := m.DB.ExecContext(ctx, query, mf.UserID, mf.Secret)
+	if err != nil {
+		log.Println("save mfa err:", err.Error())
+		return err
+	}
+	return nil
+}
+
+// Enable turns 2FA on and replaces the recovery codes in one transaction
+func (m *mysqlMFARepository) Enable(ctx context.Context, userID int64, recoveryCodeHashes []string) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `update user_mfa set enabled = 1 where user_id = ?`, userID)
+	if err != nil {
+		tx.Rollback()
+		log.Println("enable mfa err:", err.Error())
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `delete from user_mfa_recovery_code where user_id = ?`, userID)
+	if err != nil {
+		tx.Rollback()
+		log.Println("delete recovery codes err:", err.Error())
+		return err
+	}
+	for _, codeHash := range recoveryCodeHashes {
+		_, err = tx.ExecContext(ctx, `insert into user_mfa_recovery_code (user_id, code_hash) values (?, ?)`, userID, codeHash)
+		if err != nil {
+			tx.Rollback()
+			log.Println("insert recovery code err:", err.Error())
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (m *mysqlMFARepository) Delete(ctx context.Context, userID int64) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `delete from user_mfa_recovery_code where user_id = ?`, userID)
+	if err != nil {
+		tx.Rollback()
+		log.Println("delete recovery codes err:", err.Error())
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `delete from user_mfa where user_id = ?`, userID)
+	if err != nil {
+		tx.Rollback()
+		log.Println("delete mfa err:", err.Error())
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseStep only moves last_used_step forward, so two requests racing with the
+// same code can't both succeed
+func (m *mysqlMFARepository) UseStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	query := `update user_mfa set last_used_step = ? where user_id = ? and last_used_step < ?`
+	res, err := m.DB.ExecContext(ctx, query, step, userID, step)
+	if err != nil {
+		log.Println("use mfa step err:", err.Error())
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (m *mysqlMFARepository) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	query := `delete from user_mfa_recovery_code where user_id = ? and code_hash = ?`
+	res, err := m.DB.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		log.Println("use recovery code err:", err.Error())
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
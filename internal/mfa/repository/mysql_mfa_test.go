@@ -0,0 +1,110 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/famkampm/nentrytask/internal/mfa/repository"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetByUserIDSuccessMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"user_id", "secret", "enabled", "last_used_step"}).AddRow(1, "SECRET", true, 100)
+	mock.ExpectQuery("select (.+) from user_mfa where user_id").WithArgs(1).WillReturnRows(rows)
+	m := repository.NewMysqlMFARepository(db)
+	res, err := m.GetByUserID(context.TODO(), int64(1))
+	assert.NoError(t, err)
+	assert.Equal(t, &models.MFA{UserID: int64(1), Secret: "SECRET", Enabled: true, LastUsedStep: int64(100)}, res)
+}
+
+func TestGetByUserIDNotFoundMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("select (.+) from user_mfa where user_id").WithArgs(1).WillReturnError(sql.ErrNoRows)
+	m := repository.NewMysqlMFARepository(db)
+	_, err = m.GetByUserID(context.TODO(), int64(1))
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestEnableMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("update user_mfa set enabled = 1").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("delete from user_mfa_recovery_code").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("insert into user_mfa_recovery_code").WithArgs(1, "hash1").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("insert into user_mfa_recovery_code").WithArgs(1, "hash2").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+	m := repository.NewMysqlMFARepository(db)
+	err = m.Enable(context.TODO(), int64(1), []string{"hash1", "hash2"})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnableRollbackMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("update user_mfa set enabled = 1").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("delete from user_mfa_recovery_code").WithArgs(1).WillReturnError(fmt.Errorf("some error"))
+	mock.ExpectRollback()
+	m := repository.NewMysqlMFARepository(db)
+	err = m.Enable(context.TODO(), int64(1), []string{"hash1"})
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUseStepMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("update user_mfa set last_used_step").WithArgs(101, 1, 101).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("update user_mfa set last_used_step").WithArgs(101, 1, 101).WillReturnResult(sqlmock.NewResult(0, 0))
+	m := repository.NewMysqlMFARepository(db)
+	ok, err := m.UseStep(context.TODO(), int64(1), int64(101))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	// replayed step
+	ok, err = m.UseStep(context.TODO(), int64(1), int64(101))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestUseRecoveryCodeMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("delete from user_mfa_recovery_code where user_id").WithArgs(1, "hash1").WillReturnResult(sqlmock.NewResult(0, 1))
+	m := repository.NewMysqlMFARepository(db)
+	ok, err := m.UseRecoveryCode(context.TODO(), int64(1), "hash1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
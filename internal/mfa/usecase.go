@@ -0,0 +1,24 @@
+package mfa
+
+import (
+	"context"
+
+	"github.com/famkampm/nentrytask/internal/models"
+)
+
+type Usecase interface {
+	// Enroll starts (or restarts) an enrollment with a new secret
+	Enroll(ctx context.Context, userID int64) (*models.MFAEnrollment, error)
+	// Confirm enables 2FA once the user proved the app works and returns the
+	// recovery codes, which are only shown this once
+	Confirm(ctx context.Context, userID int64, code string) ([]string, error)
+	// Disable needs the password and a code, so a stolen access token alone
+	// can't turn 2FA off
+	Disable(ctx context.Context, userID int64, currentPassword, code string) error
+	// Erase drops the secret and recovery codes without any check, it is only
+	// meant for accounts being deleted
+	Erase(ctx context.Context, userID int64) error
+	IsEnabled(ctx context.Context, userID int64) (bool, error)
+	// Verify accepts either a TOTP code or an unused recovery code
+	Verify(ctx context.Context, userID int64, code string) error
+}
@@ -0,0 +1,170 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/mfa"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/user"
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/famkampm/nentrytask/pkg/totp"
+)
+
+const (
+	recoveryCodeCount = 10
+	// codes of one step either side are accepted for clock drift
+	totpSkew = 1
+)
+
+type mfaUsecase struct {
+	mfaRepo     mfa.Repository
+	userUsecase user.Usecase
+	issuer      string
+}
+
+func NewMFAUsecase(repo mfa.Repository, us user.Usecase, issuer string) mfa.Usecase {
+	return &mfaUsecase{
+		mfaRepo:     repo,
+		userUsecase: us,
+		issuer:      issuer,
+	}
+}
+
+func (m *mfaUsecase) Enroll(ctx context.Context, userID int64) (*models.MFAEnrollment, error) {
+	current, err := m.mfaRepo.GetByUserID(ctx, userID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil && current.Enabled {
+		return nil, mfa.ErrAlreadyEnabled
+	}
+	usr, err := m.userUsecase.GetByID(ctx, userID)
+	if err != nil {
+		log.Println("mfa enroll get user err:", err.Error())
+		return nil, err
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = m.mfaRepo.Save(ctx, &models.MFA{UserID: userID, Secret: secret})
+	if err != nil {
+		return nil, err
+	}
+	uri := totp.ProvisioningURI(m.issuer, usr.Username, secret)
+	return &models.MFAEnrollment{
+		Secret:          secret,
+		ProvisioningURI: uri,
+		QRPayload:       uri,
+	}, nil
+}
+
+func (m *mfaUsecase) Confirm(ctx context.Context, userID int64, code string) ([]string, error) {
+	current, err := m.mfaRepo.GetByUserID(ctx, userID)
+	if err == sql.ErrNoRows {
+		return nil, mfa.ErrNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if current.Enabled {
+		return nil, mfa.ErrAlreadyEnabled
+	}
+	err = m.verifyTOTP(ctx, current, code)
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i] = helper.RandToken(5) + "-" + helper.RandToken(5)
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	err = m.mfaRepo.Enable(ctx, userID, hashes)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (m *mfaUsecase) Disable(ctx context.Context, userID int64, currentPassword, code string) error {
+	usr, err := m.userUsecase.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = helper.VerifyPassword(usr.Password, currentPassword)
+	if err != nil {
+		return user.ErrIncorrectPassword
+	}
+	err = m.Verify(ctx, userID, code)
+	if err != nil {
+		return err
+	}
+	return m.mfaRepo.Delete(ctx, userID)
+}
+
+func (m *mfaUsecase) Erase(ctx context.Context, userID int64) error {
+	return m.mfaRepo.Delete(ctx, userID)
+}
+
+func (m *mfaUsecase) IsEnabled(ctx context.Context, userID int64) (bool, error) {
+	current, err := m.mfaRepo.GetByUserID(ctx, userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return current.Enabled, nil
+}
+
+// Verify tells codes apart by length: TOTP codes are all digits, recovery
+// codes are the longer xxxxx-xxxxx hex strings
+func (m *mfaUsecase) Verify(ctx context.Context, userID int64, code string) error {
+	current, err := m.mfaRepo.GetByUserID(ctx, userID)
+	if err == sql.ErrNoRows {
+		return mfa.ErrNotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+	if !current.Enabled {
+		return mfa.ErrNotEnrolled
+	}
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		return m.verifyTOTP(ctx, current, code)
+	}
+	ok, err := m.mfaRepo.UseRecoveryCode(ctx, userID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return mfa.ErrInvalidCode
+	}
+	return nil
+}
+
+// verifyTOTP checks code and burns its step so it can't be replayed
+func (m *mfaUsecase) verifyTOTP(ctx context.Context, current *models.MFA, code string) error {
+	step, ok := totp.Validate(current.Secret, code, time.Now(), totpSkew)
+	if !ok || step <= current.LastUsedStep {
+		return mfa.ErrInvalidCode
+	}
+	ok, err := m.mfaRepo.UseStep(ctx, current.UserID, step)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return mfa.ErrInvalidCode
+	}
+	return nil
+}
+
+func hashRecoveryCode(code string) string {
+	return helper.HashToken(strings.ToLower(strings.Replace(code, "-", "", -1)))
+}
@@ -0,0 +1,169 @@
+package usecase_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/mfa"
+	"github.com/famkampm/nentrytask/internal/mfa/mocks"
+	"github.com/famkampm/nentrytask/internal/mfa/usecase"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/user"
+	_userMocks "github.com/famkampm/nentrytask/internal/user/mocks"
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/famkampm/nentrytask/pkg/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newEnabledMFA(t *testing.T) *models.MFA {
+	secret, err := totp.GenerateSecret()
+	assert.NoError(t, err)
+	return &models.MFA{UserID: int64(1), Secret: secret, Enabled: true}
+}
+
+func currentCode(t *testing.T, secret string) string {
+	code, err := totp.CodeAt(secret, totp.Step(time.Now()))
+	assert.NoError(t, err)
+	return code
+}
+
+func TestEnrollUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockUserUsecase := new(_userMocks.Usecase)
+	mockRepo.On("GetByUserID", mock.Anything, int64(1)).Return(&models.MFA{}, sql.ErrNoRows).Once()
+	mockUserUsecase.On("GetByID", mock.Anything, int64(1)).Return(&models.User{ID: int64(1), Username: "user1"}, nil).Once()
+	mockRepo.On("Save", mock.Anything, mock.AnythingOfType("*models.MFA")).Return(nil).Once()
+
+	u := usecase.NewMFAUsecase(mockRepo, mockUserUsecase, "nentrytask")
+	enrollment, err := u.Enroll(context.TODO(), int64(1))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, enrollment.Secret)
+	assert.Contains(t, enrollment.ProvisioningURI, "otpauth://totp/nentrytask:user1?")
+	assert.Contains(t, enrollment.ProvisioningURI, "secret="+enrollment.Secret)
+	assert.Equal(t, enrollment.ProvisioningURI, enrollment.QRPayload)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestEnrollAlreadyEnabledUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("GetByUserID", mock.Anything, int64(1)).Return(newEnabledMFA(t), nil).Once()
+
+	u := usecase.NewMFAUsecase(mockRepo, new(_userMocks.Usecase), "nentrytask")
+	_, err := u.Enroll(context.TODO(), int64(1))
+	assert.Equal(t, mfa.ErrAlreadyEnabled, err)
+}
+
+func TestConfirmUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	pending := newEnabledMFA(t)
+	pending.Enabled = false
+	mockRepo.On("GetByUserID", mock.Anything, int64(1)).Return(pending, nil).Once()
+	mockRepo.On("UseStep", mock.Anything, int64(1), mock.AnythingOfType("int64")).Return(true, nil).Once()
+	mockRepo.On("Enable", mock.Anything, int64(1), mock.AnythingOfType("[]string")).Return(nil).Once()
+
+	u := usecase.NewMFAUsecase(mockRepo, new(_userMocks.Usecase), "nentrytask")
+	codes, err := u.Confirm(context.TODO(), int64(1), currentCode(t, pending.Secret))
+	assert.NoError(t, err)
+	assert.Len(t, codes, 10)
+	// only hashes are stored
+	hashes := mockRepo.Calls[2].Arguments.Get(2).([]string)
+	assert.Len(t, hashes, 10)
+	assert.NotContains(t, hashes, codes[0])
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfirmInvalidCodeUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	pending := newEnabledMFA(t)
+	pending.Enabled = false
+	mockRepo.On("GetByUserID", mock.Anything, int64(1)).Return(pending, nil).Once()
+
+	u := usecase.NewMFAUsecase(mockRepo, new(_userMocks.Usecase), "nentrytask")
+	_, err := u.Confirm(context.TODO(), int64(1), "000000x")
+	assert.Equal(t, mfa.ErrInvalidCode, err)
+	mockRepo.AssertNotCalled(t, "Enable", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestConfirmNotEnrolledUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("GetByUserID", mock.Anything, int64(1)).Return(&models.MFA{}, sql.ErrNoRows).Once()
+
+	u := usecase.NewMFAUsecase(mockRepo, new(_userMocks.Usecase), "nentrytask")
+	_, err := u.Confirm(context.TODO(), int64(1), "123456")
+	assert.Equal(t, mfa.ErrNotEnrolled, err)
+}
+
+func TestVerifyTOTPUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	enabled := newEnabledMFA(t)
+	mockRepo.On("GetByUserID", mock.Anything, int64(1)).Return(enabled, nil).Once()
+	mockRepo.On("UseStep", mock.Anything, int64(1), mock.AnythingOfType("int64")).Return(true, nil).Once()
+
+	u := usecase.NewMFAUsecase(mockRepo, new(_userMocks.Usecase), "nentrytask")
+	err := u.Verify(context.TODO(), int64(1), currentCode(t, enabled.Secret))
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestVerifyTOTPReplayUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	enabled := newEnabledMFA(t)
+	enabled.LastUsedStep = totp.Step(time.Now()) + 1
+	mockRepo.On("GetByUserID", mock.Anything, int64(1)).Return(enabled, nil).Once()
+
+	u := usecase.NewMFAUsecase(mockRepo, new(_userMocks.Usecase), "nentrytask")
+	err := u.Verify(context.TODO(), int64(1), currentCode(t, enabled.Secret))
+	assert.Equal(t, mfa.ErrInvalidCode, err)
+	mockRepo.AssertNotCalled(t, "UseStep", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestVerifyRecoveryCodeUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("GetByUserID", mock.Anything, int64(1)).Return(newEnabledMFA(t), nil).Once()
+	mockRepo.On("UseRecoveryCode", mock.Anything, int64(1), helper.HashToken("abcde12345")).Return(true, nil).Once()
+
+	u := usecase.NewMFAUsecase(mockRepo, new(_userMocks.Usecase), "nentrytask")
+	err := u.Verify(context.TODO(), int64(1), "ABCDE-12345")
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestVerifyUsedRecoveryCodeUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("GetByUserID", mock.Anything, int64(1)).Return(newEnabledMFA(t), nil).Once()
+	mockRepo.On("UseRecoveryCode", mock.Anything, int64(1), mock.AnythingOfType("string")).Return(false, nil).Once()
+
+	u := usecase.NewMFAUsecase(mockRepo, new(_userMocks.Usecase), "nentrytask")
+	err := u.Verify(context.TODO(), int64(1), "abcde-12345")
+	assert.Equal(t, mfa.ErrInvalidCode, err)
+}
+
+func TestDisableIncorrectPasswordUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockUserUsecase := new(_userMocks.Usecase)
+	hashedPassword, err := helper.HashingPassword("pass1")
+	assert.NoError(t, err)
+	mockUserUsecase.On("GetByID", mock.Anything, int64(1)).Return(&models.User{ID: int64(1), Password: hashedPassword}, nil).Once()
+
+	u := usecase.NewMFAUsecase(mockRepo, mockUserUsecase, "nentrytask")
+	err = u.Disable(context.TODO(), int64(1), "wrong", "123456")
+	assert.Equal(t, user.ErrIncorrectPassword, err)
+	mockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestIsEnabledUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("GetByUserID", mock.Anything, int64(1)).Return(&models.MFA{}, sql.ErrNoRows).Once()
+	mockRepo.On("GetByUserID", mock.Anything, int64(2)).Return(&models.MFA{UserID: int64(2), Enabled: true}, nil).Once()
+
+	u := usecase.NewMFAUsecase(mockRepo, new(_userMocks.Usecase), "nentrytask")
+	enabled, err := u.IsEnabled(context.TODO(), int64(1))
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+	enabled, err = u.IsEnabled(context.TODO(), int64(2))
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+}
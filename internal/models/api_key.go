@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// APIKey is a long lived credential for machine clients. Only the hash of the
+// key is kept; Prefix is stored in clear so users can tell their keys apart.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NewAPIKey is returned once on creation, it is the only time Key is visible
+type NewAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
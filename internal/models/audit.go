@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// AuditEntry is one security relevant event. ActorID is who did it and
+// TargetUserID whose account it touched, they differ for staff overrides.
+type AuditEntry struct {
+	ID           int64     `json:"id"`
+	ActorID      int64     `json:"actor_id"`
+	TargetUserID int64     `json:"target_user_id"`
+	Action       string    `json:"action"`
+	IP           string    `json:"ip"`
+	UserAgent    string    `json:"user_agent"`
+	Result       string    `json:"result"`
+	Detail       string    `json:"detail"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AuditFilter selects audit entries, newest first. Zero fields don't filter.
+// UserID matches the actor as well as the target. BeforeID pages through the
+// results with the id of the last entry seen.
+type AuditFilter struct {
+	UserID   int64
+	From     time.Time
+	To       time.Time
+	BeforeID int64
+	Limit    int
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+// IdentityProvider is an external OpenID Connect provider users can log in
+// with, registered in FEDERATION_PROVIDERS_FILE
+type IdentityProvider struct {
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURI  string   `json:"redirect_uri"`
+	Scopes       []string `json:"scopes"`
+	// LinkByEmail links a first login to the one local account with the
+	// same email, provided the provider verified it
+	LinkByEmail bool `json:"link_by_email"`
+	// AutoCreate creates an account on the first login nothing links to
+	AutoCreate bool `json:"auto_create"`
+}
+
+// ExternalIdentity links a user to its subject at a provider
+type ExternalIdentity struct {
+	ID        int64       `json:"id"`
+	UserID    int64       `json:"user_id"`
+	Provider  string      `json:"provider"`
+	Subject   string      `json:"subject"`
+	Email     null.String `json:"email"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// FederatedLoginState is kept between the redirect to the provider and the
+// callback
+type FederatedLoginState struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	// LinkUserID is the logged in user linking the identity, 0 to log in
+	LinkUserID int64 `json:"link_user_id"`
+}
+
+// ExternalClaims are the claims of a verified provider ID token we use
+type ExternalClaims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Name              string
+}
+
+// FederatedLogin is the outcome of a provider callback
+type FederatedLogin struct {
+	User     *User
+	Identity *ExternalIdentity
+	// Created is set for accounts created by this login, Linked when the
+	// identity was linked by it
+	Created bool
+	Linked  bool
+	// LinkOnly is set when the login was started to link an identity
+	LinkOnly bool
+}
@@ -0,0 +1,18 @@
+package models
+
+// MFA is the TOTP enrollment of a user. It only counts once Enabled is set,
+// which happens after the user confirmed a first code.
+type MFA struct {
+	UserID       int64  `json:"user_id"`
+	Secret       string `json:"-"`
+	Enabled      bool   `json:"enabled"`
+	LastUsedStep int64  `json:"-"`
+}
+
+// MFAEnrollment is returned when a user starts enrolling. QRPayload is the
+// text to render as a QR code for authenticator apps.
+type MFAEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+	QRPayload       string `json:"qr_payload"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Session is one login of a user, shared by every token pair refreshed from
+// it. ID is the sid claim of those tokens.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int64     `json:"user_id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
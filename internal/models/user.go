@@ -4,16 +4,27 @@ import "gopkg.in/guregu/null.v3"
 
 // represent user model
 type User struct {
-	ID           int64       `json:"id"; redis:"id"`
-	Username     string      `json:"username"; redis:"username"`
-	Password     string      `json:"password"; redis:"password"`
-	Nickname     null.String `json:"nickname"; redis:"nickname"`
-	ProfileImage null.String `json:"profile_image"; redis:"profile_image"`
+	ID             int64       `json:"id" redis:"id"`
+	Username       string      `json:"username" redis:"username"`
+	Password       string      `json:"password" redis:"password"`
+	Nickname       null.String `json:"nickname" redis:"nickname"`
+	ProfileImage   null.String `json:"profile_image" redis:"profile_image"`
+	Email          null.String `json:"email" redis:"email"`
+	Role           string      `json:"role" redis:"role"`
+	Status         string      `json:"status" redis:"status"`
+	SuspendedUntil null.Time   `json:"suspended_until" redis:"suspended_until"`
 }
 
 type UserProfile struct {
-	ID           int64       `json:"id"; redis:"id"`
-	Username     string      `json:"username"; redis:"username"`
-	Nickname     null.String `json:"nickname"; redis:"nickname"`
-	ProfileImage null.String `json:"profile_image"; redis:"profile_image"`
+	ID             int64       `json:"id" redis:"id"`
+	Username       string      `json:"username" redis:"username"`
+	Nickname       null.String `json:"nickname" redis:"nickname"`
+	ProfileImage   null.String `json:"profile_image" redis:"profile_image"`
+	Email          null.String `json:"email" redis:"email"`
+	Role           string      `json:"role" redis:"role"`
+	Status         string      `json:"status" redis:"status"`
+	SuspendedUntil null.Time   `json:"suspended_until" redis:"suspended_until"`
+	// ProfileImages maps "original" and every thumbnail size to its URL, those
+	// of a generated default image when ProfileImage is null
+	ProfileImages map[string]string `json:"profile_images,omitempty" redis:"-"`
 }
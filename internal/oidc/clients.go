@@ -0,0 +1,47 @@
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+
+	"github.com/famkampm/nentrytask/internal/models"
+)
+
+// LoadClients reads the registered clients from a JSON array of
+// {client_id, client_secret, name, redirect_uris}. No path means no clients.
+func LoadClients(path string) (map[string]*models.OIDCClient, error) {
+	clients := map[string]*models.OIDCClient{}
+	if path == "" {
+		return clients, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	list := []*models.OIDCClient{}
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, err
+	}
+	for _, client := range list {
+		if client.ID == "" {
+			return nil, fmt.Errorf("oidc client without client_id")
+		}
+		if clients[client.ID] != nil {
+			return nil, fmt.Errorf("duplicate oidc client %q", client.ID)
+		}
+		if len(client.RedirectURIs) == 0 {
+			return nil, fmt.Errorf("oidc client %q has no redirect_uris", client.ID)
+		}
+		for _, uri := range client.RedirectURIs {
+			u, err := url.Parse(uri)
+			if err != nil || !u.IsAbs() || u.Fragment != "" {
+				return nil, fmt.Errorf("oidc client %q has an invalid redirect uri %q", client.ID, uri)
+			}
+		}
+		clients[client.ID] = client
+	}
+	return clients, nil
+}
@@ -0,0 +1,46 @@
+package oidc_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/famkampm/nentrytask/internal/oidc"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeClients(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "oidc")
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "clients.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoadClients(t *testing.T) {
+	clients, err := oidc.LoadClients("")
+	assert.NoError(t, err)
+	assert.Empty(t, clients)
+
+	path := writeClients(t, `[{"client_id":"wiki","client_secret":"s","redirect_uris":["http://wiki.local/callback"]},{"client_id":"cli","redirect_uris":["http://127.0.0.1:9000/cb"]}]`)
+	defer os.RemoveAll(filepath.Dir(path))
+	clients, err = oidc.LoadClients(path)
+	assert.NoError(t, err)
+	assert.Len(t, clients, 2)
+	assert.Equal(t, "", clients["cli"].Secret)
+}
+
+func TestLoadClientsInvalid(t *testing.T) {
+	for _, content := range []string{
+		`[{"redirect_uris":["http://wiki.local/callback"]}]`,
+		`[{"client_id":"wiki"}]`,
+		`[{"client_id":"wiki","redirect_uris":["/callback"]}]`,
+		`[{"client_id":"wiki","redirect_uris":["http://a/cb"]},{"client_id":"wiki","redirect_uris":["http://b/cb"]}]`,
+	} {
+		path := writeClients(t, content)
+		_, err := oidc.LoadClients(path)
+		assert.NotNil(t, err, content)
+		os.RemoveAll(filepath.Dir(path))
+	}
+}
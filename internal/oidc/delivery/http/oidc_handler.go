@@ -0,0 +1,147 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/oidc"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/famkampm/nentrytask/pkg/middlewares"
+	"github.com/famkampm/nentrytask/pkg/responses"
+	"github.com/julienschmidt/httprouter"
+)
+
+type OIDCHandler struct {
+	Router      *httprouter.Router
+	OIDCUsecase oidc.Usecase
+}
+
+// NewOIDCHandler registers the OpenID Connect provider. /oauth2/authorize is
+// behind our own login: the login page sends the access token of /login and
+// follows the redirect to the client.
+func NewOIDCHandler(router *httprouter.Router, us oidc.Usecase, mw *middlewares.Middleware) {
+	handler := &OIDCHandler{
+		Router:      router,
+		OIDCUsecase: us,
+	}
+	handler.Router.GET("/.well-known/openid-configuration", middlewares.SetMiddlewareJSON(handler.Discovery))
+	handler.Router.GET("/oauth2/authorize", middlewares.SetMiddlewareJSON(mw.SetMiddlewareToken(handler.Authorize)))
+	handler.Router.POST("/oauth2/token", middlewares.SetMiddlewareJSON(handler.Token))
+	handler.Router.GET("/userinfo", middlewares.SetMiddlewareJSON(handler.UserInfo))
+}
+
+func (h *OIDCHandler) Discovery(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	responses.JSON(w, http.StatusOK, h.OIDCUsecase.Discovery())
+}
+
+// Authorize answers with a redirect to the client carrying either the code or
+// the error, unless the client or its redirect uri can't be trusted
+func (h *OIDCHandler) Authorize(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	claims, ok := auth.FromContext(r.Context())
+	if !ok {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	query := r.URL.Query()
+	req := &models.AuthorizationRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		Nonce:               query.Get("nonce"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+	code, err := h.OIDCUsecase.Authorize(context.TODO(), claims.UserID, req)
+	if err == oidc.ErrInvalidClient || err == oidc.ErrInvalidRedirectURI {
+		responses.JSON(w, http.StatusBadRequest, err)
+		return
+	}
+	params := url.Values{}
+	switch err := err.(type) {
+	case nil:
+		params.Set("code", code)
+	case *oidc.Error:
+		params.Set("error", err.Code)
+		params.Set("error_description", err.Description)
+	default:
+		params.Set("error", "server_error")
+	}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	http.Redirect(w, r, withQuery(req.RedirectURI, params), http.StatusFound)
+}
+
+// Token takes the client credentials from basic auth or the form, public
+// clients only send their client_id
+func (h *OIDCHandler) Token(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	err := r.ParseForm()
+	if err != nil {
+		responses.JSON(w, http.StatusBadRequest, oidc.ErrInvalidRequest)
+		return
+	}
+	req := &models.TokenRequest{
+		GrantType:    r.PostForm.Get("grant_type"),
+		Code:         r.PostForm.Get("code"),
+		RedirectURI:  r.PostForm.Get("redirect_uri"),
+		ClientID:     r.PostForm.Get("client_id"),
+		ClientSecret: r.PostForm.Get("client_secret"),
+		CodeVerifier: r.PostForm.Get("code_verifier"),
+	}
+	if id, secret, ok := r.BasicAuth(); ok {
+		req.ClientID, _ = url.QueryUnescape(id)
+		req.ClientSecret, _ = url.QueryUnescape(secret)
+	}
+	tokens, err := h.OIDCUsecase.Exchange(context.TODO(), req)
+	if err == oidc.ErrInvalidClient {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth2"`)
+		responses.JSON(w, http.StatusUnauthorized, err)
+		return
+	}
+	if oauthErr, ok := err.(*oidc.Error); ok {
+		responses.JSON(w, http.StatusBadRequest, oauthErr)
+		return
+	}
+	if err != nil {
+		responses.JSON(w, http.StatusInternalServerError, &oidc.Error{Code: "server_error"})
+		return
+	}
+	responses.JSON(w, http.StatusOK, tokens)
+}
+
+func (h *OIDCHandler) UserInfo(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	info, err := h.OIDCUsecase.UserInfo(context.TODO(), auth.ExtractToken(r))
+	if err == oidc.ErrInvalidToken {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		responses.JSON(w, http.StatusUnauthorized, err)
+		return
+	}
+	if err != nil {
+		log.Println("oidc userinfo err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, info)
+}
+
+func withQuery(uri string, params url.Values) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,206 @@
+package http_test
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/famkampm/nentrytask/internal/models"
+	_oidcHttpDeliver "github.com/famkampm/nentrytask/internal/oidc/delivery/http"
+	"github.com/famkampm/nentrytask/internal/oidc/repository"
+	"github.com/famkampm/nentrytask/internal/oidc/usecase"
+	_sessionMocks "github.com/famkampm/nentrytask/internal/session/mocks"
+	"github.com/famkampm/nentrytask/internal/user"
+	_userHttpDeliver "github.com/famkampm/nentrytask/internal/user/delivery/http"
+	_userMocks "github.com/famkampm/nentrytask/internal/user/mocks"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	_authMocks "github.com/famkampm/nentrytask/pkg/auth/mocks"
+	"github.com/famkampm/nentrytask/pkg/middlewares"
+	"github.com/gomodule/redigo/redis"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gopkg.in/guregu/null.v3"
+)
+
+const redirectURI = "http://wiki.local/callback"
+
+// newProvider runs the provider on a local server with redis replaced by
+// miniredis and the user store by a mock
+func newProvider(t *testing.T) (*httptest.Server, func()) {
+	km, err := auth.NewKeyManager("EdDSA", "", time.Hour, time.Hour)
+	assert.NoError(t, err)
+	auth.SetKeyManager(km)
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when starting miniredis", err)
+	}
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", s.Addr())
+		},
+	}
+
+	mockUserUsecase := new(_userMocks.Usecase)
+	mockUserUsecase.On("GetByID", mock.Anything, int64(1)).Return(&models.User{ID: 1, Username: "alice", Nickname: null.StringFrom("Alice"), Email: null.StringFrom("alice@example.com"), Status: user.StatusActive}, nil)
+	revoker := new(_authMocks.Revoker)
+	revoker.On("IsRevoked", mock.Anything, mock.Anything).Return(false, nil)
+	sessions := new(_sessionMocks.Usecase)
+	sessions.On("Touch", mock.Anything, mock.Anything).Return(true, nil)
+	mw := middlewares.InitMiddleware(revoker, nil, nil, sessions, _userHttpDeliver.NewStatusChecker(mockUserUsecase))
+
+	router := httprouter.New()
+	server := httptest.NewServer(router)
+	clients := map[string]*models.OIDCClient{
+		"wiki": {ID: "wiki", Secret: "wiki-secret", RedirectURIs: []string{redirectURI}},
+	}
+	us := usecase.NewOIDCUsecase(mockUserUsecase, repository.NewRedisOIDCRepository(pool), clients, server.URL, time.Minute)
+	_oidcHttpDeliver.NewOIDCHandler(router, us, mw)
+	router.GET("/.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		json.NewEncoder(w).Encode(auth.JWKS())
+	})
+	return server, func() {
+		server.Close()
+		s.Close()
+		auth.SetKeyManager(nil)
+	}
+}
+
+func getJSON(t *testing.T, uri, token string, v interface{}) int {
+	req, err := http.NewRequest("GET", uri, nil)
+	assert.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(v))
+	return res.StatusCode
+}
+
+// TestAuthorizationCodeFlow plays a relying party: discovery, authorize with
+// PKCE as a logged in user, code exchange, ID token check against the JWKS
+// and /userinfo
+func TestAuthorizationCodeFlow(t *testing.T) {
+	server, closeProvider := newProvider(t)
+	defer closeProvider()
+
+	doc := &models.OIDCDiscovery{}
+	assert.Equal(t, http.StatusOK, getJSON(t, server.URL+"/.well-known/openid-configuration", "", doc))
+	assert.Equal(t, server.URL, doc.Issuer)
+
+	// THE USER LOGGED IN WITH /login
+	pair, err := auth.CreateTokenPair(int64(1), auth.RoleUser)
+	assert.NoError(t, err)
+
+	verifier := "the-code-verifier-of-the-test-client-0123456789"
+	challenge := sha256Challenge(verifier)
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"wiki"},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {"openid profile email"},
+		"state":                 {"state-1"},
+		"nonce":                 {"nonce-1"},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+	req, err := http.NewRequest("GET", doc.AuthorizationEndpoint+"?"+query.Encode(), nil)
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+	noRedirect := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := noRedirect.Do(req)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusFound, res.StatusCode)
+	location, err := url.Parse(res.Header.Get("Location"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(location.String(), redirectURI+"?"))
+	assert.Equal(t, "state-1", location.Query().Get("state"))
+	code := location.Query().Get("code")
+	assert.NotEmpty(t, code)
+
+	exchange := func() *http.Response {
+		form := url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {code},
+			"redirect_uri":  {redirectURI},
+			"code_verifier": {verifier},
+		}
+		req, err := http.NewRequest("POST", doc.TokenEndpoint, strings.NewReader(form.Encode()))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("wiki", "wiki-secret")
+		res, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		return res
+	}
+	res = exchange()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	tokens := &models.OIDCTokenResponse{}
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(tokens))
+	res.Body.Close()
+
+	jwks := &auth.JWKSet{}
+	assert.Equal(t, http.StatusOK, getJSON(t, doc.JWKSURI, "", jwks))
+	idClaims := &auth.OIDCClaims{}
+	_, err = jwt.ParseWithClaims(tokens.IDToken, idClaims, func(token *jwt.Token) (interface{}, error) {
+		for _, key := range jwks.Keys {
+			if key.KeyID == token.Header["kid"] && key.Algorithm == token.Method.Alg() {
+				x, err := base64.RawURLEncoding.DecodeString(key.X)
+				return ed25519.PublicKey(x), err
+			}
+		}
+		return nil, auth.ErrUnknownKey
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, doc.Issuer, idClaims.Issuer)
+	assert.Equal(t, "wiki", idClaims.Audience)
+	assert.Equal(t, "1", idClaims.Subject)
+	assert.Equal(t, "nonce-1", idClaims.Nonce)
+	assert.Equal(t, "alice", idClaims.PreferredUsername)
+
+	info := &models.UserInfo{}
+	assert.Equal(t, http.StatusOK, getJSON(t, doc.UserInfoEndpoint, tokens.AccessToken, info))
+	assert.Equal(t, "1", info.Subject)
+	assert.Equal(t, "Alice", info.Nickname)
+	assert.Equal(t, "alice@example.com", info.Email)
+	assert.True(t, *info.EmailVerified)
+
+	// A CODE IS ONLY GOOD ONCE
+	res = exchange()
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	// AND THE CLIENT TOKEN IS NO LOGIN TOKEN
+	body := map[string]string{}
+	assert.Equal(t, http.StatusUnauthorized, getJSON(t, doc.AuthorizationEndpoint+"?"+query.Encode(), tokens.AccessToken, &body))
+}
+
+func TestAuthorizeUnknownRedirectURI(t *testing.T) {
+	server, closeProvider := newProvider(t)
+	defer closeProvider()
+	pair, err := auth.CreateTokenPair(int64(1), auth.RoleUser)
+	assert.NoError(t, err)
+
+	query := url.Values{"response_type": {"code"}, "client_id": {"wiki"}, "redirect_uri": {"http://evil.local/callback"}, "scope": {"openid"}}
+	body := map[string]string{}
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, server.URL+"/oauth2/authorize?"+query.Encode(), pair.AccessToken, &body))
+	assert.Equal(t, "invalid_request", body["error"])
+}
+
+func sha256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,25 @@
+package oidc
+
+// Error is an OAuth 2.0 error, Code goes out as the error parameter
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Description
+}
+
+var (
+	// ErrInvalidClient and ErrInvalidRedirectURI are never sent to the
+	// redirect uri, it can't be trusted yet
+	ErrInvalidClient           = &Error{Code: "invalid_client", Description: "Unknown Client Or Wrong Secret"}
+	ErrInvalidRedirectURI      = &Error{Code: "invalid_request", Description: "Redirect URI Not Registered"}
+	ErrInvalidRequest          = &Error{Code: "invalid_request", Description: "Missing Or Invalid Parameter"}
+	ErrPKCERequired            = &Error{Code: "invalid_request", Description: "PKCE With S256 Is Required"}
+	ErrUnsupportedResponseType = &Error{Code: "unsupported_response_type", Description: "Only The Code Response Type Is Supported"}
+	ErrInvalidScope            = &Error{Code: "invalid_scope", Description: "The openid Scope Is Required"}
+	ErrUnsupportedGrantType    = &Error{Code: "unsupported_grant_type", Description: "Only The Authorization Code Grant Is Supported"}
+	ErrInvalidGrant            = &Error{Code: "invalid_grant", Description: "Invalid Or Expired Authorization Code"}
+	ErrInvalidToken            = &Error{Code: "invalid_token", Description: "Invalid Or Expired Access Token"}
+)
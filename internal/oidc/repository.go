@@ -0,0 +1,16 @@
+package oidc
+
+import (
+	"context"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/models"
+)
+
+// Repository stores authorization codes by their hash, never in plain text
+type Repository interface {
+	Store(ctx context.Context, codeHash string, grant *models.AuthorizationGrant, ttl time.Duration) error
+	// Consume returns the grant of a code and deletes it, ErrInvalidGrant when
+	// it is unknown, expired or already used
+	Consume(ctx context.Context, codeHash string) (*models.AuthorizationGrant, error)
+}
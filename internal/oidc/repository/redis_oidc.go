@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/oidc"
+	"github.com/gomodule/redigo/redis"
+)
+
+type redisOIDCRepository struct {
+	RedisPool *redis.Pool
+}
+
+func NewRedisOIDCRepository(redisPool *redis.Pool) oidc.Repository {
+	return &redisOIDCRepository{
+		RedisPool: redisPool,
+	}
+}
+
+func authorizationCodeKey(codeHash string) string {
+	return "oidc_code:" + codeHash
+}
+
+func (r *redisOIDCRepository) Store(ctx context.Context, codeHash string, grant *models.AuthorizationGrant, ttl time.Duration) error {
+	data, err := json.Marshal(grant)
+	if err != nil {
+		return err
+	}
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	_, err = conn.Do("SET", authorizationCodeKey(codeHash), data, "PX", ttl.Nanoseconds()/int64(time.Millisecond))
+	return err
+}
+
+// Consume reads and deletes the code in the same transaction, so a code can
+// only ever be exchanged once
+func (r *redisOIDCRepository) Consume(ctx context.Context, codeHash string) (*models.AuthorizationGrant, error) {
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	conn.Send("MULTI")
+	conn.Send("GET", authorizationCodeKey(codeHash))
+	conn.Send("DEL", authorizationCodeKey(codeHash))
+	values, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return nil, err
+	}
+	if values[0] == nil {
+		return nil, oidc.ErrInvalidGrant
+	}
+	data, err := redis.Bytes(values[0], nil)
+	if err != nil {
+		return nil, err
+	}
+	grant := &models.AuthorizationGrant{}
+	err = json.Unmarshal(data, grant)
+	if err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
@@ -0,0 +1,53 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/oidc"
+	"github.com/famkampm/nentrytask/internal/oidc/repository"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPool(t *testing.T) (*miniredis.Miniredis, *redis.Pool) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when starting miniredis", err)
+	}
+	addr := s.Addr()
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr)
+		},
+	}
+	return s, pool
+}
+
+func TestConsumeCodeOnceRedis(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := repository.NewRedisOIDCRepository(pool)
+
+	grant := &models.AuthorizationGrant{ClientID: "wiki", RedirectURI: "http://localhost/cb", UserID: 1, Scope: "openid", CodeChallenge: "challenge"}
+	assert.NoError(t, r.Store(context.TODO(), "hash1", grant, time.Minute))
+	res, err := r.Consume(context.TODO(), "hash1")
+	assert.NoError(t, err)
+	assert.Equal(t, grant, res)
+	_, err = r.Consume(context.TODO(), "hash1")
+	assert.Equal(t, oidc.ErrInvalidGrant, err)
+}
+
+func TestConsumeExpiredCodeRedis(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := repository.NewRedisOIDCRepository(pool)
+
+	assert.NoError(t, r.Store(context.TODO(), "hash1", &models.AuthorizationGrant{UserID: 1}, time.Minute))
+	s.FastForward(time.Minute)
+	_, err := r.Consume(context.TODO(), "hash1")
+	assert.Equal(t, oidc.ErrInvalidGrant, err)
+}
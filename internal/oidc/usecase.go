@@ -0,0 +1,25 @@
+package oidc
+
+import (
+	"context"
+
+	"github.com/famkampm/nentrytask/internal/models"
+)
+
+// Scopes we understand, anything else asked for is ignored
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+type Usecase interface {
+	// Authorize issues a code to the client for the logged in userID. Apart
+	// from ErrInvalidClient and ErrInvalidRedirectURI its errors are meant for
+	// the redirect uri.
+	Authorize(ctx context.Context, userID int64, req *models.AuthorizationRequest) (string, error)
+	// Exchange trades a code and its PKCE verifier for an ID and access token
+	Exchange(ctx context.Context, req *models.TokenRequest) (*models.OIDCTokenResponse, error)
+	UserInfo(ctx context.Context, accessToken string) (*models.UserInfo, error)
+	Discovery() *models.OIDCDiscovery
+}
@@ -0,0 +1,271 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/oidc"
+	"github.com/famkampm/nentrytask/internal/user"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/famkampm/nentrytask/pkg/helper"
+)
+
+const (
+	codeChallengeMethodS256 = "S256"
+	grantTypeAuthorization  = "authorization_code"
+	responseTypeCode        = "code"
+)
+
+var supportedScopes = []string{oidc.ScopeOpenID, oidc.ScopeProfile, oidc.ScopeEmail}
+
+type oidcUsecase struct {
+	userUsecase user.Usecase
+	codeRepo    oidc.Repository
+	clients     map[string]*models.OIDCClient
+	issuer      string
+	codeTTL     time.Duration
+}
+
+func NewOIDCUsecase(us user.Usecase, repo oidc.Repository, clients map[string]*models.OIDCClient, issuer string, codeTTL time.Duration) oidc.Usecase {
+	return &oidcUsecase{
+		userUsecase: us,
+		codeRepo:    repo,
+		clients:     clients,
+		issuer:      strings.TrimSuffix(issuer, "/"),
+		codeTTL:     codeTTL,
+	}
+}
+
+func (o *oidcUsecase) Authorize(ctx context.Context, userID int64, req *models.AuthorizationRequest) (string, error) {
+	client := o.clients[req.ClientID]
+	if client == nil {
+		return "", oidc.ErrInvalidClient
+	}
+	// THE REDIRECT URI MAY ONLY BE LEFT OUT WHEN THE CLIENT HAS A SINGLE ONE
+	if req.RedirectURI == "" && len(client.RedirectURIs) == 1 {
+		req.RedirectURI = client.RedirectURIs[0]
+	}
+	if !registeredRedirectURI(client, req.RedirectURI) {
+		return "", oidc.ErrInvalidRedirectURI
+	}
+	if req.ResponseType != responseTypeCode {
+		return "", oidc.ErrUnsupportedResponseType
+	}
+	scope := normalizeScope(req.Scope)
+	if !hasScope(scope, oidc.ScopeOpenID) {
+		return "", oidc.ErrInvalidScope
+	}
+	if req.CodeChallengeMethod != codeChallengeMethodS256 || len(req.CodeChallenge) != base64.RawURLEncoding.EncodedLen(sha256.Size) {
+		return "", oidc.ErrPKCERequired
+	}
+	code := helper.RandToken(32)
+	grant := &models.AuthorizationGrant{
+		ClientID:      client.ID,
+		RedirectURI:   req.RedirectURI,
+		UserID:        userID,
+		Scope:         scope,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+	}
+	err := o.codeRepo.Store(ctx, helper.HashToken(code), grant, o.codeTTL)
+	if err != nil {
+		log.Println("oidc authorize store code err:", err.Error())
+		return "", err
+	}
+	return code, nil
+}
+
+func (o *oidcUsecase) Exchange(ctx context.Context, req *models.TokenRequest) (*models.OIDCTokenResponse, error) {
+	if req.GrantType != grantTypeAuthorization {
+		return nil, oidc.ErrUnsupportedGrantType
+	}
+	client := o.clients[req.ClientID]
+	if client == nil {
+		return nil, oidc.ErrInvalidClient
+	}
+	if client.Secret != "" && subtle.ConstantTimeCompare([]byte(client.Secret), []byte(req.ClientSecret)) != 1 {
+		return nil, oidc.ErrInvalidClient
+	}
+	// RFC 7636 VERIFIERS ARE 43 TO 128 CHARACTERS LONG
+	if req.Code == "" || len(req.CodeVerifier) < 43 || len(req.CodeVerifier) > 128 {
+		return nil, oidc.ErrInvalidRequest
+	}
+	grant, err := o.codeRepo.Consume(ctx, helper.HashToken(req.Code))
+	if err != nil {
+		if err != oidc.ErrInvalidGrant {
+			log.Println("oidc exchange consume code err:", err.Error())
+		}
+		return nil, err
+	}
+	if grant.ClientID != client.ID || grant.RedirectURI != req.RedirectURI {
+		return nil, oidc.ErrInvalidGrant
+	}
+	if subtle.ConstantTimeCompare([]byte(codeChallenge(req.CodeVerifier)), []byte(grant.CodeChallenge)) != 1 {
+		return nil, oidc.ErrInvalidGrant
+	}
+	usr, err := o.userUsecase.GetByID(ctx, grant.UserID)
+	if err == sql.ErrNoRows {
+		return nil, oidc.ErrInvalidGrant
+	}
+	if err != nil {
+		log.Println("oidc exchange get user err:", err.Error())
+		return nil, err
+	}
+	// THE ACCOUNT MAY HAVE BEEN BLOCKED SINCE THE CODE WAS ISSUED
+	if user.CheckStatus(usr, time.Now()) != nil {
+		return nil, oidc.ErrInvalidGrant
+	}
+	return o.issueTokens(client, grant, usr)
+}
+
+func (o *oidcUsecase) issueTokens(client *models.OIDCClient, grant *models.AuthorizationGrant, usr *models.User) (*models.OIDCTokenResponse, error) {
+	now := time.Now()
+	ttl := auth.AccessTokenTTL()
+	standardClaims := func() jwt.StandardClaims {
+		return jwt.StandardClaims{
+			Id:        helper.RandToken(16),
+			Issuer:    o.issuer,
+			Subject:   strconv.FormatInt(usr.ID, 10),
+			Audience:  client.ID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+		}
+	}
+	accessToken, err := auth.SignOIDCClaims(&auth.OIDCClaims{
+		TokenType:      auth.OIDCAccessTokenType,
+		Scope:          grant.Scope,
+		StandardClaims: standardClaims(),
+	})
+	if err != nil {
+		log.Println("oidc sign access token err:", err.Error())
+		return nil, err
+	}
+	idClaims := &auth.OIDCClaims{
+		Nonce:          grant.Nonce,
+		StandardClaims: standardClaims(),
+	}
+	if hasScope(grant.Scope, oidc.ScopeProfile) {
+		idClaims.PreferredUsername = usr.Username
+	}
+	idToken, err := auth.SignOIDCClaims(idClaims)
+	if err != nil {
+		log.Println("oidc sign id token err:", err.Error())
+		return nil, err
+	}
+	return &models.OIDCTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(ttl.Seconds()),
+		IDToken:     idToken,
+		Scope:       grant.Scope,
+	}, nil
+}
+
+func (o *oidcUsecase) UserInfo(ctx context.Context, accessToken string) (*models.UserInfo, error) {
+	claims, err := auth.ParseOIDCAccessToken(accessToken)
+	if err != nil {
+		return nil, oidc.ErrInvalidToken
+	}
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return nil, oidc.ErrInvalidToken
+	}
+	usr, err := o.userUsecase.GetByID(ctx, userID)
+	if err == sql.ErrNoRows {
+		return nil, oidc.ErrInvalidToken
+	}
+	if err != nil {
+		log.Println("oidc userinfo get user err:", err.Error())
+		return nil, err
+	}
+	if user.CheckStatus(usr, time.Now()) != nil {
+		return nil, oidc.ErrInvalidToken
+	}
+	profile := &models.UserProfile{
+		ID:       usr.ID,
+		Username: usr.Username,
+		Nickname: usr.Nickname,
+		Email:    usr.Email,
+		Status:   usr.Status,
+	}
+	info := &models.UserInfo{Subject: claims.Subject}
+	if hasScope(claims.Scope, oidc.ScopeProfile) {
+		info.PreferredUsername = profile.Username
+		info.Nickname = profile.Nickname.String
+	}
+	if hasScope(claims.Scope, oidc.ScopeEmail) && profile.Email.String != "" {
+		verified := profile.Status != user.StatusPendingVerification
+		info.Email = profile.Email.String
+		info.EmailVerified = &verified
+	}
+	return info, nil
+}
+
+func (o *oidcUsecase) Discovery() *models.OIDCDiscovery {
+	algs := []string{}
+	if alg := auth.SigningAlgorithm(); alg != "" {
+		algs = append(algs, alg)
+	}
+	return &models.OIDCDiscovery{
+		Issuer:                            o.issuer,
+		AuthorizationEndpoint:             o.issuer + "/oauth2/authorize",
+		TokenEndpoint:                     o.issuer + "/oauth2/token",
+		UserInfoEndpoint:                  o.issuer + "/userinfo",
+		JWKSURI:                           o.issuer + "/.well-known/jwks.json",
+		ResponseTypesSupported:            []string{responseTypeCode},
+		GrantTypesSupported:               []string{grantTypeAuthorization},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  algs,
+		ScopesSupported:                   supportedScopes,
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "nonce", "preferred_username", "nickname", "email", "email_verified"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{codeChallengeMethodS256},
+	}
+}
+
+func registeredRedirectURI(client *models.OIDCClient, uri string) bool {
+	for _, registered := range client.RedirectURIs {
+		if registered == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeScope keeps the scopes we support, once each and in our order
+func normalizeScope(scope string) string {
+	requested := map[string]bool{}
+	for _, s := range strings.Fields(scope) {
+		requested[s] = true
+	}
+	kept := []string{}
+	for _, s := range supportedScopes {
+		if requested[s] {
+			kept = append(kept, s)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+func hasScope(scope, want string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,181 @@
+package usecase_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/oidc"
+	"github.com/famkampm/nentrytask/internal/oidc/mocks"
+	"github.com/famkampm/nentrytask/internal/oidc/usecase"
+	"github.com/famkampm/nentrytask/internal/user"
+	_userMocks "github.com/famkampm/nentrytask/internal/user/mocks"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gopkg.in/guregu/null.v3"
+)
+
+const verifier = "0123456789abcdef0123456789abcdef0123456789abcdef"
+
+var clients = map[string]*models.OIDCClient{
+	"wiki": {ID: "wiki", Secret: "wiki-secret", RedirectURIs: []string{"http://wiki.local/callback"}},
+	"cli":  {ID: "cli", RedirectURIs: []string{"http://127.0.0.1/a", "http://127.0.0.1/b"}},
+}
+
+func challenge(v string) string {
+	sum := sha256.Sum256([]byte(v))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func authorizationRequest() *models.AuthorizationRequest {
+	return &models.AuthorizationRequest{
+		ResponseType:        "code",
+		ClientID:            "wiki",
+		Scope:               "email openid unknown openid",
+		Nonce:               "n-1",
+		CodeChallenge:       challenge(verifier),
+		CodeChallengeMethod: "S256",
+	}
+}
+
+func useSigningKeys(t *testing.T) {
+	km, err := auth.NewKeyManager("EdDSA", "", time.Hour, time.Hour)
+	assert.NoError(t, err)
+	auth.SetKeyManager(km)
+}
+
+func TestAuthorizeUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	grant := &models.AuthorizationGrant{ClientID: "wiki", RedirectURI: "http://wiki.local/callback", UserID: 1, Scope: "openid email", Nonce: "n-1", CodeChallenge: challenge(verifier)}
+	mockRepo.On("Store", mock.Anything, mock.AnythingOfType("string"), grant, time.Minute).Return(nil).Once()
+
+	u := usecase.NewOIDCUsecase(new(_userMocks.Usecase), mockRepo, clients, "http://localhost:8080/", time.Minute)
+	code, err := u.Authorize(context.TODO(), int64(1), authorizationRequest())
+	assert.NoError(t, err)
+	assert.NotEmpty(t, code)
+	mockRepo.AssertCalled(t, "Store", mock.Anything, helper.HashToken(code), grant, time.Minute)
+}
+
+func TestAuthorizeInvalidUsecase(t *testing.T) {
+	u := usecase.NewOIDCUsecase(new(_userMocks.Usecase), new(mocks.Repository), clients, "http://localhost:8080", time.Minute)
+	cases := []struct {
+		change func(req *models.AuthorizationRequest)
+		err    error
+	}{
+		{func(req *models.AuthorizationRequest) { req.ClientID = "unknown" }, oidc.ErrInvalidClient},
+		{func(req *models.AuthorizationRequest) { req.RedirectURI = "http://evil.local/callback" }, oidc.ErrInvalidRedirectURI},
+		// cli has two redirect uris so it has to pick one
+		{func(req *models.AuthorizationRequest) { req.ClientID = "cli" }, oidc.ErrInvalidRedirectURI},
+		{func(req *models.AuthorizationRequest) { req.ResponseType = "token" }, oidc.ErrUnsupportedResponseType},
+		{func(req *models.AuthorizationRequest) { req.Scope = "profile" }, oidc.ErrInvalidScope},
+		{func(req *models.AuthorizationRequest) { req.CodeChallengeMethod = "plain" }, oidc.ErrPKCERequired},
+		{func(req *models.AuthorizationRequest) { req.CodeChallenge = "" }, oidc.ErrPKCERequired},
+	}
+	for _, c := range cases {
+		req := authorizationRequest()
+		c.change(req)
+		_, err := u.Authorize(context.TODO(), int64(1), req)
+		assert.Equal(t, c.err, err)
+	}
+}
+
+func TestExchangeUsecase(t *testing.T) {
+	useSigningKeys(t)
+	defer auth.SetKeyManager(nil)
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("Consume", mock.Anything, helper.HashToken("code")).Return(&models.AuthorizationGrant{ClientID: "wiki", RedirectURI: "http://wiki.local/callback", UserID: 1, Scope: "openid profile", Nonce: "n-1", CodeChallenge: challenge(verifier)}, nil).Once()
+	mockUserUsecase := new(_userMocks.Usecase)
+	mockUserUsecase.On("GetByID", mock.Anything, int64(1)).Return(&models.User{ID: 1, Username: "alice", Status: user.StatusActive}, nil).Once()
+
+	u := usecase.NewOIDCUsecase(mockUserUsecase, mockRepo, clients, "http://localhost:8080", time.Minute)
+	tokens, err := u.Exchange(context.TODO(), &models.TokenRequest{GrantType: "authorization_code", Code: "code", RedirectURI: "http://wiki.local/callback", ClientID: "wiki", ClientSecret: "wiki-secret", CodeVerifier: verifier})
+	assert.NoError(t, err)
+	assert.Equal(t, "openid profile", tokens.Scope)
+
+	claims, err := auth.ParseOIDCAccessToken(tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", claims.Subject)
+	assert.Equal(t, "wiki", claims.Audience)
+	// the id token is not an access token
+	_, err = auth.ParseOIDCAccessToken(tokens.IDToken)
+	assert.Equal(t, auth.ErrWrongTokenType, err)
+	_, err = auth.ParseToken(tokens.AccessToken, auth.AccessTokenType)
+	assert.Equal(t, auth.ErrWrongTokenType, err)
+}
+
+func TestExchangeInvalidUsecase(t *testing.T) {
+	useSigningKeys(t)
+	defer auth.SetKeyManager(nil)
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("Consume", mock.Anything, helper.HashToken("code")).Return(&models.AuthorizationGrant{ClientID: "wiki", RedirectURI: "http://wiki.local/callback", UserID: 1, Scope: "openid", CodeChallenge: challenge(verifier)}, nil)
+	mockRepo.On("Consume", mock.Anything, helper.HashToken("used")).Return(nil, oidc.ErrInvalidGrant)
+	mockUserUsecase := new(_userMocks.Usecase)
+	mockUserUsecase.On("GetByID", mock.Anything, int64(1)).Return(&models.User{ID: 1, Status: user.StatusSuspended, SuspendedUntil: null.TimeFrom(time.Now().Add(time.Hour))}, nil)
+
+	u := usecase.NewOIDCUsecase(mockUserUsecase, mockRepo, clients, "http://localhost:8080", time.Minute)
+	valid := func() *models.TokenRequest {
+		return &models.TokenRequest{GrantType: "authorization_code", Code: "code", RedirectURI: "http://wiki.local/callback", ClientID: "wiki", ClientSecret: "wiki-secret", CodeVerifier: verifier}
+	}
+	cases := []struct {
+		change func(req *models.TokenRequest)
+		err    error
+	}{
+		{func(req *models.TokenRequest) { req.GrantType = "password" }, oidc.ErrUnsupportedGrantType},
+		{func(req *models.TokenRequest) { req.ClientSecret = "wrong" }, oidc.ErrInvalidClient},
+		{func(req *models.TokenRequest) { req.CodeVerifier = "short" }, oidc.ErrInvalidRequest},
+		{func(req *models.TokenRequest) { req.Code = "used" }, oidc.ErrInvalidGrant},
+		{func(req *models.TokenRequest) { req.RedirectURI = "http://wiki.local/other" }, oidc.ErrInvalidGrant},
+		{func(req *models.TokenRequest) { req.CodeVerifier = verifier + "0" }, oidc.ErrInvalidGrant},
+		// suspended since the code was issued
+		{func(req *models.TokenRequest) {}, oidc.ErrInvalidGrant},
+	}
+	for _, c := range cases {
+		req := valid()
+		c.change(req)
+		_, err := u.Exchange(context.TODO(), req)
+		assert.Equal(t, c.err, err)
+	}
+}
+
+func TestUserInfoUsecase(t *testing.T) {
+	useSigningKeys(t)
+	defer auth.SetKeyManager(nil)
+	mockUserUsecase := new(_userMocks.Usecase)
+	mockUserUsecase.On("GetByID", mock.Anything, int64(1)).Return(&models.User{ID: 1, Username: "alice", Nickname: null.StringFrom("Alice"), Email: null.StringFrom("alice@example.com"), Status: user.StatusPendingVerification}, nil)
+
+	u := usecase.NewOIDCUsecase(mockUserUsecase, new(mocks.Repository), clients, "http://localhost:8080", time.Minute)
+	token, err := auth.SignOIDCClaims(&auth.OIDCClaims{TokenType: auth.OIDCAccessTokenType, Scope: "openid email"})
+	assert.NoError(t, err)
+	_, err = u.UserInfo(context.TODO(), token)
+	assert.Equal(t, oidc.ErrInvalidToken, err)
+
+	claims := &auth.OIDCClaims{TokenType: auth.OIDCAccessTokenType, Scope: "openid email"}
+	claims.Id = "jti"
+	claims.Subject = "1"
+	claims.ExpiresAt = time.Now().Add(time.Minute).Unix()
+	token, err = auth.SignOIDCClaims(claims)
+	assert.NoError(t, err)
+	info, err := u.UserInfo(context.TODO(), token)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", info.Subject)
+	assert.Equal(t, "", info.PreferredUsername)
+	assert.Equal(t, "alice@example.com", info.Email)
+	assert.False(t, *info.EmailVerified)
+}
+
+func TestDiscoveryUsecase(t *testing.T) {
+	u := usecase.NewOIDCUsecase(new(_userMocks.Usecase), new(mocks.Repository), clients, "http://localhost:8080/", time.Minute)
+	doc := u.Discovery()
+	assert.Equal(t, "http://localhost:8080", doc.Issuer)
+	assert.Equal(t, "http://localhost:8080/oauth2/token", doc.TokenEndpoint)
+	assert.Empty(t, doc.IDTokenSigningAlgValuesSupported)
+
+	useSigningKeys(t)
+	defer auth.SetKeyManager(nil)
+	assert.Equal(t, []string{"EdDSA"}, u.Discovery().IDTokenSigningAlgValuesSupported)
+}
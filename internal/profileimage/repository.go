@@ -0,0 +1,14 @@
+package profileimage
+
+import "context"
+
+// Repository counts the references to every stored profile image
+type Repository interface {
+	// Acquire adds a reference to the image stored as name
+	Acquire(ctx context.Context, name string) error
+	// Release drops a reference to name. When it was the last one, onLast is
+	// called while no reference can be added, so it can delete the image.
+	Release(ctx context.Context, name string, onLast func() error) error
+	// Referenced returns which of names have references
+	Referenced(ctx context.Context, names []string) ([]string, error)
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+
+	"github.com/famkampm/nentrytask/internal/profileimage"
+)
+
+type mysqlProfileImageRepository struct {
+	DB *sql.DB
+}
+
+func NewMysqlProfileImageRepository(db *sql.DB) profileimage.Repository {
+	return &mysqlProfileImageRepository{
+		DB: db,
+	}
+}
+
+// Acquire waits for a Release holding the row, so the image it deletes is
+// put again after it is gone
+func (m *mysqlProfileImageRepository) Acquire(ctx context.Context, name string) error {
+	query := `insert into profile_image_ref (name, refs) values (?, 1) on duplicate key update refs = refs + 1`
+	_, err := m.DB.ExecContext(ctx, query, name)
+	if err != nil {
+		log.Println("acquire profile image err:", err.Error())
+		return err
+	}
+	return nil
+}
+
+// Release locks the row of name until onLast is done. Images stored before
+// references were counted have no row, another user may still point at them,
+// so they are left to the image sweeper which checks the users first. A
+// failing onLast still forgets the row, what is left of the image is removed
+// by the image sweeper.
+func (m *mysqlProfileImageRepository) Release(ctx context.Context, name string, onLast func() error) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	var refs int64
+	err = tx.QueryRowContext(ctx, `select refs from profile_image_ref where name = ? for update`, name).Scan(&refs)
+	if err == sql.ErrNoRows {
+		return tx.Commit()
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Println("lock profile image err:", err.Error())
+		return err
+	}
+	if refs > 1 {
+		_, err = tx.ExecContext(ctx, `update profile_image_ref set refs = refs - 1 where name = ?`, name)
+		if err != nil {
+			tx.Rollback()
+			log.Println("release profile image err:", err.Error())
+			return err
+		}
+		return tx.Commit()
+	}
+	_, err = tx.ExecContext(ctx, `delete from profile_image_ref where name = ?`, name)
+	if err != nil {
+		tx.Rollback()
+		log.Println("delete profile image ref err:", err.Error())
+		return err
+	}
+	lastErr := onLast()
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	return lastErr
+}
+
+func (m *mysqlProfileImageRepository) Referenced(ctx context.Context, names []string) ([]string, error) {
+	referenced := []string{}
+	if len(names) == 0 {
+		return referenced, nil
+	}
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		args[i] = name
+	}
+	query := `select name from profile_image_ref where name in (?` + strings.Repeat(`, ?`, len(names)-1) + `)`
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		referenced = append(referenced, name)
+	}
+	return referenced, rows.Err()
+}
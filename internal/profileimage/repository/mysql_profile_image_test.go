@@ -0,0 +1,123 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/famkampm/nentrytask/internal/profileimage/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquireMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("insert into profile_image_ref (.+) on duplicate key update refs = refs \\+ 1").WithArgs("a.png").WillReturnResult(sqlmock.NewResult(0, 1))
+	m := repository.NewMysqlProfileImageRepository(db)
+	err = m.Acquire(context.TODO(), "a.png")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReleaseSharedMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("select refs from profile_image_ref where name = (.+) for update").WithArgs("a.png").WillReturnRows(sqlmock.NewRows([]string{"refs"}).AddRow(2))
+	mock.ExpectExec("update profile_image_ref set refs = refs - 1").WithArgs("a.png").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	m := repository.NewMysqlProfileImageRepository(db)
+	err = m.Release(context.TODO(), "a.png", func() error {
+		t.Fatal("an image with references left was deleted")
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReleaseLastMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("select refs from profile_image_ref where name = (.+) for update").WithArgs("a.png").WillReturnRows(sqlmock.NewRows([]string{"refs"}).AddRow(1))
+	mock.ExpectExec("delete from profile_image_ref").WithArgs("a.png").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	m := repository.NewMysqlProfileImageRepository(db)
+	called := false
+	err = m.Release(context.TODO(), "a.png", func() error {
+		called = true
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, called)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReleaseWithoutRowMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// AN IMAGE STORED BEFORE REFERENCES WERE COUNTED
+	mock.ExpectBegin()
+	mock.ExpectQuery("select refs from profile_image_ref where name = (.+) for update").WithArgs("a.png").WillReturnError(sql.ErrNoRows)
+	mock.ExpectCommit()
+	m := repository.NewMysqlProfileImageRepository(db)
+	err = m.Release(context.TODO(), "a.png", func() error {
+		t.Fatal("an image without a reference row was deleted")
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReleaseRollbackMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("select refs from profile_image_ref where name = (.+) for update").WithArgs("a.png").WillReturnError(errors.New("some error"))
+	mock.ExpectRollback()
+	m := repository.NewMysqlProfileImageRepository(db)
+	err = m.Release(context.TODO(), "a.png", func() error {
+		t.Fatal("an image was deleted without its reference")
+		return nil
+	})
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReferencedMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"name"}).AddRow("a.png")
+	mock.ExpectQuery("select name from profile_image_ref where name in \\(\\?, \\?\\)").WithArgs("a.png", "b.png").WillReturnRows(rows)
+	m := repository.NewMysqlProfileImageRepository(db)
+	res, err := m.Referenced(context.TODO(), []string{"a.png", "b.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.png"}, res)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
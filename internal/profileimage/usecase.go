@@ -0,0 +1,19 @@
+package profileimage
+
+import (
+	"context"
+
+	"github.com/famkampm/nentrytask/pkg/imaging"
+)
+
+// Usecase stores profile images under the hash of their normalized content,
+// so every user uploading the same image shares one copy
+type Usecase interface {
+	// Save stores set unless it is stored already and adds a reference to
+	// it. It returns the name of the original.
+	Save(ctx context.Context, set *imaging.Set) (string, error)
+	// Release drops a reference to the image, the last one deletes it
+	Release(ctx context.Context, name string) error
+	// Referenced returns which of names have references
+	Referenced(ctx context.Context, names []string) ([]string, error)
+}
@@ -0,0 +1,71 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+
+	"github.com/famkampm/nentrytask/internal/profileimage"
+	"github.com/famkampm/nentrytask/pkg/blobstore"
+	"github.com/famkampm/nentrytask/pkg/imaging"
+)
+
+type profileImageUsecase struct {
+	repo  profileimage.Repository
+	store blobstore.BlobStore
+}
+
+func NewProfileImageUsecase(repo profileimage.Repository, store blobstore.BlobStore) profileimage.Usecase {
+	return &profileImageUsecase{
+		repo:  repo,
+		store: store,
+	}
+}
+
+// contentName is the name set is stored as. Set is re-encoded, so the same
+// picture uploaded twice has the same bytes and the same name.
+func contentName(set *imaging.Set) string {
+	sum := sha256.Sum256(set.Original)
+	return hex.EncodeToString(sum[:]) + set.Extension
+}
+
+// Save takes the reference before storing, a Release deleting the same image
+// at the same time is then done before it is stored again. The original is
+// stored last and deleted first, so when it is there its thumbnails are too.
+func (p *profileImageUsecase) Save(ctx context.Context, set *imaging.Set) (string, error) {
+	name := contentName(set)
+	err := p.repo.Acquire(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	_, err = p.store.Stat(ctx, name)
+	if err == nil {
+		return name, nil
+	}
+	if err != blobstore.ErrNotFound {
+		log.Println("stat profile image err:", err.Error())
+	}
+	err = imaging.Save(ctx, p.store, name, set)
+	if err != nil {
+		releaseErr := p.Release(ctx, name)
+		if releaseErr != nil {
+			log.Println("release unsaved profile image err:", releaseErr.Error())
+		}
+		return "", err
+	}
+	return name, nil
+}
+
+func (p *profileImageUsecase) Release(ctx context.Context, name string) error {
+	if name == "" {
+		return nil
+	}
+	return p.repo.Release(ctx, name, func() error {
+		return imaging.Remove(ctx, p.store, name)
+	})
+}
+
+func (p *profileImageUsecase) Referenced(ctx context.Context, names []string) ([]string, error) {
+	return p.repo.Referenced(ctx, names)
+}
@@ -0,0 +1,119 @@
+package usecase_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/famkampm/nentrytask/internal/profileimage/mocks"
+	"github.com/famkampm/nentrytask/internal/profileimage/usecase"
+	"github.com/famkampm/nentrytask/pkg/blobstore"
+	"github.com/famkampm/nentrytask/pkg/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newSet(original string) *imaging.Set {
+	return &imaging.Set{
+		Original:   []byte(original),
+		Extension:  ".png",
+		Thumbnails: map[int][]byte{64: []byte(original + "64")},
+	}
+}
+
+func contentName(original string) string {
+	sum := sha256.Sum256([]byte(original))
+	return hex.EncodeToString(sum[:]) + ".png"
+}
+
+func newStore(t *testing.T) (string, blobstore.BlobStore) {
+	dir, err := ioutil.TempDir("", "images")
+	assert.NoError(t, err)
+	store, err := blobstore.NewLocalStore(dir, "/images/", []byte("secret"))
+	assert.NoError(t, err)
+	return dir, store
+}
+
+func TestSaveUsecase(t *testing.T) {
+	dir, store := newStore(t)
+	defer os.RemoveAll(dir)
+	name := contentName("png")
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("Acquire", mock.Anything, name).Return(nil).Twice()
+
+	u := usecase.NewProfileImageUsecase(mockRepo, store)
+	res, err := u.Save(context.TODO(), newSet("png"))
+	assert.NoError(t, err)
+	assert.Equal(t, name, res)
+	data, err := ioutil.ReadFile(filepath.Join(dir, imaging.ThumbnailName(name, 64)))
+	assert.NoError(t, err)
+	assert.Equal(t, "png64", string(data))
+
+	// THE SAME IMAGE AGAIN SHARES THE STORED ONE
+	assert.NoError(t, os.Remove(filepath.Join(dir, imaging.ThumbnailName(name, 64))))
+	res, err = u.Save(context.TODO(), newSet("png"))
+	assert.NoError(t, err)
+	assert.Equal(t, name, res)
+	_, err = os.Stat(filepath.Join(dir, imaging.ThumbnailName(name, 64)))
+	assert.True(t, os.IsNotExist(err))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSaveAcquireErrorUsecase(t *testing.T) {
+	dir, store := newStore(t)
+	defer os.RemoveAll(dir)
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("Acquire", mock.Anything, mock.Anything).Return(errors.New("some error")).Once()
+
+	u := usecase.NewProfileImageUsecase(mockRepo, store)
+	_, err := u.Save(context.TODO(), newSet("png"))
+	assert.Error(t, err)
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestSaveStoreErrorUsecase(t *testing.T) {
+	dir, store := newStore(t)
+	name := contentName("png")
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("Acquire", mock.Anything, name).Return(nil).Once()
+	mockRepo.On("Release", mock.Anything, name, mock.Anything).Return(nil).Once()
+
+	u := usecase.NewProfileImageUsecase(mockRepo, store)
+	// THE STORE CAN'T WRITE ANYMORE
+	assert.NoError(t, os.RemoveAll(dir))
+	_, err := u.Save(context.TODO(), newSet("png"))
+	assert.Error(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestReleaseUsecase(t *testing.T) {
+	dir, store := newStore(t)
+	defer os.RemoveAll(dir)
+	name := contentName("png")
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("Acquire", mock.Anything, name).Return(nil).Once()
+	mockRepo.On("Release", mock.Anything, name, mock.Anything).Return(func(ctx context.Context, name string, onLast func() error) error {
+		return onLast()
+	}).Once()
+
+	u := usecase.NewProfileImageUsecase(mockRepo, store)
+	_, err := u.Save(context.TODO(), newSet("png"))
+	assert.NoError(t, err)
+	err = u.Release(context.TODO(), name)
+	assert.NoError(t, err)
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	// USERS WITHOUT AN IMAGE HOLD NO REFERENCE
+	err = u.Release(context.TODO(), "")
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,146 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/audit"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/recovery"
+	"github.com/famkampm/nentrytask/internal/throttle"
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/famkampm/nentrytask/pkg/middlewares"
+	"github.com/famkampm/nentrytask/pkg/responses"
+	"github.com/julienschmidt/httprouter"
+)
+
+type RecoveryHandler struct {
+	Router          *httprouter.Router
+	RecoveryUsecase recovery.Usecase
+	AuditUsecase    audit.Usecase
+	// ThrottleUsecase limits /password/forgot, it must not share its
+	// policies with the login throttle
+	ThrottleUsecase throttle.Usecase
+}
+
+type forgotPasswordRequest struct {
+	Username string `json:"username"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func NewRecoveryHandler(router *httprouter.Router, us recovery.Usecase, auditUsecase audit.Usecase, throttleUsecase throttle.Usecase) {
+	handler := &RecoveryHandler{
+		Router:          router,
+		RecoveryUsecase: us,
+		AuditUsecase:    auditUsecase,
+		ThrottleUsecase: throttleUsecase,
+	}
+	handler.Router.POST("/password/forgot", middlewares.SetMiddlewareJSON(handler.ForgotPassword))
+	handler.Router.POST("/password/reset", middlewares.SetMiddlewareJSON(handler.ResetPassword))
+}
+
+// ForgotPassword is limited per username and per ip, every request counts
+// whether or not the account exists
+func (h *RecoveryHandler) ForgotPassword(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	defer r.Body.Close()
+	req := &forgotPasswordRequest{}
+	err = json.Unmarshal(body, req)
+	if err != nil || req.Username == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Required Username"))
+		return
+	}
+	// PREFIXED SO THE COUNTS NEVER MIX WITH THE LOGIN ONES
+	username, ip := forgotKey(req.Username), forgotKey(helper.ClientIP(r))
+	wait, err := h.ThrottleUsecase.Check(context.TODO(), username, ip)
+	if err != nil {
+		log.Println("forgot password throttle check err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	if wait > 0 {
+		tooManyRequests(w, wait)
+		return
+	}
+	_, err = h.ThrottleUsecase.RecordFailure(context.TODO(), username, ip)
+	if err != nil {
+		log.Println("forgot password throttle record err:", err.Error())
+	}
+	err = h.RecoveryUsecase.ForgotPassword(context.TODO(), req.Username)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, "If the account exists a reset link has been sent")
+}
+
+func (h *RecoveryHandler) ResetPassword(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	defer r.Body.Close()
+	req := &resetPasswordRequest{}
+	err = json.Unmarshal(body, req)
+	if err != nil || req.Token == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Required Token"))
+		return
+	}
+	userID, err := h.RecoveryUsecase.ResetPassword(context.TODO(), req.Token, req.Password)
+	if err == recovery.ErrInvalidResetToken {
+		h.recordAudit(r, 0, audit.ResultFailure, err.Error())
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	if validationErrors, ok := err.(helper.ValidationErrors); ok {
+		responses.VALIDATION(w, validationErrors)
+		return
+	}
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	h.recordAudit(r, userID, audit.ResultSuccess, "")
+	responses.JSON(w, http.StatusOK, "Password Updated")
+}
+
+func forgotKey(key string) string {
+	return "forgot:" + key
+}
+
+func tooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	responses.ERROR(w, http.StatusTooManyRequests, errors.New("Too Many Requests"))
+}
+
+// recordAudit logs a reset attempt, userID is 0 when the token was not valid
+func (h *RecoveryHandler) recordAudit(r *http.Request, userID int64, result, detail string) {
+	err := h.AuditUsecase.Record(context.TODO(), &models.AuditEntry{
+		ActorID:      userID,
+		TargetUserID: userID,
+		Action:       audit.ActionPasswordReset,
+		IP:           helper.ClientIP(r),
+		UserAgent:    r.UserAgent(),
+		Result:       result,
+		Detail:       detail,
+	})
+	if err != nil {
+		log.Println("record audit entry err:", err.Error())
+	}
+}
@@ -0,0 +1,14 @@
+package recovery
+
+import (
+	"context"
+	"time"
+)
+
+// Repository stores password reset tokens by their hash, never in plain text
+type Repository interface {
+	Store(ctx context.Context, tokenHash string, userID int64, ttl time.Duration) error
+	// Lookup returns the user of the token without using it up
+	Lookup(ctx context.Context, tokenHash string) (int64, error)
+	Consume(ctx context.Context, tokenHash string) (int64, error)
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/recovery"
+	"github.com/gomodule/redigo/redis"
+)
+
+type redisResetTokenRepository struct {
+	RedisPool *redis.Pool
+}
+
+func NewRedisResetTokenRepository(redisPool *redis.Pool) recovery.Repository {
+	return &redisResetTokenRepository{
+		RedisPool: redisPool,
+	}
+}
+
+func resetTokenKey(tokenHash string) string {
+	return "password_reset:" + tokenHash
+}
+
+func (r *redisResetTokenRepository) Store(ctx context.Context, tokenHash string, userID int64, ttl time.Duration) error {
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	_, err := conn.Do("SET", resetTokenKey(tokenHash), strconv.FormatInt(userID, 10), "EX", int64(ttl.Seconds()))
+	return err
+}
+
+func (r *redisResetTokenRepository) Lookup(ctx context.Context, tokenHash string) (int64, error) {
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	userID, err := redis.Int64(conn.Do("GET", resetTokenKey(tokenHash)))
+	if err == redis.ErrNil {
+		return 0, recovery.ErrInvalidResetToken
+	}
+	return userID, err
+}
+
+// Consume returns the user of the token and deletes it in the same transaction,
+// so a token can only ever be used once
+func (r *redisResetTokenRepository) Consume(ctx context.Context, tokenHash string) (int64, error) {
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	conn.Send("MULTI")
+	conn.Send("GET", resetTokenKey(tokenHash))
+	conn.Send("DEL", resetTokenKey(tokenHash))
+	values, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return 0, err
+	}
+	if values[0] == nil {
+		return 0, recovery.ErrInvalidResetToken
+	}
+	return redis.Int64(values[0], nil)
+}
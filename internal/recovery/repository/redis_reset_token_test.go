@@ -0,0 +1,81 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/famkampm/nentrytask/internal/recovery"
+	"github.com/famkampm/nentrytask/internal/recovery/repository"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPool(t *testing.T) (*miniredis.Miniredis, *redis.Pool) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when starting miniredis", err)
+	}
+	addr := s.Addr()
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr)
+		},
+	}
+	return s, pool
+}
+
+func TestConsumeSuccessRedis(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := repository.NewRedisResetTokenRepository(pool)
+
+	err := r.Store(context.TODO(), "hash1", int64(1), time.Minute)
+	assert.NoError(t, err)
+	userID, err := r.Consume(context.TODO(), "hash1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), userID)
+}
+
+func TestLookupThenConsumeRedis(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := repository.NewRedisResetTokenRepository(pool)
+
+	err := r.Store(context.TODO(), "hash1", int64(1), time.Minute)
+	assert.NoError(t, err)
+	userID, err := r.Lookup(context.TODO(), "hash1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), userID)
+	userID, err = r.Consume(context.TODO(), "hash1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), userID)
+	_, err = r.Lookup(context.TODO(), "hash1")
+	assert.Equal(t, recovery.ErrInvalidResetToken, err)
+}
+
+func TestConsumeTwiceRedis(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := repository.NewRedisResetTokenRepository(pool)
+
+	err := r.Store(context.TODO(), "hash1", int64(1), time.Minute)
+	assert.NoError(t, err)
+	_, err = r.Consume(context.TODO(), "hash1")
+	assert.NoError(t, err)
+	_, err = r.Consume(context.TODO(), "hash1")
+	assert.Equal(t, recovery.ErrInvalidResetToken, err)
+}
+
+func TestConsumeExpiredRedis(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := repository.NewRedisResetTokenRepository(pool)
+
+	err := r.Store(context.TODO(), "hash1", int64(1), time.Minute)
+	assert.NoError(t, err)
+	s.FastForward(2 * time.Minute)
+	_, err = r.Consume(context.TODO(), "hash1")
+	assert.Equal(t, recovery.ErrInvalidResetToken, err)
+}
@@ -0,0 +1,13 @@
+package recovery
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrInvalidResetToken = errors.New("Invalid Or Expired Reset Token")
+
+type Usecase interface {
+	ForgotPassword(ctx context.Context, username string) error
+	ResetPassword(ctx context.Context, token, password string) (int64, error)
+}
@@ -0,0 +1,126 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/recovery"
+	"github.com/famkampm/nentrytask/internal/session"
+	"github.com/famkampm/nentrytask/internal/user"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/famkampm/nentrytask/pkg/mailer"
+)
+
+type recoveryUsecase struct {
+	userUsecase    user.Usecase
+	resetTokenRepo recovery.Repository
+	mailer         mailer.Mailer
+	revoker        auth.Revoker
+	sessionUsecase session.Usecase
+	tokenTTL       time.Duration
+	resetURL       string
+}
+
+func NewRecoveryUsecase(us user.Usecase, repo recovery.Repository, m mailer.Mailer, revoker auth.Revoker, sessionUsecase session.Usecase, tokenTTL time.Duration, resetURL string) recovery.Usecase {
+	return &recoveryUsecase{
+		userUsecase:    us,
+		resetTokenRepo: repo,
+		mailer:         m,
+		revoker:        revoker,
+		sessionUsecase: sessionUsecase,
+		tokenTTL:       tokenTTL,
+		resetURL:       resetURL,
+	}
+}
+
+// ForgotPassword mails a reset link to the user. Unknown usernames and users
+// without email succeed silently so the endpoint can't be used to probe accounts.
+func (r *recoveryUsecase) ForgotPassword(ctx context.Context, username string) error {
+	u, err := r.userUsecase.GetByUsername(ctx, username)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		log.Println("forgot password get user err:", err.Error())
+		return err
+	}
+	if !u.Email.Valid || u.Email.String == "" {
+		log.Println("forgot password user has no email. id:", u.ID)
+		return nil
+	}
+	token := helper.RandToken(32)
+	err = r.resetTokenRepo.Store(ctx, helper.HashToken(token), u.ID, r.tokenTTL)
+	if err != nil {
+		log.Println("forgot password store token err:", err.Error())
+		return err
+	}
+	msg := &mailer.Message{
+		To:      u.Email.String,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %s and can only be used once.\n\n%s%s\n\nIf you did not ask for this you can ignore this email.\n",
+			u.Username, r.tokenTTL, r.resetURL, token),
+	}
+	err = r.mailer.Send(ctx, msg)
+	if err != nil {
+		log.Println("forgot password send mail err:", err.Error())
+		return err
+	}
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token and logs the user out
+// everywhere. It returns the id of the user the token belonged to.
+func (r *recoveryUsecase) ResetPassword(ctx context.Context, token, password string) (int64, error) {
+	// THE TOKEN IS ONLY USED UP ONCE THE NEW PASSWORD PASSED THE POLICY
+	userID, err := r.resetTokenRepo.Lookup(ctx, helper.HashToken(token))
+	if err != nil {
+		return 0, err
+	}
+	u, err := r.userUsecase.GetByID(ctx, userID)
+	if err != nil {
+		log.Println("reset password get user err:", err.Error())
+		return 0, err
+	}
+	err = helper.Validate("password", u.Username, password)
+	if err != nil {
+		return 0, err
+	}
+	userID, err = r.resetTokenRepo.Consume(ctx, helper.HashToken(token))
+	if err != nil {
+		return 0, err
+	}
+	hashedPassword, err := helper.HashingPassword(password)
+	if err != nil {
+		return 0, err
+	}
+	err = r.userUsecase.UpdatePassword(ctx, userID, hashedPassword)
+	if err != nil {
+		log.Println("reset password update err:", err.Error())
+		return 0, err
+	}
+	err = r.endSessions(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// endSessions revokes every token issued so far and ends every session
+func (r *recoveryUsecase) endSessions(ctx context.Context, userID int64) error {
+	err := r.revoker.RevokeUser(ctx, userID)
+	if err != nil {
+		log.Println("reset password revoke tokens err:", err.Error())
+		return err
+	}
+	// NO SESSION IS CURRENT, SO THIS ENDS ALL OF THEM
+	_, err = r.sessionUsecase.RevokeOthers(ctx, userID, "")
+	if err != nil {
+		log.Println("reset password revoke sessions err:", err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package usecase_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/recovery"
+	"github.com/famkampm/nentrytask/internal/recovery/mocks"
+	"github.com/famkampm/nentrytask/internal/recovery/usecase"
+	_sessionMocks "github.com/famkampm/nentrytask/internal/session/mocks"
+	_userMocks "github.com/famkampm/nentrytask/internal/user/mocks"
+	_authMocks "github.com/famkampm/nentrytask/pkg/auth/mocks"
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/famkampm/nentrytask/pkg/mailer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestForgotPasswordSuccessUsecase(t *testing.T) {
+	mockUserUsecase := new(_userMocks.Usecase)
+	mockRepo := new(mocks.Repository)
+	mockRevoker := new(_authMocks.Revoker)
+	out := &bytes.Buffer{}
+	mockUser := &models.User{
+		ID:       int64(1),
+		Username: "user1",
+		Email:    null.StringFrom("user1@example.com"),
+	}
+	mockUserUsecase.On("GetByUsername", mock.Anything, "user1").Return(mockUser, nil).Once()
+	mockRepo.On("Store", mock.Anything, mock.AnythingOfType("string"), int64(1), time.Minute).Return(nil).Once()
+
+	u := usecase.NewRecoveryUsecase(mockUserUsecase, mockRepo, mailer.NewLogMailer(out), mockRevoker, new(_sessionMocks.Usecase), time.Minute, "http://reset/?token=")
+	err := u.ForgotPassword(context.TODO(), "user1")
+	assert.NoError(t, err)
+
+	// the mailed token must be the one stored, hashed
+	token := regexp.MustCompile(`http://reset/\?token=([0-9a-f]+)`).FindStringSubmatch(out.String())
+	assert.Len(t, token, 2)
+	mockRepo.AssertCalled(t, "Store", mock.Anything, helper.HashToken(token[1]), int64(1), time.Minute)
+	assert.Contains(t, out.String(), "To: user1@example.com")
+	mockUserUsecase.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestForgotPasswordUnknownUserUsecase(t *testing.T) {
+	mockUserUsecase := new(_userMocks.Usecase)
+	mockRepo := new(mocks.Repository)
+	mockRevoker := new(_authMocks.Revoker)
+	out := &bytes.Buffer{}
+	mockUserUsecase.On("GetByUsername", mock.Anything, "user1").Return(&models.User{}, sql.ErrNoRows).Once()
+
+	u := usecase.NewRecoveryUsecase(mockUserUsecase, mockRepo, mailer.NewLogMailer(out), mockRevoker, new(_sessionMocks.Usecase), time.Minute, "")
+	err := u.ForgotPassword(context.TODO(), "user1")
+	assert.NoError(t, err)
+	assert.Empty(t, out.String())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestForgotPasswordNoEmailUsecase(t *testing.T) {
+	mockUserUsecase := new(_userMocks.Usecase)
+	mockRepo := new(mocks.Repository)
+	mockRevoker := new(_authMocks.Revoker)
+	out := &bytes.Buffer{}
+	mockUserUsecase.On("GetByUsername", mock.Anything, "user1").Return(&models.User{ID: int64(1)}, nil).Once()
+
+	u := usecase.NewRecoveryUsecase(mockUserUsecase, mockRepo, mailer.NewLogMailer(out), mockRevoker, new(_sessionMocks.Usecase), time.Minute, "")
+	err := u.ForgotPassword(context.TODO(), "user1")
+	assert.NoError(t, err)
+	assert.Empty(t, out.String())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestResetPasswordSuccessUsecase(t *testing.T) {
+	mockUserUsecase := new(_userMocks.Usecase)
+	mockRepo := new(mocks.Repository)
+	mockRevoker := new(_authMocks.Revoker)
+	mockSessionUsecase := new(_sessionMocks.Usecase)
+	mockRepo.On("Lookup", mock.Anything, helper.HashToken("token1")).Return(int64(1), nil).Once()
+	mockUserUsecase.On("GetByID", mock.Anything, int64(1)).Return(&models.User{ID: int64(1), Username: "user1"}, nil).Once()
+	mockRepo.On("Consume", mock.Anything, helper.HashToken("token1")).Return(int64(1), nil).Once()
+	mockUserUsecase.On("UpdatePassword", mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil).Once()
+	mockRevoker.On("RevokeUser", mock.Anything, int64(1)).Return(nil).Once()
+	mockSessionUsecase.On("RevokeOthers", mock.Anything, int64(1), "").Return(2, nil).Once()
+
+	u := usecase.NewRecoveryUsecase(mockUserUsecase, mockRepo, mailer.NewLogMailer(&bytes.Buffer{}), mockRevoker, mockSessionUsecase, time.Minute, "")
+	userID, err := u.ResetPassword(context.TODO(), "token1", "newpass1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), userID)
+	mockUserUsecase.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+	mockRevoker.AssertExpectations(t)
+	mockSessionUsecase.AssertExpectations(t)
+}
+
+func TestResetPasswordInvalidTokenUsecase(t *testing.T) {
+	mockUserUsecase := new(_userMocks.Usecase)
+	mockRepo := new(mocks.Repository)
+	mockRevoker := new(_authMocks.Revoker)
+	mockRepo.On("Lookup", mock.Anything, helper.HashToken("token1")).Return(int64(0), recovery.ErrInvalidResetToken).Once()
+
+	u := usecase.NewRecoveryUsecase(mockUserUsecase, mockRepo, mailer.NewLogMailer(&bytes.Buffer{}), mockRevoker, new(_sessionMocks.Usecase), time.Minute, "")
+	_, err := u.ResetPassword(context.TODO(), "token1", "newpass1")
+	assert.Equal(t, recovery.ErrInvalidResetToken, err)
+	mockUserUsecase.AssertExpectations(t)
+	mockRevoker.AssertExpectations(t)
+}
+
+func TestResetPasswordSameAsUsernameUsecase(t *testing.T) {
+	mockUserUsecase := new(_userMocks.Usecase)
+	mockRepo := new(mocks.Repository)
+	mockRevoker := new(_authMocks.Revoker)
+	mockRepo.On("Lookup", mock.Anything, helper.HashToken("token1")).Return(int64(1), nil).Once()
+	mockUserUsecase.On("GetByID", mock.Anything, int64(1)).Return(&models.User{ID: int64(1), Username: "newpass1"}, nil).Once()
+
+	// THE TOKEN IS NOT CONSUMED, THE USER CAN TRY ANOTHER PASSWORD
+	u := usecase.NewRecoveryUsecase(mockUserUsecase, mockRepo, mailer.NewLogMailer(&bytes.Buffer{}), mockRevoker, new(_sessionMocks.Usecase), time.Minute, "")
+	_, err := u.ResetPassword(context.TODO(), "token1", "newpass1")
+	_, ok := err.(helper.ValidationErrors)
+	assert.True(t, ok)
+	mockRepo.AssertNotCalled(t, "Consume", mock.Anything, mock.Anything)
+	mockUserUsecase.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestResetPasswordFailedUpdateUsecase(t *testing.T) {
+	mockUserUsecase := new(_userMocks.Usecase)
+	mockRepo := new(mocks.Repository)
+	mockRevoker := new(_authMocks.Revoker)
+	mockRepo.On("Lookup", mock.Anything, helper.HashToken("token1")).Return(int64(1), nil).Once()
+	mockUserUsecase.On("GetByID", mock.Anything, int64(1)).Return(&models.User{ID: int64(1), Username: "user1"}, nil).Once()
+	mockRepo.On("Consume", mock.Anything, helper.HashToken("token1")).Return(int64(1), nil).Once()
+	mockUserUsecase.On("UpdatePassword", mock.Anything, int64(1), mock.AnythingOfType("string")).Return(errors.New("some error")).Once()
+
+	u := usecase.NewRecoveryUsecase(mockUserUsecase, mockRepo, mailer.NewLogMailer(&bytes.Buffer{}), mockRevoker, new(_sessionMocks.Usecase), time.Minute, "")
+	_, err := u.ResetPassword(context.TODO(), "token1", "newpass1")
+	assert.Error(t, err)
+	mockRevoker.AssertExpectations(t)
+}
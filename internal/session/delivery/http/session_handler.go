@@ -0,0 +1,86 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/famkampm/nentrytask/internal/session"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/famkampm/nentrytask/pkg/middlewares"
+	"github.com/famkampm/nentrytask/pkg/responses"
+	"github.com/julienschmidt/httprouter"
+)
+
+type SessionHandler struct {
+	Router         *httprouter.Router
+	SessionUsecase session.Usecase
+}
+
+type revokeOthersResponse struct {
+	Revoked int `json:"revoked"`
+}
+
+// NewSessionHandler registers the session routes. They act on the sessions of
+// whoever is logged in, so none takes a user id.
+func NewSessionHandler(router *httprouter.Router, us session.Usecase, mw *middlewares.Middleware) {
+	handler := &SessionHandler{
+		Router:         router,
+		SessionUsecase: us,
+	}
+	handler.Router.GET("/sessions", middlewares.SetMiddlewareJSON(mw.SetMiddlewareToken(handler.Fetch)))
+	handler.Router.DELETE("/sessions", middlewares.SetMiddlewareJSON(mw.SetMiddlewareToken(handler.RevokeOthers)))
+	handler.Router.DELETE("/sessions/:sid", middlewares.SetMiddlewareJSON(mw.SetMiddlewareToken(handler.Revoke)))
+}
+
+func (h *SessionHandler) Fetch(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	claims, ok := auth.FromContext(r.Context())
+	if !ok {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	sessions, err := h.SessionUsecase.Fetch(context.TODO(), claims.UserID, claims.SessionID)
+	if err != nil {
+		log.Println("fetch sessions err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, sessions)
+}
+
+// Revoke ends one session of the caller, the current one included
+func (h *SessionHandler) Revoke(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	claims, ok := auth.FromContext(r.Context())
+	if !ok {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	err := h.SessionUsecase.Revoke(context.TODO(), claims.UserID, ps.ByName("sid"))
+	if err == session.ErrSessionNotFound {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		log.Println("revoke session err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, "Session Revoked")
+}
+
+// RevokeOthers ends every session of the caller except the current one
+func (h *SessionHandler) RevokeOthers(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	claims, ok := auth.FromContext(r.Context())
+	if !ok {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	revoked, err := h.SessionUsecase.RevokeOthers(context.TODO(), claims.UserID, claims.SessionID)
+	if err != nil {
+		log.Println("revoke other sessions err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, &revokeOthersResponse{Revoked: revoked})
+}
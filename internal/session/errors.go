@@ -0,0 +1,5 @@
+package session
+
+import "errors"
+
+var ErrSessionNotFound = errors.New("Session Not Found")
@@ -0,0 +1,26 @@
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/models"
+)
+
+// Repository keeps the sessions in mysql
+type Repository interface {
+	Store(ctx context.Context, sess *models.Session) error
+	FetchByUserID(ctx context.Context, userID int64) ([]*models.Session, error)
+	Delete(ctx context.Context, userID int64, ids ...string) (int64, error)
+}
+
+// ActivityRepository tracks which sessions are live and when they were last
+// seen. It is written on every authenticated request so it lives in redis.
+type ActivityRepository interface {
+	Start(ctx context.Context, id string, at time.Time, ttl time.Duration) error
+	// Touch updates the last seen time and reports false when the session is
+	// not live anymore
+	Touch(ctx context.Context, id string, at time.Time, ttl time.Duration) (bool, error)
+	LastSeen(ctx context.Context, ids []string) ([]*time.Time, error)
+	End(ctx context.Context, ids ...string) error
+}
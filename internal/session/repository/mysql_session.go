@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/session"
+)
+
+type mysqlSessionRepository struct {
+	DB *sql.DB
+}
+
+func NewMysqlSessionRepository(db *sql.DB) session.Repository {
+	return &mysqlSessionRepository{
+		DB: db,
+	}
+}
+
+func (m *mysqlSessionRepository) Store(ctx context.Context, sess *models.Session) error {
+	query := `insert into user_session (id, user_id, user_agent, ip, created_at, last_seen_at) values (?, ?, ?, ?, ?, ?)`
+	_, err := m.DB.ExecContext(ctx, query, sess.ID, sess.UserID, sess.UserAgent, sess.IP, sess.CreatedAt, sess.LastSeenAt)
+	if err != nil {
+		log.Println("store session err:", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (m *mysqlSessionRepository) FetchByUserID(ctx context.Context, userID int64) ([]*models.Session, error) {
+	query := `select id, user_id, user_agent, ip, created_at, last_seen_at from user_session where user_id = ? order by created_at desc`
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.Println("fetch sessions err:", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := []*models.Session{}
+	for rows.Next() {
+		sess := &models.Session{}
+		err = rows.Scan(&sess.ID, &sess.UserID, &sess.UserAgent, &sess.IP, &sess.CreatedAt, &sess.LastSeenAt)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, sess)
+	}
+	return sessions, rows.Err()
+}
+
+// Delete removes the given sessions of the user and returns how many existed
+func (m *mysqlSessionRepository) Delete(ctx context.Context, userID int64, ids ...string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	args := []interface{}{userID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	query := `delete from user_session where user_id = ? and id in (?` + strings.Repeat(", ?", len(ids)-1) + `)`
+	res, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		log.Println("delete sessions err:", err.Error())
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,45 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/famkampm/nentrytask/internal/session/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchByUserIDMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	now := time.Now().UTC()
+	rows := sqlmock.NewRows([]string{"id", "user_id", "user_agent", "ip", "created_at", "last_seen_at"}).
+		AddRow("sid1", 1, "curl/7.0", "10.0.0.1", now, now)
+	mock.ExpectQuery("select (.+) from user_session where user_id").WithArgs(1).WillReturnRows(rows)
+	m := repository.NewMysqlSessionRepository(db)
+	res, err := m.FetchByUserID(context.TODO(), int64(1))
+	assert.NoError(t, err)
+	assert.Len(t, res, 1)
+	assert.Equal(t, "sid1", res[0].ID)
+	assert.Equal(t, "10.0.0.1", res[0].IP)
+}
+
+func TestDeleteMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("delete from user_session where user_id = (.+) and id in").WithArgs(1, "sid1", "sid2").WillReturnResult(sqlmock.NewResult(0, 2))
+	m := repository.NewMysqlSessionRepository(db)
+	deleted, err := m.Delete(context.TODO(), int64(1), "sid1", "sid2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/session"
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/gomodule/redigo/redis"
+)
+
+type redisSessionRepository struct {
+	RedisPool *redis.Pool
+}
+
+func NewRedisSessionRepository(redisPool *redis.Pool) session.ActivityRepository {
+	return &redisSessionRepository{
+		RedisPool: redisPool,
+	}
+}
+
+func seenKey(id string) string {
+	return "session_seen:" + id
+}
+
+// Start marks the session live. The key holds the last seen time, with
+// nanoseconds to compare it with user wide revocations, and expires after ttl of inactivity, when no token of the session is valid anymore.
+func (r *redisSessionRepository) Start(ctx context.Context, id string, at time.Time, ttl time.Duration) error {
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	_, err := conn.Do("SET", seenKey(id), helper.FormatUnixNano(at), "EX", int64(ttl.Seconds()))
+	return err
+}
+
+// Touch only updates an existing key, so ended sessions stay ended
+func (r *redisSessionRepository) Touch(ctx context.Context, id string, at time.Time, ttl time.Duration) (bool, error) {
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	reply, err := conn.Do("SET", seenKey(id), helper.FormatUnixNano(at), "EX", int64(ttl.Seconds()), "XX")
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+// LastSeen returns the last seen time of each id in order, nil for the ones
+// that are not live
+func (r *redisSessionRepository) LastSeen(ctx context.Context, ids []string) ([]*time.Time, error) {
+	res := make([]*time.Time, len(ids))
+	if len(ids) == 0 {
+		return res, nil
+	}
+	keys := make([]interface{}, len(ids))
+	for i, id := range ids {
+		keys[i] = seenKey(id)
+	}
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	values, err := redis.Values(conn.Do("MGET", keys...))
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		raw, err := redis.String(value, nil)
+		if err != nil {
+			return nil, err
+		}
+		seen, err := helper.ParseUnixNano(raw)
+		if err != nil {
+			return nil, err
+		}
+		seen = seen.UTC()
+		res[i] = &seen
+	}
+	return res, nil
+}
+
+func (r *redisSessionRepository) End(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	keys := make([]interface{}, len(ids))
+	for i, id := range ids {
+		keys[i] = seenKey(id)
+	}
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	_, err := conn.Do("DEL", keys...)
+	return err
+}
@@ -0,0 +1,64 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/famkampm/nentrytask/internal/session/repository"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPool(t *testing.T) (*miniredis.Miniredis, *redis.Pool) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when starting miniredis", err)
+	}
+	addr := s.Addr()
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr)
+		},
+	}
+	return s, pool
+}
+
+func TestTouchRedis(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := repository.NewRedisSessionRepository(pool)
+
+	start := time.Unix(1000, 0).UTC()
+	live, err := r.Touch(context.TODO(), "sid1", start, time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, live)
+
+	assert.NoError(t, r.Start(context.TODO(), "sid1", start, time.Hour))
+	live, err = r.Touch(context.TODO(), "sid1", start.Add(time.Minute), time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, live)
+
+	seen, err := r.LastSeen(context.TODO(), []string{"sid1", "sid2"})
+	assert.NoError(t, err)
+	assert.Equal(t, start.Add(time.Minute), *seen[0])
+	assert.Nil(t, seen[1])
+
+	assert.NoError(t, r.End(context.TODO(), "sid1"))
+	live, err = r.Touch(context.TODO(), "sid1", start.Add(2*time.Minute), time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, live)
+}
+
+func TestStartExpiresRedis(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := repository.NewRedisSessionRepository(pool)
+
+	assert.NoError(t, r.Start(context.TODO(), "sid1", time.Now(), time.Hour))
+	s.FastForward(2 * time.Hour)
+	live, err := r.Touch(context.TODO(), "sid1", time.Now(), time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, live)
+}
@@ -0,0 +1,16 @@
+package session
+
+import (
+	"context"
+
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/pkg/auth"
+)
+
+type Usecase interface {
+	Start(ctx context.Context, sess *models.Session) error
+	Touch(ctx context.Context, claims *auth.Claims) (bool, error)
+	Fetch(ctx context.Context, userID int64, currentID string) ([]*models.Session, error)
+	Revoke(ctx context.Context, userID int64, id string) error
+	RevokeOthers(ctx context.Context, userID int64, currentID string) (int, error)
+}
@@ -0,0 +1,158 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/session"
+	"github.com/famkampm/nentrytask/pkg/auth"
+)
+
+const maxUserAgentLength = 255
+
+type sessionUsecase struct {
+	sessionRepo  session.Repository
+	activityRepo session.ActivityRepository
+	revoker      auth.Revoker
+}
+
+func NewSessionUsecase(repo session.Repository, activityRepo session.ActivityRepository, revoker auth.Revoker) session.Usecase {
+	return &sessionUsecase{
+		sessionRepo:  repo,
+		activityRepo: activityRepo,
+		revoker:      revoker,
+	}
+}
+
+// Start records a new login. Its activity lives as long as a refresh token
+// so the session ends on its own when nobody refreshes it.
+func (s *sessionUsecase) Start(ctx context.Context, sess *models.Session) error {
+	now := time.Now().UTC()
+	sess.CreatedAt = now
+	sess.LastSeenAt = now
+	if len(sess.UserAgent) > maxUserAgentLength {
+		sess.UserAgent = sess.UserAgent[:maxUserAgentLength]
+	}
+	err := s.sessionRepo.Store(ctx, sess)
+	if err != nil {
+		return err
+	}
+	return s.activityRepo.Start(ctx, sess.ID, now, auth.RefreshTokenTTL())
+}
+
+// Touch is called on every authenticated request. Only redis is written, mysql
+// keeps the login time and the list reads last seen from redis.
+func (s *sessionUsecase) Touch(ctx context.Context, claims *auth.Claims) (bool, error) {
+	return s.activityRepo.Touch(ctx, claims.SessionID, time.Now().UTC(), auth.RefreshTokenTTL())
+}
+
+// Fetch lists the live sessions of the user and forgets the ended ones.
+// A session is also ended when every token of it was revoked by a user wide
+// revocation, i.e. it wasn't seen since then.
+func (s *sessionUsecase) Fetch(ctx context.Context, userID int64, currentID string) ([]*models.Session, error) {
+	sessions, err := s.sessionRepo.FetchByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(sessions))
+	for i, sess := range sessions {
+		ids[i] = sess.ID
+	}
+	lastSeen, err := s.activityRepo.LastSeen(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	live := []*models.Session{}
+	ended := []string{}
+	for i, sess := range sessions {
+		if lastSeen[i] == nil {
+			ended = append(ended, sess.ID)
+			continue
+		}
+		revoked, err := s.revoker.IsRevoked(ctx, &auth.Claims{
+			UserID:         userID,
+			SessionID:      sess.ID,
+			IssuedAtNano:   lastSeen[i].UnixNano(),
+			StandardClaims: jwt.StandardClaims{IssuedAt: lastSeen[i].Unix()},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			ended = append(ended, sess.ID)
+			continue
+		}
+		sess.LastSeenAt = *lastSeen[i]
+		sess.Current = sess.ID == currentID
+		live = append(live, sess)
+	}
+	if len(ended) > 0 {
+		err = s.end(ctx, userID, ended)
+		if err != nil {
+			log.Println("fetch sessions cleanup err:", err.Error())
+		}
+	}
+	return live, nil
+}
+
+func (s *sessionUsecase) Revoke(ctx context.Context, userID int64, id string) error {
+	deleted, err := s.sessionRepo.Delete(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return session.ErrSessionNotFound
+	}
+	return s.revoke(ctx, []string{id})
+}
+
+// RevokeOthers ends every session of the user but the current one
+func (s *sessionUsecase) RevokeOthers(ctx context.Context, userID int64, currentID string) (int, error) {
+	sessions, err := s.sessionRepo.FetchByUserID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	others := []string{}
+	for _, sess := range sessions {
+		if sess.ID != currentID {
+			others = append(others, sess.ID)
+		}
+	}
+	if len(others) == 0 {
+		return 0, nil
+	}
+	err = s.revoke(ctx, others)
+	if err != nil {
+		return 0, err
+	}
+	_, err = s.sessionRepo.Delete(ctx, userID, others...)
+	if err != nil {
+		return 0, err
+	}
+	return len(others), nil
+}
+
+// revoke rejects the tokens of the sessions and ends their activity
+func (s *sessionUsecase) revoke(ctx context.Context, ids []string) error {
+	until := time.Now().Add(auth.RefreshTokenTTL())
+	for _, id := range ids {
+		err := s.revoker.Revoke(ctx, id, until)
+		if err != nil {
+			return err
+		}
+	}
+	return s.activityRepo.End(ctx, ids...)
+}
+
+// end forgets sessions whose tokens are not accepted anymore
+func (s *sessionUsecase) end(ctx context.Context, userID int64, ids []string) error {
+	err := s.activityRepo.End(ctx, ids...)
+	if err != nil {
+		return err
+	}
+	_, err = s.sessionRepo.Delete(ctx, userID, ids...)
+	return err
+}
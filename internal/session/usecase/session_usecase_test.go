@@ -0,0 +1,129 @@
+package usecase_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/session"
+	"github.com/famkampm/nentrytask/internal/session/mocks"
+	"github.com/famkampm/nentrytask/internal/session/repository"
+	"github.com/famkampm/nentrytask/internal/session/usecase"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	_authMocks "github.com/famkampm/nentrytask/pkg/auth/mocks"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestStartUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockActivity := new(mocks.ActivityRepository)
+	mockRepo.On("Store", mock.Anything, mock.AnythingOfType("*models.Session")).Return(nil).Once()
+	mockActivity.On("Start", mock.Anything, "sid1", mock.AnythingOfType("time.Time"), auth.RefreshTokenTTL()).Return(nil).Once()
+
+	u := usecase.NewSessionUsecase(mockRepo, mockActivity, new(_authMocks.Revoker))
+	sess := &models.Session{ID: "sid1", UserID: 1, UserAgent: string(make([]byte, 300))}
+	err := u.Start(context.TODO(), sess)
+	assert.NoError(t, err)
+	assert.Len(t, sess.UserAgent, 255)
+	assert.False(t, sess.CreatedAt.IsZero())
+	mockRepo.AssertExpectations(t)
+	mockActivity.AssertExpectations(t)
+}
+
+func TestFetchUsecase(t *testing.T) {
+	seen := time.Now().UTC()
+	mockRepo := new(mocks.Repository)
+	mockActivity := new(mocks.ActivityRepository)
+	mockRevoker := new(_authMocks.Revoker)
+	mockRepo.On("FetchByUserID", mock.Anything, int64(1)).Return([]*models.Session{{ID: "sid1", UserID: 1}, {ID: "sid2", UserID: 1}, {ID: "sid3", UserID: 1}}, nil).Once()
+	mockActivity.On("LastSeen", mock.Anything, []string{"sid1", "sid2", "sid3"}).Return([]*time.Time{&seen, nil, &seen}, nil).Once()
+	mockRevoker.On("IsRevoked", mock.Anything, mock.MatchedBy(func(c *auth.Claims) bool { return c.SessionID == "sid1" })).Return(false, nil).Once()
+	mockRevoker.On("IsRevoked", mock.Anything, mock.MatchedBy(func(c *auth.Claims) bool { return c.SessionID == "sid3" })).Return(true, nil).Once()
+	// sid2 expired and sid3 was revoked, both are forgotten
+	mockActivity.On("End", mock.Anything, "sid2", "sid3").Return(nil).Once()
+	mockRepo.On("Delete", mock.Anything, int64(1), "sid2", "sid3").Return(int64(2), nil).Once()
+
+	u := usecase.NewSessionUsecase(mockRepo, mockActivity, mockRevoker)
+	sessions, err := u.Fetch(context.TODO(), int64(1), "sid1")
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	assert.True(t, sessions[0].Current)
+	assert.Equal(t, seen, sessions[0].LastSeenAt)
+	mockRepo.AssertExpectations(t)
+	mockActivity.AssertExpectations(t)
+}
+
+func TestFetchStartedAfterRevokeUserUsecase(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when starting miniredis", err)
+	}
+	defer s.Close()
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", s.Addr())
+		},
+	}
+	revoker := auth.NewRedisRevoker(pool)
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("Store", mock.Anything, mock.AnythingOfType("*models.Session")).Return(nil).Once()
+	mockRepo.On("FetchByUserID", mock.Anything, int64(1)).Return([]*models.Session{{ID: "sid1", UserID: 1}}, nil).Once()
+	u := usecase.NewSessionUsecase(mockRepo, repository.NewRedisSessionRepository(pool), revoker)
+
+	// A PASSWORD CHANGE REVOKES EVERYTHING AND LOGS IN AGAIN WITHIN THE SECOND
+	err = revoker.RevokeUser(context.TODO(), int64(1))
+	assert.NoError(t, err)
+	err = u.Start(context.TODO(), &models.Session{ID: "sid1", UserID: 1})
+	assert.NoError(t, err)
+	sessions, err := u.Fetch(context.TODO(), int64(1), "sid1")
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	mockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRevokeUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockActivity := new(mocks.ActivityRepository)
+	mockRevoker := new(_authMocks.Revoker)
+	mockRepo.On("Delete", mock.Anything, int64(1), "sid2").Return(int64(1), nil).Once()
+	mockRevoker.On("Revoke", mock.Anything, "sid2", mock.AnythingOfType("time.Time")).Return(nil).Once()
+	mockActivity.On("End", mock.Anything, "sid2").Return(nil).Once()
+
+	u := usecase.NewSessionUsecase(mockRepo, mockActivity, mockRevoker)
+	assert.NoError(t, u.Revoke(context.TODO(), int64(1), "sid2"))
+	mockRevoker.AssertExpectations(t)
+	mockActivity.AssertExpectations(t)
+}
+
+func TestRevokeNotFoundUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockRevoker := new(_authMocks.Revoker)
+	mockRepo.On("Delete", mock.Anything, int64(1), "sid2").Return(int64(0), nil).Once()
+
+	u := usecase.NewSessionUsecase(mockRepo, new(mocks.ActivityRepository), mockRevoker)
+	assert.Equal(t, session.ErrSessionNotFound, u.Revoke(context.TODO(), int64(1), "sid2"))
+	mockRevoker.AssertNotCalled(t, "Revoke", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRevokeOthersUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockActivity := new(mocks.ActivityRepository)
+	mockRevoker := new(_authMocks.Revoker)
+	mockRepo.On("FetchByUserID", mock.Anything, int64(1)).Return([]*models.Session{{ID: "sid1"}, {ID: "sid2"}, {ID: "sid3"}}, nil).Once()
+	mockRevoker.On("Revoke", mock.Anything, "sid2", mock.AnythingOfType("time.Time")).Return(nil).Once()
+	mockRevoker.On("Revoke", mock.Anything, "sid3", mock.AnythingOfType("time.Time")).Return(nil).Once()
+	mockActivity.On("End", mock.Anything, "sid2", "sid3").Return(nil).Once()
+	mockRepo.On("Delete", mock.Anything, int64(1), "sid2", "sid3").Return(int64(2), nil).Once()
+
+	u := usecase.NewSessionUsecase(mockRepo, mockActivity, mockRevoker)
+	revoked, err := u.RevokeOthers(context.TODO(), int64(1), "sid1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, revoked)
+	mockRepo.AssertExpectations(t)
+	mockRevoker.AssertExpectations(t)
+}
@@ -0,0 +1,41 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/famkampm/nentrytask/internal/throttle"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/famkampm/nentrytask/pkg/middlewares"
+	"github.com/famkampm/nentrytask/pkg/responses"
+	"github.com/julienschmidt/httprouter"
+)
+
+type ThrottleHandler struct {
+	Router          *httprouter.Router
+	ThrottleUsecase throttle.Usecase
+}
+
+func NewThrottleHandler(router *httprouter.Router, us throttle.Usecase, mw *middlewares.Middleware) {
+	handler := &ThrottleHandler{
+		Router:          router,
+		ThrottleUsecase: us,
+	}
+	handler.Router.POST("/admin/unlock/:username", middlewares.SetMiddlewareJSON(mw.SetMiddlewarePermission(auth.PermissionAccountUnlock)(handler.Unlock)))
+}
+
+// Unlock clears the failed logins and the lock of an account
+func (h *ThrottleHandler) Unlock(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	username := ps.ByName("username")
+	if username == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Required Username"))
+		return
+	}
+	err := h.ThrottleUsecase.Unlock(context.TODO(), username)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, "Account Unlocked")
+}
@@ -0,0 +1,15 @@
+package throttle
+
+import (
+	"context"
+	"time"
+)
+
+// Repository counts failed attempts per key in a sliding window and keeps
+// temporary locks on keys
+type Repository interface {
+	RecordFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	Lock(ctx context.Context, key string, ttl time.Duration) error
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	Reset(ctx context.Context, key string) error
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/throttle"
+	"github.com/gomodule/redigo/redis"
+)
+
+type redisThrottleRepository struct {
+	RedisPool *redis.Pool
+}
+
+func NewRedisThrottleRepository(redisPool *redis.Pool) throttle.Repository {
+	return &redisThrottleRepository{
+		RedisPool: redisPool,
+	}
+}
+
+func failuresKey(key string) string {
+	return "login_failures:" + key
+}
+
+func lockKey(key string) string {
+	return "login_lock:" + key
+}
+
+// RecordFailure keeps one sorted set member per failure scored by its time,
+// trims members older than the window and returns how many are left
+func (r *redisThrottleRepository) RecordFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	now := time.Now().UnixNano()
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	conn.Send("MULTI")
+	conn.Send("ZADD", failuresKey(key), now, strconv.FormatInt(now, 10))
+	conn.Send("ZREMRANGEBYSCORE", failuresKey(key), "-inf", now-window.Nanoseconds())
+	conn.Send("ZCARD", failuresKey(key))
+	conn.Send("PEXPIRE", failuresKey(key), window.Nanoseconds()/int64(time.Millisecond))
+	values, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return 0, err
+	}
+	return redis.Int(values[2], nil)
+}
+
+func (r *redisThrottleRepository) Lock(ctx context.Context, key string, ttl time.Duration) error {
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	_, err := conn.Do("SET", lockKey(key), "1", "PX", ttl.Nanoseconds()/int64(time.Millisecond))
+	return err
+}
+
+func (r *redisThrottleRepository) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	ttl, err := redis.Int64(conn.Do("PTTL", lockKey(key)))
+	if err != nil {
+		return 0, err
+	}
+	// -2 means no lock, -1 a lock without expiry which we never set
+	if ttl < 0 {
+		return 0, nil
+	}
+	return time.Duration(ttl) * time.Millisecond, nil
+}
+
+func (r *redisThrottleRepository) Reset(ctx context.Context, key string) error {
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	_, err := conn.Do("DEL", failuresKey(key), lockKey(key))
+	return err
+}
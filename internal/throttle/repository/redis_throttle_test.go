@@ -0,0 +1,74 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/famkampm/nentrytask/internal/throttle/repository"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPool(t *testing.T) (*miniredis.Miniredis, *redis.Pool) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when starting miniredis", err)
+	}
+	addr := s.Addr()
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr)
+		},
+	}
+	return s, pool
+}
+
+func TestRecordFailureRedis(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := repository.NewRedisThrottleRepository(pool)
+
+	for i := 1; i <= 3; i++ {
+		failures, err := r.RecordFailure(context.TODO(), "user:user1", time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, i, failures)
+	}
+}
+
+func TestLockRedis(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := repository.NewRedisThrottleRepository(pool)
+
+	wait, err := r.LockedFor(context.TODO(), "user:user1")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+
+	err = r.Lock(context.TODO(), "user:user1", time.Minute)
+	assert.NoError(t, err)
+	wait, err = r.LockedFor(context.TODO(), "user:user1")
+	assert.NoError(t, err)
+	assert.True(t, wait > 0 && wait <= time.Minute)
+}
+
+func TestResetRedis(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := repository.NewRedisThrottleRepository(pool)
+
+	_, err := r.RecordFailure(context.TODO(), "user:user1", time.Minute)
+	assert.NoError(t, err)
+	err = r.Lock(context.TODO(), "user:user1", time.Minute)
+	assert.NoError(t, err)
+
+	err = r.Reset(context.TODO(), "user:user1")
+	assert.NoError(t, err)
+	wait, err := r.LockedFor(context.TODO(), "user:user1")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+	failures, err := r.RecordFailure(context.TODO(), "user:user1", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, failures)
+}
@@ -0,0 +1,25 @@
+package throttle
+
+import (
+	"context"
+	"time"
+)
+
+// Policy decides when a key gets locked. Once MaxAttempts failures happened
+// within Window every further failure doubles the lock, starting at
+// BaseLockout and capped at MaxLockout.
+type Policy struct {
+	MaxAttempts int
+	Window      time.Duration
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+}
+
+// Usecase guards /login. A positive duration means the caller has to wait
+// that long before trying again.
+type Usecase interface {
+	Check(ctx context.Context, username, ip string) (time.Duration, error)
+	RecordFailure(ctx context.Context, username, ip string) (time.Duration, error)
+	RecordSuccess(ctx context.Context, username, ip string) error
+	Unlock(ctx context.Context, username string) error
+}
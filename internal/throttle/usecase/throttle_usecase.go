@@ -0,0 +1,105 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/throttle"
+)
+
+type throttleUsecase struct {
+	throttleRepo   throttle.Repository
+	usernamePolicy throttle.Policy
+	ipPolicy       throttle.Policy
+}
+
+func NewThrottleUsecase(repo throttle.Repository, usernamePolicy, ipPolicy throttle.Policy) throttle.Usecase {
+	return &throttleUsecase{
+		throttleRepo:   repo,
+		usernamePolicy: usernamePolicy,
+		ipPolicy:       ipPolicy,
+	}
+}
+
+func usernameKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check returns how long the caller still has to wait, the longest of the
+// username and the ip lock
+func (t *throttleUsecase) Check(ctx context.Context, username, ip string) (time.Duration, error) {
+	userWait, err := t.throttleRepo.LockedFor(ctx, usernameKey(username))
+	if err != nil {
+		return 0, err
+	}
+	ipWait, err := t.throttleRepo.LockedFor(ctx, ipKey(ip))
+	if err != nil {
+		return 0, err
+	}
+	if ipWait > userWait {
+		return ipWait, nil
+	}
+	return userWait, nil
+}
+
+// RecordFailure counts the failure for both username and ip and returns the
+// lock it caused, if any
+func (t *throttleUsecase) RecordFailure(ctx context.Context, username, ip string) (time.Duration, error) {
+	userWait, err := t.recordFailure(ctx, usernameKey(username), t.usernamePolicy)
+	if err != nil {
+		return 0, err
+	}
+	ipWait, err := t.recordFailure(ctx, ipKey(ip), t.ipPolicy)
+	if err != nil {
+		return 0, err
+	}
+	if ipWait > userWait {
+		return ipWait, nil
+	}
+	return userWait, nil
+}
+
+func (t *throttleUsecase) recordFailure(ctx context.Context, key string, policy throttle.Policy) (time.Duration, error) {
+	failures, err := t.throttleRepo.RecordFailure(ctx, key, policy.Window)
+	if err != nil {
+		return 0, err
+	}
+	if failures < policy.MaxAttempts {
+		return 0, nil
+	}
+	lockout := backoff(policy, failures-policy.MaxAttempts)
+	err = t.throttleRepo.Lock(ctx, key, lockout)
+	if err != nil {
+		return 0, err
+	}
+	log.Println("login locked. key:", key, "failures:", failures, "for:", lockout)
+	return lockout, nil
+}
+
+// backoff doubles the base lockout for every failure past the limit
+func backoff(policy throttle.Policy, extraFailures int) time.Duration {
+	lockout := policy.BaseLockout
+	for i := 0; i < extraFailures && lockout < policy.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > policy.MaxLockout {
+		return policy.MaxLockout
+	}
+	return lockout
+}
+
+// RecordSuccess forgets the failures of the username. The ip keeps its count
+// so one valid account can't be used to reset an attacker's budget.
+func (t *throttleUsecase) RecordSuccess(ctx context.Context, username, ip string) error {
+	return t.throttleRepo.Reset(ctx, usernameKey(username))
+}
+
+func (t *throttleUsecase) Unlock(ctx context.Context, username string) error {
+	return t.throttleRepo.Reset(ctx, usernameKey(username))
+}
@@ -0,0 +1,99 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/throttle"
+	"github.com/famkampm/nentrytask/internal/throttle/mocks"
+	"github.com/famkampm/nentrytask/internal/throttle/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var (
+	usernamePolicy = throttle.Policy{MaxAttempts: 3, Window: time.Minute, BaseLockout: time.Minute, MaxLockout: 10 * time.Minute}
+	ipPolicy       = throttle.Policy{MaxAttempts: 10, Window: time.Minute, BaseLockout: time.Minute, MaxLockout: 10 * time.Minute}
+)
+
+func TestCheckLockedUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("LockedFor", mock.Anything, "user:user1").Return(time.Minute, nil).Once()
+	mockRepo.On("LockedFor", mock.Anything, "ip:127.0.0.1").Return(2*time.Minute, nil).Once()
+
+	u := usecase.NewThrottleUsecase(mockRepo, usernamePolicy, ipPolicy)
+	wait, err := u.Check(context.TODO(), "User1", "127.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Minute, wait)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCheckFailedUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("LockedFor", mock.Anything, "user:user1").Return(time.Duration(0), errors.New("some error")).Once()
+
+	u := usecase.NewThrottleUsecase(mockRepo, usernamePolicy, ipPolicy)
+	_, err := u.Check(context.TODO(), "user1", "127.0.0.1")
+	assert.Error(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRecordFailureUnderLimitUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("RecordFailure", mock.Anything, "user:user1", time.Minute).Return(2, nil).Once()
+	mockRepo.On("RecordFailure", mock.Anything, "ip:127.0.0.1", time.Minute).Return(2, nil).Once()
+
+	u := usecase.NewThrottleUsecase(mockRepo, usernamePolicy, ipPolicy)
+	wait, err := u.RecordFailure(context.TODO(), "user1", "127.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRecordFailureBackoffUsecase(t *testing.T) {
+	cases := []struct {
+		failures int
+		lockout  time.Duration
+	}{
+		{3, time.Minute},
+		{4, 2 * time.Minute},
+		{5, 4 * time.Minute},
+		{6, 8 * time.Minute},
+		{7, 10 * time.Minute},
+		{20, 10 * time.Minute},
+	}
+	for _, c := range cases {
+		mockRepo := new(mocks.Repository)
+		mockRepo.On("RecordFailure", mock.Anything, "user:user1", time.Minute).Return(c.failures, nil).Once()
+		mockRepo.On("RecordFailure", mock.Anything, "ip:127.0.0.1", time.Minute).Return(1, nil).Once()
+		mockRepo.On("Lock", mock.Anything, "user:user1", c.lockout).Return(nil).Once()
+
+		u := usecase.NewThrottleUsecase(mockRepo, usernamePolicy, ipPolicy)
+		wait, err := u.RecordFailure(context.TODO(), "user1", "127.0.0.1")
+		assert.NoError(t, err)
+		assert.Equal(t, c.lockout, wait)
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestRecordSuccessUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("Reset", mock.Anything, "user:user1").Return(nil).Once()
+
+	u := usecase.NewThrottleUsecase(mockRepo, usernamePolicy, ipPolicy)
+	err := u.RecordSuccess(context.TODO(), "user1", "127.0.0.1")
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUnlockUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("Reset", mock.Anything, "user:user1").Return(nil).Once()
+
+	u := usecase.NewThrottleUsecase(mockRepo, usernamePolicy, ipPolicy)
+	err := u.Unlock(context.TODO(), "user1")
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
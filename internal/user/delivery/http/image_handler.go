@@ -0,0 +1,195 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/famkampm/nentrytask/pkg/blobstore"
+	"github.com/famkampm/nentrytask/pkg/imaging"
+	"github.com/famkampm/nentrytask/pkg/responses"
+	"github.com/julienschmidt/httprouter"
+)
+
+type ImageHandler struct {
+	Router *httprouter.Router
+	Store  blobstore.BlobStore
+	MaxAge time.Duration
+	// RedirectTTL sends clients to a signed URL of the store valid that long
+	// instead of passing the image through, 0 to pass it through
+	RedirectTTL time.Duration
+}
+
+// NewImageHandler serves the profile images kept in store and the default
+// images of users without one. Stored images are named after their content
+// and never rewritten, so they can be cached for maxAge without
+// revalidation.
+func NewImageHandler(router *httprouter.Router, store blobstore.BlobStore, maxAge, redirectTTL time.Duration) {
+	handler := &ImageHandler{
+		Router:      router,
+		Store:       store,
+		MaxAge:      maxAge,
+		RedirectTTL: redirectTTL,
+	}
+	handler.Router.GET("/images/:name", handler.Serve)
+	handler.Router.HEAD("/images/:name", handler.Serve)
+}
+
+// Serve answers with the image, or with its thumbnail for ?size=. Ranges and
+// conditional requests are handled by http.ServeContent.
+func (h *ImageHandler) Serve(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	name := ps.ByName("name")
+	query := r.URL.Query()
+	if !blobstore.ValidName(name) {
+		notFound(w)
+		return
+	}
+	if size := query.Get("size"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil || !imaging.HasSize(n) {
+			notFound(w)
+			return
+		}
+		name = imaging.ThumbnailName(name, n)
+	}
+	if imaging.IsDefaultName(name) {
+		h.serveDefault(w, r, name)
+		return
+	}
+	signature := query.Get("signature")
+	verifier, verifies := h.Store.(blobstore.SignatureVerifier)
+	if signature != "" && verifies && !verifier.Verify(name, query.Get("expires"), signature) {
+		responses.ERROR(w, http.StatusForbidden, errors.New(http.StatusText(http.StatusForbidden)))
+		return
+	}
+	if h.RedirectTTL > 0 && signature == "" {
+		h.redirect(w, r, name)
+		return
+	}
+	obj, err := h.Store.Stat(r.Context(), name)
+	if err == blobstore.ErrNotFound {
+		notFound(w)
+		return
+	}
+	if err != nil {
+		log.Println("stat image err:", err.Error())
+		responses.ERROR(w, http.StatusBadGateway, errors.New(http.StatusText(http.StatusBadGateway)))
+		return
+	}
+	w.Header().Set("ETag", obj.ETag)
+	h.setCacheHeaders(w)
+	if obj.ContentType != "" {
+		w.Header().Set("Content-Type", obj.ContentType)
+	}
+	content := &lazyContent{ctx: r.Context(), store: h.Store, name: name, size: obj.Size}
+	http.ServeContent(w, r, name, obj.ModTime, content)
+	if content.err != nil {
+		log.Println("get image err:", content.err.Error())
+	}
+}
+
+// serveDefault draws the default image of a user without a profile image.
+// The drawing only depends on the name, so it is cached like stored images.
+func (h *ImageHandler) serveDefault(w http.ResponseWriter, r *http.Request, name string) {
+	data, ok := imaging.DefaultImage(name)
+	if !ok {
+		notFound(w)
+		return
+	}
+	sum := sha256.Sum256(data)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	h.setCacheHeaders(w)
+	w.Header().Set("Content-Type", "image/png")
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
+
+func (h *ImageHandler) setCacheHeaders(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", int64(h.MaxAge.Seconds())))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+}
+
+// redirect sends the client to a signed URL of the image. The redirect may be
+// cached for half the lifetime of the URL.
+func (h *ImageHandler) redirect(w http.ResponseWriter, r *http.Request, name string) {
+	_, err := h.Store.Stat(r.Context(), name)
+	if err == blobstore.ErrNotFound {
+		notFound(w)
+		return
+	}
+	if err != nil {
+		log.Println("stat image err:", err.Error())
+		responses.ERROR(w, http.StatusBadGateway, errors.New(http.StatusText(http.StatusBadGateway)))
+		return
+	}
+	signedURL, err := h.Store.SignedURL(r.Context(), name, h.RedirectTTL)
+	if err != nil {
+		log.Println("sign image url err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int64(h.RedirectTTL.Seconds()/2)))
+	http.Redirect(w, r, signedURL, http.StatusFound)
+}
+
+func notFound(w http.ResponseWriter) {
+	responses.ERROR(w, http.StatusNotFound, errors.New(http.StatusText(http.StatusNotFound)))
+}
+
+// lazyContent only fetches the blob once http.ServeContent reads from it, so
+// answering a conditional request with 304 costs a Stat and no download.
+// Images are small, the blob is kept in memory to seek in it for ranges.
+type lazyContent struct {
+	ctx    context.Context
+	store  blobstore.BlobStore
+	name   string
+	size   int64
+	offset int64
+	data   *bytes.Reader
+	err    error
+}
+
+func (c *lazyContent) Seek(offset int64, whence int) (int64, error) {
+	if c.data != nil {
+		return c.data.Seek(offset, whence)
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += c.offset
+	case io.SeekEnd:
+		offset += c.size
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	c.offset = offset
+	return offset, nil
+}
+
+func (c *lazyContent) Read(p []byte) (int, error) {
+	if c.data == nil {
+		body, _, err := c.store.Get(c.ctx, c.name)
+		if err != nil {
+			c.err = err
+			return 0, err
+		}
+		data, err := ioutil.ReadAll(body)
+		body.Close()
+		if err != nil {
+			c.err = err
+			return 0, err
+		}
+		c.data = bytes.NewReader(data)
+		c.data.Seek(c.offset, io.SeekStart)
+	}
+	return c.data.Read(p)
+}
@@ -0,0 +1,196 @@
+package http_test
+
+import (
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_userHttpDeliver "github.com/famkampm/nentrytask/internal/user/delivery/http"
+	"github.com/famkampm/nentrytask/pkg/blobstore"
+	"github.com/famkampm/nentrytask/pkg/imaging"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func newImageServer(t *testing.T) (*httptest.Server, string) {
+	return newImageServerRedirecting(t, 0)
+}
+
+func newImageServerRedirecting(t *testing.T, redirectTTL time.Duration) (*httptest.Server, string) {
+	root, err := ioutil.TempDir("", "images")
+	assert.NoError(t, err)
+	dir := filepath.Join(root, "images")
+	assert.NoError(t, os.Mkdir(dir, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "abc.png"), []byte("0123456789"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "abc_64.png"), []byte("thumb"), 0600))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "sub.png"), 0700))
+	// A SECRET NEXT TO THE IMAGE DIRECTORY
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "secret.png"), []byte("secret"), 0600))
+	store, err := blobstore.NewLocalStore(dir, "/images/", []byte("secret"))
+	assert.NoError(t, err)
+	router := httprouter.New()
+	_userHttpDeliver.NewImageHandler(router, store, time.Hour, redirectTTL)
+	return httptest.NewServer(router), root
+}
+
+func get(t *testing.T, url string, headers map[string]string) (*http.Response, string) {
+	req, err := http.NewRequest("GET", url, nil)
+	assert.NoError(t, err)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	assert.NoError(t, err)
+	return res, string(body)
+}
+
+func TestServeImage(t *testing.T) {
+	server, dir := newImageServer(t)
+	defer server.Close()
+	defer os.RemoveAll(dir)
+
+	res, body := get(t, server.URL+"/images/abc.png", nil)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "0123456789", body)
+	assert.Equal(t, "image/png", res.Header.Get("Content-Type"))
+	assert.Equal(t, "public, max-age=3600, immutable", res.Header.Get("Cache-Control"))
+	assert.Equal(t, "nosniff", res.Header.Get("X-Content-Type-Options"))
+	etag := res.Header.Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+	lastModified := res.Header.Get("Last-Modified")
+	assert.NotEmpty(t, lastModified)
+
+	res, body = get(t, server.URL+"/images/abc.png", map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, res.StatusCode)
+	assert.Empty(t, body)
+	res, _ = get(t, server.URL+"/images/abc.png", map[string]string{"If-Modified-Since": lastModified})
+	assert.Equal(t, http.StatusNotModified, res.StatusCode)
+	res, _ = get(t, server.URL+"/images/abc.png", map[string]string{"If-None-Match": `"other"`})
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	res, body = get(t, server.URL+"/images/abc.png", map[string]string{"Range": "bytes=2-4"})
+	assert.Equal(t, http.StatusPartialContent, res.StatusCode)
+	assert.Equal(t, "234", body)
+	assert.Equal(t, "bytes 2-4/10", res.Header.Get("Content-Range"))
+	res, _ = get(t, server.URL+"/images/abc.png", map[string]string{"Range": "bytes=20-"})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, res.StatusCode)
+}
+
+func TestServeThumbnail(t *testing.T) {
+	server, dir := newImageServer(t)
+	defer server.Close()
+	defer os.RemoveAll(dir)
+
+	_, body := get(t, server.URL+"/images/abc_64.png", nil)
+	assert.Equal(t, "thumb", body)
+	res, body := get(t, server.URL+"/images/abc.png?size=64", nil)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "thumb", body)
+	res, _ = get(t, server.URL+"/images/abc.png?size=65", nil)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestServeImageNotFound(t *testing.T) {
+	server, dir := newImageServer(t)
+	defer server.Close()
+	defer os.RemoveAll(dir)
+
+	for _, path := range []string{
+		"/images/unknown.png",
+		"/images/sub.png",
+		"/images/..%2Fsecret.png",
+		"/images/%2e%2e",
+		"/images/.hidden",
+		"/images/abc.png%00",
+		"/images/abc.png?size=../../secret",
+	} {
+		res, body := get(t, server.URL+path, nil)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode, path)
+		assert.NotContains(t, body, "secret", path)
+	}
+}
+
+func TestServeImageRedirect(t *testing.T) {
+	server, dir := newImageServerRedirecting(t, time.Hour)
+	defer server.Close()
+	defer os.RemoveAll(dir)
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	res, err := client.Get(server.URL + "/images/abc.png?size=64")
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusFound, res.StatusCode)
+	assert.Equal(t, "private, max-age=1800", res.Header.Get("Cache-Control"))
+	location := res.Header.Get("Location")
+	assert.Regexp(t, `^/images/abc_64\.png\?expires=\d+&signature=[0-9a-f]{64}$`, location)
+
+	res, body := get(t, server.URL+location, nil)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "thumb", body)
+	// THE SIGNATURE OF THE THUMBNAIL DOES NOT OPEN THE ORIGINAL
+	res, _ = get(t, server.URL+strings.Replace(location, "abc_64.png", "abc.png", 1), nil)
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	res, err = client.Get(server.URL + "/images/unknown.png")
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestServeImageInvalidSignature(t *testing.T) {
+	server, dir := newImageServerRedirecting(t, time.Hour)
+	defer server.Close()
+	defer os.RemoveAll(dir)
+
+	for _, query := range []string{
+		"?expires=9999999999&signature=00",
+		// EXPIRED LONG AGO
+		"?expires=1&signature=" + strings.Repeat("0", 64),
+	} {
+		res, _ := get(t, server.URL+"/images/abc.png"+query, nil)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode, query)
+	}
+}
+
+func TestServeDefaultImage(t *testing.T) {
+	server, dir := newImageServer(t)
+	defer server.Close()
+	defer os.RemoveAll(dir)
+	name := imaging.DefaultName(1)
+
+	res, body := get(t, server.URL+"/images/"+name, nil)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "image/png", res.Header.Get("Content-Type"))
+	assert.Equal(t, "public, max-age=3600, immutable", res.Header.Get("Cache-Control"))
+	img, err := png.Decode(strings.NewReader(body))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 512, 512), img.Bounds())
+	etag := res.Header.Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+
+	res, _ = get(t, server.URL+"/images/"+name, map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, res.StatusCode)
+
+	res, body = get(t, server.URL+"/images/"+name+"?size=64", nil)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	img, err = png.Decode(strings.NewReader(body))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 64, 64), img.Bounds())
+	_, thumbnail := get(t, server.URL+"/images/"+imaging.ThumbnailName(name, 64), nil)
+	assert.Equal(t, body, thumbnail)
+
+	res, _ = get(t, server.URL+"/images/"+imaging.ThumbnailName(name, 65), nil)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
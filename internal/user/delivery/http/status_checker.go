@@ -0,0 +1,33 @@
+package http
+
+import (
+	"context"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/user"
+	"github.com/famkampm/nentrytask/pkg/middlewares"
+)
+
+type statusChecker struct {
+	UserUsecase user.Usecase
+}
+
+// NewStatusChecker lets the middleware reject suspended, banned and deleted
+// accounts. It reads the cached user, which status changes keep up to date.
+func NewStatusChecker(us user.Usecase) middlewares.StatusChecker {
+	return &statusChecker{
+		UserUsecase: us,
+	}
+}
+
+func (s *statusChecker) BlockedReason(ctx context.Context, userID int64) (string, error) {
+	usr, err := s.UserUsecase.GetByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	err = user.CheckStatus(usr, time.Now())
+	if err != nil {
+		return err.Error(), nil
+	}
+	return "", nil
+}
@@ -8,16 +8,22 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
-	"mime"
+	"math"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
+	"time"
 
+	"github.com/famkampm/nentrytask/internal/audit"
+	"github.com/famkampm/nentrytask/internal/mfa"
 	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/profileimage"
+	"github.com/famkampm/nentrytask/internal/session"
+	"github.com/famkampm/nentrytask/internal/throttle"
 	"github.com/famkampm/nentrytask/internal/user"
+	"github.com/famkampm/nentrytask/internal/verification"
 	"github.com/famkampm/nentrytask/pkg/auth"
 	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/famkampm/nentrytask/pkg/imaging"
 	"github.com/famkampm/nentrytask/pkg/middlewares"
 	"github.com/famkampm/nentrytask/pkg/responses"
 	"github.com/julienschmidt/httprouter"
@@ -25,22 +31,73 @@ import (
 )
 
 type UserHandler struct {
-	Router      *httprouter.Router
-	UserUsecase user.Usecase
+	Router          *httprouter.Router
+	UserUsecase     user.Usecase
+	Revoker         auth.Revoker
+	ThrottleUsecase throttle.Usecase
+	MFAUsecase      mfa.Usecase
+	SessionUsecase  session.Usecase
+	AuditUsecase    audit.Usecase
+	Verification    verification.Usecase
+	ProfileImages   profileimage.Usecase
 }
 
-func NewUserHandler(router *httprouter.Router, us user.Usecase) {
+type loginMFARequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+// mfaRequiredResponse is the /login answer for users with 2FA enabled
+type mfaRequiredResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type updateRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type changeUsernameRequest struct {
+	CurrentPassword string `json:"current_password"`
+	Username        string `json:"username"`
+}
+
+func NewUserHandler(router *httprouter.Router, us user.Usecase, revoker auth.Revoker, throttleUsecase throttle.Usecase, mfaUsecase mfa.Usecase, sessionUsecase session.Usecase, auditUsecase audit.Usecase, verificationUsecase verification.Usecase, profileImageUsecase profileimage.Usecase, mw *middlewares.Middleware) {
 	handler := &UserHandler{
-		Router:      router,
-		UserUsecase: us,
+		Router:          router,
+		UserUsecase:     us,
+		Revoker:         revoker,
+		ThrottleUsecase: throttleUsecase,
+		MFAUsecase:      mfaUsecase,
+		SessionUsecase:  sessionUsecase,
+		AuditUsecase:    auditUsecase,
+		Verification:    verificationUsecase,
+		ProfileImages:   profileImageUsecase,
 	}
 	handler.Router.GET("/", handler.Home)
+	handler.Router.GET("/.well-known/jwks.json", handler.JWKS)
 
 	handler.Router.POST("/register", middlewares.SetMiddlewareJSON(handler.Store))
 	handler.Router.POST("/login", middlewares.SetMiddlewareJSON(handler.Login))
-	handler.Router.GET("/profile/:id", middlewares.SetMiddlewareAuthentication(handler.GetUser))
-	handler.Router.PUT("/profile/nickname/:id", middlewares.SetMiddlewareAuthentication(handler.EditNickname))
-	handler.Router.PUT("/profile/image/:id", middlewares.SetMiddlewareAuthentication(handler.UpdateProfileImage))
+	handler.Router.POST("/login/mfa", middlewares.SetMiddlewareJSON(handler.LoginMFA))
+	handler.Router.POST("/token/refresh", middlewares.SetMiddlewareJSON(handler.RefreshToken))
+	handler.Router.POST("/logout", middlewares.SetMiddlewareJSON(mw.SetMiddlewareToken(handler.Logout)))
+	handler.Router.GET("/profile/:id", mw.SetMiddlewareSelfOrPermission(auth.PermissionProfileRead)(handler.GetUser))
+	handler.Router.PUT("/profile/nickname/:id", mw.SetMiddlewareSelfOrPermission(auth.PermissionProfileWrite)(handler.EditNickname))
+	handler.Router.PUT("/profile/image/:id", mw.SetMiddlewareSelfOrPermission(auth.PermissionProfileWrite)(handler.UpdateProfileImage))
+	handler.Router.PUT("/profile/password/:id", middlewares.SetMiddlewareJSON(mw.SetMiddlewareAuthentication(handler.ChangePassword)))
+	handler.Router.PUT("/profile/username/:id", middlewares.SetMiddlewareJSON(mw.SetMiddlewareAuthentication(handler.ChangeUsername)))
+	handler.Router.PUT("/admin/role/:id", middlewares.SetMiddlewareJSON(mw.SetMiddlewarePermission(auth.PermissionRolesWrite)(handler.UpdateRole)))
 }
 
 func (u *UserHandler) Home(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -49,6 +106,13 @@ func (u *UserHandler) Home(w http.ResponseWriter, r *http.Request, _ httprouter.
 	responses.JSON(w, http.StatusOK, "Welcome To This Awesome API")
 }
 
+// JWKS publishes the public keys other services use to verify our tokens
+func (u *UserHandler) JWKS(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	responses.JSON(w, http.StatusOK, auth.JWKS())
+}
+
 func (u *UserHandler) Store(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -66,11 +130,15 @@ func (u *UserHandler) Store(w http.ResponseWriter, r *http.Request, _ httprouter
 	}
 
 	err = helper.Validate("register", user.Username, user.Password)
-	if err != nil {
-		formatedError := helper.FormatError(err.Error())
-		responses.ERROR(w, http.StatusUnprocessableEntity, formatedError)
+	validationErrors, _ := err.(helper.ValidationErrors)
+	validationErrors = append(validationErrors, helper.ValidateEmail(user.Email.String, u.Verification.EmailRequired())...)
+	if len(validationErrors) > 0 {
+		responses.VALIDATION(w, validationErrors)
 		return
 	}
+	if user.Email.String == "" {
+		user.Email = null.String{}
+	}
 
 	_, err = u.UserUsecase.GetByUsername(context.TODO(), user.Username)
 	if err != nil && err != sql.ErrNoRows {
@@ -90,18 +158,30 @@ func (u *UserHandler) Store(w http.ResponseWriter, r *http.Request, _ httprouter
 		return
 	}
 	user.Password = hashedPassword
+	// ROLES ARE ONLY GIVEN BY STAFF, NEVER TAKEN FROM THE REGISTRATION BODY
+	user.Role = auth.RoleUser
+	// PROFILE IMAGES ARE ONLY SET BY UPLOAD, THEY HOLD A REFERENCE TO THE BLOB
+	user.ProfileImage = null.String{}
+	user.Status = u.Verification.InitialStatus(user)
 	err = u.UserUsecase.Store(context.TODO(), user)
 	if err != nil {
 		formatedError := helper.FormatError(err.Error())
 		responses.ERROR(w, http.StatusInternalServerError, formatedError)
 		return
 	}
+	// THE ACCOUNT EXISTS ALREADY, A LOST MAIL CAN BE SENT AGAIN WITH /verify/resend
+	err = u.Verification.Send(context.TODO(), user)
+	if err != nil {
+		log.Println("register send verification err:", err.Error())
+	}
 	w.Header().Set("Location", fmt.Sprintf("%s%s/%d", r.Host, r.RequestURI, user.ID))
 	userProfile := &models.UserProfile{}
 	userProfile.ID = user.ID
 	userProfile.Username = user.Username
 	userProfile.Nickname = user.Nickname
 	userProfile.ProfileImage = user.ProfileImage
+	userProfile.Email = user.Email
+	userProfile.Status = user.Status
 	responses.JSON(w, http.StatusCreated, userProfile)
 }
 
@@ -121,30 +201,337 @@ func (u *UserHandler) Login(w http.ResponseWriter, r *http.Request, _ httprouter
 		return
 	}
 	err = helper.Validate("login", user.Username, user.Password)
+	if validationErrors, ok := err.(helper.ValidationErrors); ok {
+		responses.VALIDATION(w, validationErrors)
+		return
+	}
+	// CHECK THE LOCK BEFORE BCRYPT SO A LOCKED OUT CALLER COSTS US NOTHING
+	ip := helper.ClientIP(r)
+	wait, err := u.ThrottleUsecase.Check(context.TODO(), user.Username, ip)
 	if err != nil {
-		formatedError := helper.FormatError(err.Error())
-		responses.ERROR(w, http.StatusUnprocessableEntity, formatedError)
+		log.Println("login throttle check err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	if wait > 0 {
+		u.recordAudit(r, 0, audit.ActionLogin, audit.ResultFailure, fmt.Sprintf("username %s: locked out", user.Username))
+		tooManyAttempts(w, wait)
 		return
 	}
 	hashed_user, err := u.UserUsecase.GetByUsername(context.TODO(), user.Username)
+	if err == sql.ErrNoRows {
+		// HASH ANYWAY SO AN UNKNOWN USERNAME TAKES AS LONG AS A WRONG PASSWORD
+		helper.VerifyDummyPassword(user.Password)
+		u.loginFailed(w, r, 0, user.Username, ip, helper.FormatError(err.Error()))
+		return
+	}
 	if err != nil {
 		formatedError := helper.FormatError(err.Error())
 		responses.ERROR(w, http.StatusInternalServerError, formatedError)
 		return
 	}
 	err = helper.VerifyPassword(hashed_user.Password, user.Password)
+	if err != nil {
+		u.loginFailed(w, r, hashed_user.ID, user.Username, ip, helper.FormatError(err.Error()))
+		return
+	}
+	if u.loginBlocked(w, r, hashed_user) {
+		return
+	}
+	if u.Verification.Restricted(hashed_user, verification.RestrictLogin) {
+		u.recordAudit(r, hashed_user.ID, audit.ActionLogin, audit.ResultFailure, fmt.Sprintf("username %s: %s", hashed_user.Username, verification.ErrEmailNotVerified.Error()))
+		responses.ERROR(w, http.StatusForbidden, verification.ErrEmailNotVerified)
+		return
+	}
+	// ONLY REWRITE THE HASH OF AN ACCOUNT THAT IS ALLOWED TO LOG IN
+	err = u.UserUsecase.UpgradePasswordHash(context.TODO(), hashed_user, user.Password)
+	if err != nil {
+		log.Println("login upgrade password hash err:", err.Error())
+	}
+	mfaEnabled, err := u.MFAUsecase.IsEnabled(context.TODO(), hashed_user.ID)
+	if err != nil {
+		log.Println("login check mfa err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	if mfaEnabled {
+		// THE THROTTLE IS ONLY RESET ONCE THE SECOND FACTOR PASSED TOO
+		mfaToken, err := auth.CreateMFAPendingToken(hashed_user.ID)
+		if err != nil {
+			responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+			return
+		}
+		responses.JSON(w, http.StatusOK, &mfaRequiredResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+			ExpiresIn:   int64(auth.MFAPendingTokenTTL().Seconds()),
+		})
+		return
+	}
+	u.loginSucceeded(w, r, ip, hashed_user, "password")
+}
+
+// LoginMFA exchanges the mfa pending token from /login and a TOTP or recovery
+// code for a token pair. The pending token can only be used once.
+func (u *UserHandler) LoginMFA(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	defer r.Body.Close()
+	req := &loginMFARequest{}
+	err = json.Unmarshal(body, req)
+	if err != nil || req.MFAToken == "" || req.Code == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Required MFA Token And Code"))
+		return
+	}
+	claims, err := auth.ParseToken(req.MFAToken, auth.MFAPendingTokenType)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	revoked, err := u.Revoker.IsRevoked(r.Context(), claims)
+	if err != nil {
+		log.Println("login mfa check revoked err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	if revoked {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	hashed_user, err := u.UserUsecase.GetByID(context.TODO(), claims.UserID)
 	if err != nil {
 		formatedError := helper.FormatError(err.Error())
-		responses.ERROR(w, http.StatusForbidden, formatedError)
+		responses.ERROR(w, http.StatusInternalServerError, formatedError)
+		return
+	}
+	if u.loginBlocked(w, r, hashed_user) {
+		return
+	}
+	// CODES ARE ONLY 6 DIGITS, SO THEY SHARE THE PASSWORD THROTTLE
+	ip := helper.ClientIP(r)
+	wait, err := u.ThrottleUsecase.Check(context.TODO(), hashed_user.Username, ip)
+	if err != nil {
+		log.Println("login mfa throttle check err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	if wait > 0 {
+		u.recordAudit(r, hashed_user.ID, audit.ActionLogin, audit.ResultFailure, fmt.Sprintf("username %s: locked out", hashed_user.Username))
+		tooManyAttempts(w, wait)
+		return
+	}
+	err = u.MFAUsecase.Verify(context.TODO(), claims.UserID, req.Code)
+	if err == mfa.ErrInvalidCode || err == mfa.ErrNotEnrolled {
+		u.loginFailed(w, r, hashed_user.ID, hashed_user.Username, ip, mfa.ErrInvalidCode)
 		return
 	}
-	token_string, err := auth.CreateTokenFromID(hashed_user.ID)
+	if err != nil {
+		log.Println("login mfa verify err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	// ONLY ONE OF CONCURRENT REQUESTS WITH THE SAME PENDING TOKEN LOGS IN
+	consumed, err := u.Revoker.Consume(r.Context(), claims.Id, time.Unix(claims.ExpiresAt, 0))
+	if err != nil {
+		log.Println("login mfa consume pending token err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	if !consumed {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	u.loginSucceeded(w, r, ip, hashed_user, "two-factor")
+}
+
+// loginSucceeded clears the failed attempts and starts a new session. method
+// is the last factor that was checked, it ends up in the audit log.
+func (u *UserHandler) loginSucceeded(w http.ResponseWriter, r *http.Request, ip string, usr *models.User, method string) {
+	err := u.ThrottleUsecase.RecordSuccess(context.TODO(), usr.Username, ip)
+	if err != nil {
+		log.Println("login throttle record success err:", err.Error())
+	}
+	tokenPair, err := u.startSession(r, ip, usr.ID, usr.Role)
 	if err != nil {
 		formatedError := helper.FormatError(err.Error())
 		responses.ERROR(w, http.StatusInternalServerError, formatedError)
 		return
 	}
-	responses.JSON(w, http.StatusOK, token_string)
+	u.recordAudit(r, usr.ID, audit.ActionLogin, audit.ResultSuccess, fmt.Sprintf("username %s: %s", usr.Username, method))
+	responses.JSON(w, http.StatusOK, tokenPair)
+}
+
+// startSession records a session for the device of r and issues its first
+// token pair
+func (u *UserHandler) startSession(r *http.Request, ip string, userID int64, role string) (*auth.TokenPair, error) {
+	sess := &models.Session{
+		ID:        helper.RandToken(16),
+		UserID:    userID,
+		UserAgent: r.UserAgent(),
+		IP:        ip,
+	}
+	tokenPair, err := auth.CreateSessionTokenPair(userID, sess.ID, role)
+	if err != nil {
+		return nil, err
+	}
+	err = u.SessionUsecase.Start(context.TODO(), sess)
+	if err != nil {
+		log.Println("start session err:", err.Error())
+		return nil, err
+	}
+	return tokenPair, nil
+}
+
+// loginBlocked answers 403 and returns true when the status of usr doesn't
+// allow logging in, even with the right password
+func (u *UserHandler) loginBlocked(w http.ResponseWriter, r *http.Request, usr *models.User) bool {
+	err := user.CheckStatus(usr, time.Now())
+	if err == nil {
+		return false
+	}
+	u.recordAudit(r, usr.ID, audit.ActionLogin, audit.ResultFailure, fmt.Sprintf("username %s: %s", usr.Username, err.Error()))
+	if err == user.ErrAccountSuspended {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(usr.SuspendedUntil.Time).Seconds()))))
+	}
+	responses.ERROR(w, http.StatusForbidden, err)
+	return true
+}
+
+// loginFailed records and counts the failed attempt and answers 429 when it
+// locked the account, 403 with err otherwise. userID is 0 for unknown usernames.
+func (u *UserHandler) loginFailed(w http.ResponseWriter, r *http.Request, userID int64, username, ip string, err error) {
+	u.recordAudit(r, userID, audit.ActionLogin, audit.ResultFailure, fmt.Sprintf("username %s: %s", username, err.Error()))
+	wait, throttleErr := u.ThrottleUsecase.RecordFailure(context.TODO(), username, ip)
+	if throttleErr != nil {
+		log.Println("login throttle record failure err:", throttleErr.Error())
+	}
+	if wait > 0 {
+		tooManyAttempts(w, wait)
+		return
+	}
+	responses.ERROR(w, http.StatusForbidden, err)
+}
+
+// recordAudit writes an entry about targetID to the audit log. The actor is the
+// caller of an authenticated request and the target itself otherwise, e.g. on
+// login. A failed write is logged but never fails the request.
+func (u *UserHandler) recordAudit(r *http.Request, targetID int64, action, result, detail string) {
+	entry := &models.AuditEntry{
+		ActorID:      targetID,
+		TargetUserID: targetID,
+		Action:       action,
+		IP:           helper.ClientIP(r),
+		UserAgent:    r.UserAgent(),
+		Result:       result,
+		Detail:       detail,
+	}
+	if claims, ok := auth.FromContext(r.Context()); ok {
+		entry.ActorID = claims.UserID
+	}
+	err := u.AuditUsecase.Record(context.TODO(), entry)
+	if err != nil {
+		log.Println("record audit entry err:", err.Error())
+	}
+}
+
+func tooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	responses.ERROR(w, http.StatusTooManyRequests, errors.New("Too Many Login Attempts"))
+}
+
+// RefreshToken exchanges a refresh token for a new access/refresh pair in the
+// same session. The used refresh token is revoked so it can only be used once.
+func (u *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	defer r.Body.Close()
+	req := &refreshTokenRequest{}
+	err = json.Unmarshal(body, req)
+	if err != nil || req.RefreshToken == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Required Refresh Token"))
+		return
+	}
+	claims, err := auth.ParseToken(req.RefreshToken, auth.RefreshTokenType)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	revoked, err := u.Revoker.IsRevoked(r.Context(), claims)
+	if err != nil {
+		log.Println("refresh token check revoked err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	if revoked {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	live, err := u.SessionUsecase.Touch(context.TODO(), claims)
+	if err != nil {
+		log.Println("refresh token touch session err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	if !live {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	// THE ROLE IS READ AGAIN SO ROLE CHANGES REACH THE NEXT ACCESS TOKEN
+	usr, err := u.UserUsecase.GetByID(context.TODO(), claims.UserID)
+	if err != nil {
+		log.Println("refresh token get user err:", err.Error())
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	err = user.CheckStatus(usr, time.Now())
+	if err != nil {
+		responses.ERROR(w, http.StatusForbidden, err)
+		return
+	}
+	// ONLY ONE OF CONCURRENT REQUESTS WITH THE SAME TOKEN GETS A NEW PAIR
+	consumed, err := u.Revoker.Consume(r.Context(), claims.Id, time.Unix(claims.ExpiresAt, 0))
+	if err != nil {
+		log.Println("refresh token consume err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	if !consumed {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	tokenPair, err := auth.CreateSessionTokenPair(claims.UserID, claims.SessionID, usr.Role)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, tokenPair)
+}
+
+// Logout revokes the whole session of the access token, which also makes every
+// refresh token issued for it unusable
+func (u *UserHandler) Logout(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	claims, ok := auth.FromContext(r.Context())
+	if !ok {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	err := u.SessionUsecase.Revoke(context.TODO(), claims.UserID, claims.SessionID)
+	if err == session.ErrSessionNotFound {
+		// NOT LISTED ANYMORE, STILL MAKE SURE ITS TOKENS ARE REJECTED
+		err = u.Revoker.Revoke(r.Context(), claims.SessionID, time.Now().Add(auth.RefreshTokenTTL()))
+	}
+	if err != nil {
+		log.Println("logout revoke session err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, "Logged Out")
 }
 
 func (u *UserHandler) GetUser(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
@@ -159,11 +546,19 @@ func (u *UserHandler) GetUser(w http.ResponseWriter, r *http.Request, ps httprou
 		responses.ERROR(w, http.StatusInternalServerError, formatedError)
 		return
 	}
+	// USERS WITHOUT A PROFILE IMAGE GET THE URLS OF THEIR DEFAULT IMAGE
+	images := imaging.URLs(imaging.DefaultName(user.ID))
+	if user.ProfileImage.Valid && user.ProfileImage.String != "" {
+		images = imaging.URLs(user.ProfileImage.String)
+	}
 	userProfile := &models.UserProfile{
-		ID:           int64(user_id),
-		Username:     user.Username,
-		Nickname:     user.Nickname,
-		ProfileImage: user.ProfileImage,
+		ID:             int64(user_id),
+		Username:       user.Username,
+		Nickname:       user.Nickname,
+		ProfileImage:   user.ProfileImage,
+		ProfileImages:  images,
+		Status:         user.Status,
+		SuspendedUntil: user.SuspendedUntil,
 	}
 	w.Header().Set("Location", fmt.Sprintf("%s%s/%d", r.Host, r.RequestURI, user_id))
 	responses.JSON(w, http.StatusCreated, userProfile)
@@ -187,16 +582,120 @@ func (u *UserHandler) EditNickname(w http.ResponseWriter, r *http.Request, ps ht
 		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
+	target, err := u.UserUsecase.GetByID(context.TODO(), int64(user_id))
+	if err != nil {
+		formatedError := helper.FormatError(err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, formatedError)
+		return
+	}
+	if u.Verification.Restricted(target, verification.RestrictNicknameUpdate) {
+		responses.ERROR(w, http.StatusForbidden, verification.ErrEmailNotVerified)
+		return
+	}
 	err = u.UserUsecase.UpdateNickname(context.TODO(), int64(user_id), user.Nickname.String)
 	if err != nil {
+		u.recordAudit(r, int64(user_id), audit.ActionProfileUpdate, audit.ResultFailure, "nickname")
 		formatedError := helper.FormatError(err.Error())
 		responses.ERROR(w, http.StatusInternalServerError, formatedError)
 		return
 	}
+	u.recordAudit(r, int64(user_id), audit.ActionProfileUpdate, audit.ResultSuccess, "nickname")
 	w.Header().Set("Content-Type", "application/json")
 	responses.JSON(w, http.StatusOK, "Nickname Updated")
 }
 
+// ChangePassword requires the current password. Every token issued before the
+// change is revoked and the caller gets a fresh pair to stay logged in.
+func (u *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user_id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, errors.New(http.StatusText(http.StatusBadRequest)))
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	defer r.Body.Close()
+	req := &changePasswordRequest{}
+	err = json.Unmarshal(body, req)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	err = u.UserUsecase.ChangePassword(context.TODO(), int64(user_id), req.CurrentPassword, req.NewPassword)
+	if err == user.ErrIncorrectPassword {
+		u.recordAudit(r, int64(user_id), audit.ActionPasswordChange, audit.ResultFailure, err.Error())
+		responses.ERROR(w, http.StatusForbidden, err)
+		return
+	}
+	if validationErrors, ok := err.(helper.ValidationErrors); ok {
+		responses.VALIDATION(w, validationErrors)
+		return
+	}
+	if err != nil {
+		formatedError := helper.FormatError(err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, formatedError)
+		return
+	}
+	u.recordAudit(r, int64(user_id), audit.ActionPasswordChange, audit.ResultSuccess, "")
+	err = u.Revoker.RevokeUser(r.Context(), int64(user_id))
+	if err != nil {
+		log.Println("change password revoke tokens err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	claims, _ := auth.FromContext(r.Context())
+	tokenPair, err := u.startSession(r, helper.ClientIP(r), int64(user_id), claims.Role)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, tokenPair)
+}
+
+func (u *UserHandler) ChangeUsername(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user_id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, errors.New(http.StatusText(http.StatusBadRequest)))
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	defer r.Body.Close()
+	req := &changeUsernameRequest{}
+	err = json.Unmarshal(body, req)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	err = u.UserUsecase.ChangeUsername(context.TODO(), int64(user_id), req.CurrentPassword, req.Username)
+	if err == user.ErrIncorrectPassword {
+		u.recordAudit(r, int64(user_id), audit.ActionProfileUpdate, audit.ResultFailure, "username: "+err.Error())
+		responses.ERROR(w, http.StatusForbidden, err)
+		return
+	}
+	if validationErrors, ok := err.(helper.ValidationErrors); ok {
+		responses.VALIDATION(w, validationErrors)
+		return
+	}
+	if err == user.ErrUsernameTaken {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		formatedError := helper.FormatError(err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, formatedError)
+		return
+	}
+	u.recordAudit(r, int64(user_id), audit.ActionProfileUpdate, audit.ResultSuccess, "username "+req.Username)
+	responses.JSON(w, http.StatusOK, "Username Updated")
+}
+
 func (u *UserHandler) UpdateProfileImage(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	user_id, err := strconv.Atoi(ps.ByName("id"))
 	if err != nil {
@@ -209,63 +708,140 @@ func (u *UserHandler) UpdateProfileImage(w http.ResponseWriter, r *http.Request,
 		responses.ERROR(w, http.StatusInternalServerError, formatedError)
 		return
 	}
-	err = helper.RemovePicture(user.ProfileImage.String)
-	if err != nil {
-		responses.ERROR(w, http.StatusInternalServerError, err)
+	if u.Verification.Restricted(user, verification.RestrictImageUpload) {
+		responses.ERROR(w, http.StatusForbidden, verification.ErrEmailNotVerified)
+		return
+	}
+	newPathImage, err := u.SaveImageToFile(w, r)
+	if status, ok := uploadErrorStatus(err); ok {
+		u.recordAudit(r, int64(user_id), audit.ActionImageUpload, audit.ResultFailure, err.Error())
+		responses.ERROR(w, status, err)
 		return
 	}
-	newPathImage, err := u.SaveImageToFile(r)
 	if err != nil {
-		responses.ERROR(w, http.StatusInternalServerError, err)
+		log.Println("save profile image err:", err.Error())
+		u.recordAudit(r, int64(user_id), audit.ActionImageUpload, audit.ResultFailure, newPathImage)
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
 		return
 	}
-	// log.Println("newpathimage:", newPathImage)
+	// THE OLD IMAGE IS RELEASED ONLY ONCE THE USER POINTS AT THE NEW ONE
+	oldPathImage := user.ProfileImage.String
 	user.ProfileImage = null.StringFrom(newPathImage)
 	err = u.UserUsecase.UpdateProfileImage(context.TODO(), user.ID, newPathImage)
 	if err != nil {
-		responses.ERROR(w, http.StatusInternalServerError, err)
+		log.Println("update profile image err:", err.Error())
+		u.releaseUnusedImage(newPathImage)
+		u.recordAudit(r, int64(user_id), audit.ActionImageUpload, audit.ResultFailure, newPathImage)
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
 		return
 	}
+	err = u.ProfileImages.Release(context.TODO(), oldPathImage)
+	if err != nil {
+		log.Println("release replaced profile image err:", err.Error())
+	}
+	u.recordAudit(r, int64(user_id), audit.ActionImageUpload, audit.ResultSuccess, newPathImage)
 	w.Header().Set("Content-Type", "application/json")
 	responses.JSON(w, http.StatusOK, "ProfileImage Updated")
 }
 
-func (u *UserHandler) SaveImageToFile(r *http.Request) (string, error) {
-	r.ParseMultipartForm(32 << 20)
-	file, _, err := r.FormFile("image") //retrieve the file from form data
+// releaseUnusedImage drops the reference of an upload the user update failed
+// for. An update failing after mysql was written leaves the user pointing at
+// the image, then the reference stays.
+func (u *UserHandler) releaseUnusedImage(name string) {
+	referenced, err := u.UserUsecase.ReferencedProfileImages(context.TODO(), []string{name})
+	if err != nil {
+		log.Println("check failed profile image err:", err.Error())
+		return
+	}
+	if len(referenced) > 0 {
+		return
+	}
+	err = u.ProfileImages.Release(context.TODO(), name)
+	if err != nil {
+		log.Println("release failed profile image err:", err.Error())
+	}
+}
 
-	fileBytes, err := ioutil.ReadAll(file)
+// SaveImageToFile stores the uploaded image as a normalized original plus its
+// thumbnails and returns the name of the original, which is derived from its
+// content and holds a reference to it. Thumbnails are named by
+// imaging.ThumbnailName. The upload is streamed to a
+// temporary file, it is never held in memory as a whole.
+func (u *UserHandler) SaveImageToFile(w http.ResponseWriter, r *http.Request) (string, error) {
+	upload, err := imaging.Receive(w, r, "image")
 	if err != nil {
 		return "INVALID_FILE", err
 	}
+	defer upload.Close()
 
-	// check file type, detectcontenttype only needs the first 512 bytes
-	detectedFileType := http.DetectContentType(fileBytes)
-	switch detectedFileType {
-	case "image/jpeg", "image/jpg":
-	case "image/png":
-		break
-	default:
+	set, err := imaging.ProcessReader(upload.File)
+	if err != nil {
 		return "INVALID_FILE_TYPE", err
 	}
-	fileName := helper.RandToken(12)
-	fileEndings, err := mime.ExtensionsByType(detectedFileType)
+	fileName, err := u.ProfileImages.Save(r.Context(), set)
 	if err != nil {
-		return "CANT_READ_FILE_TYPE", err
+		log.Println("save profile image err:", err.Error())
+		return "CANT_WRITE_FILE", err
 	}
-	// log.Println("fileName+fileEndings[0]:", fileName+fileEndings[0])
-	newPath := filepath.Join(os.Getenv("IMAGE_PATH"), fileName+fileEndings[0])
-	// fmt.Printf("FileType: %s, File: %s\n", detectedFileType, newPath)
+	return fileName, nil
+}
 
-	// write file
-	newFile, err := os.Create(newPath)
+// uploadErrorStatus maps the errors of an image upload the client caused to
+// their status
+func uploadErrorStatus(err error) (int, bool) {
+	switch err {
+	case http.ErrMissingFile, imaging.ErrMalformedUpload:
+		return http.StatusBadRequest, true
+	case imaging.ErrUploadTooLarge:
+		return http.StatusRequestEntityTooLarge, true
+	case imaging.ErrUnsupportedFormat:
+		return http.StatusUnsupportedMediaType, true
+	case imaging.ErrInvalidImage, imaging.ErrDimensionsTooLarge:
+		return http.StatusUnprocessableEntity, true
+	}
+	return 0, false
+}
+
+// UpdateRole changes the role of a user. The user's tokens are revoked since
+// they still carry the old role.
+func (u *UserHandler) UpdateRole(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user_id, err := strconv.Atoi(ps.ByName("id"))
 	if err != nil {
-		return "CANT_WRITE_FILE", err
+		responses.ERROR(w, http.StatusBadRequest, errors.New(http.StatusText(http.StatusBadRequest)))
+		return
 	}
-	defer newFile.Close() // idempotent, okay to call twice
-	if _, err := newFile.Write(fileBytes); err != nil || newFile.Close() != nil {
-		return "CANT_WRITE_FILE", err
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
 	}
-	return fileName + fileEndings[0], nil
-
+	defer r.Body.Close()
+	req := &updateRoleRequest{}
+	err = json.Unmarshal(body, req)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	_, err = u.UserUsecase.GetByID(context.TODO(), int64(user_id))
+	if err != nil {
+		formatedError := helper.FormatError(err.Error())
+		responses.ERROR(w, http.StatusNotFound, formatedError)
+		return
+	}
+	err = u.UserUsecase.UpdateRole(context.TODO(), int64(user_id), req.Role)
+	if err == user.ErrInvalidRole {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	err = u.Revoker.RevokeUser(r.Context(), int64(user_id))
+	if err != nil {
+		log.Println("update role revoke tokens err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, "Role Updated")
 }
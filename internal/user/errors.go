@@ -0,0 +1,16 @@
+package user
+
+import "errors"
+
+var (
+	ErrUsernameTaken     = errors.New("Username Already Taken")
+	ErrIncorrectPassword = errors.New("Incorrect Password")
+	ErrInvalidRole       = errors.New("Invalid Role")
+	ErrInvalidStatus     = errors.New("Invalid Status")
+	ErrAccountDeleted    = errors.New("Account Deleted")
+	ErrAccountBanned     = errors.New("Account Banned")
+	ErrAccountSuspended  = errors.New("Account Suspended")
+	// ErrInvalidSuspension is a suspension without an end in the future, or
+	// an end given for any other status
+	ErrInvalidSuspension = errors.New("Invalid Suspension End")
+)
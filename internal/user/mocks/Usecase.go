@@ -5,12 +5,102 @@ package mocks
 import context "context"
 import mock "github.com/stretchr/testify/mock"
 import models "github.com/famkampm/nentrytask/internal/models"
+import null "gopkg.in/guregu/null.v3"
+import time "time"
 
 // Usecase is an autogenerated mock type for the Usecase type
 type Usecase struct {
 	mock.Mock
 }
 
+// ChangePassword provides a mock function with given fields: ctx, id, currentPassword, newPassword
+func (_m *Usecase) ChangePassword(ctx context.Context, id int64, currentPassword string, newPassword string) error {
+	ret := _m.Called(ctx, id, currentPassword, newPassword)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string, string) error); ok {
+		r0 = rf(ctx, id, currentPassword, newPassword)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ChangeUsername provides a mock function with given fields: ctx, id, currentPassword, username
+func (_m *Usecase) ChangeUsername(ctx context.Context, id int64, currentPassword string, username string) error {
+	ret := _m.Called(ctx, id, currentPassword, username)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string, string) error); ok {
+		r0 = rf(ctx, id, currentPassword, username)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Delete provides a mock function with given fields: ctx, id
+func (_m *Usecase) Delete(ctx context.Context, id int64) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// FetchByEmail provides a mock function with given fields: ctx, email
+func (_m *Usecase) FetchByEmail(ctx context.Context, email string) ([]*models.User, error) {
+	ret := _m.Called(ctx, email)
+
+	var r0 []*models.User
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*models.User); ok {
+		r0 = rf(ctx, email)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FetchDeletedBefore provides a mock function with given fields: ctx, before, limit
+func (_m *Usecase) FetchDeletedBefore(ctx context.Context, before time.Time, limit int) ([]*models.User, error) {
+	ret := _m.Called(ctx, before, limit)
+
+	var r0 []*models.User
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) []*models.User); ok {
+		r0 = rf(ctx, before, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, int) error); ok {
+		r1 = rf(ctx, before, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetByID provides a mock function with given fields: ctx, id
 func (_m *Usecase) GetByID(ctx context.Context, id int64) (*models.User, error) {
 	ret := _m.Called(ctx, id)
@@ -57,6 +147,43 @@ func (_m *Usecase) GetByUsername(ctx context.Context, username string) (*models.
 	return r0, r1
 }
 
+// ReferencedProfileImages provides a mock function with given fields: ctx, names
+func (_m *Usecase) ReferencedProfileImages(ctx context.Context, names []string) ([]string, error) {
+	ret := _m.Called(ctx, names)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, []string) []string); ok {
+		r0 = rf(ctx, names)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, names)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SoftDelete provides a mock function with given fields: ctx, id
+func (_m *Usecase) SoftDelete(ctx context.Context, id int64) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Store provides a mock function with given fields: ctx, _a1
 func (_m *Usecase) Store(ctx context.Context, _a1 *models.User) error {
 	ret := _m.Called(ctx, _a1)
@@ -85,6 +212,20 @@ func (_m *Usecase) UpdateNickname(ctx context.Context, id int64, nickname string
 	return r0
 }
 
+// UpdatePassword provides a mock function with given fields: ctx, id, password
+func (_m *Usecase) UpdatePassword(ctx context.Context, id int64, password string) error {
+	ret := _m.Called(ctx, id, password)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) error); ok {
+		r0 = rf(ctx, id, password)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateProfileImage provides a mock function with given fields: ctx, id, profile_image
 func (_m *Usecase) UpdateProfileImage(ctx context.Context, id int64, profile_image string) error {
 	ret := _m.Called(ctx, id, profile_image)
@@ -98,3 +239,45 @@ func (_m *Usecase) UpdateProfileImage(ctx context.Context, id int64, profile_ima
 
 	return r0
 }
+
+// UpdateRole provides a mock function with given fields: ctx, id, role
+func (_m *Usecase) UpdateRole(ctx context.Context, id int64, role string) error {
+	ret := _m.Called(ctx, id, role)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) error); ok {
+		r0 = rf(ctx, id, role)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateStatus provides a mock function with given fields: ctx, id, status, suspendedUntil
+func (_m *Usecase) UpdateStatus(ctx context.Context, id int64, status string, suspendedUntil null.Time) error {
+	ret := _m.Called(ctx, id, status, suspendedUntil)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string, null.Time) error); ok {
+		r0 = rf(ctx, id, status, suspendedUntil)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpgradePasswordHash provides a mock function with given fields: ctx, _a1, password
+func (_m *Usecase) UpgradePasswordHash(ctx context.Context, _a1 *models.User, password string) error {
+	ret := _m.Called(ctx, _a1, password)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.User, string) error); ok {
+		r0 = rf(ctx, _a1, password)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -2,13 +2,32 @@ package user
 
 import (
 	"context"
+	"time"
+
 	"github.com/famkampm/nentrytask/internal/models"
+	"gopkg.in/guregu/null.v3"
 )
 
 type Repository interface {
 	Store(ctx context.Context, user *models.User) error
+	// Fill caches a user read from mysql unless it is already cached or was
+	// written to since, a cache miss must not bring back a stale copy
+	Fill(ctx context.Context, user *models.User) error
 	GetByID(ctx context.Context, id int64) (*models.User, error)
 	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	// FetchByEmail lists the accounts not deleted using email
+	FetchByEmail(ctx context.Context, email string) ([]*models.User, error)
 	UpdateNickname(ctx context.Context, id int64, nickname string) error
 	UpdateProfileImage(ctx context.Context, id int64, profile_image string) error
+	UpdatePassword(ctx context.Context, id int64, password string) error
+	UpdateUsername(ctx context.Context, id int64, username string) error
+	UpdateRole(ctx context.Context, id int64, role string) error
+	UpdateStatus(ctx context.Context, id int64, status string, suspendedUntil null.Time) error
+	// SoftDelete marks the user deleted at the given time, Delete removes it
+	SoftDelete(ctx context.Context, id int64, at time.Time) error
+	FetchDeletedBefore(ctx context.Context, before time.Time, limit int) ([]*models.User, error)
+	// ReferencedProfileImages returns which of names are the profile image of
+	// a user, deleted users included
+	ReferencedProfileImages(ctx context.Context, names []string) ([]string, error)
+	Delete(ctx context.Context, id int64) error
 }
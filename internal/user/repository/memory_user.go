@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"log"
+	"time"
 
 	"github.com/famkampm/nentrytask/internal/models"
 	"github.com/famkampm/nentrytask/internal/user"
@@ -29,6 +30,12 @@ func (m *memoryUserRepository) Store(ctx context.Context, user *models.User) err
 	m.hm[user.ID] = string(user_byte)
 	return nil
 }
+func (m *memoryUserRepository) Fill(ctx context.Context, user *models.User) error {
+	if _, ok := m.hm[user.ID]; ok {
+		return nil
+	}
+	return m.Store(ctx, user)
+}
 func (m *memoryUserRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
 	ret := m.hm[id]
 	if ret == "" {
@@ -44,6 +51,10 @@ func (m *memoryUserRepository) GetByID(ctx context.Context, id int64) (*models.U
 func (m *memoryUserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	return &models.User{}, nil
 }
+func (m *memoryUserRepository) FetchByEmail(ctx context.Context, email string) ([]*models.User, error) {
+	return []*models.User{}, nil
+}
+
 func (m *memoryUserRepository) UpdateNickname(ctx context.Context, id int64, nickname string) error {
 	user, err := m.GetByID(ctx, id)
 	if err != nil {
@@ -73,3 +84,79 @@ func (m *memoryUserRepository) UpdateProfileImage(ctx context.Context, id int64,
 	}
 	return nil
 }
+func (m *memoryUserRepository) UpdatePassword(ctx context.Context, id int64, password string) error {
+	user, err := m.GetByID(ctx, id)
+	if err != nil {
+		log.Println("update password memory getbyid err:", err.Error())
+		return err
+	}
+	user.Password = password
+	err = m.Store(ctx, user)
+	if err != nil {
+		log.Println("update password memory store err:", err.Error())
+		return err
+	}
+	return nil
+}
+func (m *memoryUserRepository) UpdateUsername(ctx context.Context, id int64, username string) error {
+	user, err := m.GetByID(ctx, id)
+	if err != nil {
+		log.Println("update username memory getbyid err:", err.Error())
+		return err
+	}
+	user.Username = username
+	err = m.Store(ctx, user)
+	if err != nil {
+		log.Println("update username memory store err:", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (m *memoryUserRepository) UpdateRole(ctx context.Context, id int64, role string) error {
+	user, err := m.GetByID(ctx, id)
+	if err != nil {
+		log.Println("update role memory getbyid err:", err.Error())
+		return err
+	}
+	user.Role = role
+	err = m.Store(ctx, user)
+	if err != nil {
+		log.Println("update role memory store err:", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (m *memoryUserRepository) UpdateStatus(ctx context.Context, id int64, status string, suspendedUntil null.Time) error {
+	user, err := m.GetByID(ctx, id)
+	if err != nil {
+		log.Println("update status memory getbyid err:", err.Error())
+		return err
+	}
+	user.Status = status
+	user.SuspendedUntil = suspendedUntil
+	err = m.Store(ctx, user)
+	if err != nil {
+		log.Println("update status memory store err:", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (m *memoryUserRepository) SoftDelete(ctx context.Context, id int64, at time.Time) error {
+	return m.UpdateStatus(ctx, id, user.StatusDeleted, null.Time{})
+}
+
+func (m *memoryUserRepository) FetchDeletedBefore(ctx context.Context, before time.Time, limit int) ([]*models.User, error) {
+	return []*models.User{}, nil
+}
+
+func (m *memoryUserRepository) ReferencedProfileImages(ctx context.Context, names []string) ([]string, error) {
+	return []string{}, nil
+}
+
+func (m *memoryUserRepository) Delete(ctx context.Context, id int64) error {
+	delete(m.hm, id)
+	return nil
+}
@@ -4,12 +4,21 @@ import (
 	"context"
 	"database/sql"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/famkampm/nentrytask/internal/models"
 	"github.com/famkampm/nentrytask/internal/user"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/go-sql-driver/mysql"
 	"gopkg.in/guregu/null.v3"
 )
 
+// mysqlErrDuplicateEntry is returned when an insert or update breaks a unique index
+const mysqlErrDuplicateEntry = 1062
+
+const userColumns = `id, username, password, nickname, profile_image, email, role, status, suspended_until`
+
 type mysqlUserRepository struct {
 	DB *sql.DB
 }
@@ -21,15 +30,21 @@ func NewMysqlUserRepository(db *sql.DB) user.Repository {
 
 }
 
-func (m *mysqlUserRepository) Store(ctx context.Context, user *models.User) error {
+func (m *mysqlUserRepository) Store(ctx context.Context, usr *models.User) error {
 	// query := `INSERT  article SET title=? , content=? , author_id=?, updated_at=? , created_at=?`
-	query := `insert into user (username, password, nickname, profile_image) values (?, ?, ?, ?)`
+	query := `insert into user (username, password, nickname, profile_image, email, role, status) values (?, ?, ?, ?, ?, ?, ?)`
 	stmt, err := m.DB.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
-	res, err := stmt.ExecContext(ctx, user.Username, user.Password, user.Nickname, user.ProfileImage)
+	if usr.Role == "" {
+		usr.Role = auth.RoleUser
+	}
+	if usr.Status == "" {
+		usr.Status = user.StatusActive
+	}
+	res, err := stmt.ExecContext(ctx, usr.Username, usr.Password, usr.Nickname, usr.ProfileImage, usr.Email, usr.Role, usr.Status)
 	if err != nil {
 		return err
 	}
@@ -37,15 +52,20 @@ func (m *mysqlUserRepository) Store(ctx context.Context, user *models.User) erro
 	if err != nil {
 		return err
 	}
-	user.ID = id
+	usr.ID = id
 	return nil
 
 }
 
+// Fill is only answered by redis
+func (m *mysqlUserRepository) Fill(ctx context.Context, usr *models.User) error {
+	return nil
+}
+
 func (m *mysqlUserRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
 	user := &models.User{}
-	query := `select * from user where id= ?`
-	err := m.DB.QueryRow(query, id).Scan(&user.ID, &user.Username, &user.Password, &user.Nickname, &user.ProfileImage)
+	query := `select ` + userColumns + ` from user where id= ?`
+	err := m.DB.QueryRow(query, id).Scan(&user.ID, &user.Username, &user.Password, &user.Nickname, &user.ProfileImage, &user.Email, &user.Role, &user.Status, &user.SuspendedUntil)
 	if err != nil {
 		return &models.User{}, err
 	}
@@ -54,14 +74,33 @@ func (m *mysqlUserRepository) GetByID(ctx context.Context, id int64) (*models.Us
 
 func (m *mysqlUserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	user := &models.User{}
-	query := `select * from user where username= ?`
-	err := m.DB.QueryRow(query, username).Scan(&user.ID, &user.Username, &user.Password, &user.Nickname, &user.ProfileImage)
+	query := `select ` + userColumns + ` from user where username= ?`
+	err := m.DB.QueryRow(query, username).Scan(&user.ID, &user.Username, &user.Password, &user.Nickname, &user.ProfileImage, &user.Email, &user.Role, &user.Status, &user.SuspendedUntil)
 	if err != nil {
 		return &models.User{}, err
 	}
 	return user, nil
 }
 
+func (m *mysqlUserRepository) FetchByEmail(ctx context.Context, email string) ([]*models.User, error) {
+	query := `select ` + userColumns + ` from user where email = ? and status != ? order by id`
+	rows, err := m.DB.QueryContext(ctx, query, email, user.StatusDeleted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := []*models.User{}
+	for rows.Next() {
+		usr := &models.User{}
+		err = rows.Scan(&usr.ID, &usr.Username, &usr.Password, &usr.Nickname, &usr.ProfileImage, &usr.Email, &usr.Role, &usr.Status, &usr.SuspendedUntil)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, usr)
+	}
+	return users, rows.Err()
+}
+
 func (m *mysqlUserRepository) UpdateNickname(ctx context.Context, id int64, nickname string) error {
 	query := `update user set nickname = ? where id = ?`
 	stmt, err := m.DB.PrepareContext(ctx, query)
@@ -92,3 +131,139 @@ func (m *mysqlUserRepository) UpdateProfileImage(ctx context.Context, id int64,
 	}
 	return nil
 }
+
+func (m *mysqlUserRepository) UpdatePassword(ctx context.Context, id int64, password string) error {
+	query := `update user set password = ? where id = ?`
+	stmt, err := m.DB.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, password, id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateUsername relies on the unique index on username, so two users racing
+// for the same name can't both get it
+func (m *mysqlUserRepository) UpdateUsername(ctx context.Context, id int64, username string) error {
+	query := `update user set username = ? where id = ?`
+	stmt, err := m.DB.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, username, id)
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == mysqlErrDuplicateEntry {
+		return user.ErrUsernameTaken
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *mysqlUserRepository) UpdateRole(ctx context.Context, id int64, role string) error {
+	query := `update user set role = ? where id = ?`
+	stmt, err := m.DB.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, role, id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *mysqlUserRepository) UpdateStatus(ctx context.Context, id int64, status string, suspendedUntil null.Time) error {
+	query := `update user set status = ?, suspended_until = ? where id = ?`
+	stmt, err := m.DB.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, status, suspendedUntil, id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *mysqlUserRepository) SoftDelete(ctx context.Context, id int64, at time.Time) error {
+	query := `update user set status = ?, suspended_until = null, deleted_at = ? where id = ?`
+	stmt, err := m.DB.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, user.StatusDeleted, at, id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// FetchDeletedBefore checks the status as well, an account moved out of
+// deleted during its grace period keeps its stale deleted_at
+func (m *mysqlUserRepository) FetchDeletedBefore(ctx context.Context, before time.Time, limit int) ([]*models.User, error) {
+	query := `select ` + userColumns + ` from user where status = ? and deleted_at < ? order by deleted_at limit ?`
+	rows, err := m.DB.QueryContext(ctx, query, user.StatusDeleted, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := []*models.User{}
+	for rows.Next() {
+		usr := &models.User{}
+		err = rows.Scan(&usr.ID, &usr.Username, &usr.Password, &usr.Nickname, &usr.ProfileImage, &usr.Email, &usr.Role, &usr.Status, &usr.SuspendedUntil)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, usr)
+	}
+	return users, rows.Err()
+}
+
+func (m *mysqlUserRepository) ReferencedProfileImages(ctx context.Context, names []string) ([]string, error) {
+	referenced := []string{}
+	if len(names) == 0 {
+		return referenced, nil
+	}
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		args[i] = name
+	}
+	query := `select distinct profile_image from user where profile_image in (?` + strings.Repeat(`, ?`, len(names)-1) + `)`
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		referenced = append(referenced, name)
+	}
+	return referenced, rows.Err()
+}
+
+func (m *mysqlUserRepository) Delete(ctx context.Context, id int64) error {
+	query := `delete from user where id = ?`
+	stmt, err := m.DB.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/user"
 	"github.com/famkampm/nentrytask/internal/user/repository"
+	"github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/guregu/null.v3"
 )
@@ -22,7 +25,7 @@ func TestStoreSuccessMysql(t *testing.T) {
 	defer db.Close()
 
 	prep := mock.ExpectPrepare("insert into user")
-	prep.ExpectExec().WithArgs("user1", "pass1", "nick1", "prof1").
+	prep.ExpectExec().WithArgs("user1", "pass1", "nick1", "prof1", "email1", "user", "active").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 		// a := articleRepo.NewMysqlArticleRepository(db)
@@ -32,6 +35,7 @@ func TestStoreSuccessMysql(t *testing.T) {
 		Password:     "pass1",
 		Nickname:     null.StringFrom("nick1"),
 		ProfileImage: null.StringFrom("prof1"),
+		Email:        null.StringFrom("email1"),
 	}
 	err = u.Store(context.TODO(), user)
 	assert.NoError(t, err)
@@ -59,7 +63,7 @@ func TestStoreFailedMysql(t *testing.T) {
 	defer db.Close()
 
 	prep := mock.ExpectPrepare("insert into user")
-	prep.ExpectExec().WithArgs("user1", "pass1", "nick1", "prof1").
+	prep.ExpectExec().WithArgs("user1", "pass1", "nick1", "prof1", "email1", "user", "active").
 		WillReturnError(fmt.Errorf("some error"))
 	u := repository.NewMysqlUserRepository(db)
 	user := &models.User{
@@ -67,6 +71,7 @@ func TestStoreFailedMysql(t *testing.T) {
 		Password:     "pass1",
 		Nickname:     null.StringFrom("nick1"),
 		ProfileImage: null.StringFrom("prof1"),
+		Email:        null.StringFrom("email1"),
 	}
 	err = u.Store(context.TODO(), user)
 	assert.NotNil(t, err)
@@ -81,10 +86,10 @@ func TestGetByIDSuccessMysql(t *testing.T) {
 	defer db.Close()
 
 	// before we actually execute our api function, we need to expect required DB actions
-	rows := sqlmock.NewRows([]string{"id", "username", "password", "nickname", "profile_image"}).
-		AddRow(1, "user1", "pass1", "nick1", "prof1")
+	rows := sqlmock.NewRows([]string{"id", "username", "password", "nickname", "profile_image", "email", "role", "status", "suspended_until"}).
+		AddRow(1, "user1", "pass1", "nick1", "prof1", "email1", "user", "active", nil)
 
-	mock.ExpectQuery("select \\* from user where id= \\?").WithArgs(1).WillReturnRows(rows)
+	mock.ExpectQuery("select (.+) from user where id= \\?").WithArgs(1).WillReturnRows(rows)
 	u := repository.NewMysqlUserRepository(db)
 	user, err := u.GetByID(context.TODO(), 1)
 	assert.NoError(t, err)
@@ -98,7 +103,7 @@ func TestGetByIDFailedMysql(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectQuery("select \\* from user where id= \\?").WithArgs(1).WillReturnError(fmt.Errorf("some error"))
+	mock.ExpectQuery("select (.+) from user where id= \\?").WithArgs(1).WillReturnError(fmt.Errorf("some error"))
 	u := repository.NewMysqlUserRepository(db)
 	_, err = u.GetByID(context.TODO(), 1)
 	assert.NotNil(t, err)
@@ -112,10 +117,10 @@ func TestGetByUsernameSuccessMysql(t *testing.T) {
 	defer db.Close()
 
 	// before we actually execute our api function, we need to expect required DB actions
-	rows := sqlmock.NewRows([]string{"id", "username", "password", "nickname", "profile_image"}).
-		AddRow(1, "user1", "pass1", "nick1", "prof1")
+	rows := sqlmock.NewRows([]string{"id", "username", "password", "nickname", "profile_image", "email", "role", "status", "suspended_until"}).
+		AddRow(1, "user1", "pass1", "nick1", "prof1", "email1", "user", "active", nil)
 
-	mock.ExpectQuery("select \\* from user where username= \\?").WithArgs("user1").
+	mock.ExpectQuery("select (.+) from user where username= \\?").WithArgs("user1").
 		WillReturnRows(rows)
 	u := repository.NewMysqlUserRepository(db)
 	user, err := u.GetByUsername(context.TODO(), "user1")
@@ -130,7 +135,7 @@ func TestGetByUsernameFailedMysql(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectQuery("select \\* from user where username= \\?").WithArgs("user1").
+	mock.ExpectQuery("select (.+) from user where username= \\?").WithArgs("user1").
 		WillReturnError(fmt.Errorf("some error"))
 
 	u := repository.NewMysqlUserRepository(db)
@@ -138,6 +143,26 @@ func TestGetByUsernameFailedMysql(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestFetchByEmailMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "username", "password", "nickname", "profile_image", "email", "role", "status", "suspended_until"}).
+		AddRow(1, "user1", "pass1", "nick1", "prof1", "a@example.com", "user", "active", nil).
+		AddRow(2, "user2", "pass2", "nick2", "prof2", "a@example.com", "user", "suspended", nil)
+
+	mock.ExpectQuery("select (.+) from user where email = \\? and status != \\?").WithArgs("a@example.com", "deleted").
+		WillReturnRows(rows)
+	u := repository.NewMysqlUserRepository(db)
+	users, err := u.FetchByEmail(context.TODO(), "a@example.com")
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, int64(2), users[1].ID)
+}
+
 func TestUpdateNicknameSuccessMysql(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -231,3 +256,183 @@ func TestUpdateProfileImageFailedMysql(t *testing.T) {
 	err = u.UpdateProfileImage(context.TODO(), int64(1), "prof1")
 	assert.NotNil(t, err)
 }
+
+func TestUpdatePasswordSuccessMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	prep := mock.ExpectPrepare("update user set password = \\? where id = \\?")
+	prep.ExpectExec().WithArgs("hash1", int64(1)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	u := repository.NewMysqlUserRepository(db)
+	err = u.UpdatePassword(context.TODO(), int64(1), "hash1")
+	assert.Nil(t, err)
+}
+
+func TestUpdatePasswordFailedMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	prep := mock.ExpectPrepare("update user set password = \\? where id = \\?")
+	prep.ExpectExec().WithArgs("hash1", int64(1)).
+		WillReturnError(fmt.Errorf("some error"))
+
+	u := repository.NewMysqlUserRepository(db)
+	err = u.UpdatePassword(context.TODO(), int64(1), "hash1")
+	assert.NotNil(t, err)
+}
+
+func TestUpdateUsernameSuccessMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	prep := mock.ExpectPrepare("update user set username = \\? where id = \\?")
+	prep.ExpectExec().WithArgs("user2", int64(1)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	u := repository.NewMysqlUserRepository(db)
+	err = u.UpdateUsername(context.TODO(), int64(1), "user2")
+	assert.Nil(t, err)
+}
+
+func TestUpdateUsernameDuplicateMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	prep := mock.ExpectPrepare("update user set username = \\? where id = \\?")
+	prep.ExpectExec().WithArgs("user2", int64(1)).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'user2' for key 'username'"})
+
+	u := repository.NewMysqlUserRepository(db)
+	err = u.UpdateUsername(context.TODO(), int64(1), "user2")
+	assert.Equal(t, user.ErrUsernameTaken, err)
+}
+
+func TestUpdateRoleSuccessMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	prep := mock.ExpectPrepare("update user set role")
+	prep.ExpectExec().WithArgs("support", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	u := repository.NewMysqlUserRepository(db)
+	err = u.UpdateRole(context.TODO(), int64(1), "support")
+	assert.NoError(t, err)
+}
+
+func TestUpdateStatusSuccessMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	until := null.TimeFrom(time.Now().UTC().Add(time.Hour))
+	prep := mock.ExpectPrepare("update user set status = \\?, suspended_until = \\? where id = \\?")
+	prep.ExpectExec().WithArgs("suspended", until, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	u := repository.NewMysqlUserRepository(db)
+	err = u.UpdateStatus(context.TODO(), int64(1), "suspended", until)
+	assert.NoError(t, err)
+}
+
+func TestGetByIDSuspendedMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	until := time.Now().UTC().Add(time.Hour)
+	rows := sqlmock.NewRows([]string{"id", "username", "password", "nickname", "profile_image", "email", "role", "status", "suspended_until"}).
+		AddRow(1, "user1", "pass1", nil, nil, nil, "user", "suspended", until)
+	mock.ExpectQuery("select (.+) from user where id= \\?").WithArgs(1).WillReturnRows(rows)
+	u := repository.NewMysqlUserRepository(db)
+	usr, err := u.GetByID(context.TODO(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, user.StatusSuspended, usr.Status)
+	assert.Equal(t, until, usr.SuspendedUntil.Time)
+}
+
+func TestSoftDeleteSuccessMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	at := time.Now().UTC()
+	prep := mock.ExpectPrepare("update user set status = \\?, suspended_until = null, deleted_at = \\? where id = \\?")
+	prep.ExpectExec().WithArgs(user.StatusDeleted, at, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	u := repository.NewMysqlUserRepository(db)
+	err = u.SoftDelete(context.TODO(), int64(1), at)
+	assert.NoError(t, err)
+}
+
+func TestFetchDeletedBeforeSuccessMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	before := time.Now().UTC()
+	rows := sqlmock.NewRows([]string{"id", "username", "password", "nickname", "profile_image", "email", "role", "status", "suspended_until"}).
+		AddRow(1, "user1", "pass1", "nick1", "prof1", nil, "user", "deleted", nil).
+		AddRow(2, "user2", "pass2", nil, nil, nil, "user", "deleted", nil)
+	mock.ExpectQuery("select (.+) from user where status = \\? and deleted_at < \\? order by deleted_at limit \\?").
+		WithArgs(user.StatusDeleted, before, 100).WillReturnRows(rows)
+	u := repository.NewMysqlUserRepository(db)
+	users, err := u.FetchDeletedBefore(context.TODO(), before, 100)
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "prof1", users[0].ProfileImage.String)
+	assert.False(t, users[1].ProfileImage.Valid)
+}
+
+func TestReferencedProfileImagesMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"profile_image"}).AddRow("a.png")
+	mock.ExpectQuery("select distinct profile_image from user where profile_image in \\(\\?, \\?\\)").
+		WithArgs("a.png", "b.png").WillReturnRows(rows)
+	u := repository.NewMysqlUserRepository(db)
+	referenced, err := u.ReferencedProfileImages(context.TODO(), []string{"a.png", "b.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.png"}, referenced)
+
+	// NO QUERY FOR NO NAMES
+	referenced, err = u.ReferencedProfileImages(context.TODO(), nil)
+	assert.NoError(t, err)
+	assert.Empty(t, referenced)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteSuccessMysql(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	prep := mock.ExpectPrepare("delete from user where id = \\?")
+	prep.ExpectExec().WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	u := repository.NewMysqlUserRepository(db)
+	err = u.Delete(context.TODO(), int64(1))
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"log"
 	"strconv"
+	"time"
 
 	"github.com/famkampm/nentrytask/internal/models"
 	"github.com/famkampm/nentrytask/internal/user"
@@ -12,6 +13,26 @@ import (
 	"gopkg.in/guregu/null.v3"
 )
 
+const (
+	// FILLED COPIES EXPIRE SO A MISSED INVALIDATION HEALS ITSELF
+	fillTTL = time.Minute
+	// A FILL THAT READ MYSQL BEFORE A WRITE CAN LAND AFTER IT, THE WRITE
+	// LEAVES A MARKER THAT BLOCKS FILLS FOR A WHILE
+	writtenTTL = 10 * time.Second
+)
+
+// fillScript sets KEYS[1] only when neither it nor the written marker KEYS[2]
+// exists
+var fillScript = redis.NewScript(2, `
+if redis.call("EXISTS", KEYS[2]) == 1 then
+	return 0
+end
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "EX", ARGV[2]) then
+	return 1
+end
+return 0
+`)
+
 type redisUserRepository struct {
 	// Redis redis.Conn
 	RedisPool *redis.Pool
@@ -52,6 +73,19 @@ func (r *redisUserRepository) Store(ctx context.Context, user *models.User) erro
 	return nil
 }
 
+// Fill caches a user read from mysql with a short ttl, skipping it when the
+// user is already cached or was just written
+func (r *redisUserRepository) Fill(ctx context.Context, user *models.User) error {
+	json, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	_, err = fillScript.Do(conn, strconv.Itoa(int(user.ID)), writtenKey(user.ID), string(json), int(fillTTL.Seconds()))
+	return err
+}
+
 func (r *redisUserRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
 	conn := r.RedisPool.Get()
 	defer conn.Close()
@@ -78,6 +112,11 @@ func (r *redisUserRepository) GetByUsername(ctx context.Context, username string
 	return &models.User{}, nil
 }
 
+// FetchByEmail is only answered by mysql
+func (r *redisUserRepository) FetchByEmail(ctx context.Context, email string) ([]*models.User, error) {
+	return []*models.User{}, nil
+}
+
 func (r *redisUserRepository) UpdateNickname(ctx context.Context, id int64, nickname string) error {
 	// log.Println("update nickname caled")
 	user, err := r.GetByID(ctx, id)
@@ -107,3 +146,76 @@ func (r *redisUserRepository) UpdateProfileImage(ctx context.Context, id int64,
 	}
 	return nil
 }
+
+// UpdatePassword drops the cached user instead of rewriting it, the next
+// GetByID reloads it from mysql
+func (r *redisUserRepository) UpdatePassword(ctx context.Context, id int64, password string) error {
+	return r.invalidate(id)
+}
+
+func (r *redisUserRepository) UpdateUsername(ctx context.Context, id int64, username string) error {
+	return r.invalidate(id)
+}
+
+func (r *redisUserRepository) UpdateRole(ctx context.Context, id int64, role string) error {
+	return r.invalidate(id)
+}
+
+// UpdateStatus rewrites the cached copy rather than dropping it, the middleware
+// reads the status on every request
+func (r *redisUserRepository) UpdateStatus(ctx context.Context, id int64, status string, suspendedUntil null.Time) error {
+	err := r.markWritten(id)
+	if err != nil {
+		return err
+	}
+	user, err := r.GetByID(ctx, id)
+	if err == redis.ErrNil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	user.Status = status
+	user.SuspendedUntil = suspendedUntil
+	return r.Store(ctx, user)
+}
+
+func (r *redisUserRepository) SoftDelete(ctx context.Context, id int64, at time.Time) error {
+	return r.invalidate(id)
+}
+
+// FetchDeletedBefore is only answered by mysql
+func (r *redisUserRepository) FetchDeletedBefore(ctx context.Context, before time.Time, limit int) ([]*models.User, error) {
+	return []*models.User{}, nil
+}
+
+// ReferencedProfileImages is only answered by mysql
+func (r *redisUserRepository) ReferencedProfileImages(ctx context.Context, names []string) ([]string, error) {
+	return []string{}, nil
+}
+
+func (r *redisUserRepository) Delete(ctx context.Context, id int64) error {
+	return r.invalidate(id)
+}
+
+func (r *redisUserRepository) invalidate(id int64) error {
+	err := r.markWritten(id)
+	if err != nil {
+		return err
+	}
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	_, err = conn.Do("DEL", strconv.Itoa(int(id)))
+	return err
+}
+
+func (r *redisUserRepository) markWritten(id int64) error {
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	_, err := conn.Do("SET", writtenKey(id), 1, "EX", int(writtenTTL.Seconds()))
+	return err
+}
+
+func writtenKey(id int64) string {
+	return "user_written:" + strconv.Itoa(int(id))
+}
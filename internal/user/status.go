@@ -0,0 +1,47 @@
+package user
+
+import (
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/models"
+)
+
+// Account statuses. Accounts registered with an email start as pending until
+// the address is verified. Suspended accounts are blocked until their
+// suspended_until, banned ones for good. Deleted accounts are purged once their
+// grace period is over.
+const (
+	StatusActive              = "active"
+	StatusPendingVerification = "pending_verification"
+	StatusSuspended           = "suspended"
+	StatusBanned              = "banned"
+	StatusDeleted             = "deleted"
+)
+
+var statuses = map[string]bool{
+	StatusActive:              true,
+	StatusPendingVerification: true,
+	StatusSuspended:           true,
+	StatusBanned:              true,
+	StatusDeleted:             true,
+}
+
+func ValidStatus(status string) bool {
+	return statuses[status]
+}
+
+// CheckStatus returns why the account of usr can't be used at now, nil when it
+// can. A suspension that ran out needs no reactivation.
+func CheckStatus(usr *models.User, now time.Time) error {
+	switch usr.Status {
+	case StatusDeleted:
+		return ErrAccountDeleted
+	case StatusBanned:
+		return ErrAccountBanned
+	case StatusSuspended:
+		if !usr.SuspendedUntil.Valid || now.Before(usr.SuspendedUntil.Time) {
+			return ErrAccountSuspended
+		}
+	}
+	return nil
+}
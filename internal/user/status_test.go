@@ -0,0 +1,24 @@
+package user_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/user"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestCheckStatus(t *testing.T) {
+	now := time.Now()
+	assert.NoError(t, user.CheckStatus(&models.User{Status: user.StatusActive}, now))
+	assert.NoError(t, user.CheckStatus(&models.User{Status: user.StatusPendingVerification}, now))
+	assert.Equal(t, user.ErrAccountBanned, user.CheckStatus(&models.User{Status: user.StatusBanned}, now))
+	assert.Equal(t, user.ErrAccountDeleted, user.CheckStatus(&models.User{Status: user.StatusDeleted}, now))
+
+	suspended := &models.User{Status: user.StatusSuspended, SuspendedUntil: null.TimeFrom(now.Add(time.Hour))}
+	assert.Equal(t, user.ErrAccountSuspended, user.CheckStatus(suspended, now))
+	// the suspension ran out
+	assert.NoError(t, user.CheckStatus(suspended, now.Add(2*time.Hour)))
+}
@@ -2,8 +2,10 @@ package user
 
 import (
 	"context"
+	"time"
 
 	"github.com/famkampm/nentrytask/internal/models"
+	"gopkg.in/guregu/null.v3"
 )
 
 // type
@@ -12,6 +14,22 @@ type Usecase interface {
 	Store(ctx context.Context, user *models.User) error
 	GetByID(ctx context.Context, id int64) (*models.User, error)
 	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	FetchByEmail(ctx context.Context, email string) ([]*models.User, error)
 	UpdateNickname(ctx context.Context, id int64, nickname string) error
 	UpdateProfileImage(ctx context.Context, id int64, profile_image string) error
+	UpdatePassword(ctx context.Context, id int64, password string) error
+	UpgradePasswordHash(ctx context.Context, user *models.User, password string) error
+	ChangePassword(ctx context.Context, id int64, currentPassword, newPassword string) error
+	ChangeUsername(ctx context.Context, id int64, currentPassword, username string) error
+	UpdateRole(ctx context.Context, id int64, role string) error
+	// UpdateStatus needs suspendedUntil for suspensions only
+	UpdateStatus(ctx context.Context, id int64, status string, suspendedUntil null.Time) error
+	SoftDelete(ctx context.Context, id int64) error
+	// FetchDeletedBefore lists the users soft deleted before the given time
+	FetchDeletedBefore(ctx context.Context, before time.Time, limit int) ([]*models.User, error)
+	// ReferencedProfileImages returns which of names are the profile image of
+	// a user, deleted users included
+	ReferencedProfileImages(ctx context.Context, names []string) ([]string, error)
+	// Delete removes the user from mysql, redis and memory for good
+	Delete(ctx context.Context, id int64) error
 }
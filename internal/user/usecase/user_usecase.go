@@ -2,10 +2,14 @@ package usecase
 
 import (
 	"context"
+	"database/sql"
 	"github.com/famkampm/nentrytask/internal/models"
 	"github.com/famkampm/nentrytask/internal/user"
+	"github.com/famkampm/nentrytask/pkg/auth"
 	"github.com/famkampm/nentrytask/pkg/helper"
+	"gopkg.in/guregu/null.v3"
 	"log"
+	"time"
 )
 
 type userUsecase struct {
@@ -66,6 +70,12 @@ func (u *userUsecase) GetByID(ctx context.Context, id int64) (*models.User, erro
 		log.Println("usecase get by id from mysql err:", err.Error())
 		return &models.User{}, err
 	}
+	// PUT IT BACK TO REDIS, THE MIDDLEWARE READS THE USER ON EVERY REQUEST.
+	// FILL SKIPS IT IF THE USER WAS WRITTEN WHILE WE READ MYSQL
+	err = u.userRepoRedis.Fill(ctx, user)
+	if err != nil {
+		log.Println("usecase fill redis after get by id err:", err.Error())
+	}
 
 	return user, nil
 }
@@ -74,6 +84,10 @@ func (u *userUsecase) GetByUsername(ctx context.Context, username string) (*mode
 	return u.userRepoMysql.GetByUsername(ctx, username)
 }
 
+func (u *userUsecase) FetchByEmail(ctx context.Context, email string) ([]*models.User, error) {
+	return u.userRepoMysql.FetchByEmail(ctx, email)
+}
+
 func (u *userUsecase) UpdateNickname(ctx context.Context, id int64, nickname string) error {
 	// UPDATE MUST APPLY TO BOTH REDIS AND MYSQL
 	// err = u.userRepoMemory.UpdateNickname(ctx, id, nickname)
@@ -115,6 +129,181 @@ func (u *userUsecase) UpdateProfileImage(ctx context.Context, id int64, profile_
 	return nil
 }
 
+func (u *userUsecase) UpdatePassword(ctx context.Context, id int64, password string) error {
+	// THE REDIS COPY CARRIES THE HASH TOO, SO IT IS DROPPED FROM THE CACHE
+	err := u.userRepoMysql.UpdatePassword(ctx, id, password)
+	if err != nil {
+		log.Println("usecase failed to update password mysql repo:", err.Error())
+		return err
+	}
+	err = u.userRepoRedis.UpdatePassword(ctx, id, password)
+	if err != nil {
+		log.Println("usecase failed to update password redis repo:", err.Error())
+		return err
+	}
+	return nil
+}
+
+// UpgradePasswordHash re-hashes the password the user just logged in with when
+// the stored hash is weaker than the current hash policy
+func (u *userUsecase) UpgradePasswordHash(ctx context.Context, usr *models.User, password string) error {
+	if !helper.NeedsRehash(usr.Password) {
+		return nil
+	}
+	hashedPassword, err := helper.HashingPassword(password)
+	if err != nil {
+		return err
+	}
+	err = u.UpdatePassword(ctx, usr.ID, hashedPassword)
+	if err != nil {
+		return err
+	}
+	usr.Password = hashedPassword
+	return nil
+}
+
+// ChangePassword re-checks the current password against mysql before setting
+// the new one
+func (u *userUsecase) ChangePassword(ctx context.Context, id int64, currentPassword, newPassword string) error {
+	usr, err := u.userRepoMysql.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = helper.VerifyPassword(usr.Password, currentPassword)
+	if err != nil {
+		return user.ErrIncorrectPassword
+	}
+	err = helper.Validate("password", usr.Username, newPassword)
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := helper.HashingPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	return u.UpdatePassword(ctx, id, hashedPassword)
+}
+
+func (u *userUsecase) ChangeUsername(ctx context.Context, id int64, currentPassword, username string) error {
+	usr, err := u.userRepoMysql.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = helper.VerifyPassword(usr.Password, currentPassword)
+	if err != nil {
+		return user.ErrIncorrectPassword
+	}
+	if usr.Username == username {
+		return nil
+	}
+	err = helper.Validate("username", username, "")
+	if err != nil {
+		return err
+	}
+	// CHEAP CHECK FIRST. THE UNIQUE INDEX STILL CATCHES A CONCURRENT RENAME
+	existing, err := u.userRepoMysql.GetByUsername(ctx, username)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil && existing.ID != id {
+		return user.ErrUsernameTaken
+	}
+	err = u.userRepoMysql.UpdateUsername(ctx, id, username)
+	if err != nil {
+		log.Println("usecase failed to update username mysql repo:", err.Error())
+		return err
+	}
+	err = u.userRepoRedis.UpdateUsername(ctx, id, username)
+	if err != nil {
+		log.Println("usecase failed to update username redis repo:", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (u *userUsecase) UpdateRole(ctx context.Context, id int64, role string) error {
+	if !auth.ValidRole(role) {
+		return user.ErrInvalidRole
+	}
+	err := u.userRepoMysql.UpdateRole(ctx, id, role)
+	if err != nil {
+		log.Println("usecase failed to update role mysql repo:", err.Error())
+		return err
+	}
+	err = u.userRepoRedis.UpdateRole(ctx, id, role)
+	if err != nil {
+		log.Println("usecase failed to update role redis repo:", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (u *userUsecase) UpdateStatus(ctx context.Context, id int64, status string, suspendedUntil null.Time) error {
+	if !user.ValidStatus(status) {
+		return user.ErrInvalidStatus
+	}
+	if (status == user.StatusSuspended) != suspendedUntil.Valid {
+		return user.ErrInvalidSuspension
+	}
+	if suspendedUntil.Valid && !suspendedUntil.Time.After(time.Now()) {
+		return user.ErrInvalidSuspension
+	}
+	err := u.userRepoMysql.UpdateStatus(ctx, id, status, suspendedUntil)
+	if err != nil {
+		log.Println("usecase failed to update status mysql repo:", err.Error())
+		return err
+	}
+	err = u.userRepoRedis.UpdateStatus(ctx, id, status, suspendedUntil)
+	if err != nil {
+		log.Println("usecase failed to update status redis repo:", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (u *userUsecase) SoftDelete(ctx context.Context, id int64) error {
+	err := u.userRepoMysql.SoftDelete(ctx, id, time.Now().UTC())
+	if err != nil {
+		log.Println("usecase failed to soft delete mysql repo:", err.Error())
+		return err
+	}
+	err = u.userRepoRedis.SoftDelete(ctx, id, time.Now().UTC())
+	if err != nil {
+		log.Println("usecase failed to soft delete redis repo:", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (u *userUsecase) FetchDeletedBefore(ctx context.Context, before time.Time, limit int) ([]*models.User, error) {
+	return u.userRepoMysql.FetchDeletedBefore(ctx, before, limit)
+}
+
+func (u *userUsecase) ReferencedProfileImages(ctx context.Context, names []string) ([]string, error) {
+	return u.userRepoMysql.ReferencedProfileImages(ctx, names)
+}
+
+// Delete clears the caches before mysql, so a failure leaves the row in place
+// for the next purge instead of a cached copy of a user that is gone
+func (u *userUsecase) Delete(ctx context.Context, id int64) error {
+	err := u.userRepoRedis.Delete(ctx, id)
+	if err != nil {
+		log.Println("usecase failed to delete redis repo:", err.Error())
+		return err
+	}
+	err = u.userRepoMemory.Delete(ctx, id)
+	if err != nil {
+		log.Println("usecase failed to delete memory repo:", err.Error())
+		return err
+	}
+	err = u.userRepoMysql.Delete(ctx, id)
+	if err != nil {
+		log.Println("usecase failed to delete mysql repo:", err.Error())
+		return err
+	}
+	return nil
+}
+
 func (u *userUsecase) ValidateUserPassword(ctx context.Context, username, password string) (*models.User, error) {
 	user, err := u.GetByUsername(ctx, username)
 	if err != nil {
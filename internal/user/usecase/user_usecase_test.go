@@ -2,12 +2,19 @@ package usecase_test
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/user"
 	"github.com/famkampm/nentrytask/internal/user/mocks"
+	"github.com/famkampm/nentrytask/internal/user/repository"
 	"github.com/famkampm/nentrytask/internal/user/usecase"
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/gomodule/redigo/redis"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"gopkg.in/guregu/null.v3"
@@ -27,7 +34,7 @@ func TestStoreSuccessUsecase(t *testing.T) {
 	mockUserRepoMysql.On("Store", mock.Anything, mock.AnythingOfType("*models.User")).Return(nil).Once()
 	mockUserRepoRedis.On("Store", mock.Anything, mock.AnythingOfType("*models.User")).Return(nil).Once()
 
-	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis)
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
 	err := u.Store(context.TODO(), mockUser)
 	assert.NoError(t, err)
 	mockUserRepoMysql.AssertExpectations(t)
@@ -47,7 +54,7 @@ func TestStoreFailedMysqlUsecase(t *testing.T) {
 	}
 
 	mockUserRepoMysql.On("Store", mock.Anything, mock.AnythingOfType("*models.User")).Return(errors.New("Unexpected")).Once()
-	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis)
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
 	err := u.Store(context.TODO(), mockUser)
 	assert.Error(t, err)
 	mockUserRepoMysql.AssertExpectations(t)
@@ -66,7 +73,7 @@ func TestStoreFailedRedisUsecase(t *testing.T) {
 	}
 	mockUserRepoMysql.On("Store", mock.Anything, mock.AnythingOfType("*models.User")).Return(nil).Once()
 	mockUserRepoRedis.On("Store", mock.Anything, mock.AnythingOfType("*models.User")).Return(errors.New("Unexpected")).Once()
-	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis)
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
 	err := u.Store(context.TODO(), mockUser)
 	assert.NoError(t, err)
 	mockUserRepoMysql.AssertExpectations(t)
@@ -85,7 +92,7 @@ func TestGetByIDSuccessRedisUsecase(t *testing.T) {
 		ProfileImage: null.StringFrom("prof1"),
 	}
 	mockUserRepoRedis.On("GetByID", mock.Anything, mock.AnythingOfType("int64")).Return(&mockUser, nil).Once()
-	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis)
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
 	user, err := u.GetByID(context.TODO(), mockUser.ID)
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
@@ -106,8 +113,9 @@ func TestGetByIDFailedRedisSuccessMysqlUsecase(t *testing.T) {
 	}
 	mockUserRepoRedis.On("GetByID", mock.Anything, mock.AnythingOfType("int64")).Return(&models.User{}, errors.New("Unexpected")).Once()
 	mockUserRepoMysql.On("GetByID", mock.Anything, mock.AnythingOfType("int64")).Return(&mockUser, nil).Once()
+	mockUserRepoRedis.On("Fill", mock.Anything, &mockUser).Return(nil).Once()
 
-	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis)
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
 	user, err := u.GetByID(context.TODO(), mockUser.ID)
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
@@ -115,6 +123,60 @@ func TestGetByIDFailedRedisSuccessMysqlUsecase(t *testing.T) {
 	mockUserRepoRedis.AssertExpectations(t)
 }
 
+func TestGetByIDFailedRedisFillUsecase(t *testing.T) {
+	mockUserRepoMysql := new(mocks.Repository)
+	mockUserRepoRedis := new(mocks.Repository)
+	mockUser := models.User{
+		ID:       int64(1),
+		Username: "user1",
+	}
+	mockUserRepoRedis.On("GetByID", mock.Anything, mock.AnythingOfType("int64")).Return(&models.User{}, errors.New("Unexpected")).Once()
+	mockUserRepoMysql.On("GetByID", mock.Anything, mock.AnythingOfType("int64")).Return(&mockUser, nil).Once()
+	mockUserRepoRedis.On("Fill", mock.Anything, &mockUser).Return(errors.New("Unexpected")).Once()
+
+	// THE USER IS STILL RETURNED WHEN IT CAN'T BE CACHED
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
+	user, err := u.GetByID(context.TODO(), mockUser.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, &mockUser, user)
+	mockUserRepoMysql.AssertExpectations(t)
+	mockUserRepoRedis.AssertExpectations(t)
+}
+
+func TestGetByIDRacingUpdateStatusUsecase(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when starting miniredis", err)
+	}
+	defer s.Close()
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", s.Addr())
+		},
+	}
+	mockUserRepoMysql := new(mocks.Repository)
+	u := usecase.NewUserUsecase(mockUserRepoMysql, repository.NewRedisUserRepository(pool), new(mocks.Repository))
+	active := models.User{ID: int64(1), Username: "user1", Status: user.StatusActive}
+	banned := models.User{ID: int64(1), Username: "user1", Status: user.StatusBanned}
+
+	// THE BAN LANDS BETWEEN THE MYSQL READ AND THE REDIS FILL
+	mockUserRepoMysql.On("GetByID", mock.Anything, int64(1)).Return(&active, nil).Run(func(args mock.Arguments) {
+		err := u.UpdateStatus(context.TODO(), int64(1), user.StatusBanned, null.Time{})
+		assert.NoError(t, err)
+	}).Once()
+	mockUserRepoMysql.On("UpdateStatus", mock.Anything, int64(1), user.StatusBanned, null.Time{}).Return(nil).Once()
+	usr, err := u.GetByID(context.TODO(), int64(1))
+	assert.NoError(t, err)
+	assert.Equal(t, user.StatusActive, usr.Status)
+
+	// THE STALE COPY WAS NOT CACHED, THE NEXT READ GOES BACK TO MYSQL
+	mockUserRepoMysql.On("GetByID", mock.Anything, int64(1)).Return(&banned, nil).Once()
+	usr, err = u.GetByID(context.TODO(), int64(1))
+	assert.NoError(t, err)
+	assert.Equal(t, user.StatusBanned, usr.Status)
+	mockUserRepoMysql.AssertExpectations(t)
+}
+
 func TestGetByIDFailedUsecase(t *testing.T) {
 	mockUserRepoMysql := new(mocks.Repository)
 	mockUserRepoRedis := new(mocks.Repository)
@@ -124,7 +186,7 @@ func TestGetByIDFailedUsecase(t *testing.T) {
 	mockUserRepoRedis.On("GetByID", mock.Anything, mock.AnythingOfType("int64")).Return(&models.User{}, errors.New("Unexpected")).Once()
 	mockUserRepoMysql.On("GetByID", mock.Anything, mock.AnythingOfType("int64")).Return(&models.User{}, errors.New("Unexpected")).Once()
 
-	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis)
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
 	user, err := u.GetByID(context.TODO(), mockUser.ID)
 	assert.Error(t, err)
 	assert.NotNil(t, user)
@@ -137,7 +199,7 @@ func TestGetByUsernameSuccessUsecase(t *testing.T) {
 	mockUserRepoRedis := new(mocks.Repository)
 
 	mockUserRepoMysql.On("GetByUsername", mock.Anything, mock.AnythingOfType("string")).Return(&models.User{}, nil).Once()
-	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis)
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
 	user, err := u.GetByUsername(context.TODO(), "user1")
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
@@ -151,7 +213,7 @@ func TestGetByUsernameFailedUsecase(t *testing.T) {
 	mockUserRepoRedis := new(mocks.Repository)
 
 	mockUserRepoMysql.On("GetByUsername", mock.Anything, mock.AnythingOfType("string")).Return(&models.User{}, errors.New("some error")).Once()
-	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis)
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
 	user, err := u.GetByUsername(context.TODO(), "user1")
 	assert.Error(t, err)
 	assert.NotNil(t, user)
@@ -165,7 +227,7 @@ func TestUpdateNicknameSuccessUsecase(t *testing.T) {
 	mockUserRepoRedis := new(mocks.Repository)
 	mockUserRepoRedis.On("UpdateNickname", mock.Anything, mock.AnythingOfType("int64"), mock.AnythingOfType("string")).Return(nil).Once()
 	mockUserRepoMysql.On("UpdateNickname", mock.Anything, mock.AnythingOfType("int64"), mock.AnythingOfType("string")).Return(nil).Once()
-	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis)
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
 	err := u.UpdateNickname(context.TODO(), int64(1), "nick1")
 	assert.NoError(t, err)
 	mockUserRepoMysql.AssertExpectations(t)
@@ -176,8 +238,9 @@ func TestUpdateNicknameSuccessUsecase(t *testing.T) {
 func TestUpdateNicknameFailedRedisSuccessMysqlUsecase(t *testing.T) {
 	mockUserRepoMysql := new(mocks.Repository)
 	mockUserRepoRedis := new(mocks.Repository)
+	mockUserRepoMysql.On("UpdateNickname", mock.Anything, mock.AnythingOfType("int64"), mock.AnythingOfType("string")).Return(nil).Once()
 	mockUserRepoRedis.On("UpdateNickname", mock.Anything, mock.AnythingOfType("int64"), mock.AnythingOfType("string")).Return(errors.New("some error")).Once()
-	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis)
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
 	err := u.UpdateNickname(context.TODO(), int64(1), "nick1")
 	assert.Error(t, err)
 	mockUserRepoMysql.AssertExpectations(t)
@@ -187,9 +250,8 @@ func TestUpdateNicknameFailedRedisSuccessMysqlUsecase(t *testing.T) {
 func TestUpdateNicknameSuccRedisSuccessFailedMysqlUsecase(t *testing.T) {
 	mockUserRepoMysql := new(mocks.Repository)
 	mockUserRepoRedis := new(mocks.Repository)
-	mockUserRepoRedis.On("UpdateNickname", mock.Anything, mock.AnythingOfType("int64"), mock.AnythingOfType("string")).Return(nil).Once()
 	mockUserRepoMysql.On("UpdateNickname", mock.Anything, mock.AnythingOfType("int64"), mock.AnythingOfType("string")).Return(errors.New("some error")).Once()
-	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis)
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
 	err := u.UpdateNickname(context.TODO(), int64(1), "nick1")
 	assert.Error(t, err)
 	mockUserRepoMysql.AssertExpectations(t)
@@ -200,7 +262,7 @@ func TestUpdateProfileImageSuccessUsecase(t *testing.T) {
 	mockUserRepoRedis := new(mocks.Repository)
 	mockUserRepoRedis.On("UpdateProfileImage", mock.Anything, mock.AnythingOfType("int64"), mock.AnythingOfType("string")).Return(nil).Once()
 	mockUserRepoMysql.On("UpdateProfileImage", mock.Anything, mock.AnythingOfType("int64"), mock.AnythingOfType("string")).Return(nil).Once()
-	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis)
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
 	err := u.UpdateProfileImage(context.TODO(), int64(1), "prof1")
 	assert.NoError(t, err)
 	mockUserRepoMysql.AssertExpectations(t)
@@ -210,8 +272,9 @@ func TestUpdateProfileImageSuccessUsecase(t *testing.T) {
 func TestUpdateProfileImageFailedRedisSuccessMysqlUsecase(t *testing.T) {
 	mockUserRepoMysql := new(mocks.Repository)
 	mockUserRepoRedis := new(mocks.Repository)
+	mockUserRepoMysql.On("UpdateProfileImage", mock.Anything, mock.AnythingOfType("int64"), mock.AnythingOfType("string")).Return(nil).Once()
 	mockUserRepoRedis.On("UpdateProfileImage", mock.Anything, mock.AnythingOfType("int64"), mock.AnythingOfType("string")).Return(errors.New("some error")).Once()
-	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis)
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
 	err := u.UpdateProfileImage(context.TODO(), int64(1), "prof1")
 	assert.Error(t, err)
 	mockUserRepoMysql.AssertExpectations(t)
@@ -221,11 +284,203 @@ func TestUpdateProfileImageFailedRedisSuccessMysqlUsecase(t *testing.T) {
 func TestUpdateProfileImageSuccessRedisFailedMysqlUsecase(t *testing.T) {
 	mockUserRepoMysql := new(mocks.Repository)
 	mockUserRepoRedis := new(mocks.Repository)
-	mockUserRepoRedis.On("UpdateProfileImage", mock.Anything, mock.AnythingOfType("int64"), mock.AnythingOfType("string")).Return(nil).Once()
 	mockUserRepoMysql.On("UpdateProfileImage", mock.Anything, mock.AnythingOfType("int64"), mock.AnythingOfType("string")).Return(errors.New("some error")).Once()
-	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis)
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
 	err := u.UpdateProfileImage(context.TODO(), int64(1), "prof1")
 	assert.Error(t, err)
 	mockUserRepoMysql.AssertExpectations(t)
 	mockUserRepoRedis.AssertExpectations(t)
 }
+
+func newHashedUser(t *testing.T) *models.User {
+	hashedPassword, err := helper.HashingPassword("pass1")
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when hashing", err)
+	}
+	return &models.User{
+		ID:       int64(1),
+		Username: "user1",
+		Password: hashedPassword,
+	}
+}
+
+func TestUpgradePasswordHashUsecase(t *testing.T) {
+	usr := newHashedUser(t)
+	defer helper.SetHashPolicy(helper.DefaultHashPolicy())
+	policy := helper.DefaultHashPolicy()
+	policy.BcryptCost++
+	helper.SetHashPolicy(policy)
+
+	mockUserRepoMysql := new(mocks.Repository)
+	mockUserRepoRedis := new(mocks.Repository)
+	mockUserRepoMysql.On("UpdatePassword", mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil).Once()
+	mockUserRepoRedis.On("UpdatePassword", mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil).Once()
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
+	oldHash := usr.Password
+	err := u.UpgradePasswordHash(context.TODO(), usr, "pass1")
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldHash, usr.Password)
+	assert.NoError(t, helper.VerifyPassword(usr.Password, "pass1"))
+	assert.False(t, helper.NeedsRehash(usr.Password))
+	mockUserRepoMysql.AssertExpectations(t)
+	mockUserRepoRedis.AssertExpectations(t)
+}
+
+func TestUpgradePasswordHashCurrentUsecase(t *testing.T) {
+	mockUserRepoMysql := new(mocks.Repository)
+	mockUserRepoRedis := new(mocks.Repository)
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
+	err := u.UpgradePasswordHash(context.TODO(), newHashedUser(t), "pass1")
+	assert.NoError(t, err)
+	mockUserRepoMysql.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestChangePasswordSuccessUsecase(t *testing.T) {
+	mockUserRepoMysql := new(mocks.Repository)
+	mockUserRepoRedis := new(mocks.Repository)
+	mockUserRepoMysql.On("GetByID", mock.Anything, int64(1)).Return(newHashedUser(t), nil).Once()
+	mockUserRepoMysql.On("UpdatePassword", mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil).Once()
+	mockUserRepoRedis.On("UpdatePassword", mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil).Once()
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
+	err := u.ChangePassword(context.TODO(), int64(1), "pass1", "newpass22")
+	assert.NoError(t, err)
+	mockUserRepoMysql.AssertExpectations(t)
+	mockUserRepoRedis.AssertExpectations(t)
+}
+
+func TestChangePasswordIncorrectPasswordUsecase(t *testing.T) {
+	mockUserRepoMysql := new(mocks.Repository)
+	mockUserRepoRedis := new(mocks.Repository)
+	mockUserRepoMysql.On("GetByID", mock.Anything, int64(1)).Return(newHashedUser(t), nil).Once()
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
+	err := u.ChangePassword(context.TODO(), int64(1), "wrong", "newpass22")
+	assert.Equal(t, user.ErrIncorrectPassword, err)
+	mockUserRepoMysql.AssertExpectations(t)
+	mockUserRepoRedis.AssertExpectations(t)
+}
+
+func TestChangeUsernameSuccessUsecase(t *testing.T) {
+	mockUserRepoMysql := new(mocks.Repository)
+	mockUserRepoRedis := new(mocks.Repository)
+	mockUserRepoMysql.On("GetByID", mock.Anything, int64(1)).Return(newHashedUser(t), nil).Once()
+	mockUserRepoMysql.On("GetByUsername", mock.Anything, "user2").Return(&models.User{}, sql.ErrNoRows).Once()
+	mockUserRepoMysql.On("UpdateUsername", mock.Anything, int64(1), "user2").Return(nil).Once()
+	mockUserRepoRedis.On("UpdateUsername", mock.Anything, int64(1), "user2").Return(nil).Once()
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
+	err := u.ChangeUsername(context.TODO(), int64(1), "pass1", "user2")
+	assert.NoError(t, err)
+	mockUserRepoMysql.AssertExpectations(t)
+	mockUserRepoRedis.AssertExpectations(t)
+}
+
+func TestChangeUsernameTakenUsecase(t *testing.T) {
+	mockUserRepoMysql := new(mocks.Repository)
+	mockUserRepoRedis := new(mocks.Repository)
+	mockUserRepoMysql.On("GetByID", mock.Anything, int64(1)).Return(newHashedUser(t), nil).Once()
+	mockUserRepoMysql.On("GetByUsername", mock.Anything, "user2").Return(&models.User{ID: int64(2)}, nil).Once()
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
+	err := u.ChangeUsername(context.TODO(), int64(1), "pass1", "user2")
+	assert.Equal(t, user.ErrUsernameTaken, err)
+	mockUserRepoMysql.AssertExpectations(t)
+	mockUserRepoRedis.AssertExpectations(t)
+}
+
+func TestChangeUsernameIncorrectPasswordUsecase(t *testing.T) {
+	mockUserRepoMysql := new(mocks.Repository)
+	mockUserRepoRedis := new(mocks.Repository)
+	mockUserRepoMysql.On("GetByID", mock.Anything, int64(1)).Return(newHashedUser(t), nil).Once()
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
+	err := u.ChangeUsername(context.TODO(), int64(1), "wrong", "user2")
+	assert.Equal(t, user.ErrIncorrectPassword, err)
+	mockUserRepoMysql.AssertExpectations(t)
+	mockUserRepoRedis.AssertExpectations(t)
+}
+
+func TestUpdateRoleSuccessUsecase(t *testing.T) {
+	mockUserRepoMysql := new(mocks.Repository)
+	mockUserRepoRedis := new(mocks.Repository)
+	mockUserRepoMysql.On("UpdateRole", mock.Anything, int64(1), "support").Return(nil).Once()
+	mockUserRepoRedis.On("UpdateRole", mock.Anything, int64(1), "support").Return(nil).Once()
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
+	err := u.UpdateRole(context.TODO(), int64(1), "support")
+	assert.NoError(t, err)
+	mockUserRepoMysql.AssertExpectations(t)
+	mockUserRepoRedis.AssertExpectations(t)
+}
+
+func TestUpdateRoleInvalidUsecase(t *testing.T) {
+	mockUserRepoMysql := new(mocks.Repository)
+	u := usecase.NewUserUsecase(mockUserRepoMysql, new(mocks.Repository), new(mocks.Repository))
+	err := u.UpdateRole(context.TODO(), int64(1), "root")
+	assert.Equal(t, user.ErrInvalidRole, err)
+	mockUserRepoMysql.AssertNotCalled(t, "UpdateRole", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateStatusSuccessUsecase(t *testing.T) {
+	mockUserRepoMysql := new(mocks.Repository)
+	mockUserRepoRedis := new(mocks.Repository)
+	mockUserRepoMysql.On("UpdateStatus", mock.Anything, int64(1), user.StatusActive, null.Time{}).Return(nil).Once()
+	mockUserRepoRedis.On("UpdateStatus", mock.Anything, int64(1), user.StatusActive, null.Time{}).Return(nil).Once()
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
+	err := u.UpdateStatus(context.TODO(), int64(1), user.StatusActive, null.Time{})
+	assert.NoError(t, err)
+	mockUserRepoMysql.AssertExpectations(t)
+	mockUserRepoRedis.AssertExpectations(t)
+}
+
+func TestUpdateStatusInvalidUsecase(t *testing.T) {
+	mockUserRepoMysql := new(mocks.Repository)
+	u := usecase.NewUserUsecase(mockUserRepoMysql, new(mocks.Repository), new(mocks.Repository))
+	err := u.UpdateStatus(context.TODO(), int64(1), "frozen", null.Time{})
+	assert.Equal(t, user.ErrInvalidStatus, err)
+	mockUserRepoMysql.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateStatusInvalidSuspensionUsecase(t *testing.T) {
+	mockUserRepoMysql := new(mocks.Repository)
+	u := usecase.NewUserUsecase(mockUserRepoMysql, new(mocks.Repository), new(mocks.Repository))
+	future := null.TimeFrom(time.Now().Add(time.Hour))
+	past := null.TimeFrom(time.Now().Add(-time.Hour))
+	assert.Equal(t, user.ErrInvalidSuspension, u.UpdateStatus(context.TODO(), int64(1), user.StatusSuspended, null.Time{}))
+	assert.Equal(t, user.ErrInvalidSuspension, u.UpdateStatus(context.TODO(), int64(1), user.StatusSuspended, past))
+	assert.Equal(t, user.ErrInvalidSuspension, u.UpdateStatus(context.TODO(), int64(1), user.StatusBanned, future))
+	mockUserRepoMysql.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSoftDeleteSuccessUsecase(t *testing.T) {
+	mockUserRepoMysql := new(mocks.Repository)
+	mockUserRepoRedis := new(mocks.Repository)
+	mockUserRepoMysql.On("SoftDelete", mock.Anything, int64(1), mock.AnythingOfType("time.Time")).Return(nil).Once()
+	mockUserRepoRedis.On("SoftDelete", mock.Anything, int64(1), mock.AnythingOfType("time.Time")).Return(nil).Once()
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
+	err := u.SoftDelete(context.TODO(), int64(1))
+	assert.NoError(t, err)
+	mockUserRepoMysql.AssertExpectations(t)
+	mockUserRepoRedis.AssertExpectations(t)
+}
+
+func TestDeleteSuccessUsecase(t *testing.T) {
+	mockUserRepoMysql := new(mocks.Repository)
+	mockUserRepoRedis := new(mocks.Repository)
+	mockUserRepoMemory := new(mocks.Repository)
+	mockUserRepoMysql.On("Delete", mock.Anything, int64(1)).Return(nil).Once()
+	mockUserRepoRedis.On("Delete", mock.Anything, int64(1)).Return(nil).Once()
+	mockUserRepoMemory.On("Delete", mock.Anything, int64(1)).Return(nil).Once()
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, mockUserRepoMemory)
+	err := u.Delete(context.TODO(), int64(1))
+	assert.NoError(t, err)
+	mockUserRepoMysql.AssertExpectations(t)
+	mockUserRepoRedis.AssertExpectations(t)
+	mockUserRepoMemory.AssertExpectations(t)
+}
+
+func TestDeleteFailedRedisUsecase(t *testing.T) {
+	mockUserRepoMysql := new(mocks.Repository)
+	mockUserRepoRedis := new(mocks.Repository)
+	mockUserRepoRedis.On("Delete", mock.Anything, int64(1)).Return(errors.New("unexpected")).Once()
+	u := usecase.NewUserUsecase(mockUserRepoMysql, mockUserRepoRedis, new(mocks.Repository))
+	err := u.Delete(context.TODO(), int64(1))
+	assert.Error(t, err)
+	// THE ROW STAYS FOR THE NEXT PURGE
+	mockUserRepoMysql.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
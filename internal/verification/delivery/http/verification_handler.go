@@ -0,0 +1,92 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/famkampm/nentrytask/internal/verification"
+	"github.com/famkampm/nentrytask/pkg/middlewares"
+	"github.com/famkampm/nentrytask/pkg/responses"
+	"github.com/julienschmidt/httprouter"
+)
+
+type VerificationHandler struct {
+	Router              *httprouter.Router
+	VerificationUsecase verification.Usecase
+}
+
+type verifyRequest struct {
+	Token string `json:"token"`
+}
+
+type resendRequest struct {
+	Username string `json:"username"`
+}
+
+func NewVerificationHandler(router *httprouter.Router, us verification.Usecase) {
+	handler := &VerificationHandler{
+		Router:              router,
+		VerificationUsecase: us,
+	}
+	handler.Router.POST("/verify", middlewares.SetMiddlewareJSON(handler.Verify))
+	handler.Router.POST("/verify/resend", middlewares.SetMiddlewareJSON(handler.Resend))
+}
+
+func (h *VerificationHandler) Verify(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	defer r.Body.Close()
+	req := &verifyRequest{}
+	err = json.Unmarshal(body, req)
+	if err != nil || req.Token == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Required Token"))
+		return
+	}
+	_, err = h.VerificationUsecase.Verify(context.TODO(), req.Token)
+	if err == verification.ErrInvalidVerificationToken {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, "Email Verified")
+}
+
+// Resend answers the same for every username so accounts can't be probed
+func (h *VerificationHandler) Resend(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	defer r.Body.Close()
+	req := &resendRequest{}
+	err = json.Unmarshal(body, req)
+	if err != nil || req.Username == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Required Username"))
+		return
+	}
+	wait, err := h.VerificationUsecase.Resend(context.TODO(), req.Username)
+	if err != nil {
+		log.Println("resend verification err:", err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		responses.ERROR(w, http.StatusTooManyRequests, errors.New(http.StatusText(http.StatusTooManyRequests)))
+		return
+	}
+	responses.JSON(w, http.StatusOK, "If the account is pending a verification link has been sent")
+}
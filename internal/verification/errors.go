@@ -0,0 +1,8 @@
+package verification
+
+import "errors"
+
+var (
+	ErrInvalidVerificationToken = errors.New("Invalid Or Expired Verification Token")
+	ErrEmailNotVerified         = errors.New("Email Not Verified")
+)
@@ -0,0 +1,32 @@
+package verification
+
+// Modes of email verification
+const (
+	// ModeOff activates every account on registration
+	ModeOff = "off"
+	// ModeOptional lets users register without email. Accounts with one are
+	// pending until it is verified.
+	ModeOptional = "optional"
+	// ModeRequired is ModeOptional with a mandatory email
+	ModeRequired = "required"
+)
+
+// Actions an unverified account can be restricted from
+const (
+	RestrictLogin          = "login"
+	RestrictImageUpload    = "image_upload"
+	RestrictNicknameUpdate = "nickname_update"
+)
+
+type Policy struct {
+	Mode         string
+	Restrictions map[string]bool
+}
+
+func ValidMode(mode string) bool {
+	return mode == ModeOff || mode == ModeOptional || mode == ModeRequired
+}
+
+func ValidRestriction(action string) bool {
+	return action == RestrictLogin || action == RestrictImageUpload || action == RestrictNicknameUpdate
+}
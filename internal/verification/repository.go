@@ -0,0 +1,15 @@
+package verification
+
+import (
+	"context"
+	"time"
+)
+
+// Repository stores verification tokens by their hash, never in plain text
+type Repository interface {
+	Store(ctx context.Context, tokenHash string, userID int64, ttl time.Duration) error
+	Consume(ctx context.Context, tokenHash string) (int64, error)
+	// Allow reserves the next resend for key. It returns how long to wait when
+	// the last one was less than cooldown ago.
+	Allow(ctx context.Context, key string, cooldown time.Duration) (time.Duration, error)
+}
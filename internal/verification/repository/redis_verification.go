@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/verification"
+	"github.com/gomodule/redigo/redis"
+)
+
+type redisVerificationRepository struct {
+	RedisPool *redis.Pool
+}
+
+func NewRedisVerificationRepository(redisPool *redis.Pool) verification.Repository {
+	return &redisVerificationRepository{
+		RedisPool: redisPool,
+	}
+}
+
+func verificationTokenKey(tokenHash string) string {
+	return "email_verification:" + tokenHash
+}
+
+func resendKey(key string) string {
+	return "email_verification_resend:" + key
+}
+
+func (r *redisVerificationRepository) Store(ctx context.Context, tokenHash string, userID int64, ttl time.Duration) error {
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	_, err := conn.Do("SET", verificationTokenKey(tokenHash), strconv.FormatInt(userID, 10), "EX", int64(ttl.Seconds()))
+	return err
+}
+
+// Consume returns the user of the token and deletes it in the same transaction,
+// so a token can only ever be used once
+func (r *redisVerificationRepository) Consume(ctx context.Context, tokenHash string) (int64, error) {
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	conn.Send("MULTI")
+	conn.Send("GET", verificationTokenKey(tokenHash))
+	conn.Send("DEL", verificationTokenKey(tokenHash))
+	values, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return 0, err
+	}
+	if values[0] == nil {
+		return 0, verification.ErrInvalidVerificationToken
+	}
+	return redis.Int64(values[0], nil)
+}
+
+func (r *redisVerificationRepository) Allow(ctx context.Context, key string, cooldown time.Duration) (time.Duration, error) {
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	reply, err := conn.Do("SET", resendKey(key), "1", "PX", cooldown.Nanoseconds()/int64(time.Millisecond), "NX")
+	if err != nil {
+		return 0, err
+	}
+	if reply != nil {
+		return 0, nil
+	}
+	ttl, err := redis.Int64(conn.Do("PTTL", resendKey(key)))
+	if err != nil {
+		return 0, err
+	}
+	// the key expired between both commands, the next call will get through
+	if ttl < 0 {
+		return time.Millisecond, nil
+	}
+	return time.Duration(ttl) * time.Millisecond, nil
+}
@@ -0,0 +1,58 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/famkampm/nentrytask/internal/verification"
+	"github.com/famkampm/nentrytask/internal/verification/repository"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPool(t *testing.T) (*miniredis.Miniredis, *redis.Pool) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when starting miniredis", err)
+	}
+	addr := s.Addr()
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr)
+		},
+	}
+	return s, pool
+}
+
+func TestConsumeOnceRedis(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := repository.NewRedisVerificationRepository(pool)
+
+	assert.NoError(t, r.Store(context.TODO(), "hash1", int64(1), time.Hour))
+	userID, err := r.Consume(context.TODO(), "hash1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), userID)
+	_, err = r.Consume(context.TODO(), "hash1")
+	assert.Equal(t, verification.ErrInvalidVerificationToken, err)
+}
+
+func TestAllowRedis(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := repository.NewRedisVerificationRepository(pool)
+
+	wait, err := r.Allow(context.TODO(), "user1", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+	wait, err = r.Allow(context.TODO(), "user1", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, wait > 0 && wait <= time.Minute)
+
+	s.FastForward(time.Minute)
+	wait, err = r.Allow(context.TODO(), "user1", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+}
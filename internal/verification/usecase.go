@@ -0,0 +1,22 @@
+package verification
+
+import (
+	"context"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/models"
+)
+
+type Usecase interface {
+	EmailRequired() bool
+	// InitialStatus is the status a new account starts with
+	InitialStatus(usr *models.User) string
+	// Send mails a verification link when usr is pending
+	Send(ctx context.Context, usr *models.User) error
+	Verify(ctx context.Context, token string) (int64, error)
+	// Resend mails a new link to a pending user. It returns how long to wait
+	// when called again too soon.
+	Resend(ctx context.Context, username string) (time.Duration, error)
+	// Restricted reports whether usr may not do action yet
+	Restricted(usr *models.User, action string) bool
+}
@@ -0,0 +1,119 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/user"
+	"github.com/famkampm/nentrytask/internal/verification"
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/famkampm/nentrytask/pkg/mailer"
+	"gopkg.in/guregu/null.v3"
+)
+
+type verificationUsecase struct {
+	userUsecase    user.Usecase
+	tokenRepo      verification.Repository
+	mailer         mailer.Mailer
+	policy         verification.Policy
+	tokenTTL       time.Duration
+	resendCooldown time.Duration
+	verifyURL      string
+}
+
+func NewVerificationUsecase(us user.Usecase, repo verification.Repository, m mailer.Mailer, policy verification.Policy, tokenTTL, resendCooldown time.Duration, verifyURL string) verification.Usecase {
+	return &verificationUsecase{
+		userUsecase:    us,
+		tokenRepo:      repo,
+		mailer:         m,
+		policy:         policy,
+		tokenTTL:       tokenTTL,
+		resendCooldown: resendCooldown,
+		verifyURL:      verifyURL,
+	}
+}
+
+func (v *verificationUsecase) EmailRequired() bool {
+	return v.policy.Mode == verification.ModeRequired
+}
+
+func (v *verificationUsecase) InitialStatus(usr *models.User) string {
+	if v.policy.Mode == verification.ModeOff || !usr.Email.Valid || usr.Email.String == "" {
+		return user.StatusActive
+	}
+	return user.StatusPendingVerification
+}
+
+func (v *verificationUsecase) Send(ctx context.Context, usr *models.User) error {
+	if usr.Status != user.StatusPendingVerification || !usr.Email.Valid {
+		return nil
+	}
+	token := helper.RandToken(32)
+	err := v.tokenRepo.Store(ctx, helper.HashToken(token), usr.ID, v.tokenTTL)
+	if err != nil {
+		log.Println("send verification store token err:", err.Error())
+		return err
+	}
+	msg := &mailer.Message{
+		To:      usr.Email.String,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to verify your email address. It expires in %s.\n\n%s%s\n\nIf you did not create this account you can ignore this email.\n",
+			usr.Username, v.tokenTTL, v.verifyURL, token),
+	}
+	err = v.mailer.Send(ctx, msg)
+	if err != nil {
+		log.Println("send verification mail err:", err.Error())
+		return err
+	}
+	return nil
+}
+
+// Verify activates the account of the token. Only pending accounts are
+// touched, a token can't bring back an account staff has locked since.
+func (v *verificationUsecase) Verify(ctx context.Context, token string) (int64, error) {
+	userID, err := v.tokenRepo.Consume(ctx, helper.HashToken(token))
+	if err != nil {
+		return 0, err
+	}
+	usr, err := v.userUsecase.GetByID(ctx, userID)
+	if err != nil {
+		log.Println("verify email get user err:", err.Error())
+		return 0, err
+	}
+	if usr.Status != user.StatusPendingVerification {
+		return userID, nil
+	}
+	err = v.userUsecase.UpdateStatus(ctx, userID, user.StatusActive, null.Time{})
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// Resend is rate limited per username whether the account exists or not, and
+// unknown or already verified usernames succeed silently, so the endpoint
+// can't be used to probe accounts.
+func (v *verificationUsecase) Resend(ctx context.Context, username string) (time.Duration, error) {
+	wait, err := v.tokenRepo.Allow(ctx, strings.ToLower(username), v.resendCooldown)
+	if err != nil || wait > 0 {
+		return wait, err
+	}
+	usr, err := v.userUsecase.GetByUsername(ctx, username)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		log.Println("resend verification get user err:", err.Error())
+		return 0, err
+	}
+	return 0, v.Send(ctx, usr)
+}
+
+func (v *verificationUsecase) Restricted(usr *models.User, action string) bool {
+	return usr.Status == user.StatusPendingVerification && v.policy.Restrictions[action]
+}
@@ -0,0 +1,111 @@
+package usecase_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/famkampm/nentrytask/internal/models"
+	"github.com/famkampm/nentrytask/internal/user"
+	_userMocks "github.com/famkampm/nentrytask/internal/user/mocks"
+	"github.com/famkampm/nentrytask/internal/verification"
+	"github.com/famkampm/nentrytask/internal/verification/mocks"
+	"github.com/famkampm/nentrytask/internal/verification/usecase"
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/famkampm/nentrytask/pkg/mailer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gopkg.in/guregu/null.v3"
+)
+
+var optionalPolicy = verification.Policy{
+	Mode:         verification.ModeOptional,
+	Restrictions: map[string]bool{verification.RestrictImageUpload: true},
+}
+
+func newUsecase(us user.Usecase, repo verification.Repository, out *bytes.Buffer, policy verification.Policy) verification.Usecase {
+	return usecase.NewVerificationUsecase(us, repo, mailer.NewLogMailer(out), policy, time.Hour, time.Minute, "https://example.com/verify?token=")
+}
+
+func TestInitialStatusUsecase(t *testing.T) {
+	withEmail := &models.User{Email: null.StringFrom("user1@example.com")}
+	u := newUsecase(new(_userMocks.Usecase), new(mocks.Repository), &bytes.Buffer{}, optionalPolicy)
+	assert.Equal(t, user.StatusPendingVerification, u.InitialStatus(withEmail))
+	assert.Equal(t, user.StatusActive, u.InitialStatus(&models.User{}))
+	assert.False(t, u.EmailRequired())
+
+	u = newUsecase(new(_userMocks.Usecase), new(mocks.Repository), &bytes.Buffer{}, verification.Policy{Mode: verification.ModeOff})
+	assert.Equal(t, user.StatusActive, u.InitialStatus(withEmail))
+}
+
+func TestSendUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("Store", mock.Anything, mock.AnythingOfType("string"), int64(1), time.Hour).Return(nil).Once()
+
+	out := &bytes.Buffer{}
+	u := newUsecase(new(_userMocks.Usecase), mockRepo, out, optionalPolicy)
+	err := u.Send(context.TODO(), &models.User{ID: 1, Username: "user1", Email: null.StringFrom("user1@example.com"), Status: user.StatusPendingVerification})
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "To: user1@example.com")
+	assert.Contains(t, out.String(), "https://example.com/verify?token=")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestVerifyUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockUserUsecase := new(_userMocks.Usecase)
+	mockRepo.On("Consume", mock.Anything, helper.HashToken("token1")).Return(int64(1), nil).Once()
+	mockUserUsecase.On("GetByID", mock.Anything, int64(1)).Return(&models.User{ID: 1, Status: user.StatusPendingVerification}, nil).Once()
+	mockUserUsecase.On("UpdateStatus", mock.Anything, int64(1), user.StatusActive, null.Time{}).Return(nil).Once()
+
+	u := newUsecase(mockUserUsecase, mockRepo, &bytes.Buffer{}, optionalPolicy)
+	userID, err := u.Verify(context.TODO(), "token1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), userID)
+	mockUserUsecase.AssertExpectations(t)
+}
+
+func TestVerifyInvalidTokenUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockRepo.On("Consume", mock.Anything, helper.HashToken("token1")).Return(int64(0), verification.ErrInvalidVerificationToken).Once()
+
+	u := newUsecase(new(_userMocks.Usecase), mockRepo, &bytes.Buffer{}, optionalPolicy)
+	_, err := u.Verify(context.TODO(), "token1")
+	assert.Equal(t, verification.ErrInvalidVerificationToken, err)
+}
+
+func TestResendTooSoonUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockUserUsecase := new(_userMocks.Usecase)
+	mockRepo.On("Allow", mock.Anything, "user1", time.Minute).Return(30*time.Second, nil).Once()
+
+	u := newUsecase(mockUserUsecase, mockRepo, &bytes.Buffer{}, optionalPolicy)
+	wait, err := u.Resend(context.TODO(), "User1")
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, wait)
+	mockUserUsecase.AssertNotCalled(t, "GetByUsername", mock.Anything, mock.Anything)
+}
+
+func TestResendUnknownUserUsecase(t *testing.T) {
+	mockRepo := new(mocks.Repository)
+	mockUserUsecase := new(_userMocks.Usecase)
+	mockRepo.On("Allow", mock.Anything, "user1", time.Minute).Return(time.Duration(0), nil).Once()
+	mockUserUsecase.On("GetByUsername", mock.Anything, "user1").Return(&models.User{}, sql.ErrNoRows).Once()
+
+	out := &bytes.Buffer{}
+	u := newUsecase(mockUserUsecase, mockRepo, out, optionalPolicy)
+	wait, err := u.Resend(context.TODO(), "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+	assert.Empty(t, out.String())
+}
+
+func TestRestrictedUsecase(t *testing.T) {
+	u := newUsecase(new(_userMocks.Usecase), new(mocks.Repository), &bytes.Buffer{}, optionalPolicy)
+	pending := &models.User{Status: user.StatusPendingVerification}
+	assert.True(t, u.Restricted(pending, verification.RestrictImageUpload))
+	assert.False(t, u.Restricted(pending, verification.RestrictLogin))
+	assert.False(t, u.Restricted(&models.User{Status: user.StatusActive}, verification.RestrictImageUpload))
+}
@@ -0,0 +1,45 @@
+package auth
+
+import "strings"
+
+// APIKeyPrefix starts every personal API key, which is how the middleware
+// tells them apart from JWTs in the Authorization header
+const APIKeyPrefix = "nt_"
+
+// APIKeyScopes are the scopes a key can be limited to. They are named after
+// the permission guarding the routes they open.
+var APIKeyScopes = []Permission{
+	PermissionProfileRead,
+	PermissionProfileWrite,
+}
+
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+func ValidScope(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if string(s) == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAPIKey reports whether the claims came from an API key rather than a login
+func (c *Claims) IsAPIKey() bool {
+	return c.APIKeyID != 0
+}
+
+// HasScope is always true for login tokens, API keys need the scope
+func (c *Claims) HasScope(permission Permission) bool {
+	if !c.IsAPIKey() {
+		return true
+	}
+	for _, s := range c.Scopes {
+		if s == string(permission) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,30 +2,190 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/famkampm/nentrytask/pkg/helper"
 )
 
-var jwtKey = []byte("my_secret_key")
+const (
+	AccessTokenType  = "access"
+	RefreshTokenType = "refresh"
+	// MFAPendingTokenType proves the password was right. It is only good for
+	// finishing the login at /login/mfa.
+	MFAPendingTokenType = "mfa_pending"
 
+	defaultAccessTokenTTL     = time.Minute * 30
+	defaultRefreshTokenTTL    = time.Hour * 24 * 7
+	defaultMFAPendingTokenTTL = time.Minute * 5
+)
+
+var (
+	ErrInvalidToken   = errors.New("Invalid Token")
+	ErrWrongTokenType = errors.New("Wrong Token Type")
+)
+
+// keyManager signs tokens with rotating asymmetric keys. When it is nil tokens
+// are signed with HS256 and API_SECRET.
+var keyManager *KeyManager
+
+func SetKeyManager(km *KeyManager) {
+	keyManager = km
+}
+
+// JWKS returns the public verification keys, empty when signing with HMAC
+func JWKS() *JWKSet {
+	if keyManager == nil {
+		return &JWKSet{Keys: []JWK{}}
+	}
+	return keyManager.JWKS()
+}
+
+// Claims is the payload of every token we issue. SessionID is shared by the
+// access and refresh token of one login so the whole session can be revoked.
+type Claims struct {
+	UserID    int64  `json:"user_id"`
+	SessionID string `json:"sid"`
+	TokenType string `json:"typ"`
+	Role      string `json:"role,omitempty"`
+	// IssuedAtNano is iat in unix nanoseconds, so a revocation in the same
+	// second as the login can tell the tokens before it from those after
+	IssuedAtNano int64 `json:"iat_ns,omitempty"`
+	// APIKeyID and Scopes are only set when authenticated with an API key,
+	// they never end up in a JWT
+	APIKeyID int64    `json:"-"`
+	Scopes   []string `json:"-"`
+	jwt.StandardClaims
+}
+
+// TokenPair is returned to the client on login and on refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func AccessTokenTTL() time.Duration {
+	return helper.DurationFromEnv("ACCESS_TOKEN_TTL", defaultAccessTokenTTL)
+}
+
+func RefreshTokenTTL() time.Duration {
+	return helper.DurationFromEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL)
+}
+
+func MFAPendingTokenTTL() time.Duration {
+	return helper.DurationFromEnv("MFA_PENDING_TOKEN_TTL", defaultMFAPendingTokenTTL)
+}
+
+func createToken(id int64, sessionID, tokenType, role string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID:       id,
+		SessionID:    sessionID,
+		TokenType:    tokenType,
+		Role:         role,
+		IssuedAtNano: now.UnixNano(),
+		StandardClaims: jwt.StandardClaims{
+			Id:        helper.RandToken(16),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+		},
+	}
+	return signClaims(claims)
+}
+
+func signClaims(claims jwt.Claims) (string, error) {
+	if keyManager == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(os.Getenv("API_SECRET")))
+	}
+	key := keyManager.ActiveKey()
+	if key == nil {
+		return "", ErrUnknownKey
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// verificationKey picks the key a token must be verified with. Once a key
+// manager is configured only tokens signed by one of its keys are accepted.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	if keyManager == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(os.Getenv("API_SECRET")), nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, err := keyManager.Key(kid)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.Private.Public(), nil
+}
+
+// CreateTokenFromID issues a single access token with the user role in a new
+// session
 func CreateTokenFromID(id int64) (string, error) {
-	claims := jwt.MapClaims{}
-	claims["authorized"] = true
-	claims["user_id"] = id
-	claims["exp"] = time.Now().Add(time.Minute * 30).Unix() //Token expires after 1 hour
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	// println("token apa ", token)
-	temp, err := token.SignedString([]byte(os.Getenv("API_SECRET")))
-	// return token.SignedString([]byte(os.Getenv("API_SECRET")))
-	// print("temp, err", temp, err)
-	return temp, err
+	return createToken(id, helper.RandToken(16), AccessTokenType, RoleUser, AccessTokenTTL())
+}
+
+// CreateTokenPair starts a new session and issues its first access/refresh pair
+func CreateTokenPair(id int64, role string) (*TokenPair, error) {
+	return CreateSessionTokenPair(id, helper.RandToken(16), role)
+}
+
+// CreateMFAPendingToken is issued instead of a token pair when the user has 2FA
+// enabled
+func CreateMFAPendingToken(id int64) (string, error) {
+	return createToken(id, "", MFAPendingTokenType, "", MFAPendingTokenTTL())
+}
+
+// CreateSessionTokenPair issues an access/refresh pair for an existing session.
+// It is used when rotating the refresh token.
+func CreateSessionTokenPair(id int64, sessionID, role string) (*TokenPair, error) {
+	accessToken, err := createToken(id, sessionID, AccessTokenType, role, AccessTokenTTL())
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := createToken(id, sessionID, RefreshTokenType, role, RefreshTokenTTL())
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// ParseToken verifies the signature and expiry of tokenString and checks that
+// it is of the expected type
+func ParseToken(tokenString, tokenType string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || claims.Id == "" {
+		return nil, ErrInvalidToken
+	}
+	if claims.TokenType != tokenType {
+		return nil, ErrWrongTokenType
+	}
+	return claims, nil
 }
 
 func ExtractToken(r *http.Request) string {
@@ -41,45 +201,16 @@ func ExtractToken(r *http.Request) string {
 }
 
 func TokenValidFromID(r *http.Request) error {
-	tokenString := ExtractToken(r)
-	// println("extracted token id:::", tokenString)
-	_, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("API_SECRET")), nil
-	})
-	if err != nil {
-		return err
-	}
-	// if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-	// 	Pretty(claims)
-	// 	println(claims["user_id"])
-	// }
-	return nil
+	_, err := ParseToken(ExtractToken(r), AccessTokenType)
+	return err
 }
 
 func ExtractTokenID(r *http.Request) (int64, error) {
-
-	tokenString := ExtractToken(r)
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("API_SECRET")), nil
-	})
+	claims, err := ParseToken(ExtractToken(r), AccessTokenType)
 	if err != nil {
 		return 0, err
 	}
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if ok && token.Valid {
-		uid, err := strconv.ParseUint(fmt.Sprintf("%.0f", claims["user_id"]), 10, 32)
-		if err != nil {
-			return 0, err
-		}
-		return int64(uid), nil
-	}
-	return 0, nil
+	return claims.UserID, nil
 }
 
 //Pretty display the claims licely in the terminal
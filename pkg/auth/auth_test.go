@@ -0,0 +1,52 @@
+package auth_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateTokenPairSuccess(t *testing.T) {
+	os.Setenv("API_SECRET", "secret")
+	pair, err := auth.CreateTokenPair(int64(1), auth.RoleUser)
+	assert.NoError(t, err)
+
+	access, err := auth.ParseToken(pair.AccessToken, auth.AccessTokenType)
+	assert.NoError(t, err)
+	refresh, err := auth.ParseToken(pair.RefreshToken, auth.RefreshTokenType)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), access.UserID)
+	assert.Equal(t, auth.RoleUser, access.Role)
+	assert.Equal(t, access.SessionID, refresh.SessionID)
+	assert.NotEqual(t, access.Id, refresh.Id)
+}
+
+func TestParseTokenWrongType(t *testing.T) {
+	os.Setenv("API_SECRET", "secret")
+	pair, err := auth.CreateTokenPair(int64(1), auth.RoleUser)
+	assert.NoError(t, err)
+
+	_, err = auth.ParseToken(pair.RefreshToken, auth.AccessTokenType)
+	assert.Equal(t, auth.ErrWrongTokenType, err)
+}
+
+func TestParseTokenWrongSecret(t *testing.T) {
+	os.Setenv("API_SECRET", "secret")
+	pair, err := auth.CreateTokenPair(int64(1), auth.RoleUser)
+	assert.NoError(t, err)
+
+	os.Setenv("API_SECRET", "other")
+	_, err = auth.ParseToken(pair.AccessToken, auth.AccessTokenType)
+	assert.NotNil(t, err)
+}
+
+func TestHasPermission(t *testing.T) {
+	assert.False(t, auth.HasPermission(auth.RoleUser, auth.PermissionProfileRead))
+	assert.True(t, auth.HasPermission(auth.RoleSupport, auth.PermissionProfileWrite))
+	assert.False(t, auth.HasPermission(auth.RoleSupport, auth.PermissionRolesWrite))
+	assert.True(t, auth.HasPermission(auth.RoleAdmin, auth.PermissionRolesWrite))
+	assert.False(t, auth.HasPermission("root", auth.PermissionProfileRead))
+	assert.False(t, auth.ValidRole("root"))
+}
@@ -0,0 +1,441 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/famkampm/nentrytask/pkg/helper"
+)
+
+const (
+	rsaKeyBits = 2048
+	// keyReloadInterval is the least time between two reloads of the key dir
+	// triggered by unknown kids
+	keyReloadInterval = 10 * time.Second
+	// unknownKeyTTL is how long a kid a reload didn't find is rejected without
+	// reloading again
+	unknownKeyTTL   = 10 * time.Minute
+	maxUnknownKeys  = 1024
+	rotationChecks  = 10
+	rotationLock    = "rotate.lock"
+	staleRotateLock = time.Minute
+	// minKeyRotation keeps the rotation checks StartRotation makes sane
+	minKeyRotation = time.Minute
+)
+
+var (
+	ErrUnknownKey          = errors.New("Unknown Signing Key")
+	ErrUnsupportedKeyAlg   = errors.New("Unsupported Signing Algorithm")
+	ErrKeyRotationTooShort = errors.New("Key Rotation Too Short")
+)
+
+// SigningKey is one asymmetric key identified by its kid
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	Private   crypto.Signer
+	CreatedAt time.Time
+}
+
+// JWK is the public part of a SigningKey as published in the JWKS document
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeyManager holds the signing keys. The newest key signs new tokens and the
+// older ones are kept for verification until every token they signed expired.
+// When dir is set keys are persisted there as PKCS8 PEM files named <kid>.pem,
+// so several app instances sharing the dir verify each other's tokens. Only
+// one of them rotates, the others pick up its key from the dir.
+type KeyManager struct {
+	mu          sync.RWMutex
+	method      jwt.SigningMethod
+	dir         string
+	rotateEvery time.Duration
+	retain      time.Duration
+	keys        []*SigningKey
+
+	reloadMu   sync.Mutex
+	reloadedAt time.Time
+	unknown    map[string]time.Time
+}
+
+func signingMethodFromAlg(alg string) (jwt.SigningMethod, error) {
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		return jwt.SigningMethodRS256, nil
+	case SigningMethodEdDSA.Alg():
+		return SigningMethodEdDSA, nil
+	}
+	return nil, ErrUnsupportedKeyAlg
+}
+
+// NewKeyManager loads the keys found in dir and makes sure there is an active
+// key no older than rotateEvery. retain is how long a key is still accepted
+// for verification after it stopped signing.
+func NewKeyManager(alg, dir string, rotateEvery, retain time.Duration) (*KeyManager, error) {
+	method, err := signingMethodFromAlg(alg)
+	if err != nil {
+		return nil, err
+	}
+	if rotateEvery < minKeyRotation {
+		return nil, ErrKeyRotationTooShort
+	}
+	km := &KeyManager{
+		method:      method,
+		dir:         dir,
+		rotateEvery: rotateEvery,
+		retain:      retain,
+		unknown:     map[string]time.Time{},
+	}
+	err = km.rotateIfDue()
+	if err != nil {
+		return nil, err
+	}
+	return km, nil
+}
+
+// NewKeyManagerFromEnv returns nil when JWT_ALGORITHM is unset or HS256, in
+// which case tokens keep being signed with API_SECRET
+func NewKeyManagerFromEnv() (*KeyManager, error) {
+	alg := os.Getenv("JWT_ALGORITHM")
+	if alg == "" || alg == jwt.SigningMethodHS256.Alg() {
+		return nil, nil
+	}
+	rotateEvery := helper.DurationFromEnv("JWT_KEY_ROTATION", time.Hour*24)
+	return NewKeyManager(alg, os.Getenv("JWT_KEYS_DIR"), rotateEvery, RefreshTokenTTL())
+}
+
+func (km *KeyManager) generate() (*SigningKey, error) {
+	var private crypto.Signer
+	var err error
+	switch km.method {
+	case jwt.SigningMethodRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case SigningMethodEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{
+		ID:        fmt.Sprintf("%d-%s", time.Now().Unix(), helper.RandToken(4)),
+		Method:    km.method,
+		Private:   private,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// load reads every key file in dir. Keys of another algorithm are still
+// loaded so tokens signed before an algorithm switch keep verifying.
+func (km *KeyManager) load() error {
+	if km.dir == "" {
+		return nil
+	}
+	files, err := ioutil.ReadDir(km.dir)
+	if err != nil {
+		return err
+	}
+	keys := make([]*SigningKey, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".pem" {
+			continue
+		}
+		raw, err := ioutil.ReadFile(filepath.Join(km.dir, f.Name()))
+		if err != nil {
+			return err
+		}
+		block, _ := pem.Decode(raw)
+		if block == nil {
+			log.Println("skipping key file without pem block:", f.Name())
+			continue
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			log.Println("skipping unreadable key file:", f.Name(), "err:", err.Error())
+			continue
+		}
+		key := &SigningKey{
+			ID:        strings.TrimSuffix(f.Name(), ".pem"),
+			CreatedAt: f.ModTime(),
+		}
+		switch private := parsed.(type) {
+		case *rsa.PrivateKey:
+			key.Method = jwt.SigningMethodRS256
+			key.Private = private
+		case ed25519.PrivateKey:
+			key.Method = SigningMethodEdDSA
+			key.Private = private
+		default:
+			log.Println("skipping key file of unsupported type:", f.Name())
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	km.mu.Lock()
+	km.keys = keys
+	km.mu.Unlock()
+	return nil
+}
+
+func (km *KeyManager) save(key *SigningKey) error {
+	if km.dir == "" {
+		return nil
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+	if err != nil {
+		return err
+	}
+	raw := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	// RENAMED INTO PLACE SO OTHER INSTANCES NEVER LOAD HALF A KEY
+	tmp := filepath.Join(km.dir, key.ID+".pem.tmp")
+	err = ioutil.WriteFile(tmp, raw, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(km.dir, key.ID+".pem"))
+}
+
+// Rotate generates a new active key and drops keys that can no longer have
+// any unexpired token
+func (km *KeyManager) Rotate() error {
+	key, err := km.generate()
+	if err != nil {
+		return err
+	}
+	err = km.save(key)
+	if err != nil {
+		return err
+	}
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	km.keys = append(km.keys, key)
+	keep := make([]*SigningKey, 0, len(km.keys))
+	for i, k := range km.keys {
+		// a key stops signing when the next one is created
+		if i < len(km.keys)-1 && time.Since(km.keys[i+1].CreatedAt) > km.retain {
+			if km.dir != "" {
+				os.Remove(filepath.Join(km.dir, k.ID+".pem"))
+			}
+			continue
+		}
+		keep = append(keep, k)
+	}
+	km.keys = keep
+	return nil
+}
+
+// rotateIfDue rotates when the active key is rotateEvery old. With a dir the
+// instance holding the rotation lock does it, the others load its new key.
+func (km *KeyManager) rotateIfDue() error {
+	if km.dir != "" {
+		locked, err := km.lockRotation()
+		if err != nil {
+			return err
+		}
+		if !locked {
+			err = km.load()
+			// INSTANCES STARTING TOGETHER ON AN EMPTY DIR STILL NEED A KEY TO SIGN
+			if err != nil || km.ActiveKey() != nil {
+				return err
+			}
+			return km.Rotate()
+		}
+		defer os.Remove(filepath.Join(km.dir, rotationLock))
+		err = km.load()
+		if err != nil {
+			return err
+		}
+	}
+	active := km.ActiveKey()
+	if active != nil && time.Since(active.CreatedAt) < km.rotateEvery {
+		return nil
+	}
+	return km.Rotate()
+}
+
+// lockRotation creates the lock file in dir. A lock left behind by an instance
+// that died while rotating is taken over once it is stale.
+func (km *KeyManager) lockRotation() (bool, error) {
+	path := filepath.Join(km.dir, rotationLock)
+	for i := 0; i < 2; i++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			return true, f.Close()
+		}
+		if !os.IsExist(err) {
+			return false, err
+		}
+		info, err := os.Stat(path)
+		if err != nil || time.Since(info.ModTime()) < staleRotateLock {
+			return false, nil
+		}
+		os.Remove(path)
+	}
+	return false, nil
+}
+
+// StartRotation checks rotateEvery/10 whether the active key is due until stop
+// is closed, so instances not rotating themselves adopt the new key soon
+func (km *KeyManager) StartRotation(stop <-chan struct{}) {
+	ticker := time.NewTicker(km.rotateEvery / rotationChecks)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				err := km.rotateIfDue()
+				if err != nil {
+					log.Println("rotate signing key err:", err.Error())
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (km *KeyManager) ActiveKey() *SigningKey {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	if len(km.keys) == 0 {
+		return nil
+	}
+	return km.keys[len(km.keys)-1]
+}
+
+// Key looks up a key by kid. Unknown kids trigger a reload from dir, since
+// another instance may have rotated in the meantime, at most once every
+// keyReloadInterval. A kid the reload didn't find either is rejected right away
+// for unknownKeyTTL, so forged kids can't keep the dir being read.
+func (km *KeyManager) Key(kid string) (*SigningKey, error) {
+	if key := km.find(kid); key != nil {
+		return key, nil
+	}
+	if km.dir == "" {
+		return nil, ErrUnknownKey
+	}
+	km.reloadMu.Lock()
+	defer km.reloadMu.Unlock()
+	// ANOTHER LOOKUP MAY HAVE RELOADED IT WHILE THIS ONE WAITED
+	if key := km.find(kid); key != nil {
+		return key, nil
+	}
+	if missed, ok := km.unknown[kid]; ok && time.Since(missed) < unknownKeyTTL {
+		return nil, ErrUnknownKey
+	}
+	if time.Since(km.reloadedAt) < keyReloadInterval {
+		return nil, ErrUnknownKey
+	}
+	km.reloadedAt = time.Now()
+	err := km.load()
+	if err != nil {
+		return nil, err
+	}
+	if key := km.find(kid); key != nil {
+		return key, nil
+	}
+	if len(km.unknown) >= maxUnknownKeys {
+		km.unknown = map[string]time.Time{}
+	}
+	km.unknown[kid] = time.Now()
+	return nil, ErrUnknownKey
+}
+
+func (km *KeyManager) find(kid string) *SigningKey {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	for _, k := range km.keys {
+		if k.ID == kid {
+			return k
+		}
+	}
+	return nil
+}
+
+// JWKS returns the public keys of every key still accepted for verification
+func (km *KeyManager) JWKS() *JWKSet {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	set := &JWKSet{Keys: make([]JWK, 0, len(km.keys))}
+	for _, k := range km.keys {
+		jwk := JWK{
+			KeyID:     k.ID,
+			Use:       "sig",
+			Algorithm: k.Method.Alg(),
+		}
+		switch public := k.Private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// PublicKey decodes the verification key of a JWK, ours or one published by
+// another provider
+func (j JWK) PublicKey() (crypto.PublicKey, error) {
+	switch j.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, err
+		}
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, ErrUnsupportedKeyAlg
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if j.Curve != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKeyAlg
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, ErrUnsupportedKeyAlg
+}
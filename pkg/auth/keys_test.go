@@ -0,0 +1,148 @@
+package auth_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyManagerSignAndVerify(t *testing.T) {
+	for _, alg := range []string{"RS256", "EdDSA"} {
+		km, err := auth.NewKeyManager(alg, "", time.Hour, time.Hour)
+		assert.NoError(t, err)
+		auth.SetKeyManager(km)
+
+		pair, err := auth.CreateTokenPair(int64(1), auth.RoleUser)
+		assert.NoError(t, err)
+		claims, err := auth.ParseToken(pair.AccessToken, auth.AccessTokenType)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), claims.UserID)
+		assert.Equal(t, alg, auth.JWKS().Keys[0].Algorithm)
+	}
+	auth.SetKeyManager(nil)
+}
+
+func TestKeyManagerRotateKeepsOldKey(t *testing.T) {
+	km, err := auth.NewKeyManager("EdDSA", "", time.Hour, time.Hour)
+	assert.NoError(t, err)
+	auth.SetKeyManager(km)
+	defer auth.SetKeyManager(nil)
+
+	pair, err := auth.CreateTokenPair(int64(1), auth.RoleUser)
+	assert.NoError(t, err)
+	oldKey := km.ActiveKey()
+	err = km.Rotate()
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldKey.ID, km.ActiveKey().ID)
+
+	_, err = auth.ParseToken(pair.AccessToken, auth.AccessTokenType)
+	assert.NoError(t, err)
+	assert.Len(t, km.JWKS().Keys, 2)
+}
+
+func TestKeyManagerRejectsHMAC(t *testing.T) {
+	os.Setenv("API_SECRET", "secret")
+	pair, err := auth.CreateTokenPair(int64(1), auth.RoleUser)
+	assert.NoError(t, err)
+
+	km, err := auth.NewKeyManager("RS256", "", time.Hour, time.Hour)
+	assert.NoError(t, err)
+	auth.SetKeyManager(km)
+	defer auth.SetKeyManager(nil)
+	_, err = auth.ParseToken(pair.AccessToken, auth.AccessTokenType)
+	assert.NotNil(t, err)
+}
+
+func TestKeyManagerLoadFromDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	first, err := auth.NewKeyManager("EdDSA", dir, time.Hour, time.Hour)
+	assert.NoError(t, err)
+	second, err := auth.NewKeyManager("EdDSA", dir, time.Hour, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, first.ActiveKey().ID, second.ActiveKey().ID)
+
+	err = first.Rotate()
+	assert.NoError(t, err)
+	key, err := second.Key(first.ActiveKey().ID)
+	assert.NoError(t, err)
+	assert.Equal(t, first.ActiveKey().ID, key.ID)
+}
+
+func TestKeyManagerReloadLimited(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	first, err := auth.NewKeyManager("EdDSA", dir, time.Hour, time.Hour)
+	assert.NoError(t, err)
+	second, err := auth.NewKeyManager("EdDSA", dir, time.Hour, time.Hour)
+	assert.NoError(t, err)
+	_, err = second.Key("forged")
+	assert.Equal(t, auth.ErrUnknownKey, err)
+
+	// THE FORGED KID JUST RELOADED THE DIR, THE NEW KEY WAITS FOR THE NEXT ONE
+	err = first.Rotate()
+	assert.NoError(t, err)
+	_, err = second.Key(first.ActiveKey().ID)
+	assert.Equal(t, auth.ErrUnknownKey, err)
+}
+
+func TestKeyManagerRotationLocked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	first, err := auth.NewKeyManager("EdDSA", dir, time.Hour, time.Hour)
+	assert.NoError(t, err)
+	// THE KEY FILE IS OLD ENOUGH TO BE DUE
+	old := time.Now().Add(-2 * time.Hour)
+	err = os.Chtimes(filepath.Join(dir, first.ActiveKey().ID+".pem"), old, old)
+	assert.NoError(t, err)
+	lock := filepath.Join(dir, "rotate.lock")
+	err = ioutil.WriteFile(lock, nil, 0600)
+	assert.NoError(t, err)
+
+	// ANOTHER INSTANCE IS ROTATING, THE DUE KEY IS KEPT UNTIL IT IS DONE
+	second, err := auth.NewKeyManager("EdDSA", dir, time.Hour, 3*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, first.ActiveKey().ID, second.ActiveKey().ID)
+
+	err = os.Remove(lock)
+	assert.NoError(t, err)
+	third, err := auth.NewKeyManager("EdDSA", dir, time.Hour, 3*time.Hour)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.ActiveKey().ID, third.ActiveKey().ID)
+	assert.Len(t, third.JWKS().Keys, 2)
+}
+
+func TestNewKeyManagerRotationTooShort(t *testing.T) {
+	for _, rotateEvery := range []time.Duration{0, -time.Hour, time.Nanosecond} {
+		_, err := auth.NewKeyManager("EdDSA", "", rotateEvery, time.Hour)
+		assert.Equal(t, auth.ErrKeyRotationTooShort, err)
+	}
+}
+
+func TestNewKeyManagerUnsupportedAlg(t *testing.T) {
+	_, err := auth.NewKeyManager("ES256", "", time.Hour, time.Hour)
+	assert.Equal(t, auth.ErrUnsupportedKeyAlg, err)
+}
+
+func TestJWKPublicKey(t *testing.T) {
+	for _, alg := range []string{"RS256", "EdDSA"} {
+		km, err := auth.NewKeyManager(alg, "", time.Hour, time.Hour)
+		assert.NoError(t, err)
+		public, err := km.JWKS().Keys[0].PublicKey()
+		assert.NoError(t, err)
+		assert.Equal(t, km.ActiveKey().Private.Public(), public)
+	}
+	_, err := auth.JWK{KeyType: "EC"}.PublicKey()
+	assert.Equal(t, auth.ErrUnsupportedKeyAlg, err)
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// OIDCAccessTokenType is the access token handed to OpenID Connect clients. It
+// is only good for /userinfo, never for our own API.
+const OIDCAccessTokenType = "oidc_access"
+
+var ErrNoSigningKeys = errors.New("OpenID Connect Needs RS256 Or EdDSA Signing Keys")
+
+// OIDCClaims are the claims of the ID and access tokens issued to OpenID
+// Connect clients. Subject is the user id and Audience the client id.
+type OIDCClaims struct {
+	TokenType         string `json:"typ,omitempty"`
+	Scope             string `json:"scope,omitempty"`
+	Nonce             string `json:"nonce,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	jwt.StandardClaims
+}
+
+// SigningAlgorithm is the alg of the tokens issued to OpenID Connect clients,
+// empty when signing with API_SECRET
+func SigningAlgorithm() string {
+	if keyManager == nil {
+		return ""
+	}
+	key := keyManager.ActiveKey()
+	if key == nil {
+		return ""
+	}
+	return key.Method.Alg()
+}
+
+// SignOIDCClaims signs with the active key. Clients verify our tokens with the
+// JWKS, so it refuses to sign with API_SECRET.
+func SignOIDCClaims(claims *OIDCClaims) (string, error) {
+	if keyManager == nil {
+		return "", ErrNoSigningKeys
+	}
+	return signClaims(claims)
+}
+
+// ParseOIDCAccessToken verifies an access token issued by SignOIDCClaims
+func ParseOIDCAccessToken(tokenString string) (*OIDCClaims, error) {
+	if keyManager == nil {
+		return nil, ErrNoSigningKeys
+	}
+	claims := &OIDCClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || claims.Id == "" || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	if claims.TokenType != OIDCAccessTokenType {
+		return nil, ErrWrongTokenType
+	}
+	return claims, nil
+}
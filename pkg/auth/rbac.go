@@ -0,0 +1,57 @@
+package auth
+
+// Roles a user can have. Tokens carry the role as the role claim.
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+)
+
+// Permission lets a role act on resources of other users. Acting on your own
+// resources never needs one.
+type Permission string
+
+const (
+	PermissionProfileRead   Permission = "profile:read"
+	PermissionProfileWrite  Permission = "profile:write"
+	PermissionRolesWrite    Permission = "roles:write"
+	PermissionAccountUnlock Permission = "account:unlock"
+	PermissionAuditRead     Permission = "audit:read"
+	PermissionAccountDelete Permission = "account:delete"
+	PermissionAccountExport Permission = "account:export"
+	PermissionAccountStatus Permission = "account:status"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleUser: {},
+	RoleSupport: {
+		PermissionProfileRead,
+		PermissionProfileWrite,
+		PermissionAccountUnlock,
+	},
+	RoleAdmin: {
+		PermissionProfileRead,
+		PermissionProfileWrite,
+		PermissionRolesWrite,
+		PermissionAccountUnlock,
+		PermissionAuditRead,
+		PermissionAccountDelete,
+		PermissionAccountExport,
+		PermissionAccountStatus,
+	},
+}
+
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether role grants permission. Unknown roles have none.
+func HasPermission(role string, permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/gomodule/redigo/redis"
+)
+
+// Revoker keeps track of token and session IDs that must no longer be accepted.
+// The list lives in redis so every app instance sees the same revocations.
+type Revoker interface {
+	Revoke(ctx context.Context, id string, until time.Time) error
+	Consume(ctx context.Context, id string, until time.Time) (bool, error)
+	RevokeUser(ctx context.Context, userID int64) error
+	IsRevoked(ctx context.Context, claims *Claims) (bool, error)
+}
+
+type redisRevoker struct {
+	RedisPool *redis.Pool
+}
+
+func NewRedisRevoker(redisPool *redis.Pool) Revoker {
+	return &redisRevoker{
+		RedisPool: redisPool,
+	}
+}
+
+func revokedKey(id string) string {
+	return "revoked:" + id
+}
+
+func revokedUserKey(userID int64) string {
+	return "revoked_user:" + strconv.FormatInt(userID, 10)
+}
+
+// Revoke stores id until the given time. After that the token carrying it has
+// expired anyway, so the key is left to expire with it.
+func (r *redisRevoker) Revoke(ctx context.Context, id string, until time.Time) error {
+	ttl := int64(time.Until(until).Seconds()) + 1
+	if ttl <= 1 {
+		return nil
+	}
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	_, err := conn.Do("SET", revokedKey(id), "1", "EX", ttl)
+	return err
+}
+
+// Consume revokes id like Revoke unless it is revoked already, in a single
+// SET NX, and reports whether this call did it. Of several concurrent uses of
+// a single-use token only one gets through.
+func (r *redisRevoker) Consume(ctx context.Context, id string, until time.Time) (bool, error) {
+	ttl := int64(time.Until(until).Seconds()) + 1
+	if ttl <= 1 {
+		return false, nil
+	}
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	reply, err := conn.Do("SET", revokedKey(id), "1", "NX", "EX", ttl)
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+// RevokeUser rejects every token of the user issued before now. The time is
+// kept as <seconds>.<nanoseconds>, tokens issued right after it in the same
+// second stay valid. The marker only has to outlive the longest token lifetime.
+func (r *redisRevoker) RevokeUser(ctx context.Context, userID int64) error {
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	_, err := conn.Do("SET", revokedUserKey(userID), helper.FormatUnixNano(time.Now()), "EX", int64(RefreshTokenTTL().Seconds()))
+	return err
+}
+
+// IsRevoked checks the token ID, its session and the user wide marker in a
+// single round trip
+func (r *redisRevoker) IsRevoked(ctx context.Context, claims *Claims) (bool, error) {
+	conn := r.RedisPool.Get()
+	defer conn.Close()
+	values, err := redis.Values(conn.Do("MGET", revokedKey(claims.Id), revokedKey(claims.SessionID), revokedUserKey(claims.UserID)))
+	if err != nil {
+		return false, err
+	}
+	if values[0] != nil || values[1] != nil {
+		return true, nil
+	}
+	if values[2] == nil {
+		return false, nil
+	}
+	marker, err := redis.String(values[2], nil)
+	if err != nil {
+		return false, err
+	}
+	revokedAt, err := helper.ParseUnixNano(marker)
+	if err != nil {
+		return false, err
+	}
+	issuedAt := claims.IssuedAtNano
+	if issuedAt == 0 {
+		issuedAt = claims.IssuedAt * int64(time.Second)
+	}
+	return issuedAt < revokedAt.UnixNano(), nil
+}
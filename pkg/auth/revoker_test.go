@@ -0,0 +1,160 @@
+package auth_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/famkampm/nentrytask/pkg/auth"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPool(t *testing.T) (*miniredis.Miniredis, *redis.Pool) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when starting miniredis", err)
+	}
+	addr := s.Addr()
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr)
+		},
+	}
+	return s, pool
+}
+
+func newTestClaims() *auth.Claims {
+	claims := &auth.Claims{
+		UserID:    int64(1),
+		SessionID: "sid1",
+	}
+	claims.Id = "jti1"
+	claims.IssuedAt = time.Now().Add(-time.Minute).Unix()
+	return claims
+}
+
+func TestRevokeSuccess(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := auth.NewRedisRevoker(pool)
+
+	err := r.Revoke(context.TODO(), "jti1", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	revoked, err := r.IsRevoked(context.TODO(), newTestClaims())
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestRevokeExpired(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := auth.NewRedisRevoker(pool)
+
+	err := r.Revoke(context.TODO(), "jti1", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	s.FastForward(2 * time.Minute)
+	revoked, err := r.IsRevoked(context.TODO(), newTestClaims())
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+}
+
+func TestConsumeOnce(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := auth.NewRedisRevoker(pool)
+
+	consumed, err := r.Consume(context.TODO(), "jti1", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, consumed)
+	consumed, err = r.Consume(context.TODO(), "jti1", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, consumed)
+	revoked, err := r.IsRevoked(context.TODO(), newTestClaims())
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestRevokeSessionSuccess(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := auth.NewRedisRevoker(pool)
+
+	err := r.Revoke(context.TODO(), "sid1", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	revoked, err := r.IsRevoked(context.TODO(), newTestClaims())
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestRevokeUserSuccess(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := auth.NewRedisRevoker(pool)
+
+	err := r.RevokeUser(context.TODO(), int64(1))
+	assert.NoError(t, err)
+	revoked, err := r.IsRevoked(context.TODO(), newTestClaims())
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	// tokens issued after the revocation are still accepted
+	claims := newTestClaims()
+	claims.IssuedAt = time.Now().Add(time.Second).Unix()
+	revoked, err = r.IsRevoked(context.TODO(), claims)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+}
+
+func TestRevokeUserSameSecond(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := auth.NewRedisRevoker(pool)
+
+	before := newTestClaims()
+	before.IssuedAt, before.IssuedAtNano = time.Now().Unix(), time.Now().UnixNano()
+	err := r.RevokeUser(context.TODO(), int64(1))
+	assert.NoError(t, err)
+	after := newTestClaims()
+	after.IssuedAt, after.IssuedAtNano = time.Now().Unix(), time.Now().UnixNano()
+
+	revoked, err := r.IsRevoked(context.TODO(), before)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = r.IsRevoked(context.TODO(), after)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+}
+
+func TestRevokeUserSecondsMarker(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := auth.NewRedisRevoker(pool)
+
+	// A MARKER SET BEFORE NANOSECONDS WERE KEPT
+	s.Set("revoked_user:1", strconv.FormatInt(time.Now().Unix(), 10))
+	revoked, err := r.IsRevoked(context.TODO(), newTestClaims())
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestIsRevokedNotFound(t *testing.T) {
+	s, pool := newTestPool(t)
+	defer s.Close()
+	r := auth.NewRedisRevoker(pool)
+
+	revoked, err := r.IsRevoked(context.TODO(), newTestClaims())
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+}
+
+func TestIsRevokedFailedRedis(t *testing.T) {
+	s, pool := newTestPool(t)
+	r := auth.NewRedisRevoker(pool)
+	s.Close()
+
+	_, err := r.IsRevoked(context.TODO(), newTestClaims())
+	assert.NotNil(t, err)
+}
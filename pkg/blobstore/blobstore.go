@@ -0,0 +1,81 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound    = errors.New("Blob Not Found")
+	ErrInvalidName = errors.New("Invalid Blob Name")
+)
+
+// Object describes a stored blob
+type Object struct {
+	Name        string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+	// ETag is a strong validator of the content
+	ETag string
+}
+
+// BlobStore keeps blobs such as profile images under flat names, so that every
+// app instance sees the same ones
+type BlobStore interface {
+	// Put stores size bytes read from r under name, replacing what was there
+	Put(ctx context.Context, name string, r io.Reader, size int64, contentType string) error
+	// Get opens the blob, ErrNotFound when there is none
+	Get(ctx context.Context, name string) (io.ReadCloser, *Object, error)
+	Stat(ctx context.Context, name string) (*Object, error)
+	// Delete removes the blob. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, name string) error
+	// SignedURL is a URL anyone holding it can GET the blob from until ttl
+	// runs out
+	SignedURL(ctx context.Context, name string, ttl time.Duration) (string, error)
+	// List calls fn with every blob, stopping at the first error fn returns
+	List(ctx context.Context, fn func(*Object) error) error
+}
+
+// SignatureVerifier is implemented by the stores whose signed URLs point at
+// the app itself, which then has to check them
+type SignatureVerifier interface {
+	Verify(name, expires, signature string) bool
+}
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9._-]*$`)
+
+// ValidName tells whether name can name a blob. Names are a single path
+// segment, so no store can be tricked into reaching outside of its root.
+func ValidName(name string) bool {
+	return len(name) <= 255 && validName.MatchString(name) && !strings.Contains(name, "..")
+}
+
+// NewBlobStoreFromEnv returns an S3 store when BLOB_STORE=s3. Otherwise blobs
+// are files in IMAGE_PATH, which only works for a single app instance.
+func NewBlobStoreFromEnv() (BlobStore, error) {
+	if os.Getenv("BLOB_STORE") == "s3" {
+		return NewS3Store(&S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			Prefix:          os.Getenv("S3_PREFIX"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PathStyle:       os.Getenv("S3_PATH_STYLE") == "true",
+		}, nil)
+	}
+	secret := []byte(os.Getenv("IMAGE_URL_SECRET"))
+	if len(secret) == 0 {
+		// SIGNED URLS THEN ONLY LAST AS LONG AS THE PROCESS
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	}
+	return NewLocalStore(os.Getenv("IMAGE_PATH"), os.Getenv("IMAGE_BASE_URL"), secret)
+}
@@ -0,0 +1,150 @@
+package blobstore_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/famkampm/nentrytask/pkg/blobstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func newLocalStore(t *testing.T) (blobstore.BlobStore, string) {
+	dir, err := ioutil.TempDir("", "blobs")
+	assert.NoError(t, err)
+	store, err := blobstore.NewLocalStore(filepath.Join(dir, "images"), "/images/", []byte("secret"))
+	assert.NoError(t, err)
+	return store, dir
+}
+
+func TestLocalStore(t *testing.T) {
+	store, dir := newLocalStore(t)
+	defer os.RemoveAll(dir)
+	ctx := context.TODO()
+
+	_, err := store.Stat(ctx, "abc.png")
+	assert.Equal(t, blobstore.ErrNotFound, err)
+	_, _, err = store.Get(ctx, "abc.png")
+	assert.Equal(t, blobstore.ErrNotFound, err)
+
+	assert.NoError(t, store.Put(ctx, "abc.png", strings.NewReader("0123456789"), 10, "image/png"))
+	obj, err := store.Stat(ctx, "abc.png")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc.png", obj.Name)
+	assert.Equal(t, int64(10), obj.Size)
+	assert.Equal(t, "image/png", obj.ContentType)
+	assert.Regexp(t, `^"[0-9a-f]{32}"$`, obj.ETag)
+
+	body, got, err := store.Get(ctx, "abc.png")
+	assert.NoError(t, err)
+	data, err := ioutil.ReadAll(body)
+	body.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+	assert.Equal(t, obj.ETag, got.ETag)
+
+	// REPLACING CHANGES THE ETAG
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, store.Put(ctx, "abc.png", strings.NewReader("98765"), 5, "image/png"))
+	replaced, err := store.Stat(ctx, "abc.png")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), replaced.Size)
+	assert.NotEqual(t, obj.ETag, replaced.ETag)
+
+	assert.NoError(t, store.Delete(ctx, "abc.png"))
+	_, err = store.Stat(ctx, "abc.png")
+	assert.Equal(t, blobstore.ErrNotFound, err)
+	assert.NoError(t, store.Delete(ctx, "abc.png"))
+}
+
+func TestLocalStorePutWrongSize(t *testing.T) {
+	store, dir := newLocalStore(t)
+	defer os.RemoveAll(dir)
+	ctx := context.TODO()
+
+	assert.Error(t, store.Put(ctx, "short.png", strings.NewReader("0123"), 10, "image/png"))
+	assert.Error(t, store.Put(ctx, "long.png", bytes.NewReader(make([]byte, 20)), 10, "image/png"))
+	_, err := store.Stat(ctx, "short.png")
+	assert.Equal(t, blobstore.ErrNotFound, err)
+	_, err = store.Stat(ctx, "long.png")
+	assert.Equal(t, blobstore.ErrNotFound, err)
+	// NO TEMPORARY FILES ARE LEFT BEHIND
+	files, err := ioutil.ReadDir(filepath.Join(dir, "images"))
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestLocalStoreInvalidName(t *testing.T) {
+	store, dir := newLocalStore(t)
+	defer os.RemoveAll(dir)
+	ctx := context.TODO()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "secret.png"), []byte("secret"), 0600))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "images", "sub.png"), 0700))
+
+	for _, name := range []string{"", "../secret.png", "..", ".hidden", "a/b.png", "abc.png\x00", strings.Repeat("a", 256)} {
+		assert.Equal(t, blobstore.ErrInvalidName, store.Put(ctx, name, strings.NewReader("x"), 1, ""), name)
+		_, _, err := store.Get(ctx, name)
+		assert.Equal(t, blobstore.ErrInvalidName, err, name)
+		_, err = store.Stat(ctx, name)
+		assert.Equal(t, blobstore.ErrInvalidName, err, name)
+		assert.Equal(t, blobstore.ErrInvalidName, store.Delete(ctx, name), name)
+	}
+	// DIRECTORIES ARE NOT BLOBS
+	_, err := store.Stat(ctx, "sub.png")
+	assert.Equal(t, blobstore.ErrNotFound, err)
+	_, _, err = store.Get(ctx, "sub.png")
+	assert.Equal(t, blobstore.ErrNotFound, err)
+}
+
+func TestLocalStoreList(t *testing.T) {
+	store, dir := newLocalStore(t)
+	defer os.RemoveAll(dir)
+	ctx := context.TODO()
+	for _, name := range []string{"b.png", "a.png", "a_64.png"} {
+		assert.NoError(t, store.Put(ctx, name, strings.NewReader(name), int64(len(name)), "image/png"))
+	}
+	// LEFT BY A PUT THAT DIED HALFWAY, AND A DIRECTORY
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "images", ".put-123"), []byte("x"), 0600))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "images", "sub.png"), 0700))
+
+	names := []string{}
+	err := store.List(ctx, func(obj *blobstore.Object) error {
+		names = append(names, obj.Name)
+		assert.Equal(t, int64(len(obj.Name)), obj.Size)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.png", "a_64.png", "b.png"}, names)
+}
+
+func TestLocalStoreSignedURL(t *testing.T) {
+	store, dir := newLocalStore(t)
+	defer os.RemoveAll(dir)
+	verifier := store.(blobstore.SignatureVerifier)
+
+	signed, err := store.SignedURL(context.TODO(), "abc.png", time.Minute)
+	assert.NoError(t, err)
+	u, err := url.Parse(signed)
+	assert.NoError(t, err)
+	assert.Equal(t, "/images/abc.png", u.Path)
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+	assert.True(t, verifier.Verify("abc.png", expires, signature))
+	assert.False(t, verifier.Verify("abd.png", expires, signature))
+	assert.False(t, verifier.Verify("abc.png", expires+"0", signature))
+	assert.False(t, verifier.Verify("abc.png", "", signature))
+
+	signed, err = store.SignedURL(context.TODO(), "abc.png", -time.Minute)
+	assert.NoError(t, err)
+	u, err = url.Parse(signed)
+	assert.NoError(t, err)
+	assert.False(t, verifier.Verify("abc.png", u.Query().Get("expires"), u.Query().Get("signature")))
+
+	_, err = store.SignedURL(context.TODO(), "../secret.png", time.Minute)
+	assert.Equal(t, blobstore.ErrInvalidName, err)
+}
@@ -0,0 +1,260 @@
+package blobstore
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// S3Config points at a bucket of S3 or of an S3-compatible store such as
+// MinIO
+type S3Config struct {
+	// Endpoint is the base URL of the service, https://s3.amazonaws.com for AWS
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix is put in front of every blob name to make the object key
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PathStyle addresses the bucket as endpoint/bucket rather than as
+	// bucket.endpoint, which most S3-compatible stores need
+	PathStyle bool
+}
+
+type s3Store struct {
+	config     *S3Config
+	endpoint   *url.URL
+	signer     *SigV4Signer
+	HTTPClient *http.Client
+}
+
+func NewS3Store(config *S3Config, httpClient *http.Client) (BlobStore, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || !endpoint.IsAbs() {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", config.Endpoint)
+	}
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("s3 blob store needs a bucket")
+	}
+	region := config.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &s3Store{
+		config:   config,
+		endpoint: endpoint,
+		signer: &SigV4Signer{
+			AccessKeyID:     config.AccessKeyID,
+			SecretAccessKey: config.SecretAccessKey,
+			Region:          region,
+			Service:         "s3",
+		},
+		HTTPClient: httpClient,
+	}, nil
+}
+
+// bucketURL addresses the bucket itself, which objects are listed from
+func (s *s3Store) bucketURL() *url.URL {
+	u := *s.endpoint
+	if s.config.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.config.Bucket + "/"
+	} else {
+		u.Host = s.config.Bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/"
+	}
+	return &u
+}
+
+func (s *s3Store) objectURL(name string) (*url.URL, error) {
+	if !ValidName(name) {
+		return nil, ErrInvalidName
+	}
+	u := s.bucketURL()
+	u.Path += s.config.Prefix + name
+	return u, nil
+}
+
+func (s *s3Store) do(ctx context.Context, method string, u *url.URL, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	payloadHash := EmptyPayloadHash
+	if body != nil {
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+		req.Header.Set("Content-Type", contentType)
+		payloadHash = UnsignedPayload
+	}
+	s.signer.Sign(req, payloadHash, time.Now())
+	return s.HTTPClient.Do(req)
+}
+
+// doObject makes a request on the object stored as name
+func (s *s3Store) doObject(ctx context.Context, method, name string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	u, err := s.objectURL(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.do(ctx, method, u, body, size, contentType)
+}
+
+func (s *s3Store) Put(ctx context.Context, name string, r io.Reader, size int64, contentType string) error {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	res, err := s.doObject(ctx, "PUT", name, ioutil.NopCloser(r), size, contentType)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return s3Error("put", name, res)
+	}
+	return nil
+}
+
+func (s *s3Store) Get(ctx context.Context, name string) (io.ReadCloser, *Object, error) {
+	res, err := s.doObject(ctx, "GET", name, nil, 0, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return nil, nil, ErrNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		return nil, nil, s3Error("get", name, res)
+	}
+	return res.Body, object(name, res), nil
+}
+
+func (s *s3Store) Stat(ctx context.Context, name string) (*Object, error) {
+	res, err := s.doObject(ctx, "HEAD", name, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, s3Error("stat", name, res)
+	}
+	return object(name, res), nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, name string) error {
+	res, err := s.doObject(ctx, "DELETE", name, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+		return s3Error("delete", name, res)
+	}
+	return nil
+}
+
+func (s *s3Store) SignedURL(ctx context.Context, name string, ttl time.Duration) (string, error) {
+	u, err := s.objectURL(name)
+	if err != nil {
+		return "", err
+	}
+	return s.signer.Presign(u, time.Now(), ttl), nil
+}
+
+// listBucketResult is the part of the ListObjectsV2 answer List needs
+type listBucketResult struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key          string
+		LastModified time.Time
+		ETag         string
+		Size         int64
+	}
+}
+
+// List pages through the objects under the prefix. Objects whose key does
+// not make a valid name were not put by the store and are skipped.
+func (s *s3Store) List(ctx context.Context, fn func(*Object) error) error {
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", s.config.Prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		u := s.bucketURL()
+		u.RawQuery = canonicalQuery(query)
+		res, err := s.do(ctx, "GET", u, nil, 0, "")
+		if err != nil {
+			return err
+		}
+		if res.StatusCode != http.StatusOK {
+			defer res.Body.Close()
+			return s3Error("list", s.config.Prefix, res)
+		}
+		result := &listBucketResult{}
+		err = xml.NewDecoder(res.Body).Decode(result)
+		res.Body.Close()
+		if err != nil {
+			return err
+		}
+		for _, content := range result.Contents {
+			name := strings.TrimPrefix(content.Key, s.config.Prefix)
+			if !ValidName(name) {
+				continue
+			}
+			err = fn(&Object{
+				Name:        name,
+				Size:        content.Size,
+				ContentType: mime.TypeByExtension(path.Ext(name)),
+				ModTime:     content.LastModified,
+				ETag:        content.ETag,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func object(name string, res *http.Response) *Object {
+	modTime, _ := http.ParseTime(res.Header.Get("Last-Modified"))
+	return &Object{
+		Name:        name,
+		Size:        res.ContentLength,
+		ContentType: res.Header.Get("Content-Type"),
+		ModTime:     modTime,
+		ETag:        res.Header.Get("ETag"),
+	}
+}
+
+// s3Error keeps the start of the XML error S3 answered with
+func s3Error(op, name string, res *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+	return fmt.Errorf("s3 %s %s answered %d: %s", op, name, res.StatusCode, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,232 @@
+package blobstore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/famkampm/nentrytask/pkg/blobstore"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 keeps objects in memory and answers like S3 does for the requests
+// the store makes
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+	fail    bool
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	assert.True(f.t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=key/"), auth)
+	assert.Contains(f.t, auth, "/eu-west-1/s3/aws4_request")
+	assert.NotEmpty(f.t, r.Header.Get("X-Amz-Date"))
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("<Error><Code>AccessDenied</Code></Error>"))
+		return
+	}
+	key := r.URL.Path
+	if r.Method == "GET" && r.URL.Query().Get("list-type") == "2" {
+		f.list(w, r)
+		return
+	}
+	switch r.Method {
+	case "PUT":
+		assert.Equal(f.t, blobstore.UnsignedPayload, r.Header.Get("X-Amz-Content-Sha256"))
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(f.t, err)
+		f.objects[key] = body
+		f.types[key] = r.Header.Get("Content-Type")
+	case "GET", "HEAD":
+		body, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", f.types[key])
+		w.Header().Set("ETag", `"etag-`+string(body)+`"`)
+		w.Header().Set("Last-Modified", "Fri, 24 May 2013 00:00:00 GMT")
+		w.Header().Set("Content-Length", "10")
+		if r.Method == "GET" {
+			w.Write(body)
+		}
+	case "DELETE":
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// list answers ListObjectsV2 two keys at a time
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	assert.Equal(f.t, "/bucket/", r.URL.Path)
+	prefix := r.URL.Query().Get("prefix")
+	keys := []string{}
+	for path := range f.objects {
+		key := strings.TrimPrefix(path, "/bucket/")
+		if strings.HasPrefix(key, prefix) && key > r.URL.Query().Get("continuation-token") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	truncated := len(keys) > 2
+	if truncated {
+		keys = keys[:2]
+	}
+	fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult>`)
+	for _, key := range keys {
+		fmt.Fprintf(w, `<Contents><Key>%s</Key><LastModified>2013-05-24T00:00:00.000Z</LastModified><ETag>"etag"</ETag><Size>%d</Size></Contents>`, key, len(f.objects["/bucket/"+key]))
+	}
+	if truncated {
+		fmt.Fprintf(w, `<IsTruncated>true</IsTruncated><NextContinuationToken>%s</NextContinuationToken>`, keys[1])
+	}
+	fmt.Fprint(w, `</ListBucketResult>`)
+}
+
+func newS3Store(t *testing.T) (blobstore.BlobStore, *fakeS3, *httptest.Server) {
+	fake := &fakeS3{t: t, objects: map[string][]byte{}, types: map[string]string{}}
+	server := httptest.NewServer(fake)
+	store, err := blobstore.NewS3Store(&blobstore.S3Config{
+		Endpoint:        server.URL,
+		Region:          "eu-west-1",
+		Bucket:          "bucket",
+		Prefix:          "images/",
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+	}, nil)
+	assert.NoError(t, err)
+	return store, fake, server
+}
+
+func TestS3Store(t *testing.T) {
+	store, fake, server := newS3Store(t)
+	defer server.Close()
+	ctx := context.TODO()
+
+	_, err := store.Stat(ctx, "abc.png")
+	assert.Equal(t, blobstore.ErrNotFound, err)
+	_, _, err = store.Get(ctx, "abc.png")
+	assert.Equal(t, blobstore.ErrNotFound, err)
+
+	assert.NoError(t, store.Put(ctx, "abc.png", strings.NewReader("0123456789"), 10, "image/png"))
+	assert.Equal(t, "0123456789", string(fake.objects["/bucket/images/abc.png"]))
+	assert.Equal(t, "image/png", fake.types["/bucket/images/abc.png"])
+
+	obj, err := store.Stat(ctx, "abc.png")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc.png", obj.Name)
+	assert.Equal(t, int64(10), obj.Size)
+	assert.Equal(t, "image/png", obj.ContentType)
+	assert.Equal(t, `"etag-0123456789"`, obj.ETag)
+	assert.Equal(t, time.Date(2013, 5, 24, 0, 0, 0, 0, time.UTC), obj.ModTime)
+
+	body, _, err := store.Get(ctx, "abc.png")
+	assert.NoError(t, err)
+	data, err := ioutil.ReadAll(body)
+	body.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+
+	assert.NoError(t, store.Delete(ctx, "abc.png"))
+	assert.Empty(t, fake.objects)
+	assert.NoError(t, store.Delete(ctx, "abc.png"))
+
+	assert.NoError(t, store.Put(ctx, "empty.png", strings.NewReader(""), 0, ""))
+	assert.Equal(t, "application/octet-stream", fake.types["/bucket/images/empty.png"])
+}
+
+func TestS3StoreList(t *testing.T) {
+	store, fake, server := newS3Store(t)
+	defer server.Close()
+	ctx := context.TODO()
+	for _, name := range []string{"a.png", "a_64.png", "b.png", "c.png", "d.png"} {
+		assert.NoError(t, store.Put(ctx, name, strings.NewReader(name), int64(len(name)), "image/png"))
+	}
+	// NOT UNDER THE PREFIX OF THE STORE
+	fake.objects["/bucket/other/x.png"] = []byte("x")
+
+	objects := []*blobstore.Object{}
+	err := store.List(ctx, func(obj *blobstore.Object) error {
+		objects = append(objects, obj)
+		return nil
+	})
+	assert.NoError(t, err)
+	names := []string{}
+	for _, obj := range objects {
+		names = append(names, obj.Name)
+	}
+	assert.Equal(t, []string{"a.png", "a_64.png", "b.png", "c.png", "d.png"}, names)
+	assert.Equal(t, int64(8), objects[1].Size)
+	assert.Equal(t, "image/png", objects[1].ContentType)
+	assert.Equal(t, time.Date(2013, 5, 24, 0, 0, 0, 0, time.UTC), objects[1].ModTime)
+
+	stop := errors.New("stop")
+	calls := 0
+	err = store.List(ctx, func(obj *blobstore.Object) error {
+		calls++
+		return stop
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestS3StoreErrors(t *testing.T) {
+	store, fake, server := newS3Store(t)
+	defer server.Close()
+	ctx := context.TODO()
+	fake.fail = true
+
+	err := store.Put(ctx, "abc.png", strings.NewReader("0123456789"), 10, "image/png")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "403")
+	assert.Contains(t, err.Error(), "AccessDenied")
+	_, err = store.Stat(ctx, "abc.png")
+	assert.Error(t, err)
+	assert.NotEqual(t, blobstore.ErrNotFound, err)
+	assert.Error(t, store.Delete(ctx, "abc.png"))
+
+	assert.Equal(t, blobstore.ErrInvalidName, store.Put(ctx, "../abc.png", strings.NewReader("x"), 1, ""))
+	_, err = store.SignedURL(ctx, "../abc.png", time.Minute)
+	assert.Equal(t, blobstore.ErrInvalidName, err)
+}
+
+func TestS3StoreSignedURL(t *testing.T) {
+	store, err := blobstore.NewS3Store(&blobstore.S3Config{
+		Endpoint: "https://s3.eu-west-1.amazonaws.com",
+		Region:   "eu-west-1",
+		Bucket:   "bucket",
+		Prefix:   "images/",
+	}, nil)
+	assert.NoError(t, err)
+
+	signed, err := store.SignedURL(context.TODO(), "abc.png", time.Hour)
+	assert.NoError(t, err)
+	u, err := url.Parse(signed)
+	assert.NoError(t, err)
+	assert.Equal(t, "bucket.s3.eu-west-1.amazonaws.com", u.Host)
+	assert.Equal(t, "/images/abc.png", u.Path)
+	assert.Equal(t, "3600", u.Query().Get("X-Amz-Expires"))
+	assert.Regexp(t, `^[0-9a-f]{64}$`, u.Query().Get("X-Amz-Signature"))
+}
+
+func TestNewS3StoreInvalidConfig(t *testing.T) {
+	_, err := blobstore.NewS3Store(&blobstore.S3Config{Endpoint: "s3.amazonaws.com", Bucket: "bucket"}, nil)
+	assert.Error(t, err)
+	_, err = blobstore.NewS3Store(&blobstore.S3Config{Endpoint: "https://s3.amazonaws.com"}, nil)
+	assert.Error(t, err)
+}
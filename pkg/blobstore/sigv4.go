@@ -0,0 +1,147 @@
+package blobstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	sigV4DateTime  = "20060102T150405Z"
+	sigV4Date      = "20060102"
+	// UnsignedPayload lets the body stream without hashing it first
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// EmptyPayloadHash is the sha256 of no bytes
+	EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// SigV4Signer signs requests with AWS signature version 4, which S3 and the
+// S3-compatible stores understand
+type SigV4Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Region          string
+	Service         string
+}
+
+// Sign adds the x-amz-date, x-amz-content-sha256 and Authorization headers.
+// Host and every header already set on req are signed.
+func (s *SigV4Signer) Sign(req *http.Request, payloadHash string, t time.Time) {
+	t = t.UTC()
+	req.Header.Set("X-Amz-Date", t.Format(sigV4DateTime))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	signedHeaders, canonicalHeaders := canonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	signature := s.signature(t, canonicalRequest)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", sigV4Algorithm, s.AccessKeyID, s.scope(t), signedHeaders, signature))
+}
+
+// Presign returns u with the query parameters that allow a GET of it until
+// ttl after t
+func (s *SigV4Signer) Presign(u *url.URL, t time.Time, ttl time.Duration) string {
+	t = t.UTC()
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", s.AccessKeyID+"/"+s.scope(t))
+	query.Set("X-Amz-Date", t.Format(sigV4DateTime))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(ttl.Seconds()), 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		canonicalURI(u),
+		canonicalQuery(query),
+		"host:" + u.Host + "\n",
+		"host",
+		UnsignedPayload,
+	}, "\n")
+	query.Set("X-Amz-Signature", s.signature(t, canonicalRequest))
+	signed := *u
+	signed.RawQuery = canonicalQuery(query)
+	return signed.String()
+}
+
+func (s *SigV4Signer) scope(t time.Time) string {
+	return t.Format(sigV4Date) + "/" + s.Region + "/" + s.Service + "/aws4_request"
+}
+
+func (s *SigV4Signer) signature(t time.Time, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{sigV4Algorithm, t.Format(sigV4DateTime), s.scope(t), hex.EncodeToString(hash[:])}, "\n")
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), t.Format(sigV4Date))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func canonicalHeaders(req *http.Request) (string, string) {
+	headers := map[string]string{"host": req.URL.Host}
+	if req.Host != "" {
+		headers["host"] = req.Host
+	}
+	for key, values := range req.Header {
+		headers[strings.ToLower(key)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	canonical := &strings.Builder{}
+	for _, key := range keys {
+		canonical.WriteString(key + ":" + headers[key] + "\n")
+	}
+	return strings.Join(keys, ";"), canonical.String()
+}
+
+func canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// canonicalQuery sorts the parameters and escapes them the way AWS expects,
+// spaces as %20 rather than +
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := []string{}
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, awsEscape(key)+"="+awsEscape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func awsEscape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
@@ -0,0 +1,227 @@
+package helper
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	BcryptAlgorithm   = "bcrypt"
+	Argon2idAlgorithm = "argon2id"
+
+	argon2idPrefix  = "$argon2id$"
+	argon2SaltBytes = 16
+)
+
+var (
+	ErrUnknownHashAlgorithm = errors.New("Unknown Password Hash Algorithm")
+	ErrMalformedHash        = errors.New("Malformed Password Hash")
+)
+
+// HashPolicy is how new password hashes are made. Every hash carries its own
+// algorithm and parameters ($2a$<cost>$... for bcrypt, the PHC string
+// $argon2id$v=19$m=..,t=..,p=..$salt$key for argon2id), so hashes made under an
+// older policy keep verifying and can be told apart.
+type HashPolicy struct {
+	Algorithm     string
+	BcryptCost    int
+	Argon2Time    uint32
+	Argon2Memory  uint32 // KiB
+	Argon2Threads uint8
+	Argon2KeyLen  uint32
+}
+
+var hashPolicy = DefaultHashPolicy()
+
+func SetHashPolicy(p *HashPolicy) {
+	hashPolicy = p
+}
+
+// DefaultHashPolicy keeps the bcrypt hashes we always made
+func DefaultHashPolicy() *HashPolicy {
+	return &HashPolicy{
+		Algorithm:     BcryptAlgorithm,
+		BcryptCost:    bcrypt.DefaultCost,
+		Argon2Time:    1,
+		Argon2Memory:  64 * 1024,
+		Argon2Threads: 4,
+		Argon2KeyLen:  32,
+	}
+}
+
+// HashPolicyFromEnv starts from the default policy and applies the
+// PASSWORD_HASH_ALGORITHM, BCRYPT_COST and ARGON2_* settings
+func HashPolicyFromEnv() (*HashPolicy, error) {
+	p := DefaultHashPolicy()
+	if alg := os.Getenv("PASSWORD_HASH_ALGORITHM"); alg != "" {
+		p.Algorithm = strings.ToLower(alg)
+	}
+	if p.Algorithm != BcryptAlgorithm && p.Algorithm != Argon2idAlgorithm {
+		return nil, ErrUnknownHashAlgorithm
+	}
+	if n, err := strconv.Atoi(os.Getenv("BCRYPT_COST")); err == nil {
+		if n < bcrypt.MinCost || n > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		p.BcryptCost = n
+	}
+	if n, err := strconv.ParseUint(os.Getenv("ARGON2_TIME"), 10, 32); err == nil && n > 0 {
+		p.Argon2Time = uint32(n)
+	}
+	if n, err := strconv.ParseUint(os.Getenv("ARGON2_MEMORY"), 10, 32); err == nil && n > 0 {
+		p.Argon2Memory = uint32(n)
+	}
+	if n, err := strconv.ParseUint(os.Getenv("ARGON2_THREADS"), 10, 8); err == nil && n > 0 {
+		p.Argon2Threads = uint8(n)
+	}
+	return p, nil
+}
+
+// Hash hashes password with the current hash policy
+func Hash(password string) ([]byte, error) {
+	return hashPolicy.Hash(password)
+}
+
+func (p *HashPolicy) Hash(password string) ([]byte, error) {
+	switch p.Algorithm {
+	case BcryptAlgorithm:
+		return bcrypt.GenerateFromPassword([]byte(password), p.BcryptCost)
+	case Argon2idAlgorithm:
+		salt := make([]byte, argon2SaltBytes)
+		_, err := rand.Read(salt)
+		if err != nil {
+			return nil, err
+		}
+		params := &argon2Params{
+			version: argon2.Version,
+			time:    p.Argon2Time,
+			memory:  p.Argon2Memory,
+			threads: p.Argon2Threads,
+			salt:    salt,
+		}
+		params.key = argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, p.Argon2KeyLen)
+		return []byte(params.String()), nil
+	}
+	return nil, ErrUnknownHashAlgorithm
+}
+
+// VerifyPassword checks password against a hash of any supported algorithm. A
+// wrong password is always bcrypt.ErrMismatchedHashAndPassword.
+func VerifyPassword(hashedPassword, password string) error {
+	if !strings.HasPrefix(hashedPassword, argon2idPrefix) {
+		return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	}
+	params, err := parseArgon2id(hashedPassword)
+	if err != nil {
+		return err
+	}
+	key := argon2.IDKey([]byte(password), params.salt, params.time, params.memory, params.threads, uint32(len(params.key)))
+	if subtle.ConstantTimeCompare(key, params.key) != 1 {
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+// dummyHash is a hash of a random password made with the policy it belongs to
+var dummyHash struct {
+	sync.Mutex
+	policy *HashPolicy
+	hash   string
+}
+
+// VerifyDummyPassword costs as much as VerifyPassword against a hash of the
+// current policy and always fails. Logins for unknown users use it so they take
+// as long as a wrong password.
+func VerifyDummyPassword(password string) error {
+	dummyHash.Lock()
+	if dummyHash.policy != hashPolicy {
+		hash, err := hashPolicy.Hash(RandToken(16))
+		if err != nil {
+			dummyHash.Unlock()
+			return err
+		}
+		dummyHash.policy, dummyHash.hash = hashPolicy, string(hash)
+	}
+	hash := dummyHash.hash
+	dummyHash.Unlock()
+	VerifyPassword(hash, password)
+	return bcrypt.ErrMismatchedHashAndPassword
+}
+
+// NeedsRehash reports whether hashedPassword was made with another algorithm
+// or weaker parameters than the current hash policy
+func NeedsRehash(hashedPassword string) bool {
+	return hashPolicy.NeedsRehash(hashedPassword)
+}
+
+func (p *HashPolicy) NeedsRehash(hashedPassword string) bool {
+	if strings.HasPrefix(hashedPassword, argon2idPrefix) {
+		if p.Algorithm != Argon2idAlgorithm {
+			return true
+		}
+		params, err := parseArgon2id(hashedPassword)
+		if err != nil {
+			return false
+		}
+		return params.version < argon2.Version ||
+			params.time < p.Argon2Time ||
+			params.memory < p.Argon2Memory ||
+			params.threads < p.Argon2Threads ||
+			uint32(len(params.key)) < p.Argon2KeyLen
+	}
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	if err != nil {
+		return false
+	}
+	return p.Algorithm != BcryptAlgorithm || cost < p.BcryptCost
+}
+
+type argon2Params struct {
+	version int
+	time    uint32
+	memory  uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+func (a *argon2Params) String() string {
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, a.version, a.memory, a.time, a.threads,
+		base64.RawStdEncoding.EncodeToString(a.salt), base64.RawStdEncoding.EncodeToString(a.key))
+}
+
+func parseArgon2id(hashedPassword string) (*argon2Params, error) {
+	// "", "argon2id", "v=19", "m=65536,t=1,p=4", salt, key
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 {
+		return nil, ErrMalformedHash
+	}
+	params := &argon2Params{}
+	_, err := fmt.Sscanf(parts[2], "v=%d", &params.version)
+	if err != nil {
+		return nil, ErrMalformedHash
+	}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads)
+	if err != nil {
+		return nil, ErrMalformedHash
+	}
+	params.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, ErrMalformedHash
+	}
+	params.key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(params.key) == 0 {
+		return nil, ErrMalformedHash
+	}
+	return params, nil
+}
@@ -0,0 +1,100 @@
+package helper_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func fastArgon2Policy() *helper.HashPolicy {
+	p := helper.DefaultHashPolicy()
+	p.Algorithm = helper.Argon2idAlgorithm
+	p.Argon2Memory = 1024
+	p.Argon2Threads = 1
+	return p
+}
+
+func TestHashPolicyBcrypt(t *testing.T) {
+	p := helper.DefaultHashPolicy()
+	p.BcryptCost = bcrypt.MinCost
+	hash, err := p.Hash("pass1")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(hash), "$2a$04$"))
+	assert.NoError(t, helper.VerifyPassword(string(hash), "pass1"))
+	assert.Equal(t, bcrypt.ErrMismatchedHashAndPassword, helper.VerifyPassword(string(hash), "pass2"))
+
+	assert.False(t, p.NeedsRehash(string(hash)))
+	p.BcryptCost++
+	assert.True(t, p.NeedsRehash(string(hash)))
+}
+
+func TestHashPolicyArgon2id(t *testing.T) {
+	p := fastArgon2Policy()
+	hash, err := p.Hash("pass1")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(hash), "$argon2id$v=19$m=1024,t=1,p=1$"))
+	assert.NoError(t, helper.VerifyPassword(string(hash), "pass1"))
+	assert.Equal(t, bcrypt.ErrMismatchedHashAndPassword, helper.VerifyPassword(string(hash), "pass2"))
+
+	assert.False(t, p.NeedsRehash(string(hash)))
+	p.Argon2Time = 2
+	assert.True(t, p.NeedsRehash(string(hash)))
+	// lowering the policy does not downgrade existing hashes
+	p = fastArgon2Policy()
+	p.Argon2Memory = 512
+	assert.False(t, p.NeedsRehash(string(hash)))
+}
+
+func TestHashPolicyAlgorithmSwitch(t *testing.T) {
+	bcryptPolicy := helper.DefaultHashPolicy()
+	bcryptPolicy.BcryptCost = bcrypt.MinCost
+	bcryptHash, err := bcryptPolicy.Hash("pass1")
+	assert.NoError(t, err)
+	argonPolicy := fastArgon2Policy()
+	argonHash, err := argonPolicy.Hash("pass1")
+	assert.NoError(t, err)
+
+	assert.True(t, argonPolicy.NeedsRehash(string(bcryptHash)))
+	assert.True(t, bcryptPolicy.NeedsRehash(string(argonHash)))
+}
+
+func TestVerifyPasswordMalformedArgon2id(t *testing.T) {
+	assert.Equal(t, helper.ErrMalformedHash, helper.VerifyPassword("$argon2id$v=19$m=1024", "pass1"))
+	assert.False(t, helper.DefaultHashPolicy().NeedsRehash("not a hash"))
+}
+
+func TestHashPolicyFromEnv(t *testing.T) {
+	os.Setenv("PASSWORD_HASH_ALGORITHM", "argon2id")
+	os.Setenv("ARGON2_MEMORY", "32768")
+	defer os.Unsetenv("PASSWORD_HASH_ALGORITHM")
+	defer os.Unsetenv("ARGON2_MEMORY")
+
+	p, err := helper.HashPolicyFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, helper.Argon2idAlgorithm, p.Algorithm)
+	assert.Equal(t, uint32(32768), p.Argon2Memory)
+
+	os.Setenv("PASSWORD_HASH_ALGORITHM", "md5")
+	_, err = helper.HashPolicyFromEnv()
+	assert.Equal(t, helper.ErrUnknownHashAlgorithm, err)
+
+	os.Setenv("PASSWORD_HASH_ALGORITHM", "bcrypt")
+	os.Setenv("BCRYPT_COST", "99")
+	defer os.Unsetenv("BCRYPT_COST")
+	_, err = helper.HashPolicyFromEnv()
+	assert.Error(t, err)
+}
+
+func TestVerifyDummyPassword(t *testing.T) {
+	defer helper.SetHashPolicy(helper.DefaultHashPolicy())
+	p := helper.DefaultHashPolicy()
+	p.BcryptCost = bcrypt.MinCost
+	helper.SetHashPolicy(p)
+	assert.Equal(t, bcrypt.ErrMismatchedHashAndPassword, helper.VerifyDummyPassword("pass1"))
+	helper.SetHashPolicy(fastArgon2Policy())
+	assert.Equal(t, bcrypt.ErrMismatchedHashAndPassword, helper.VerifyDummyPassword("pass1"))
+}
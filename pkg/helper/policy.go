@@ -0,0 +1,250 @@
+package helper
+
+import (
+	"bufio"
+	"fmt"
+	"net/mail"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// the user.username column is varchar(40)
+	usernameColumnLength = 40
+	// bcrypt ignores everything past 72 bytes
+	bcryptMaxPasswordLength = 72
+	// the user.email column is varchar(240)
+	emailColumnLength = 240
+)
+
+// FieldError is one rule an input field broke
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors lists every broken rule so the client can show them next to
+// the fields
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, 0, len(v))
+	for _, e := range v {
+		messages = append(messages, e.Field+": "+e.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (v ValidationErrors) add(field, format string, args ...interface{}) ValidationErrors {
+	return append(v, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// CredentialPolicy holds the rules for usernames and new passwords
+type CredentialPolicy struct {
+	MinPasswordLength int
+	RequireUpper      bool
+	RequireLower      bool
+	RequireLetter     bool
+	RequireDigit      bool
+	RequireSymbol     bool
+	MinUsernameLength int
+	MaxUsernameLength int
+	UsernamePattern   *regexp.Regexp
+	ReservedUsernames map[string]bool
+	BreachedPasswords map[string]bool
+}
+
+var credentialPolicy = DefaultCredentialPolicy()
+
+func SetCredentialPolicy(p *CredentialPolicy) {
+	credentialPolicy = p
+}
+
+func DefaultCredentialPolicy() *CredentialPolicy {
+	return &CredentialPolicy{
+		MinPasswordLength: 8,
+		RequireLetter:     true,
+		RequireDigit:      true,
+		MinUsernameLength: 3,
+		MaxUsernameLength: usernameColumnLength,
+		UsernamePattern:   regexp.MustCompile(`^[a-zA-Z0-9._-]+$`),
+		ReservedUsernames: toSet([]string{"admin", "administrator", "root", "system", "support", "null"}),
+		BreachedPasswords: map[string]bool{},
+	}
+}
+
+// CredentialPolicyFromEnv starts from the default policy and applies the
+// PASSWORD_*, USERNAME_*, RESERVED_USERNAMES and BREACHED_PASSWORDS_FILE settings
+func CredentialPolicyFromEnv() (*CredentialPolicy, error) {
+	p := DefaultCredentialPolicy()
+	if n, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil {
+		p.MinPasswordLength = n
+	}
+	if classes := os.Getenv("PASSWORD_REQUIRED_CLASSES"); classes != "" {
+		p.RequireUpper, p.RequireLower, p.RequireLetter, p.RequireDigit, p.RequireSymbol = false, false, false, false, false
+		for _, class := range strings.Split(classes, ",") {
+			switch strings.TrimSpace(class) {
+			case "upper":
+				p.RequireUpper = true
+			case "lower":
+				p.RequireLower = true
+			case "letter":
+				p.RequireLetter = true
+			case "digit":
+				p.RequireDigit = true
+			case "symbol":
+				p.RequireSymbol = true
+			case "none":
+			default:
+				return nil, fmt.Errorf("unknown password character class %q", class)
+			}
+		}
+	}
+	if n, err := strconv.Atoi(os.Getenv("USERNAME_MIN_LENGTH")); err == nil {
+		p.MinUsernameLength = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("USERNAME_MAX_LENGTH")); err == nil && n < usernameColumnLength {
+		p.MaxUsernameLength = n
+	}
+	if pattern := os.Getenv("USERNAME_PATTERN"); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		p.UsernamePattern = re
+	}
+	if reserved := os.Getenv("RESERVED_USERNAMES"); reserved != "" {
+		p.ReservedUsernames = toSet(strings.Split(reserved, ","))
+	}
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		breached, err := LoadBreachedPasswords(path)
+		if err != nil {
+			return nil, err
+		}
+		p.BreachedPasswords = breached
+	}
+	return p, nil
+}
+
+// LoadBreachedPasswords reads a list with one password per line
+func LoadBreachedPasswords(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	breached := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			breached[strings.ToLower(line)] = true
+		}
+	}
+	return breached, scanner.Err()
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			set[v] = true
+		}
+	}
+	return set
+}
+
+func (p *CredentialPolicy) ValidateUsername(username string) ValidationErrors {
+	var errs ValidationErrors
+	length := utf8.RuneCountInString(username)
+	if length == 0 {
+		return errs.add("username", "Required Username")
+	}
+	if length < p.MinUsernameLength {
+		errs = errs.add("username", "Must be at least %d characters", p.MinUsernameLength)
+	}
+	if length > p.MaxUsernameLength {
+		errs = errs.add("username", "Must be at most %d characters", p.MaxUsernameLength)
+	}
+	if p.UsernamePattern != nil && !p.UsernamePattern.MatchString(username) {
+		errs = errs.add("username", "Contains characters that are not allowed")
+	}
+	if p.ReservedUsernames[strings.ToLower(username)] {
+		errs = errs.add("username", "Is reserved")
+	}
+	return errs
+}
+
+func (p *CredentialPolicy) ValidatePassword(username, password string) ValidationErrors {
+	var errs ValidationErrors
+	if password == "" {
+		return errs.add("password", "Required Password")
+	}
+	if utf8.RuneCountInString(password) < p.MinPasswordLength {
+		errs = errs.add("password", "Must be at least %d characters", p.MinPasswordLength)
+	}
+	if len(password) > bcryptMaxPasswordLength {
+		errs = errs.add("password", "Must be at most %d bytes", bcryptMaxPasswordLength)
+	}
+	var hasUpper, hasLower, hasLetter, hasDigit, hasSymbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			hasUpper, hasLetter = true, true
+		case unicode.IsLower(c):
+			hasLower, hasLetter = true, true
+		case unicode.IsLetter(c):
+			hasLetter = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		errs = errs.add("password", "Must contain an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		errs = errs.add("password", "Must contain a lowercase letter")
+	}
+	if p.RequireLetter && !hasLetter {
+		errs = errs.add("password", "Must contain a letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		errs = errs.add("password", "Must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		errs = errs.add("password", "Must contain a symbol")
+	}
+	if username != "" && strings.EqualFold(username, password) {
+		errs = errs.add("password", "Must not be the same as the username")
+	}
+	if p.BreachedPasswords[strings.ToLower(password)] {
+		errs = errs.add("password", "Appears in a list of breached passwords")
+	}
+	return errs
+}
+
+// ValidateEmail checks a plain address like user@example.com, display names
+// are not accepted. An empty email is only an error when required.
+func ValidateEmail(email string, required bool) ValidationErrors {
+	var errs ValidationErrors
+	if email == "" {
+		if required {
+			return errs.add("email", "Required Email")
+		}
+		return errs
+	}
+	if len(email) > emailColumnLength {
+		return errs.add("email", "Must be at most %d characters", emailColumnLength)
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || !strings.Contains(addr.Address, "@") {
+		errs = errs.add("email", "Must be a valid email address")
+	}
+	return errs
+}
@@ -0,0 +1,103 @@
+package helper_test
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func fieldMessages(errs helper.ValidationErrors, field string) []string {
+	messages := []string{}
+	for _, e := range errs {
+		if e.Field == field {
+			messages = append(messages, e.Message)
+		}
+	}
+	return messages
+}
+
+func TestValidateUsername(t *testing.T) {
+	p := helper.DefaultCredentialPolicy()
+	assert.Empty(t, p.ValidateUsername("user_1.x"))
+	assert.Equal(t, []string{"Required Username"}, fieldMessages(p.ValidateUsername(""), "username"))
+	assert.Len(t, p.ValidateUsername("ab"), 1)
+	assert.Len(t, p.ValidateUsername(strings.Repeat("a", 41)), 1)
+	assert.Len(t, p.ValidateUsername("user name"), 1)
+	assert.Equal(t, []string{"Is reserved"}, fieldMessages(p.ValidateUsername("Admin"), "username"))
+}
+
+func TestValidatePassword(t *testing.T) {
+	p := helper.DefaultCredentialPolicy()
+	assert.Empty(t, p.ValidatePassword("user1", "correct1horse"))
+	assert.Equal(t, []string{"Required Password"}, fieldMessages(p.ValidatePassword("user1", ""), "password"))
+	assert.Len(t, p.ValidatePassword("user1", "abc1"), 1)
+	assert.Len(t, p.ValidatePassword("user1", "abcdefghij"), 1)
+	assert.Len(t, p.ValidatePassword("user1", strings.Repeat("a1", 40)), 1)
+	assert.Len(t, p.ValidatePassword("password1", "Password1"), 1)
+
+	p.RequireUpper = true
+	p.RequireSymbol = true
+	assert.Len(t, p.ValidatePassword("user1", "correct1horse"), 2)
+	assert.Empty(t, p.ValidatePassword("user1", "Correct1horse!"))
+}
+
+func TestValidatePasswordBreached(t *testing.T) {
+	f, err := ioutil.TempFile("", "breached")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	f.WriteString("password1\nqwerty123\n")
+	f.Close()
+
+	breached, err := helper.LoadBreachedPasswords(f.Name())
+	assert.NoError(t, err)
+	p := helper.DefaultCredentialPolicy()
+	p.BreachedPasswords = breached
+	assert.Equal(t, []string{"Appears in a list of breached passwords"}, fieldMessages(p.ValidatePassword("user1", "QWERTY123"), "password"))
+}
+
+func TestValidateActions(t *testing.T) {
+	helper.SetCredentialPolicy(helper.DefaultCredentialPolicy())
+
+	assert.Nil(t, helper.Validate("login", "admin", "x"))
+	err := helper.Validate("login", "", "")
+	assert.Len(t, err.(helper.ValidationErrors), 2)
+
+	err = helper.Validate("register", "admin", "short")
+	assert.NotEmpty(t, fieldMessages(err.(helper.ValidationErrors), "username"))
+	assert.NotEmpty(t, fieldMessages(err.(helper.ValidationErrors), "password"))
+	assert.Nil(t, helper.Validate("register", "user1", "correct1horse"))
+}
+
+func TestCredentialPolicyFromEnv(t *testing.T) {
+	os.Setenv("PASSWORD_MIN_LENGTH", "12")
+	os.Setenv("PASSWORD_REQUIRED_CLASSES", "upper,symbol")
+	os.Setenv("USERNAME_MAX_LENGTH", "100")
+	defer os.Unsetenv("PASSWORD_MIN_LENGTH")
+	defer os.Unsetenv("PASSWORD_REQUIRED_CLASSES")
+	defer os.Unsetenv("USERNAME_MAX_LENGTH")
+
+	p, err := helper.CredentialPolicyFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, 12, p.MinPasswordLength)
+	assert.True(t, p.RequireUpper && p.RequireSymbol)
+	assert.False(t, p.RequireDigit)
+	// can't go past the column size
+	assert.Equal(t, 40, p.MaxUsernameLength)
+
+	os.Setenv("PASSWORD_REQUIRED_CLASSES", "emoji")
+	_, err = helper.CredentialPolicyFromEnv()
+	assert.Error(t, err)
+}
+
+func TestValidateEmail(t *testing.T) {
+	assert.Empty(t, helper.ValidateEmail("user1@example.com", true))
+	assert.Empty(t, helper.ValidateEmail("", false))
+	assert.Equal(t, []string{"Required Email"}, fieldMessages(helper.ValidateEmail("", true), "email"))
+	assert.Len(t, helper.ValidateEmail("user1", false), 1)
+	assert.Len(t, helper.ValidateEmail("User <user1@example.com>", false), 1)
+	assert.Len(t, helper.ValidateEmail(strings.Repeat("a", 240)+"@example.com", false), 1)
+}
@@ -2,23 +2,19 @@ package helper
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"strconv"
 	"strings"
-
-	"golang.org/x/crypto/bcrypt"
+	"time"
 )
 
-func Hash(password string) ([]byte, error) {
-	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-}
-
-func VerifyPassword(hashedPassword, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
-}
-
 func HashingPassword(plain_password string) (string, error) {
 	hashedPassword, err := Hash(plain_password)
 	if err != nil {
@@ -27,14 +23,28 @@ func HashingPassword(plain_password string) (string, error) {
 	return string(hashedPassword), nil
 }
 
+// Validate checks credentials for an action. login only needs both fields to be
+// present, register, username and password apply the credential policy. The
+// returned error is a ValidationErrors.
 func Validate(action, username, password string) error {
-	if strings.ToLower(action) == "login" || strings.ToLower(action) == "register" {
+	var errs ValidationErrors
+	switch strings.ToLower(action) {
+	case "login":
 		if username == "" {
-			return errors.New("Required Username")
+			errs = errs.add("username", "Required Username")
 		}
 		if password == "" {
-			return errors.New("Required Password")
+			errs = errs.add("password", "Required Password")
 		}
+	case "register":
+		errs = append(credentialPolicy.ValidateUsername(username), credentialPolicy.ValidatePassword(username, password)...)
+	case "username":
+		errs = credentialPolicy.ValidateUsername(username)
+	case "password":
+		errs = credentialPolicy.ValidatePassword(username, password)
+	}
+	if len(errs) > 0 {
+		return errs
 	}
 	return nil
 }
@@ -56,22 +66,128 @@ func FormatError(err string) error {
 	return errors.New("Incorrect Details")
 }
 
-func RemovePicture(profile_image string) error {
-	if profile_image == "" {
-		return nil
+func RandToken(len int) string {
+	b := make([]byte, len)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
+
+// HashToken returns the hex sha256 of a random token. Tokens are long enough
+// that a fast hash is fine for storing them.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// DurationFromEnv reads a Go duration from key. Unset or invalid values, the
+// latter logged, give fallback.
+func DurationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
 	}
-	path := os.Getenv("IMAGE_PATH") + profile_image
-	// log.Println("PATH TO REMOVE PICT:", path)
-	err := os.Remove(path)
+	d, err := time.ParseDuration(value)
 	if err != nil {
-		log.Println("os remove err", err.Error())
-		return err
+		log.Println("invalid duration for", key, "err:", err.Error())
+		return fallback
 	}
-	return nil
+	return d
 }
 
-func RandToken(len int) string {
-	b := make([]byte, len)
-	rand.Read(b)
-	return fmt.Sprintf("%x", b)
+// FormatUnixNano writes t as <unix seconds>.<nanoseconds> for storing in redis
+func FormatUnixNano(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+// ParseUnixNano reads a time written by FormatUnixNano. Whole unix seconds,
+// as stored before the nanoseconds were kept, are read too.
+func ParseUnixNano(value string) (time.Time, error) {
+	parts := strings.SplitN(value, ".", 2)
+	seconds, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var nanos int64
+	if len(parts) == 2 {
+		nanos, err = strconv.ParseInt((parts[1] + "000000000")[:9], 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.Unix(seconds, nanos), nil
+}
+
+var trustedProxies []*net.IPNet
+
+// SetTrustedProxies sets the proxies ClientIP takes X-Forwarded-For from
+func SetTrustedProxies(proxies []*net.IPNet) {
+	trustedProxies = proxies
+}
+
+// TrustedProxiesFromEnv reads TRUSTED_PROXIES, the comma separated ips and
+// CIDRs of the proxies in front of the app
+func TrustedProxiesFromEnv() ([]*net.IPNet, error) {
+	proxies := []*net.IPNet{}
+	for _, field := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !strings.Contains(field, "/") {
+			ip := net.ParseIP(field)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry %q", field)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, proxy, err := net.ParseCIDR(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry %q", field)
+		}
+		proxies = append(proxies, proxy)
+	}
+	return proxies, nil
+}
+
+func trustedProxy(ip net.IP) bool {
+	for _, proxy := range trustedProxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the caller. X-Forwarded-For is only read
+// when the request comes from a trusted proxy, otherwise any client could pick
+// its own ip. Every trusted proxy appends the address it got the request from,
+// so the rightmost hop that isn't a trusted proxy is the client.
+func ClientIP(r *http.Request) string {
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+	ip := net.ParseIP(client)
+	if ip == nil || !trustedProxy(ip) {
+		return client
+	}
+	hops := strings.Split(strings.Join(r.Header["X-Forwarded-For"], ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		ip = net.ParseIP(hop)
+		// A HOP THAT IS NO IP WASN'T WRITTEN BY A PROXY WE TRUST
+		if ip == nil {
+			break
+		}
+		client = hop
+		if !trustedProxy(ip) {
+			break
+		}
+	}
+	return client
 }
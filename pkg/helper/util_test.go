@@ -0,0 +1,59 @@
+package helper_test
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/famkampm/nentrytask/pkg/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	os.Setenv("TRUSTED_PROXIES", "10.0.0.1, 192.168.0.0/16")
+	defer os.Unsetenv("TRUSTED_PROXIES")
+	proxies, err := helper.TrustedProxiesFromEnv()
+	assert.NoError(t, err)
+	helper.SetTrustedProxies(proxies)
+	defer helper.SetTrustedProxies(nil)
+
+	cases := []struct {
+		remote    string
+		forwarded string
+		want      string
+	}{
+		// NOT FROM A PROXY, THE HEADER IS THE CLIENT'S OWN
+		{"203.0.113.9:4000", "1.2.3.4", "203.0.113.9"},
+		{"10.0.0.1:4000", "1.2.3.4", "1.2.3.4"},
+		{"10.0.0.1:4000", "", "10.0.0.1"},
+		// THE CLIENT PREPENDED A FAKE HOP, THE TRUSTED PROXIES APPENDED THE REST
+		{"10.0.0.1:4000", "1.2.3.4, 198.51.100.7, 192.168.1.1", "198.51.100.7"},
+		{"10.0.0.1:4000", "192.168.1.2, 192.168.1.1", "192.168.1.2"},
+		{"10.0.0.1:4000", "garbage, 192.168.1.1", "192.168.1.1"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = c.remote
+		if c.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+		assert.Equal(t, c.want, helper.ClientIP(r), c.forwarded)
+	}
+}
+
+func TestTrustedProxiesFromEnvInvalid(t *testing.T) {
+	os.Setenv("TRUSTED_PROXIES", "10.0.0.1,proxy")
+	defer os.Unsetenv("TRUSTED_PROXIES")
+	_, err := helper.TrustedProxiesFromEnv()
+	assert.Error(t, err)
+}
+
+func TestDurationFromEnv(t *testing.T) {
+	defer os.Unsetenv("TEST_DURATION")
+	assert.Equal(t, time.Minute, helper.DurationFromEnv("TEST_DURATION", time.Minute))
+	os.Setenv("TEST_DURATION", "90s")
+	assert.Equal(t, 90*time.Second, helper.DurationFromEnv("TEST_DURATION", time.Minute))
+	os.Setenv("TEST_DURATION", "soon")
+	assert.Equal(t, time.Minute, helper.DurationFromEnv("TEST_DURATION", time.Minute))
+}
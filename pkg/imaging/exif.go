@@ -0,0 +1,151 @@
+package imaging
+
+import (
+	"bufio"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"io"
+)
+
+// exifOrientation reads the EXIF orientation of a JPEG, 1 when it has none.
+// Only the segments before the image data are read.
+func exifOrientation(r io.Reader) int {
+	br := bufio.NewReader(r)
+	var marker [2]byte
+	_, err := io.ReadFull(br, marker[:])
+	if err != nil || marker != [2]byte{0xFF, 0xD8} {
+		return 1
+	}
+	for {
+		_, err = io.ReadFull(br, marker[:])
+		if err != nil || marker[0] != 0xFF {
+			return 1
+		}
+		// MARKERS MAY BE PADDED WITH FILL BYTES
+		for marker[1] == 0xFF {
+			marker[1], err = br.ReadByte()
+			if err != nil {
+				return 1
+			}
+		}
+		switch {
+		case marker[1] == 0xDA || marker[1] == 0xD9:
+			// START OF SCAN OR END OF IMAGE, NO METADATA FOLLOWS
+			return 1
+		case marker[1] == 0x01 || marker[1] >= 0xD0 && marker[1] <= 0xD7:
+			// MARKERS WITHOUT A LENGTH
+			continue
+		}
+		var length [2]byte
+		_, err = io.ReadFull(br, length[:])
+		if err != nil {
+			return 1
+		}
+		n := int(binary.BigEndian.Uint16(length[:])) - 2
+		if n < 0 {
+			return 1
+		}
+		if marker[1] != 0xE1 {
+			_, err = br.Discard(n)
+			if err != nil {
+				return 1
+			}
+			continue
+		}
+		segment := make([]byte, n)
+		_, err = io.ReadFull(br, segment)
+		if err != nil {
+			return 1
+		}
+		if orientation := orientationFromExif(segment); orientation != 0 {
+			return orientation
+		}
+	}
+}
+
+// orientationFromExif looks for the orientation tag in the first IFD of an
+// APP1 segment, 0 when the segment is no EXIF or has none
+func orientationFromExif(segment []byte) int {
+	if len(segment) < 14 || string(segment[:6]) != "Exif\x00\x00" {
+		return 0
+	}
+	tiff := segment[6:]
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return 0
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		// THE ORIENTATION IS A SINGLE SHORT
+		if order.Uint16(tiff[entry:]) != 0x0112 || order.Uint16(tiff[entry+2:]) != 3 {
+			continue
+		}
+		orientation := int(order.Uint16(tiff[entry+8:]))
+		if orientation < 1 || orientation > 8 {
+			return 0
+		}
+		return orientation
+	}
+	return 0
+}
+
+// orient turns img the way EXIF orientation asks for, so it displays upright
+// once the EXIF is gone
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if orientation >= 5 {
+		dst = image.NewRGBA(image.Rect(0, 0, h, w))
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dy*dst.Stride+dx*4:dy*dst.Stride+dx*4+4], src.Pix[y*src.Stride+x*4:])
+		}
+	}
+	return dst
+}
+
+// swapsSides tells whether orientation turns the image by a quarter
+func swapsSides(orientation int) bool {
+	return orientation >= 5 && orientation <= 8
+}
@@ -0,0 +1,105 @@
+package imaging_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/famkampm/nentrytask/pkg/imaging"
+	"github.com/stretchr/testify/assert"
+)
+
+// jpegWithOrientation encodes a 16x8 image, red on the left and blue on the
+// right, with an EXIF segment that holds orientation and a GPS note
+func jpegWithOrientation(t *testing.T, order binary.ByteOrder, orientation uint16) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			img.Set(x, y, color.RGBA{255, 0, 0, 255})
+			if x >= 8 {
+				img.Set(x, y, color.RGBA{0, 0, 255, 255})
+			}
+		}
+	}
+	buf := &bytes.Buffer{}
+	assert.NoError(t, jpeg.Encode(buf, img, &jpeg.Options{Quality: 100}))
+
+	tiff := &bytes.Buffer{}
+	if order == binary.LittleEndian {
+		tiff.WriteString("II")
+	} else {
+		tiff.WriteString("MM")
+	}
+	binary.Write(tiff, order, uint16(42))
+	binary.Write(tiff, order, uint32(8))
+	binary.Write(tiff, order, uint16(1))
+	// ORIENTATION, SHORT, ONE VALUE
+	binary.Write(tiff, order, []uint16{0x0112, 3})
+	binary.Write(tiff, order, uint32(1))
+	binary.Write(tiff, order, []uint16{orientation, 0})
+	binary.Write(tiff, order, uint32(0))
+	tiff.WriteString("GPS 52.5200N 13.4050E")
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+
+	data := buf.Bytes()
+	out := &bytes.Buffer{}
+	out.Write(data[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(out, binary.BigEndian, uint16(len(segment)+2))
+	out.Write(segment)
+	out.Write(data[2:])
+	return out.Bytes()
+}
+
+func isRed(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r > 0xC000 && g < 0x4000 && b < 0x4000
+}
+
+func isBlue(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r < 0x4000 && g < 0x4000 && b > 0xC000
+}
+
+func TestProcessAppliesOrientation(t *testing.T) {
+	p := imaging.DefaultPolicy()
+	for _, c := range []struct {
+		order       binary.ByteOrder
+		orientation uint16
+		bounds      image.Rectangle
+		// WHERE THE RED HALF ENDS UP
+		red, blue image.Point
+	}{
+		{binary.BigEndian, 1, image.Rect(0, 0, 16, 8), image.Pt(2, 4), image.Pt(13, 4)},
+		{binary.LittleEndian, 2, image.Rect(0, 0, 16, 8), image.Pt(13, 4), image.Pt(2, 4)},
+		{binary.BigEndian, 3, image.Rect(0, 0, 16, 8), image.Pt(13, 4), image.Pt(2, 4)},
+		// A QUARTER CLOCKWISE, THE LEFT SIDE GOES ON TOP
+		{binary.LittleEndian, 6, image.Rect(0, 0, 8, 16), image.Pt(4, 2), image.Pt(4, 13)},
+		{binary.BigEndian, 8, image.Rect(0, 0, 8, 16), image.Pt(4, 13), image.Pt(4, 2)},
+	} {
+		data := jpegWithOrientation(t, c.order, c.orientation)
+		set, err := p.Process(data)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(set.Original), "Exif", c.orientation)
+		assert.NotContains(t, string(set.Original), "GPS", c.orientation)
+		img, err := jpeg.Decode(bytes.NewReader(set.Original))
+		assert.NoError(t, err)
+		assert.Equal(t, c.bounds, img.Bounds(), c.orientation)
+		assert.True(t, isRed(img.At(c.red.X, c.red.Y)), c.orientation)
+		assert.True(t, isBlue(img.At(c.blue.X, c.blue.Y)), c.orientation)
+	}
+}
+
+func TestProcessOrientationSwapsLimits(t *testing.T) {
+	p := imaging.DefaultPolicy()
+	p.MaxWidth = 10
+	p.MaxHeight = 16
+	_, err := p.Process(jpegWithOrientation(t, binary.BigEndian, 1))
+	assert.Equal(t, imaging.ErrDimensionsTooLarge, err)
+	// UPRIGHT IT IS 8 WIDE
+	_, err = p.Process(jpegWithOrientation(t, binary.BigEndian, 6))
+	assert.NoError(t, err)
+}
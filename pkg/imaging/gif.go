@@ -0,0 +1,158 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+)
+
+// thumbnailPalette quantizes the frames of animated thumbnails, with a
+// transparent entry for what the frames don't cover
+var thumbnailPalette = append(color.Palette{color.Transparent}, palette.WebSafe...)
+
+var errGIFBlock = errors.New("unknown gif block")
+
+// gifFrames counts the frames of a GIF by walking its blocks, without
+// decompressing them
+func gifFrames(r io.Reader) (int, error) {
+	br := bufio.NewReader(r)
+	// HEADER AND LOGICAL SCREEN DESCRIPTOR
+	header := make([]byte, 13)
+	_, err := io.ReadFull(br, header)
+	if err != nil {
+		return 0, err
+	}
+	err = skipColorTable(br, header[10])
+	if err != nil {
+		return 0, err
+	}
+	frames := 0
+	for {
+		block, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch block {
+		case 0x21:
+			// EXTENSION: LABEL AND SUB-BLOCKS
+			_, err = br.ReadByte()
+			if err == nil {
+				err = skipSubBlocks(br)
+			}
+		case 0x2C:
+			// IMAGE DESCRIPTOR, COLOR TABLE, LZW CODE SIZE AND SUB-BLOCKS
+			descriptor := make([]byte, 9)
+			_, err = io.ReadFull(br, descriptor)
+			if err == nil {
+				err = skipColorTable(br, descriptor[8])
+			}
+			if err == nil {
+				_, err = br.ReadByte()
+			}
+			if err == nil {
+				err = skipSubBlocks(br)
+			}
+			frames++
+		case 0x3B:
+			return frames, nil
+		default:
+			return 0, errGIFBlock
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+func skipColorTable(br *bufio.Reader, flags byte) error {
+	if flags&0x80 == 0 {
+		return nil
+	}
+	_, err := br.Discard(3 << ((flags & 0x07) + 1))
+	return err
+}
+
+func skipSubBlocks(br *bufio.Reader) error {
+	for {
+		size, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		if size == 0 {
+			return nil
+		}
+		_, err = br.Discard(int(size))
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// processAnimated keeps every frame of a GIF. Frames are counted before any
+// is decoded: all of them together may take as much memory as a still image
+// of the largest dimensions.
+func (p *Policy) processAnimated(r io.ReadSeeker, config image.Config, frames int) (*Set, error) {
+	if frames > p.MaxFrames || frames*config.Width*config.Height > 4*p.MaxWidth*p.MaxHeight {
+		return nil, ErrDimensionsTooLarge
+	}
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	set := &Set{Extension: ".gif", Thumbnails: map[int][]byte{}}
+	// RE-ENCODING KEEPS THE FRAMES, DELAYS AND LOOP COUNT AND DROPS THE REST
+	buf := &bytes.Buffer{}
+	err = gif.EncodeAll(buf, g)
+	if err != nil {
+		return nil, err
+	}
+	set.Original = buf.Bytes()
+
+	thumbnails := map[int]*gif.GIF{}
+	for _, size := range p.Sizes {
+		thumbnails[size] = &gif.GIF{LoopCount: g.LoopCount}
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, config.Width, config.Height))
+	for i, frame := range g.Image {
+		disposal := byte(gif.DisposalNone)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(canvas.Bounds())
+			copy(previous.Pix, canvas.Pix)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		for _, size := range p.Sizes {
+			// EVERY THUMBNAIL FRAME IS WHOLE AND CLEARED AFTER IT IS SHOWN
+			thumbnail := Thumbnail(canvas, size)
+			paletted := image.NewPaletted(thumbnail.Bounds(), thumbnailPalette)
+			draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), thumbnail, image.Point{})
+			thumbnails[size].Image = append(thumbnails[size].Image, paletted)
+			thumbnails[size].Delay = append(thumbnails[size].Delay, g.Delay[i])
+			thumbnails[size].Disposal = append(thumbnails[size].Disposal, gif.DisposalBackground)
+		}
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	for size, thumbnail := range thumbnails {
+		buf := &bytes.Buffer{}
+		err = gif.EncodeAll(buf, thumbnail)
+		if err != nil {
+			return nil, err
+		}
+		set.Thumbnails[size] = buf.Bytes()
+	}
+	return set, nil
+}
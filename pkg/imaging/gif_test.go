@@ -0,0 +1,107 @@
+package imaging_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"testing"
+
+	"github.com/famkampm/nentrytask/pkg/imaging"
+	"github.com/stretchr/testify/assert"
+)
+
+// animatedGIF has a red frame covering 20x10, then a blue 5x5 patch in its
+// center and then green ones up to frames
+func animatedGIF(t *testing.T, frames int) []byte {
+	frame := func(rect image.Rectangle, c color.Color) *image.Paletted {
+		img := image.NewPaletted(rect, palette.WebSafe)
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				img.Set(x, y, c)
+			}
+		}
+		return img
+	}
+	g := &gif.GIF{
+		Image: []*image.Paletted{
+			frame(image.Rect(0, 0, 20, 10), color.RGBA{255, 0, 0, 255}),
+			frame(image.Rect(8, 3, 13, 8), color.RGBA{0, 0, 255, 255}),
+		},
+		Delay:    []int{10, 20},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalNone},
+	}
+	for i := 2; i < frames; i++ {
+		g.Image = append(g.Image, frame(image.Rect(8, 3, 13, 8), color.RGBA{0, 255, 0, 255}))
+		g.Delay = append(g.Delay, 10*(i+1))
+		g.Disposal = append(g.Disposal, gif.DisposalNone)
+	}
+	buf := &bytes.Buffer{}
+	assert.NoError(t, gif.EncodeAll(buf, g))
+	return buf.Bytes()
+}
+
+func TestProcessAnimatedGIF(t *testing.T) {
+	p := imaging.DefaultPolicy()
+	set, err := p.Process(animatedGIF(t, 3))
+	assert.NoError(t, err)
+	assert.Equal(t, ".gif", set.Extension)
+
+	original, err := gif.DecodeAll(bytes.NewReader(set.Original))
+	assert.NoError(t, err)
+	assert.Len(t, original.Image, 3)
+	assert.Equal(t, []int{10, 20, 30}, original.Delay)
+
+	thumbnail, err := gif.DecodeAll(bytes.NewReader(set.Thumbnails[64]))
+	assert.NoError(t, err)
+	assert.Len(t, thumbnail.Image, 3)
+	assert.Equal(t, []int{10, 20, 30}, thumbnail.Delay)
+	for _, frame := range thumbnail.Image {
+		assert.Equal(t, image.Rect(0, 0, 64, 64), frame.Bounds())
+	}
+	// THE PATCHES ARE DRAWN OVER THE RED FRAME, NOT ON THEIR OWN
+	assert.True(t, isRed(thumbnail.Image[1].At(5, 5)))
+	assert.True(t, isBlue(thumbnail.Image[1].At(32, 32)))
+	assert.False(t, isBlue(thumbnail.Image[2].At(32, 32)))
+}
+
+func TestProcessGIFFirstFrame(t *testing.T) {
+	p := imaging.DefaultPolicy()
+	p.AnimatedGIF = false
+	set, err := p.Process(animatedGIF(t, 3))
+	assert.NoError(t, err)
+	assert.Equal(t, ".png", set.Extension)
+	img, err := png.Decode(bytes.NewReader(set.Original))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 20, 10), img.Bounds())
+	assert.True(t, isRed(img.At(2, 2)))
+
+	// A SINGLE FRAME IS A STILL IMAGE EITHER WAY
+	buf := &bytes.Buffer{}
+	assert.NoError(t, gif.Encode(buf, testImage(10, 10), nil))
+	set, err = imaging.DefaultPolicy().Process(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, ".png", set.Extension)
+}
+
+func TestProcessAnimatedGIFLimits(t *testing.T) {
+	p := imaging.DefaultPolicy()
+	p.MaxFrames = 2
+	_, err := p.Process(animatedGIF(t, 3))
+	assert.Equal(t, imaging.ErrDimensionsTooLarge, err)
+
+	// 5 FRAMES TAKE MORE THAN 4 STILL IMAGES OF THE LARGEST DIMENSIONS
+	p = imaging.DefaultPolicy()
+	p.MaxWidth = 20
+	p.MaxHeight = 10
+	_, err = p.Process(animatedGIF(t, 4))
+	assert.NoError(t, err)
+	_, err = p.Process(animatedGIF(t, 5))
+	assert.Equal(t, imaging.ErrDimensionsTooLarge, err)
+
+	data := animatedGIF(t, 3)
+	_, err = imaging.DefaultPolicy().Process(data[:len(data)-20])
+	assert.Equal(t, imaging.ErrInvalidImage, err)
+}
@@ -0,0 +1,122 @@
+package imaging
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"image/png"
+	"regexp"
+	"strconv"
+)
+
+// identiconVersion changes the default image names whenever the drawing
+// changes, cached copies of the old drawing are then never served
+const identiconVersion = "v1"
+
+var defaultName = regexp.MustCompile(`^default-([0-9a-f]{16})(?:_([0-9]+))?\.png$`)
+
+// DefaultName is the name of the generated default image of a user without a
+// profile image. It is served like a stored image but never stored.
+func DefaultName(userID int64) string {
+	sum := sha256.Sum256([]byte("identicon:" + identiconVersion + ":" + strconv.FormatInt(userID, 10)))
+	return "default-" + hex.EncodeToString(sum[:8]) + ".png"
+}
+
+// IsDefaultName tells whether name, or the thumbnail name, is a default
+// image
+func IsDefaultName(name string) bool {
+	return defaultName.MatchString(name)
+}
+
+// DefaultImage draws the PNG of a name made by DefaultName or by
+// ThumbnailName of one. The original is as large as the largest thumbnail.
+func DefaultImage(name string) ([]byte, bool) {
+	match := defaultName.FindStringSubmatch(name)
+	if match == nil {
+		return nil, false
+	}
+	size := identiconSize()
+	if match[2] != "" {
+		n, err := strconv.Atoi(match[2])
+		if err != nil || !HasSize(n) {
+			return nil, false
+		}
+		size = n
+	}
+	seed, _ := hex.DecodeString(match[1])
+	buf := &bytes.Buffer{}
+	err := png.Encode(buf, Identicon(seed, size))
+	if err != nil {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+func identiconSize() int {
+	size := 512
+	if len(policy.Sizes) > 0 {
+		size = policy.Sizes[len(policy.Sizes)-1]
+	}
+	return size
+}
+
+// Identicon draws a 5x5 pattern mirrored left to right in one color on a
+// light background, both picked from seed, with a margin of half a cell
+func Identicon(seed []byte, size int) image.Image {
+	sum := sha256.Sum256(seed)
+	foreground := hslColor(float64(int(sum[0])<<8|int(sum[1]))/65536, 0.45+float64(sum[2])/255*0.2, 0.5+float64(sum[3])/255*0.1)
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.RGBA{240, 240, 240, 255}, foreground})
+	var cells [5][5]bool
+	for row := 0; row < 5; row++ {
+		for col := 0; col < 3; col++ {
+			bit := row*3 + col
+			cells[row][col] = sum[4+bit/8]>>(uint(bit)%8)&1 == 1
+			cells[row][4-col] = cells[row][col]
+		}
+	}
+	for y := 0; y < size; y++ {
+		// POSITIONS IN HALF CELLS, THE FIRST AND THE LAST ARE THE MARGIN
+		fy := y * 12 / size
+		if fy < 1 || fy >= 11 {
+			continue
+		}
+		for x := 0; x < size; x++ {
+			fx := x * 12 / size
+			if fx < 1 || fx >= 11 {
+				continue
+			}
+			if cells[(fy-1)/2][(fx-1)/2] {
+				img.Pix[y*img.Stride+x] = 1
+			}
+		}
+	}
+	return img
+}
+
+func hslColor(h, s, l float64) color.RGBA {
+	hue := func(t float64) float64 {
+		if t < 0 {
+			t++
+		}
+		if t > 1 {
+			t--
+		}
+		q := l + s - l*s
+		if l < 0.5 {
+			q = l * (1 + s)
+		}
+		p := 2*l - q
+		switch {
+		case t < 1.0/6:
+			return p + (q-p)*6*t
+		case t < 1.0/2:
+			return q
+		case t < 2.0/3:
+			return p + (q-p)*(2.0/3-t)*6
+		}
+		return p
+	}
+	return color.RGBA{uint8(hue(h+1.0/3) * 255), uint8(hue(h) * 255), uint8(hue(h-1.0/3) * 255), 255}
+}
@@ -0,0 +1,65 @@
+package imaging_test
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/famkampm/nentrytask/pkg/imaging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultName(t *testing.T) {
+	name := imaging.DefaultName(1)
+	assert.Regexp(t, `^default-[0-9a-f]{16}\.png$`, name)
+	assert.Equal(t, name, imaging.DefaultName(1))
+	assert.NotEqual(t, name, imaging.DefaultName(2))
+	assert.True(t, imaging.IsDefaultName(name))
+	assert.True(t, imaging.IsDefaultName(imaging.ThumbnailName(name, 64)))
+	assert.False(t, imaging.IsDefaultName("abc.png"))
+	assert.False(t, imaging.IsDefaultName("default-abc.png"))
+}
+
+func TestDefaultImage(t *testing.T) {
+	name := imaging.DefaultName(1)
+	data, ok := imaging.DefaultImage(name)
+	assert.True(t, ok)
+	img, err := png.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 512, 512), img.Bounds())
+	again, _ := imaging.DefaultImage(name)
+	assert.Equal(t, data, again)
+	other, _ := imaging.DefaultImage(imaging.DefaultName(2))
+	assert.NotEqual(t, data, other)
+
+	data, ok = imaging.DefaultImage(imaging.ThumbnailName(name, 128))
+	assert.True(t, ok)
+	img, err = png.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 128, 128), img.Bounds())
+
+	_, ok = imaging.DefaultImage(imaging.ThumbnailName(name, 100))
+	assert.False(t, ok)
+	_, ok = imaging.DefaultImage("abc.png")
+	assert.False(t, ok)
+}
+
+func TestIdenticon(t *testing.T) {
+	img := imaging.Identicon([]byte("seed"), 60)
+	assert.Equal(t, image.Rect(0, 0, 60, 60), img.Bounds())
+	background := img.At(0, 0)
+	foreground := 0
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 60; x++ {
+			// MIRRORED LEFT TO RIGHT
+			assert.Equal(t, img.At(x, y), img.At(59-x, y))
+			if x < 5 || y < 5 || x >= 55 || y >= 55 {
+				assert.Equal(t, background, img.At(x, y))
+			} else if img.At(x, y) != background {
+				foreground++
+			}
+		}
+	}
+	assert.NotZero(t, foreground)
+}
@@ -0,0 +1,333 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	_ "golang.org/x/image/webp"
+)
+
+// decodedFormats are the image formats uploads may have
+var decodedFormats = map[string]bool{
+	"jpeg": true,
+	"png":  true,
+	"gif":  true,
+	"webp": true,
+}
+
+var (
+	ErrInvalidImage       = errors.New("Invalid Image")
+	ErrUnsupportedFormat  = errors.New("Unsupported Image Format")
+	ErrDimensionsTooLarge = errors.New("Image Dimensions Too Large")
+)
+
+// OriginalSize is the key of the uploaded image in URLs
+const OriginalSize = "original"
+
+// Policy is what uploads are checked against and which thumbnails are made
+// of them
+type Policy struct {
+	// MaxBytes is the largest upload accepted
+	MaxBytes    int64
+	MaxWidth    int
+	MaxHeight   int
+	Sizes       []int
+	JPEGQuality int
+	// AnimatedGIF keeps every frame of animated GIFs, otherwise only the
+	// first one is stored
+	AnimatedGIF bool
+	// MaxFrames is the most frames an animated GIF may have
+	MaxFrames int
+	// BaseURL is prepended to the file names to make the image URLs
+	BaseURL string
+}
+
+var policy = DefaultPolicy()
+
+func SetPolicy(p *Policy) {
+	policy = p
+}
+
+func DefaultPolicy() *Policy {
+	return &Policy{
+		MaxBytes:    10 << 20,
+		MaxWidth:    4096,
+		MaxHeight:   4096,
+		Sizes:       []int{64, 128, 512},
+		JPEGQuality: 90,
+		AnimatedGIF: true,
+		MaxFrames:   200,
+		BaseURL:     "/images/",
+	}
+}
+
+// PolicyFromEnv starts from the default policy and applies the
+// PROFILE_IMAGE_MAX_BYTES, PROFILE_IMAGE_MAX_WIDTH, PROFILE_IMAGE_MAX_HEIGHT,
+// PROFILE_IMAGE_SIZES, PROFILE_IMAGE_JPEG_QUALITY, PROFILE_IMAGE_GIF_ANIMATED,
+// PROFILE_IMAGE_MAX_FRAMES and IMAGE_BASE_URL settings
+func PolicyFromEnv() (*Policy, error) {
+	p := DefaultPolicy()
+	if n, err := strconv.ParseInt(os.Getenv("PROFILE_IMAGE_MAX_BYTES"), 10, 64); err == nil && n > 0 {
+		p.MaxBytes = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("PROFILE_IMAGE_MAX_WIDTH")); err == nil && n > 0 {
+		p.MaxWidth = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("PROFILE_IMAGE_MAX_HEIGHT")); err == nil && n > 0 {
+		p.MaxHeight = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("PROFILE_IMAGE_JPEG_QUALITY")); err == nil {
+		if n < 1 || n > 100 {
+			return nil, fmt.Errorf("jpeg quality must be between 1 and 100")
+		}
+		p.JPEGQuality = n
+	}
+	if animated := os.Getenv("PROFILE_IMAGE_GIF_ANIMATED"); animated != "" {
+		p.AnimatedGIF = animated == "true"
+	}
+	if n, err := strconv.Atoi(os.Getenv("PROFILE_IMAGE_MAX_FRAMES")); err == nil && n > 0 {
+		p.MaxFrames = n
+	}
+	if sizes := os.Getenv("PROFILE_IMAGE_SIZES"); sizes != "" {
+		p.Sizes = nil
+		for _, s := range strings.Split(sizes, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid thumbnail size %q", s)
+			}
+			p.Sizes = append(p.Sizes, n)
+		}
+		sort.Ints(p.Sizes)
+	}
+	if base := os.Getenv("IMAGE_BASE_URL"); base != "" {
+		p.BaseURL = base
+	}
+	return p, nil
+}
+
+// Set is an upload re-encoded as a normalized original plus one square
+// thumbnail per size of the policy
+type Set struct {
+	Extension  string
+	Original   []byte
+	Thumbnails map[int][]byte
+}
+
+// Process decodes an upload with the current policy
+func Process(data []byte) (*Set, error) {
+	return policy.Process(data)
+}
+
+// ProcessReader decodes an upload read from r with the current policy
+func ProcessReader(r io.ReadSeeker) (*Set, error) {
+	return policy.ProcessReader(r)
+}
+
+func (p *Policy) Process(data []byte) (*Set, error) {
+	return p.ProcessReader(bytes.NewReader(data))
+}
+
+// ProcessReader decodes a JPEG, PNG, GIF or WebP upload and checks its
+// dimensions before the pixels are decoded, so a small file claiming a huge
+// image is turned down cheaply. Every image is re-encoded, which drops EXIF
+// and other metadata and anything appended to the image, and JPEGs are
+// turned upright by their EXIF orientation first.
+func (p *Policy) ProcessReader(r io.ReadSeeker) (*Set, error) {
+	_, err := r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	config, format, err := image.DecodeConfig(r)
+	if err == image.ErrFormat {
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if !decodedFormats[format] {
+		return nil, ErrUnsupportedFormat
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, ErrInvalidImage
+	}
+	orientation := 1
+	if format == "jpeg" {
+		_, err = r.Seek(0, io.SeekStart)
+		if err != nil {
+			return nil, err
+		}
+		orientation = exifOrientation(r)
+	}
+	width, height := config.Width, config.Height
+	if swapsSides(orientation) {
+		width, height = height, width
+	}
+	if width > p.MaxWidth || height > p.MaxHeight {
+		return nil, ErrDimensionsTooLarge
+	}
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	if format == "gif" && p.AnimatedGIF {
+		frames, err := gifFrames(r)
+		if err != nil {
+			return nil, ErrInvalidImage
+		}
+		_, err = r.Seek(0, io.SeekStart)
+		if err != nil {
+			return nil, err
+		}
+		if frames > 1 {
+			return p.processAnimated(r, config, frames)
+		}
+	}
+	// A GIF DECODES TO ITS FIRST FRAME
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	img = orient(img, orientation)
+	// PHOTOS STAY JPEG, THE REST IS STORED AS PNG TO KEEP TRANSPARENCY
+	output := "png"
+	if format == "jpeg" || format == "webp" && opaque(img) {
+		output = "jpeg"
+	}
+	set := &Set{Extension: ".png", Thumbnails: map[int][]byte{}}
+	if output == "jpeg" {
+		set.Extension = ".jpg"
+	}
+	set.Original, err = p.encode(img, output)
+	if err != nil {
+		return nil, err
+	}
+	for _, size := range p.Sizes {
+		set.Thumbnails[size], err = p.encode(Thumbnail(img, size), output)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+// opaque tells whether img has no transparent pixels, as far as its type can
+// tell cheaply
+func opaque(img image.Image) bool {
+	o, ok := img.(interface{ Opaque() bool })
+	return ok && o.Opaque()
+}
+
+func (p *Policy) encode(img image.Image, format string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	var err error
+	if format == "jpeg" {
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: p.JPEGQuality})
+	} else {
+		err = png.Encode(buf, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Thumbnail crops the center square of img and scales it to size x size.
+// Every thumbnail pixel is the average of the source pixels it covers, which
+// keeps downscaled images smooth.
+func Thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	// PREMULTIPLIED, SO TRANSPARENT PIXELS DON'T DARKEN THE EDGES
+	src := image.NewRGBA(image.Rect(0, 0, side, side))
+	offset := image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2)
+	draw.Draw(src, src.Bounds(), img, offset, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0, y1 := span(y, size, side)
+		for x := 0; x < size; x++ {
+			x0, x1 := span(x, size, side)
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					r += uint32(row[sx*4])
+					g += uint32(row[sx*4+1])
+					bl += uint32(row[sx*4+2])
+					a += uint32(row[sx*4+3])
+					n++
+				}
+			}
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(bl / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// span is the range of source pixels covered by pixel i of n, at least one
+// pixel wide when upscaling
+func span(i, n, side int) (int, int) {
+	start := i * side / n
+	end := (i + 1) * side / n
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
+
+// HasSize tells whether thumbnails of size are made
+func HasSize(size int) bool {
+	for _, s := range policy.Sizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+// ThumbnailName is the file name of the thumbnail of size for the image
+// stored as name
+func ThumbnailName(name string, size int) string {
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "_" + strconv.Itoa(size) + ext
+}
+
+var thumbnailSuffix = regexp.MustCompile(`_[0-9]+(\.[a-z0-9]+)$`)
+
+// OriginalName is the name of the image a thumbnail name was made from, of
+// any size ever configured, or name itself when it is no thumbnail
+func OriginalName(name string) string {
+	return thumbnailSuffix.ReplaceAllString(name, "$1")
+}
+
+// URLs maps the original and every thumbnail size to its URL, nil when there
+// is no image
+func URLs(name string) map[string]string {
+	if name == "" {
+		return nil
+	}
+	urls := map[string]string{OriginalSize: policy.BaseURL + name}
+	for _, size := range policy.Sizes {
+		urls[strconv.Itoa(size)] = policy.BaseURL + ThumbnailName(name, size)
+	}
+	return urls
+}
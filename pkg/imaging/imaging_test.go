@@ -0,0 +1,166 @@
+package imaging_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/famkampm/nentrytask/pkg/imaging"
+	"github.com/stretchr/testify/assert"
+)
+
+func testImage(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 200, 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	buf := &bytes.Buffer{}
+	assert.NoError(t, png.Encode(buf, img))
+	return buf.Bytes()
+}
+
+func TestProcessPNG(t *testing.T) {
+	p := imaging.DefaultPolicy()
+	p.Sizes = []int{16, 64}
+	// SOMETHING SMUGGLED AFTER THE IMAGE DATA DOES NOT SURVIVE
+	data := append(encodePNG(t, testImage(300, 200)), []byte("<script>")...)
+	set, err := p.Process(data)
+	assert.NoError(t, err)
+	assert.Equal(t, ".png", set.Extension)
+	assert.False(t, bytes.Contains(set.Original, []byte("<script>")))
+
+	original, err := png.Decode(bytes.NewReader(set.Original))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 300, 200), original.Bounds())
+	assert.Len(t, set.Thumbnails, 2)
+	for _, size := range p.Sizes {
+		thumbnail, err := png.Decode(bytes.NewReader(set.Thumbnails[size]))
+		assert.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, size, size), thumbnail.Bounds())
+	}
+}
+
+func TestProcessJPEG(t *testing.T) {
+	buf := &bytes.Buffer{}
+	assert.NoError(t, jpeg.Encode(buf, testImage(100, 400), nil))
+	set, err := imaging.DefaultPolicy().Process(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, ".jpg", set.Extension)
+	// SMALL IMAGES ARE SCALED UP TO EVERY SIZE
+	thumbnail, err := jpeg.Decode(bytes.NewReader(set.Thumbnails[512]))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 512, 512), thumbnail.Bounds())
+}
+
+func TestProcessRejects(t *testing.T) {
+	p := imaging.DefaultPolicy()
+	p.MaxWidth = 100
+	p.MaxHeight = 50
+
+	_, err := p.Process(encodePNG(t, testImage(101, 10)))
+	assert.Equal(t, imaging.ErrDimensionsTooLarge, err)
+	_, err = p.Process(encodePNG(t, testImage(10, 51)))
+	assert.Equal(t, imaging.ErrDimensionsTooLarge, err)
+
+	// TIFF HAS NO DECODER
+	_, err = p.Process([]byte("II*\x00\x08\x00\x00\x00\x00\x00"))
+	assert.Equal(t, imaging.ErrUnsupportedFormat, err)
+	_, err = p.Process([]byte("not an image at all"))
+	assert.Equal(t, imaging.ErrUnsupportedFormat, err)
+
+	truncated := encodePNG(t, testImage(20, 20))
+	_, err = p.Process(truncated[:60])
+	assert.Equal(t, imaging.ErrInvalidImage, err)
+}
+
+func TestThumbnailAverages(t *testing.T) {
+	// LEFT HALF BLACK, RIGHT HALF WHITE, INSIDE A WIDER TRANSPARENT BORDER
+	img := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	for y := 0; y < 4; y++ {
+		for x := 2; x < 6; x++ {
+			c := color.RGBA{0, 0, 0, 255}
+			if x >= 4 {
+				c = color.RGBA{255, 255, 255, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	thumbnail := imaging.Thumbnail(img, 1)
+	assert.Equal(t, color.RGBA{127, 127, 127, 255}, thumbnail.At(0, 0))
+	thumbnail = imaging.Thumbnail(img, 2)
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, thumbnail.At(0, 1))
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, thumbnail.At(1, 1))
+}
+
+func TestThumbnailNameAndURLs(t *testing.T) {
+	assert.Equal(t, "abc_64.jpg", imaging.ThumbnailName("abc.jpg", 64))
+	assert.Nil(t, imaging.URLs(""))
+
+	p := imaging.DefaultPolicy()
+	p.Sizes = []int{64}
+	p.BaseURL = "https://cdn.local/images/"
+	imaging.SetPolicy(p)
+	defer imaging.SetPolicy(imaging.DefaultPolicy())
+	assert.Equal(t, map[string]string{
+		"original": "https://cdn.local/images/abc.png",
+		"64":       "https://cdn.local/images/abc_64.png",
+	}, imaging.URLs("abc.png"))
+}
+
+func TestPolicyFromEnv(t *testing.T) {
+	os.Setenv("PROFILE_IMAGE_SIZES", "512, 64,128")
+	os.Setenv("PROFILE_IMAGE_MAX_WIDTH", "2048")
+	os.Setenv("PROFILE_IMAGE_MAX_BYTES", "1024")
+	defer os.Unsetenv("PROFILE_IMAGE_SIZES")
+	defer os.Unsetenv("PROFILE_IMAGE_MAX_WIDTH")
+	defer os.Unsetenv("PROFILE_IMAGE_MAX_BYTES")
+	p, err := imaging.PolicyFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, []int{64, 128, 512}, p.Sizes)
+	assert.Equal(t, 2048, p.MaxWidth)
+	assert.Equal(t, 4096, p.MaxHeight)
+	assert.Equal(t, int64(1024), p.MaxBytes)
+
+	os.Setenv("PROFILE_IMAGE_SIZES", "64,big")
+	_, err = imaging.PolicyFromEnv()
+	assert.NotNil(t, err)
+}
+
+func TestOriginalName(t *testing.T) {
+	assert.Equal(t, "abc.jpg", imaging.OriginalName("abc.jpg"))
+	assert.Equal(t, "abc.jpg", imaging.OriginalName(imaging.ThumbnailName("abc.jpg", 64)))
+	// SIZES NO LONGER CONFIGURED TOO
+	assert.Equal(t, "abc.png", imaging.OriginalName("abc_1000.png"))
+	assert.Equal(t, "abc_x.png", imaging.OriginalName("abc_x.png"))
+}
+
+func TestProcessWebP(t *testing.T) {
+	opaque, err := ioutil.ReadFile("testdata/opaque.webp")
+	assert.NoError(t, err)
+	set, err := imaging.Process(opaque)
+	assert.NoError(t, err)
+	assert.Equal(t, ".jpg", set.Extension)
+	original, err := jpeg.Decode(bytes.NewReader(set.Original))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 30, 20), original.Bounds())
+
+	// TRANSLUCENT PIXELS KEEP THEIR ALPHA IN A PNG
+	alpha, err := ioutil.ReadFile("testdata/alpha.webp")
+	assert.NoError(t, err)
+	set, err = imaging.Process(alpha)
+	assert.NoError(t, err)
+	assert.Equal(t, ".png", set.Extension)
+	_, err = png.Decode(bytes.NewReader(set.Thumbnails[64]))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,49 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"mime"
+
+	"github.com/famkampm/nentrytask/pkg/blobstore"
+)
+
+// Save puts the thumbnails and then the original of set in store under name.
+// The thumbnails go first so the original is never there without them. On
+// failure whatever was put is left, the image may be shared and is only
+// removed by whoever holds the last reference.
+func Save(ctx context.Context, store blobstore.BlobStore, name string, set *Set) error {
+	contentType := mime.TypeByExtension(set.Extension)
+	for _, size := range policy.Sizes {
+		data, ok := set.Thumbnails[size]
+		if !ok {
+			continue
+		}
+		err := store.Put(ctx, ThumbnailName(name, size), bytes.NewReader(data), int64(len(data)), contentType)
+		if err != nil {
+			return err
+		}
+	}
+	return store.Put(ctx, name, bytes.NewReader(set.Original), int64(len(set.Original)), contentType)
+}
+
+// Remove deletes the image stored as name and then its thumbnails, the
+// reverse of Save. Thumbnails of sizes no longer configured are left behind.
+func Remove(ctx context.Context, store blobstore.BlobStore, name string) error {
+	if name == "" {
+		return nil
+	}
+	err := store.Delete(ctx, name)
+	if err != nil {
+		log.Println("remove image err", err.Error())
+		return err
+	}
+	for _, size := range policy.Sizes {
+		err = store.Delete(ctx, ThumbnailName(name, size))
+		if err != nil {
+			log.Println("remove thumbnail err", err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package imaging
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+)
+
+var (
+	ErrUploadTooLarge  = errors.New("Image Upload Too Large")
+	ErrMalformedUpload = errors.New("Malformed Image Upload")
+)
+
+// multipartOverhead is allowed on top of MaxBytes for the part headers,
+// boundaries and small fields sent along with the image
+const multipartOverhead = 64 << 10
+
+// sniffLen is how many bytes http.DetectContentType looks at
+const sniffLen = 512
+
+// sniffedFormats are the content types uploads may start like
+var sniffedFormats = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Upload is an uploaded image spooled to a temporary file
+type Upload struct {
+	File        *os.File
+	ContentType string
+	Size        int64
+}
+
+// Close closes and removes the temporary file
+func (u *Upload) Close() error {
+	u.File.Close()
+	return os.Remove(u.File.Name())
+}
+
+// Receive streams the upload in field of the multipart request r with the
+// current policy
+func Receive(w http.ResponseWriter, r *http.Request, field string) (*Upload, error) {
+	return policy.Receive(w, r, field)
+}
+
+// Receive streams the file in field of the multipart request r to a
+// temporary file, so no upload is held in memory. Its content type is
+// sniffed from the first bytes before anything is written. The caller closes
+// the upload.
+func (p *Policy) Receive(w http.ResponseWriter, r *http.Request, field string) (*Upload, error) {
+	body := &countingReader{r: http.MaxBytesReader(w, r.Body, p.MaxBytes+multipartOverhead)}
+	limit := p.MaxBytes + multipartOverhead
+	r.Body = body
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, ErrMalformedUpload
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, http.ErrMissingFile
+		}
+		if err != nil {
+			return nil, body.err(limit, ErrMalformedUpload)
+		}
+		if part.FormName() != field {
+			_, err = io.Copy(ioutil.Discard, part)
+			if err != nil {
+				return nil, body.err(limit, ErrMalformedUpload)
+			}
+			continue
+		}
+		upload, err := p.spool(part)
+		if err != nil {
+			return nil, body.err(limit, err)
+		}
+		return upload, nil
+	}
+}
+
+func (p *Policy) spool(part *multipart.Part) (*Upload, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, ErrMalformedUpload
+	}
+	head = head[:n]
+	if n == 0 {
+		return nil, http.ErrMissingFile
+	}
+	contentType := http.DetectContentType(head)
+	if !sniffedFormats[contentType] {
+		return nil, ErrUnsupportedFormat
+	}
+	file, err := ioutil.TempFile("", "upload-")
+	if err != nil {
+		return nil, err
+	}
+	upload := &Upload{File: file, ContentType: contentType}
+	_, err = file.Write(head)
+	if err == nil {
+		// ONE BYTE MORE THAN ALLOWED TELLS A FILE TOO LARGE
+		upload.Size, err = io.Copy(file, io.LimitReader(part, p.MaxBytes-int64(n)+1))
+		upload.Size += int64(n)
+	}
+	if err == nil && upload.Size > p.MaxBytes {
+		err = ErrUploadTooLarge
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		upload.Close()
+		return nil, err
+	}
+	return upload, nil
+}
+
+// countingReader tells whether a read error came from http.MaxBytesReader,
+// whose error has no type of its own
+type countingReader struct {
+	r io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) Close() error {
+	return c.r.Close()
+}
+
+// err is ErrUploadTooLarge once the whole limit was read, fallback for the
+// read errors of a malformed body
+func (c *countingReader) err(limit int64, err error) error {
+	if c.n >= limit {
+		return ErrUploadTooLarge
+	}
+	return err
+}
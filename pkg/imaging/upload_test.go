@@ -0,0 +1,107 @@
+package imaging_test
+
+import (
+	"bytes"
+	"image"
+	"io/ioutil"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/famkampm/nentrytask/pkg/imaging"
+	"github.com/stretchr/testify/assert"
+)
+
+type formPart struct {
+	field, file string
+	data        []byte
+}
+
+func uploadRequest(t *testing.T, parts ...formPart) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, part := range parts {
+		var w interface{ Write([]byte) (int, error) }
+		var err error
+		if part.file == "" {
+			w, err = writer.CreateFormField(part.field)
+		} else {
+			w, err = writer.CreateFormFile(part.field, part.file)
+		}
+		assert.NoError(t, err)
+		w.Write(part.data)
+	}
+	assert.NoError(t, writer.Close())
+	req := httptest.NewRequest("POST", "/profile/image/1", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestReceive(t *testing.T) {
+	data := encodePNG(t, testImage(40, 20))
+	req := uploadRequest(t, formPart{field: "name", data: []byte("me")}, formPart{field: "image", file: "a.png", data: data})
+
+	upload, err := imaging.Receive(httptest.NewRecorder(), req, "image")
+	assert.NoError(t, err)
+	defer upload.Close()
+	assert.Equal(t, "image/png", upload.ContentType)
+	assert.Equal(t, int64(len(data)), upload.Size)
+	spooled, err := ioutil.ReadAll(upload.File)
+	assert.NoError(t, err)
+	assert.Equal(t, data, spooled)
+
+	set, err := imaging.ProcessReader(upload.File)
+	assert.NoError(t, err)
+	assert.Equal(t, ".png", set.Extension)
+
+	gifUpload, err := imaging.Receive(httptest.NewRecorder(), uploadRequest(t, formPart{field: "image", file: "a.gif", data: animatedGIF(t, 3)}), "image")
+	assert.NoError(t, err)
+	assert.Equal(t, "image/gif", gifUpload.ContentType)
+	gifUpload.Close()
+
+	name := upload.File.Name()
+	assert.NoError(t, upload.Close())
+	_, err = os.Stat(name)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestReceiveRejects(t *testing.T) {
+	p := imaging.DefaultPolicy()
+	p.MaxBytes = 1024
+	// NOISE DOES NOT COMPRESS
+	noise := image.NewGray(image.Rect(0, 0, 100, 100))
+	rand.New(rand.NewSource(1)).Read(noise.Pix)
+	big := encodePNG(t, noise)
+	assert.True(t, len(big) > 1024)
+
+	for _, c := range []struct {
+		name string
+		req  *http.Request
+		err  error
+	}{
+		{"no file", uploadRequest(t, formPart{field: "name", data: []byte("me")}), http.ErrMissingFile},
+		{"empty file", uploadRequest(t, formPart{field: "image", file: "a.png"}), http.ErrMissingFile},
+		{"text", uploadRequest(t, formPart{field: "image", file: "a.png", data: []byte("hello world")}), imaging.ErrUnsupportedFormat},
+		{"html", uploadRequest(t, formPart{field: "image", file: "a.png", data: []byte("<html><script>alert(1)</script>")}), imaging.ErrUnsupportedFormat},
+		{"too large", uploadRequest(t, formPart{field: "image", file: "a.png", data: big}), imaging.ErrUploadTooLarge},
+		// THE LIMIT HOLDS FOR THE WHOLE BODY, NOT ONLY THE IMAGE
+		{"large field", uploadRequest(t, formPart{field: "name", data: bytes.Repeat([]byte("a"), 100<<10)}, formPart{field: "image", file: "a.png", data: big[:100]}), imaging.ErrUploadTooLarge},
+	} {
+		_, err := p.Receive(httptest.NewRecorder(), c.req, "image")
+		assert.Equal(t, c.err, err, c.name)
+	}
+
+	req := httptest.NewRequest("POST", "/profile/image/1", strings.NewReader("image=abc"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err := p.Receive(httptest.NewRecorder(), req, "image")
+	assert.Equal(t, imaging.ErrMalformedUpload, err)
+
+	req = httptest.NewRequest("POST", "/profile/image/1", strings.NewReader("--x\r\nbroken"))
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	_, err = p.Receive(httptest.NewRecorder(), req, "image")
+	assert.Equal(t, imaging.ErrMalformedUpload, err)
+}
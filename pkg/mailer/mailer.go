@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"strconv"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional mail such as password reset links
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NewMailerFromEnv returns an SMTP mailer when MAILER=smtp. Otherwise mail is
+// written to MAIL_LOG_PATH, or to stdout when that is empty.
+func NewMailerFromEnv() (Mailer, error) {
+	if os.Getenv("MAILER") == "smtp" {
+		port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil {
+			return nil, err
+		}
+		return NewSMTPMailer(os.Getenv("SMTP_HOST"), port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), os.Getenv("MAIL_FROM")), nil
+	}
+	if path := os.Getenv("MAIL_LOG_PATH"); path != "" {
+		return NewFileMailer(path)
+	}
+	return NewLogMailer(os.Stdout), nil
+}
@@ -1,8 +1,12 @@
 package middlewares
 
 import (
+	"context"
+	"crypto/subtle"
 	"errors"
+	"log"
 	"net/http"
+	"os"
 	"strconv"
 
 	"github.com/famkampm/nentrytask/pkg/auth"
@@ -10,11 +14,157 @@ import (
 	"github.com/julienschmidt/httprouter"
 )
 
-func SetMiddlewareAuthentication(next httprouter.Handle) httprouter.Handle {
+// OverrideRecorder records requests that were only allowed because of a
+// permission, i.e. staff acting on an account that isn't theirs. claims is nil
+// when the operator ADMIN_TOKEN was used.
+type OverrideRecorder interface {
+	RecordOverride(r *http.Request, claims *auth.Claims, targetUserID int64, permission auth.Permission, status int)
+}
+
+// APIKeyAuthenticator resolves a personal API key to the claims of its owner
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (*auth.Claims, error)
+}
+
+// SessionTracker reports whether the session of a token is still live and
+// records it as seen
+type SessionTracker interface {
+	Touch(ctx context.Context, claims *auth.Claims) (bool, error)
+}
+
+// StatusChecker tells whether the account of a user may be used. reason is
+// empty when it may, and otherwise shown to the caller, e.g. "Account Banned".
+type StatusChecker interface {
+	BlockedReason(ctx context.Context, userID int64) (string, error)
+}
+
+// blockedError is answered with 403 rather than 401, logging in again won't help
+type blockedError struct {
+	reason string
+}
+
+func (e *blockedError) Error() string {
+	return e.reason
+}
+
+type Middleware struct {
+	Revoker  auth.Revoker
+	Recorder OverrideRecorder
+	APIKeys  APIKeyAuthenticator
+	Sessions SessionTracker
+	Statuses StatusChecker
+}
+
+func InitMiddleware(revoker auth.Revoker, recorder OverrideRecorder, apiKeys APIKeyAuthenticator, sessions SessionTracker, statuses StatusChecker) *Middleware {
+	return &Middleware{
+		Revoker:  revoker,
+		Recorder: recorder,
+		APIKeys:  apiKeys,
+		Sessions: sessions,
+		Statuses: statuses,
+	}
+}
+
+// statusRecorder keeps the status code so overrides are recorded with their result
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// serveRecorded runs next and records it as an override once it answered
+func (m *Middleware) serveRecorded(next httprouter.Handle, w http.ResponseWriter, r *http.Request, ps httprouter.Params, claims *auth.Claims, targetUserID int64, permission auth.Permission) {
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	if claims != nil {
+		r = r.WithContext(auth.NewContext(r.Context(), claims))
+	}
+	next(recorder, r, ps)
+	m.Recorder.RecordOverride(r, claims, targetUserID, permission, recorder.status)
+}
+
+// authenticate accepts a bearer access token whose token has not been revoked
+// and whose session is live, or a personal API key. Either way the account
+// status has to allow it.
+func (m *Middleware) authenticate(r *http.Request) (*auth.Claims, error) {
+	claims, err := m.authenticateCredentials(r)
+	if err != nil {
+		return nil, err
+	}
+	reason, err := m.Statuses.BlockedReason(r.Context(), claims.UserID)
+	if err != nil {
+		log.Println("middleware check account status err:", err.Error())
+		return nil, err
+	}
+	if reason != "" {
+		return nil, &blockedError{reason: reason}
+	}
+	return claims, nil
+}
+
+func (m *Middleware) authenticateCredentials(r *http.Request) (*auth.Claims, error) {
+	token := auth.ExtractToken(r)
+	if auth.IsAPIKey(token) {
+		return m.APIKeys.Authenticate(r.Context(), token)
+	}
+	claims, err := auth.ParseToken(token, auth.AccessTokenType)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := m.Revoker.IsRevoked(r.Context(), claims)
+	if err != nil {
+		log.Println("middleware check revoked token err:", err.Error())
+		return nil, err
+	}
+	if revoked {
+		return nil, auth.ErrInvalidToken
+	}
+	live, err := m.Sessions.Touch(r.Context(), claims)
+	if err != nil {
+		log.Println("middleware touch session err:", err.Error())
+		return nil, err
+	}
+	if !live {
+		return nil, auth.ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// authenticateSession is authenticate without API keys. Routes managing the
+// account itself need someone who logged in.
+func (m *Middleware) authenticateSession(r *http.Request) (*auth.Claims, error) {
+	claims, err := m.authenticate(r)
+	if err != nil {
+		return nil, err
+	}
+	if claims.IsAPIKey() {
+		return nil, auth.ErrWrongTokenType
+	}
+	return claims, nil
+}
+
+// SetMiddlewareToken only requires a valid access token
+func (m *Middleware) SetMiddlewareToken(next httprouter.Handle) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-		err := auth.TokenValidFromID(r)
+		claims, err := m.authenticateSession(r)
 		if err != nil {
-			responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+			unauthorized(w, err)
+			return
+		}
+		next(w, r.WithContext(auth.NewContext(r.Context(), claims)), ps)
+	}
+}
+
+// SetMiddlewareAuthentication requires a valid access token belonging to the
+// user in the :id path parameter
+func (m *Middleware) SetMiddlewareAuthentication(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		claims, err := m.authenticateSession(r)
+		if err != nil {
+			unauthorized(w, err)
 			return
 		}
 		user_id, err := strconv.Atoi(ps.ByName("id"))
@@ -22,15 +172,91 @@ func SetMiddlewareAuthentication(next httprouter.Handle) httprouter.Handle {
 			responses.ERROR(w, http.StatusInternalServerError, errors.New("Unauthorized"))
 			return
 		}
-		extracted_user_id, err := auth.ExtractTokenID(r)
-		if err != nil || extracted_user_id != int64(user_id) {
+		if claims.UserID != int64(user_id) {
 			responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
 			return
 		}
-		next(w, r, ps)
+		next(w, r.WithContext(auth.NewContext(r.Context(), claims)), ps)
+	}
+}
+
+// SetMiddlewareSelfOrPermission lets the owner of the :id path parameter
+// through, and anyone else whose role has permission. The latter is recorded.
+func (m *Middleware) SetMiddlewareSelfOrPermission(permission auth.Permission) func(httprouter.Handle) httprouter.Handle {
+	return func(next httprouter.Handle) httprouter.Handle {
+		return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+			claims, err := m.authenticate(r)
+			if err != nil {
+				unauthorized(w, err)
+				return
+			}
+			user_id, err := strconv.ParseInt(ps.ByName("id"), 10, 64)
+			if err != nil {
+				responses.ERROR(w, http.StatusBadRequest, errors.New(http.StatusText(http.StatusBadRequest)))
+				return
+			}
+			if !claims.HasScope(permission) {
+				responses.ERROR(w, http.StatusForbidden, errors.New("Insufficient Scope"))
+				return
+			}
+			if claims.UserID == user_id {
+				next(w, r.WithContext(auth.NewContext(r.Context(), claims)), ps)
+				return
+			}
+			if !auth.HasPermission(claims.Role, permission) {
+				responses.ERROR(w, http.StatusForbidden, errors.New(http.StatusText(http.StatusForbidden)))
+				return
+			}
+			m.serveRecorded(next, w, r, ps, claims, user_id, permission)
+		}
 	}
 }
 
+// SetMiddlewarePermission guards staff endpoints. The caller needs a token whose
+// role has permission, or the operator ADMIN_TOKEN. Every call is recorded, with
+// the :id path parameter as target when the route has one.
+func (m *Middleware) SetMiddlewarePermission(permission auth.Permission) func(httprouter.Handle) httprouter.Handle {
+	return func(next httprouter.Handle) httprouter.Handle {
+		return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+			target, _ := strconv.ParseInt(ps.ByName("id"), 10, 64)
+			if r.Header.Get("X-Admin-Token") != "" {
+				if !validAdminToken(r) {
+					responses.ERROR(w, http.StatusForbidden, errors.New(http.StatusText(http.StatusForbidden)))
+					return
+				}
+				m.serveRecorded(next, w, r, ps, nil, target, permission)
+				return
+			}
+			claims, err := m.authenticateSession(r)
+			if err != nil {
+				unauthorized(w, err)
+				return
+			}
+			if !auth.HasPermission(claims.Role, permission) {
+				responses.ERROR(w, http.StatusForbidden, errors.New(http.StatusText(http.StatusForbidden)))
+				return
+			}
+			m.serveRecorded(next, w, r, ps, claims, target, permission)
+		}
+	}
+}
+
+// unauthorized answers a failed authentication. Only blocked accounts learn
+// why, anything else is a plain 401.
+func unauthorized(w http.ResponseWriter, err error) {
+	if blocked, ok := err.(*blockedError); ok {
+		responses.ERROR(w, http.StatusForbidden, errors.New(blocked.reason))
+		return
+	}
+	responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+}
+
+func validAdminToken(r *http.Request) bool {
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	given := r.Header.Get("X-Admin-Token")
+	return adminToken != "" && subtle.ConstantTimeCompare([]byte(adminToken), []byte(given)) == 1
+}
+
 func MiddlewareTestHttpRouter(next httprouter.Handle) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		next(w, r, ps)
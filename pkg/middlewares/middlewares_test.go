@@ -0,0 +1,184 @@
+package middlewares_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/famkampm/nentrytask/pkg/auth"
+	_authMocks "github.com/famkampm/nentrytask/pkg/auth/mocks"
+	"github.com/famkampm/nentrytask/pkg/middlewares"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type override struct {
+	claims     *auth.Claims
+	target     int64
+	permission auth.Permission
+	status     int
+}
+
+type fakeRecorder struct {
+	overrides []override
+}
+
+func (f *fakeRecorder) RecordOverride(r *http.Request, claims *auth.Claims, targetUserID int64, permission auth.Permission, status int) {
+	f.overrides = append(f.overrides, override{claims, targetUserID, permission, status})
+}
+
+// fakeAPIKeys knows one key of user 1 limited to reading profiles
+type fakeAPIKeys struct{}
+
+func (f *fakeAPIKeys) Authenticate(ctx context.Context, key string) (*auth.Claims, error) {
+	if key != "nt_abc_secret" {
+		return nil, errors.New("Invalid API Key")
+	}
+	return &auth.Claims{UserID: 1, APIKeyID: 1, Scopes: []string{string(auth.PermissionProfileRead)}}, nil
+}
+
+// fakeSessions treats every session as live unless it belongs to ended
+type fakeSessions struct {
+	ended int64
+}
+
+func (f *fakeSessions) Touch(ctx context.Context, claims *auth.Claims) (bool, error) {
+	return claims.UserID != f.ended, nil
+}
+
+// fakeStatuses blocks the account of banned
+type fakeStatuses struct {
+	banned int64
+}
+
+func (f *fakeStatuses) BlockedReason(ctx context.Context, userID int64) (string, error) {
+	if userID == f.banned {
+		return "Account Banned", nil
+	}
+	return "", nil
+}
+
+func newRouter(t *testing.T, recorder *fakeRecorder) *httprouter.Router {
+	return newRouterWith(t, recorder, &fakeSessions{}, &fakeStatuses{})
+}
+
+func newRouterEnded(t *testing.T, recorder *fakeRecorder, ended int64) *httprouter.Router {
+	return newRouterWith(t, recorder, &fakeSessions{ended: ended}, &fakeStatuses{})
+}
+
+func newRouterWith(t *testing.T, recorder *fakeRecorder, sessions *fakeSessions, statuses *fakeStatuses) *httprouter.Router {
+	revoker := new(_authMocks.Revoker)
+	revoker.On("IsRevoked", mock.Anything, mock.Anything).Return(false, nil)
+	mw := middlewares.InitMiddleware(revoker, recorder, &fakeAPIKeys{}, sessions, statuses)
+	ok := func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	router := httprouter.New()
+	router.GET("/profile/:id", mw.SetMiddlewareSelfOrPermission(auth.PermissionProfileRead)(ok))
+	router.PUT("/profile/nickname/:id", mw.SetMiddlewareSelfOrPermission(auth.PermissionProfileWrite)(ok))
+	router.PUT("/profile/password/:id", mw.SetMiddlewareAuthentication(ok))
+	router.PUT("/admin/role/:id", mw.SetMiddlewarePermission(auth.PermissionRolesWrite)(ok))
+	return router
+}
+
+func request(t *testing.T, router *httprouter.Router, method, path string, id int64, role string) int {
+	os.Setenv("API_SECRET", "secret")
+	pair, err := auth.CreateTokenPair(id, role)
+	assert.NoError(t, err)
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr.Code
+}
+
+func TestSelfOrPermissionSelf(t *testing.T) {
+	recorder := &fakeRecorder{}
+	router := newRouter(t, recorder)
+	assert.Equal(t, http.StatusNoContent, request(t, router, "GET", "/profile/1", 1, auth.RoleUser))
+	assert.Empty(t, recorder.overrides)
+}
+
+func TestSelfOrPermissionOtherUser(t *testing.T) {
+	recorder := &fakeRecorder{}
+	router := newRouter(t, recorder)
+	assert.Equal(t, http.StatusForbidden, request(t, router, "GET", "/profile/2", 1, auth.RoleUser))
+	assert.Empty(t, recorder.overrides)
+}
+
+func TestSelfOrPermissionOverrideIsRecorded(t *testing.T) {
+	recorder := &fakeRecorder{}
+	router := newRouter(t, recorder)
+	assert.Equal(t, http.StatusNoContent, request(t, router, "GET", "/profile/2", 1, auth.RoleSupport))
+	assert.Len(t, recorder.overrides, 1)
+	assert.Equal(t, int64(1), recorder.overrides[0].claims.UserID)
+	assert.Equal(t, int64(2), recorder.overrides[0].target)
+	assert.Equal(t, auth.PermissionProfileRead, recorder.overrides[0].permission)
+	assert.Equal(t, http.StatusNoContent, recorder.overrides[0].status)
+}
+
+func TestPermission(t *testing.T) {
+	recorder := &fakeRecorder{}
+	router := newRouter(t, recorder)
+	assert.Equal(t, http.StatusForbidden, request(t, router, "PUT", "/admin/role/2", 1, auth.RoleSupport))
+	assert.Equal(t, http.StatusNoContent, request(t, router, "PUT", "/admin/role/2", 1, auth.RoleAdmin))
+	assert.Len(t, recorder.overrides, 1)
+}
+
+func TestPermissionAdminToken(t *testing.T) {
+	os.Setenv("ADMIN_TOKEN", "operator")
+	defer os.Unsetenv("ADMIN_TOKEN")
+	recorder := &fakeRecorder{}
+	router := newRouter(t, recorder)
+
+	req := httptest.NewRequest("PUT", "/admin/role/2", nil)
+	req.Header.Set("X-Admin-Token", "wrong")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	req.Header.Set("X-Admin-Token", "operator")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Len(t, recorder.overrides, 1)
+	assert.Nil(t, recorder.overrides[0].claims)
+}
+
+func apiKeyRequest(router *httprouter.Router, method, path, key string) int {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+key)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr.Code
+}
+
+func TestAPIKey(t *testing.T) {
+	router := newRouter(t, &fakeRecorder{})
+	assert.Equal(t, http.StatusNoContent, apiKeyRequest(router, "GET", "/profile/1", "nt_abc_secret"))
+	assert.Equal(t, http.StatusUnauthorized, apiKeyRequest(router, "GET", "/profile/1", "nt_abc_wrong"))
+	// out of scope
+	assert.Equal(t, http.StatusForbidden, apiKeyRequest(router, "PUT", "/profile/nickname/1", "nt_abc_secret"))
+	// keys act as their owner only
+	assert.Equal(t, http.StatusForbidden, apiKeyRequest(router, "GET", "/profile/2", "nt_abc_secret"))
+	// account management needs a login
+	assert.Equal(t, http.StatusUnauthorized, apiKeyRequest(router, "PUT", "/profile/password/1", "nt_abc_secret"))
+}
+
+func TestEndedSession(t *testing.T) {
+	router := newRouterEnded(t, &fakeRecorder{}, 1)
+	assert.Equal(t, http.StatusUnauthorized, request(t, router, "GET", "/profile/1", 1, auth.RoleUser))
+	assert.Equal(t, http.StatusNoContent, request(t, router, "GET", "/profile/2", 2, auth.RoleUser))
+}
+
+func TestBlockedAccount(t *testing.T) {
+	router := newRouterWith(t, &fakeRecorder{}, &fakeSessions{}, &fakeStatuses{banned: 1})
+	assert.Equal(t, http.StatusForbidden, request(t, router, "GET", "/profile/1", 1, auth.RoleUser))
+	// api keys of the account are blocked too
+	assert.Equal(t, http.StatusForbidden, apiKeyRequest(router, "GET", "/profile/1", "nt_abc_secret"))
+	assert.Equal(t, http.StatusNoContent, request(t, router, "GET", "/profile/2", 2, auth.RoleUser))
+}
@@ -25,3 +25,15 @@ func ERROR(w http.ResponseWriter, statusCode int, err error) {
 	}
 	JSON(w, http.StatusBadRequest, nil)
 }
+
+// VALIDATION answers 422 with every invalid field so clients can show the
+// errors next to the inputs
+func VALIDATION(w http.ResponseWriter, fields interface{}) {
+	JSON(w, http.StatusUnprocessableEntity, struct {
+		Error  string      `json:"error"`
+		Fields interface{} `json:"fields"`
+	}{
+		Error:  "Validation Failed",
+		Fields: fields,
+	})
+}
@@ -0,0 +1,54 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/famkampm/nentrytask/pkg/totp"
+	"github.com/stretchr/testify/assert"
+)
+
+// secret of the RFC 6238 SHA1 test vectors
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeAtRFCVectors(t *testing.T) {
+	// the RFC lists 8 digit codes, we keep the last 6
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, expected := range vectors {
+		code, err := totp.CodeAt(rfcSecret, totp.Step(time.Unix(unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code, "time %d", unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	assert.NoError(t, err)
+	now := time.Unix(1600000000, 0)
+	previous, _ := totp.CodeAt(secret, totp.Step(now)-1)
+
+	step, ok := totp.Validate(secret, previous, now, 1)
+	assert.True(t, ok)
+	assert.Equal(t, totp.Step(now)-1, step)
+
+	_, ok = totp.Validate(secret, previous, now, 0)
+	assert.False(t, ok)
+	_, ok = totp.Validate(secret, "12345", now, 1)
+	assert.False(t, ok)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := totp.ProvisioningURI("nentrytask", "user 1", "SECRET")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/nentrytask:user%201?"))
+	assert.Contains(t, uri, "secret=SECRET")
+	assert.Contains(t, uri, "issuer=nentrytask")
+}